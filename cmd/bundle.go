@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/clicky"
+	"github.com/flanksource/clicky/task"
+	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/flanksource/deps/pkg/bundle"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/filelock"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleOutput    string
+	bundlePlatforms []string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:          "bundle [package...]",
+	Short:        "Pack the artifacts locked in deps-lock.yaml into one archive for air-gapped installs",
+	SilenceUsage: true,
+	Long: `Download every artifact locked in deps-lock.yaml for the selected platforms, verify it
+against the locked checksum, and pack the artifacts, the lock file and the registry entries
+of the bundled packages into a single archive with a manifest.
+
+Install from the archive on a machine without network access with 'deps install --from-bundle'.
+
+Examples:
+  deps bundle -o tools.tar                                # Every locked package and platform
+  deps bundle -o tools.tar.gz --platforms linux-amd64     # Gzipped, one platform only
+  deps bundle -o k8s.tar kubectl helm                     # Specific packages`,
+	RunE: runBundle,
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.Flags().StringVarP(&bundleOutput, "output", "o", "deps-bundle.tar", "Archive to write (gzipped when it ends in .gz or .tgz)")
+	bundleCmd.Flags().StringSliceVar(&bundlePlatforms, "platforms", nil, "Platforms to bundle (default: every platform in deps-lock.yaml)")
+}
+
+func runBundle(cmd *cobra.Command, args []string) error {
+	depsConfig := GetDepsConfig()
+	lockFile, err := config.LoadLockFile("")
+	if err != nil {
+		return fmt.Errorf("deps bundle requires a lock file, run 'deps lock' first: %w", err)
+	}
+
+	cacheDirToUse := cacheDir
+	if cacheDirToUse == "" {
+		cacheDirToUse = depsConfig.Settings.CacheDir
+	}
+	platforms, err := platform.ParseList(bundlePlatforms)
+	if err != nil {
+		return err
+	}
+	opts := bundle.Options{
+		Packages:    args,
+		CacheDir:    cacheDirToUse,
+		Timeout:     timeout,
+		LockTimeout: filelock.Timeout(),
+	}
+	for _, plat := range platforms {
+		opts.Platforms = append(opts.Platforms, plat.String())
+	}
+
+	var manifest *bundle.Manifest
+	task.StartTask("bundle", func(ctx flanksourceContext.Context, t *task.Task) (interface{}, error) {
+		manifest, err = bundle.Create(bundleOutput, lockFile, depsConfig, opts, t)
+		if err != nil {
+			return nil, err
+		}
+		t.Success()
+		return manifest, nil
+	})
+
+	exitCode := clicky.WaitForGlobalCompletion()
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("bundle failed with exit code %d", exitCode)
+	}
+
+	var size int64
+	for _, a := range manifest.Artifacts {
+		size += a.Size
+	}
+	fmt.Printf("✓ Bundled %d artifacts (%s) for %d platforms into %s\n",
+		len(manifest.Artifacts), utils.FormatBytes(size), len(manifest.Platforms), bundleOutput)
+	return nil
+}
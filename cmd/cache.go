@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/clicky"
+	"github.com/flanksource/deps/pkg/cache"
+	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cachePruneOlderThan  string
+	cachePruneKeepLocked bool
+	cacheImportURL       string
+)
+
+// CacheEntryInfo represents a cached download
+type CacheEntryInfo struct {
+	Package  string `json:"package" pretty:"label=Package"`
+	Version  string `json:"version" pretty:"label=Version"`
+	Platform string `json:"platform" pretty:"label=Platform"`
+	URL      string `json:"url" pretty:"label=URL"`
+	Size     string `json:"size" pretty:"label=Size"`
+	LastUsed string `json:"last_used" pretty:"label=Last Used"`
+}
+
+// CacheVerifyInfo represents the verification result of a cached download
+type CacheVerifyInfo struct {
+	Package  string `json:"package" pretty:"label=Package"`
+	Version  string `json:"version" pretty:"label=Version"`
+	Platform string `json:"platform" pretty:"label=Platform"`
+	URL      string `json:"url" pretty:"label=URL"`
+	Status   string `json:"status" pretty:"label=Status"`
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and manage the download cache",
+	Long: `Inspect and manage the download cache (settings.cache_dir, ~/.deps/cache by default).
+
+Examples:
+  deps cache ls
+  deps cache verify
+  deps cache prune --older-than 30d --keep-locked
+  deps cache import ./kubectl --url https://dl.k8s.io/release/v1.28.0/bin/linux/amd64/kubectl`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List cached downloads with the locked package they belong to",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runCacheLs,
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:          "verify",
+	Short:        "Re-hash cached downloads against deps-lock.yaml",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	Long: `Re-hash every cached download. Downloads locked in deps-lock.yaml are checked against
+the locked checksum, the others against the digest they were cached under. Corrupted
+downloads are removed from the cache.`,
+	RunE: runCacheVerify,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:          "prune",
+	Short:        "Remove cached downloads that have not been used recently",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runCachePrune,
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:          "import file --url url",
+	Short:        "Seed the cache with a file downloaded some other way",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	Long: `Add a file to the cache as the download of a URL, so installs of that URL use it
+instead of fetching it. If the URL is locked in deps-lock.yaml the file must match the
+locked checksum.`,
+	RunE: runCacheImport,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheLsCmd, cacheVerifyCmd, cachePruneCmd, cacheImportCmd)
+
+	cachePruneCmd.Flags().StringVar(&cachePruneOlderThan, "older-than", "30d", "Remove downloads not used for this long (e.g. 12h, 30d)")
+	cachePruneCmd.Flags().BoolVar(&cachePruneKeepLocked, "keep-locked", false, "Keep downloads locked in deps-lock.yaml regardless of age")
+	cacheImportCmd.Flags().StringVar(&cacheImportURL, "url", "", "URL the file was downloaded from")
+	_ = cacheImportCmd.MarkFlagRequired("url")
+}
+
+// lockedDownload identifies the lock entry a URL belongs to
+type lockedDownload struct {
+	name     string
+	version  string
+	platform string
+	checksum string
+}
+
+// lockedDownloads indexes deps-lock.yaml by URL; it is empty when there is no lock file
+func lockedDownloads() map[string]lockedDownload {
+	locked := map[string]lockedDownload{}
+	lockFile, err := config.LoadLockFile("")
+	if err != nil {
+		return locked
+	}
+	for name, entry := range lockFile.Dependencies {
+		for plat, p := range entry.Platforms {
+			locked[p.URL] = lockedDownload{name: name, version: entry.Version, platform: plat, checksum: p.Checksum}
+		}
+	}
+	return locked
+}
+
+func resolveCacheDir(depsConfig *types.DepsConfig) (string, error) {
+	dir := cacheDir
+	if dir == "" {
+		dir = depsConfig.Settings.CacheDir
+	}
+	if dir == "" {
+		return "", fmt.Errorf("the download cache is disabled, set settings.cache_dir or --cache-dir")
+	}
+	return dir, nil
+}
+
+func runCacheLs(cmd *cobra.Command, args []string) error {
+	dir, err := resolveCacheDir(GetDepsConfig())
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		cmd.Printf("The download cache %s is empty\n", dir)
+		return nil
+	}
+
+	locked := lockedDownloads()
+	var rows []CacheEntryInfo
+	var total int64
+	seen := map[string]bool{}
+	for _, e := range entries {
+		l := locked[e.URL]
+		rows = append(rows, CacheEntryInfo{
+			Package:  l.name,
+			Version:  l.version,
+			Platform: l.platform,
+			URL:      e.URL,
+			Size:     utils.FormatBytes(e.Size),
+			LastUsed: e.LastUsed.Format(time.DateTime),
+		})
+		if !seen[e.Digest] {
+			seen[e.Digest] = true
+			total += e.Size
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Package < rows[j].Package })
+
+	result, err := clicky.Format(rows)
+	if err != nil {
+		return err
+	}
+	cmd.Println(result)
+	cmd.Printf("%d downloads, %d artifacts, %s in %s\n", len(entries), len(seen), utils.FormatBytes(total), dir)
+	return nil
+}
+
+func runCacheVerify(cmd *cobra.Command, args []string) error {
+	dir, err := resolveCacheDir(GetDepsConfig())
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List(dir)
+	if err != nil {
+		return err
+	}
+
+	locked := lockedDownloads()
+	var rows []CacheVerifyInfo
+	failed := 0
+	for _, e := range entries {
+		l, isLocked := locked[e.URL]
+		row := CacheVerifyInfo{Package: l.name, Version: l.version, Platform: l.platform, URL: e.URL, Status: "ok"}
+
+		if _, ok := cache.VerifyDigest(dir, e.Digest); !ok {
+			// VerifyDigest has already removed the corrupted artifact
+			row.Status = fmt.Sprintf("corrupted, does not match %s (removed)", e.Digest)
+			failed++
+		} else if isLocked && l.checksum != "" {
+			if err := checksum.VerifyChecksum(e.BlobPath, l.checksum); err != nil {
+				row.Status = fmt.Sprintf("does not match deps-lock.yaml: %v", err)
+				failed++
+			}
+		} else if !isLocked {
+			row.Status = "ok (not locked)"
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		cmd.Printf("The download cache %s is empty\n", dir)
+		return nil
+	}
+
+	result, err := clicky.Format(rows)
+	if err != nil {
+		return err
+	}
+	cmd.Println(result)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cached downloads failed verification", failed, len(rows))
+	}
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	dir, err := resolveCacheDir(GetDepsConfig())
+	if err != nil {
+		return err
+	}
+	olderThan, err := parseAge(cachePruneOlderThan)
+	if err != nil {
+		return err
+	}
+
+	var keep func(cache.Entry) bool
+	if cachePruneKeepLocked {
+		locked := lockedDownloads()
+		keep = func(e cache.Entry) bool {
+			_, ok := locked[e.URL]
+			return ok
+		}
+	}
+
+	result, err := cache.Prune(dir, olderThan, keep)
+	if err != nil {
+		return err
+	}
+	for _, e := range result.Removed {
+		cmd.Printf("Removed %s (last used %s)\n", e.URL, e.LastUsed.Format(time.DateTime))
+	}
+	cmd.Printf("✓ Removed %d downloads, freed %s\n", len(result.Removed), utils.FormatBytes(result.Freed))
+	return nil
+}
+
+func runCacheImport(cmd *cobra.Command, args []string) error {
+	dir, err := resolveCacheDir(GetDepsConfig())
+	if err != nil {
+		return err
+	}
+	file := args[0]
+	if info, err := os.Stat(file); err != nil {
+		return err
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", file)
+	}
+
+	if l, ok := lockedDownloads()[cacheImportURL]; ok && l.checksum != "" {
+		if err := checksum.VerifyChecksum(file, l.checksum); err != nil {
+			return fmt.Errorf("%s does not match the checksum locked for %s (%s): %w", file, l.name, l.platform, err)
+		}
+	}
+
+	if err := cache.SaveToCache(dir, cacheImportURL, file); err != nil {
+		return err
+	}
+	cmd.Printf("✓ Imported %s as %s\n", file, cacheImportURL)
+	return nil
+}
+
+// parseAge parses a duration that may also be given in days, e.g. "30d"
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(strings.TrimSpace(value), "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, use e.g. 12h or 30d", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/deps/pkg/cache"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":  30 * 24 * time.Hour,
+		"1.5d": 36 * time.Hour,
+		"12h":  12 * time.Hour,
+		"0":    0,
+	}
+	for value, want := range tests {
+		got, err := parseAge(value)
+		if err != nil || got != want {
+			t.Errorf("parseAge(%q) = %s, %v; want %s", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "soon", "-1d"} {
+		if _, err := parseAge(value); err == nil {
+			t.Errorf("parseAge(%q) should fail", value)
+		}
+	}
+}
+
+// useCache points the cache commands at an empty cache, run from a directory holding lock as
+// its deps-lock.yaml
+func useCache(t *testing.T, lock string) string {
+	prevConfig, prevCacheDir := depsConfig, cacheDir
+	t.Cleanup(func() { depsConfig, cacheDir = prevConfig, prevCacheDir })
+	depsConfig = &types.DepsConfig{}
+	cacheDir = t.TempDir()
+
+	project := t.TempDir()
+	if lock != "" {
+		if err := os.WriteFile(filepath.Join(project, config.LockFile), []byte(lock), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(project)
+	return cacheDir
+}
+
+// seedCache caches content as the download of url and returns its cache entry
+func seedCache(t *testing.T, dir, url, content string) *cache.Entry {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), filepath.Base(url))
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SaveToCache(dir, url, file); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := cache.Lookup(dir, url)
+	if !ok {
+		t.Fatalf("%s was not cached", url)
+	}
+	return entry
+}
+
+func runCacheCommand(run func(*cobra.Command, []string) error, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	err := run(cmd, args)
+	return out.String(), err
+}
+
+func lockFor(url, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("dependencies:\n  kubectl:\n    version: v1.28.0\n    platforms:\n      linux-amd64:\n        url: %s\n        checksum: sha256:%s\n", url, hex.EncodeToString(sum[:]))
+}
+
+const (
+	lockedURL   = "https://dl.k8s.io/release/v1.28.0/bin/linux/amd64/kubectl"
+	unlockedURL = "https://example.com/tool.tar.gz"
+)
+
+func TestCacheLs(t *testing.T) {
+	dir := useCache(t, lockFor(lockedURL, "kubectl"))
+
+	out, err := runCacheCommand(runCacheLs)
+	if err != nil || !strings.Contains(out, "is empty") {
+		t.Fatalf("expected an empty cache, got %q, %v", out, err)
+	}
+
+	seedCache(t, dir, lockedURL, "kubectl")
+	seedCache(t, dir, unlockedURL, "tool")
+	out, err = runCacheCommand(runCacheLs)
+	if err != nil {
+		t.Fatalf("cache ls: %v", err)
+	}
+	if !strings.Contains(out, "kubectl") || !strings.Contains(out, "2 downloads, 2 artifacts") {
+		t.Errorf("expected both downloads with the locked package, got:\n%s", out)
+	}
+}
+
+func TestCacheVerifyRemovesCorruptedDownloads(t *testing.T) {
+	dir := useCache(t, lockFor(lockedURL, "kubectl"))
+	seedCache(t, dir, lockedURL, "kubectl")
+	corrupted := seedCache(t, dir, unlockedURL, "tool")
+
+	if _, err := runCacheCommand(runCacheVerify); err != nil {
+		t.Fatalf("cache verify of an intact cache: %v", err)
+	}
+
+	_ = os.Chmod(corrupted.BlobPath, 0644)
+	if err := os.WriteFile(corrupted.BlobPath, []byte("tool, corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCacheCommand(runCacheVerify)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 cached downloads failed verification") {
+		t.Fatalf("expected the corrupted download to fail verification, got %v:\n%s", err, out)
+	}
+	if _, ok := cache.Lookup(dir, unlockedURL); ok {
+		t.Errorf("expected the corrupted download to be removed")
+	}
+	if _, ok := cache.Lookup(dir, lockedURL); !ok {
+		t.Errorf("expected the intact download to be kept")
+	}
+}
+
+func TestCachePruneKeepLocked(t *testing.T) {
+	prevOlderThan, prevKeepLocked := cachePruneOlderThan, cachePruneKeepLocked
+	t.Cleanup(func() { cachePruneOlderThan, cachePruneKeepLocked = prevOlderThan, prevKeepLocked })
+
+	dir := useCache(t, lockFor(lockedURL, "kubectl"))
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	for _, entry := range []*cache.Entry{seedCache(t, dir, lockedURL, "kubectl"), seedCache(t, dir, unlockedURL, "tool")} {
+		if err := os.Chtimes(entry.BlobPath, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cachePruneOlderThan, cachePruneKeepLocked = "30d", true
+	out, err := runCacheCommand(runCachePrune)
+	if err != nil {
+		t.Fatalf("cache prune: %v", err)
+	}
+	if !strings.Contains(out, "Removed 1 downloads") {
+		t.Errorf("expected one download to be removed, got:\n%s", out)
+	}
+	if _, ok := cache.Lookup(dir, lockedURL); !ok {
+		t.Errorf("expected the locked download to be kept")
+	}
+	if _, ok := cache.Lookup(dir, unlockedURL); ok {
+		t.Errorf("expected the unlocked download to be removed")
+	}
+}
+
+func TestCacheImport(t *testing.T) {
+	prevURL := cacheImportURL
+	t.Cleanup(func() { cacheImportURL = prevURL })
+
+	dir := useCache(t, lockFor(lockedURL, "kubectl"))
+	file := filepath.Join(t.TempDir(), "kubectl")
+
+	cacheImportURL = lockedURL
+	if err := os.WriteFile(file, []byte("not kubectl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCacheCommand(runCacheImport, file); err == nil || !strings.Contains(err.Error(), "does not match the checksum locked for kubectl") {
+		t.Fatalf("expected a file not matching the lock to be rejected, got %v", err)
+	}
+	if _, ok := cache.Lookup(dir, lockedURL); ok {
+		t.Fatalf("expected the rejected file not to be cached")
+	}
+
+	if err := os.WriteFile(file, []byte("kubectl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCacheCommand(runCacheImport, file); err != nil {
+		t.Fatalf("cache import: %v", err)
+	}
+	entry, ok := cache.Lookup(dir, lockedURL)
+	if !ok || entry.Size != int64(len("kubectl")) {
+		t.Errorf("expected the file to be cached as %s, got %+v", lockedURL, entry)
+	}
+}
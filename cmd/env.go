@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/shellenv"
+	"github.com/spf13/cobra"
+)
+
+var envShell string
+
+var envCmd = &cobra.Command{
+	Use:          "env",
+	Short:        "Print shell commands that activate the nearest deps.yaml project",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	Long: `Print the exports that put the nearest project's bin-dir on PATH and set the env
+declared by its dependencies (e.g. JAVA_HOME), for the shell to eval.
+
+Supported shells: ` + strings.Join(shellenv.Shells, ", ") + `. The shell defaults to $SHELL;
+--shell direnv prints a 'use deps' helper for ~/.config/direnv/direnvrc.
+
+Examples:
+  eval "$(deps env)"                         # bash / zsh
+  deps env --shell fish | source
+  deps env --shell powershell | Invoke-Expression
+  deps env --shell direnv >> ~/.config/direnv/direnvrc`,
+	RunE: runEnv,
+}
+
+var shellCmd = &cobra.Command{
+	Use:          "shell",
+	Short:        "Start a subshell with the nearest deps.yaml project activated",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	Long: `Start $SHELL with the same environment 'deps env' prints. Exit the subshell to deactivate.
+
+Examples:
+  deps shell`,
+	RunE: runShell,
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd, shellCmd)
+	envCmd.Flags().StringVar(&envShell, "shell", "", "Shell to print exports for (default: detected from $SHELL)")
+}
+
+func projectEnv() (*shellenv.Env, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	project, err := config.FindProject(cwd)
+	if err != nil {
+		return nil, err
+	}
+	return shellenv.ForProject(project, appDir, platform.Platform{OS: osOverride, Arch: archOverride})
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	shell := envShell
+	if shell == "" {
+		shell = shellenv.DetectShell()
+	}
+	if shell == "direnv" {
+		cmd.Print(shellenv.DirenvHook)
+		return nil
+	}
+
+	env, err := projectEnv()
+	if err != nil {
+		return err
+	}
+	out, err := env.Render(shell)
+	if err != nil {
+		return err
+	}
+	cmd.Print(out)
+	return nil
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	if active := os.Getenv("DEPS_SHELL"); active != "" {
+		return fmt.Errorf("already in a deps shell for %s", active)
+	}
+	env, err := projectEnv()
+	if err != nil {
+		return err
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+		if shellenv.DetectShell() == "powershell" {
+			shell = "powershell.exe"
+		}
+	}
+
+	sub := exec.Command(shell)
+	sub.Env = append(env.Environ(os.Environ()), "DEPS_SHELL="+env.Project)
+	sub.Stdin, sub.Stdout, sub.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := sub.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to start %s: %w", shell, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/installer"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:          "exec tool [-- args...]",
+	Short:        "Run the version of a tool pinned by the nearest deps.yaml",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	Long: `Run a tool at the version pinned by the nearest deps-lock.yaml or deps.yaml.
+
+The current directory and its parents are searched for the project's config. If the
+pinned version is not installed it is installed into the version store first, using
+the lock file's URL and checksum when available, without changing the active version
+in bin-dir. Tools the project does not pin run the active bin-dir version.
+
+bin-dir and app-dir are the project's (settings.bin_dir, ./bin by default), the same
+ones 'deps env' activates, unless --bin-dir, --app-dir or --system is given.
+
+Examples:
+  deps exec kubectl -- get pods
+  deps exec terraform -- plan -out plan.tfplan`,
+	RunE: runExec,
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	name, toolArgs := args[0], args[1:]
+	if dash := cmd.ArgsLenAtDash(); dash > 1 {
+		return fmt.Errorf("unexpected arguments before '--': %v", args[1:dash])
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path, err := execPath(cmd, cwd, name)
+	if err != nil {
+		return err
+	}
+	return execTool(path, toolArgs)
+}
+
+// execPath resolves the executable 'deps exec' runs for name in dir. Inside a project it uses
+// the project's bin-dir and app-dir, the ones 'deps env' activates, unless --bin-dir, --app-dir
+// or --system chose others.
+func execPath(cmd *cobra.Command, dir, name string) (string, error) {
+	depsConfig := GetDepsConfig()
+	var opts []installer.InstallOption
+	project, err := config.FindProject(dir)
+	if err == nil {
+		depsConfig = project.Config
+		settings := project.Config.Settings
+		if !cmd.Flags().Changed("bin-dir") && !systemInstall {
+			opts = append(opts, installer.WithBinDir(settings.BinDir))
+		}
+		if settings.AppDir != "" && !cmd.Flags().Changed("app-dir") && !systemInstall {
+			opts = append(opts, installer.WithAppDir(settings.AppDir))
+		}
+	}
+
+	return newCLIInstallerWithConfig(depsConfig, opts...).ProjectExecutable(project, name, &task.Task{})
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/shellenv"
+	"github.com/spf13/cobra"
+)
+
+func TestExecPathUsesTheBinDirEnvActivates(t *testing.T) {
+	prevBinDir, prevAppDir, prevSystem := binDir, appDir, systemInstall
+	defer func() { binDir, appDir, systemInstall = prevBinDir, prevAppDir, prevSystem }()
+
+	project := t.TempDir()
+	deps := "settings:\n  bin_dir: tools\nregistry:\n  exec-env-tool:\n    manager: github_release\n    repo: example/exec-env-tool\n"
+	if err := os.WriteFile(filepath.Join(project, config.DepsFile), []byte(deps), 0644); err != nil {
+		t.Fatal(err)
+	}
+	global := t.TempDir()
+	for _, dir := range []string{filepath.Join(project, "tools"), global} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "exec-env-tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binDir, appDir, systemInstall = global, t.TempDir(), false
+
+	found, err := config.FindProject(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := shellenv.ForProject(found, appDir, platform.Platform{OS: osOverride, Arch: archOverride})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringVar(&binDir, "bin-dir", binDir, "")
+	cmd.Flags().StringVar(&appDir, "app-dir", appDir, "")
+
+	path, err := execPath(cmd, project, "exec-env-tool")
+	if err != nil {
+		t.Fatalf("execPath() error = %v", err)
+	}
+	if want := filepath.Join(env.Path[0], "exec-env-tool"); path != want {
+		t.Errorf("execPath() = %s, want %s from the bin-dir deps env puts on PATH", path, want)
+	}
+
+	if err := cmd.Flags().Set("bin-dir", global); err != nil {
+		t.Fatal(err)
+	}
+	path, err = execPath(cmd, project, "exec-env-tool")
+	if err != nil {
+		t.Fatalf("execPath() error = %v", err)
+	}
+	if want := filepath.Join(global, "exec-env-tool"); path != want {
+		t.Errorf("execPath() with --bin-dir = %s, want %s", path, want)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// execTool replaces the deps process with the tool, so signals and the exit code pass straight through.
+func execTool(path string, args []string) error {
+	if err := syscall.Exec(path, append([]string{path}, args...), os.Environ()); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", path, err)
+	}
+	return nil
+}
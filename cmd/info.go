@@ -82,6 +82,15 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	if pkg.BinaryName != "" {
 		fmt.Fprintf(out, "Binary: %s\n", pkg.BinaryName)
 	}
+	if len(pkg.Requires) > 0 {
+		tree, err := inst.RequirementTree(toolSpec.Name)
+		if err != nil {
+			fmt.Fprintf(out, "Requires: (error: %v)\n", err)
+		} else {
+			fmt.Fprintf(out, "Requires:\n")
+			printRequirementTree(out, tree.Requires, "  ")
+		}
+	}
 
 	var versions []types.Version
 	versions, err = mgr.DiscoverVersions(ctx, pkg, preview.Platform, infoVersionLimit)
@@ -177,6 +186,13 @@ func runInfoAll(out io.Writer, ctx context.Context, includePrerelease bool) erro
 		constraint = "latest"
 	}
 
+	// Fetch the versions of all packages at once where the manager supports it
+	pkgs := make([]types.Package, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, depsConfig.Registry[name])
+	}
+	manager.GetGlobalRegistry().PrefetchVersions(ctx, pkgs)
+
 	for _, name := range names {
 		pkg := depsConfig.Registry[name]
 
@@ -258,6 +274,22 @@ func printInstallPreview(out io.Writer, preview *installer.InstallPreview) {
 	}
 }
 
+// printRequirementTree prints required packages as a tree, e.g. "├── go@>=1.22"
+func printRequirementTree(out io.Writer, nodes []*installer.RequirementNode, indent string) {
+	for idx, node := range nodes {
+		branch, next := "├── ", "│   "
+		if idx == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		name := node.Name
+		if node.Constraint != "" {
+			name += "@" + node.Constraint
+		}
+		fmt.Fprintf(out, "%s%s%s\n", indent, branch, name)
+		printRequirementTree(out, node.Requires, indent+next)
+	}
+}
+
 func truncateVersion(version string, maxLen int) string {
 	if len(version) <= maxLen {
 		return version
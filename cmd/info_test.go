@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/flanksource/deps/mock"
+	"github.com/flanksource/deps/pkg/installer"
 	"github.com/flanksource/deps/pkg/manager"
 	"github.com/flanksource/deps/pkg/types"
 	"github.com/spf13/cobra"
@@ -100,3 +101,16 @@ func TestRunInfoIncludesInstallPreview(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintRequirementTree(t *testing.T) {
+	var out bytes.Buffer
+	printRequirementTree(&out, []*installer.RequirementNode{
+		{Name: "go", Constraint: ">=1.22"},
+		{Name: "helm-diff", Requires: []*installer.RequirementNode{{Name: "helm"}}},
+	}, "  ")
+
+	expected := "  ├── go@>=1.22\n  └── helm-diff\n      └── helm\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected tree:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/flanksource/clicky"
 	"github.com/flanksource/clicky/task"
 	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/flanksource/deps/pkg/bundle"
 	"github.com/flanksource/deps/pkg/config"
+	depshttp "github.com/flanksource/deps/pkg/http"
 	"github.com/flanksource/deps/pkg/installer"
 	"github.com/flanksource/deps/pkg/types"
 	"github.com/flanksource/deps/pkg/verify"
@@ -16,7 +19,11 @@ import (
 
 var (
 	installCheck    bool
+	installFrozen   bool
+	installBundle   string
 	iterateVersions int
+	installJobs     int
+	hostJobs        = installer.DefaultHostJobs
 )
 
 var installCmd = &cobra.Command{
@@ -32,39 +39,54 @@ Examples:
   deps install jq                    # Install jq with default version
   deps install kubectl@v1.28.0       # Install kubectl version v1.28.0
   deps install jq yq@v4.16.2 kind    # Install multiple tools
-  deps install --check jq            # Install jq and verify the installation`,
+  deps install --check jq            # Install jq and verify the installation
+  deps install --frozen              # Install exactly what deps-lock.yaml records, without API calls
+  deps install --from-bundle tools.tar  # Install from a 'deps bundle' archive without network access
+  deps install --jobs 8              # Install up to 8 dependencies at once`,
 	RunE: runInstall,
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
 	installCmd.Flags().BoolVar(&installCheck, "check", false, "Verify installation by checking version after install")
+	installCmd.Flags().BoolVar(&installFrozen, "frozen", false, "Install the exact URLs and checksums from deps-lock.yaml, failing if a dependency or platform is not locked")
+	installCmd.Flags().StringVar(&installBundle, "from-bundle", "", "Install from an archive created by 'deps bundle', without network access")
+	installCmd.Flags().IntVar(&installJobs, "jobs", 0, "Maximum dependencies installed at once (default: settings.jobs, or 4 when settings.parallel is enabled)")
+	installCmd.Flags().IntVar(&hostJobs, "host-jobs", installer.DefaultHostJobs, "Maximum concurrent downloads from a single host")
 	installCmd.Flags().IntVar(&iterateVersions, "iterate-versions", 0, "Number of releases to try when 'latest' has no matching assets (0=disabled)")
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
 	inst := newCLIInstaller()
-
-	// If no arguments provided, install from deps.yaml
-	if len(args) == 0 {
-		var installErr error
-		task.StartTask("install", func(ctx flanksourceContext.Context, task *task.Task) (interface{}, error) {
-			installErr = inst.InstallFromConfig(task)
-			return nil, installErr
-		})
-		if installErr != nil {
-			return installErr
+	if installBundle != "" {
+		dir, err := os.MkdirTemp(tmpDir, "deps-bundle-*")
+		if err != nil {
+			return fmt.Errorf("failed to create directory for bundle: %w", err)
 		}
-	} else {
-		// Parse tools and install them
-		tools := installer.ParseTools(args)
-		if err := inst.InstallMultiple(tools); err != nil {
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		b, err := bundle.Open(installBundle, dir)
+		if err != nil {
 			return err
 		}
+		depshttp.SetOffline(true)
+		inst = newCLIInstallerWithConfig(b.Config, installer.WithBundle(b))
+	}
+
+	// Installs run as their own tasks and are waited for, so failures from every tool are reported together
+	var installErr error
+	if len(args) == 0 {
+		// If no arguments provided, install from deps.yaml
+		installErr = inst.InstallFromConfig(&task.Task{})
+	} else {
+		installErr = inst.InstallMultiple(installer.ParseTools(args))
 	}
 
 	// Wait for all installations to complete
 	exitCode := clicky.WaitForGlobalCompletion()
+	if installErr != nil {
+		return installErr
+	}
 	if exitCode != 0 {
 		return fmt.Errorf("installation failed with exit code %d", exitCode)
 	}
@@ -1,15 +1,23 @@
 package cmd
 
-import "github.com/flanksource/deps/pkg/installer"
+import (
+	"github.com/flanksource/deps/pkg/installer"
+	"github.com/flanksource/deps/pkg/types"
+)
 
 func newCLIInstaller() *installer.Installer {
+	return newCLIInstallerWithConfig(GetDepsConfig())
+}
+
+// newCLIInstallerWithConfig creates an installer from the CLI flags for a specific deps config,
+// e.g. the one of the project 'deps exec' runs in rather than the current directory's.
+func newCLIInstallerWithConfig(depsConfig *types.DepsConfig, extra ...installer.InstallOption) *installer.Installer {
 	cacheDirToUse := cacheDir
 	if cacheDirToUse == "" {
-		cacheDirToUse = GetDepsConfig().Settings.CacheDir
+		cacheDirToUse = depsConfig.Settings.CacheDir
 	}
 
-	return installer.NewWithConfig(
-		GetDepsConfig(),
+	opts := []installer.InstallOption{
 		installer.WithBinDir(binDir),
 		installer.WithAppDir(appDir),
 		installer.WithTmpDir(tmpDir),
@@ -21,5 +29,10 @@ func newCLIInstaller() *installer.Installer {
 		installer.WithOS(osOverride, archOverride),
 		installer.WithTimeout(timeout),
 		installer.WithIterateVersions(iterateVersions),
-	)
+		installer.WithFrozenLock(installFrozen),
+		installer.WithOffline(offline),
+		installer.WithJobs(installJobs),
+		installer.WithHostJobs(hostJobs),
+	}
+	return installer.NewWithConfig(depsConfig, append(opts, extra...)...)
 }
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/clicky"
+	"github.com/flanksource/clicky/task"
+	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:          "rollback tool...",
+	Short:        "Restore the version a tool had before its last install",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	Long: `Restore the version of one or more tools that was active before the last install or 'deps use'.
+
+Installs are staged and verified before they replace anything, and the replaced
+version is kept, so rolling back never downloads. Running rollback twice returns
+to the newer version.
+
+Examples:
+  deps rollback kubectl
+  deps rollback openjdk go`,
+	RunE: runRollback,
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	inst := newCLIInstaller()
+
+	for _, name := range args {
+		toolName := name
+		task.StartTask(toolName, func(ctx flanksourceContext.Context, t *task.Task) (interface{}, error) {
+			version, err := inst.Rollback(toolName, t)
+			if err != nil {
+				return nil, fmt.Errorf("failed to roll back %s: %w", toolName, err)
+			}
+			t.Infof("✓ Rolled back %s to %s", toolName, version)
+			t.Success()
+			return version, nil
+		})
+	}
+
+	if exitCode := clicky.WaitForGlobalCompletion(); exitCode != 0 {
+		return fmt.Errorf("rollback failed with exit code %d", exitCode)
+	}
+	return nil
+}
@@ -11,7 +11,11 @@ import (
 	"github.com/flanksource/clicky"
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/properties"
+	"github.com/flanksource/deps/pkg/cache"
 	"github.com/flanksource/deps/pkg/config"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/manager/github"
+	"github.com/flanksource/deps/pkg/manager/gitlab"
 	"github.com/flanksource/deps/pkg/platform"
 	"github.com/flanksource/deps/pkg/types"
 	"github.com/spf13/cobra"
@@ -20,8 +24,8 @@ import (
 	// Register all package managers via init functions
 	_ "github.com/flanksource/deps/pkg/manager/apache"
 	_ "github.com/flanksource/deps/pkg/manager/direct"
-	_ "github.com/flanksource/deps/pkg/manager/github"
-	_ "github.com/flanksource/deps/pkg/manager/gitlab"
+	_ "github.com/flanksource/deps/pkg/manager/docker"
+	_ "github.com/flanksource/deps/pkg/manager/gitea"
 	_ "github.com/flanksource/deps/pkg/manager/golang"
 	_ "github.com/flanksource/deps/pkg/manager/maven"
 	_ "github.com/flanksource/deps/pkg/manager/url"
@@ -45,6 +49,10 @@ var (
 	showVersion    bool
 	systemInstall  bool
 	timeout        time.Duration
+	offline        bool
+	httpAttempts   int
+	httpBackoff    time.Duration
+	refresh        bool
 )
 
 var clickyFlagNames = map[string]struct{}{
@@ -107,6 +115,10 @@ It can download and install various tools like kubectl, helm, terraform, and mor
 			appDir = "/usr/local"
 		}
 
+		if offline {
+			depshttp.SetOffline(true)
+		}
+
 		// Apply clicky flags after command line parsing
 		clicky.Flags.UseFlags()
 
@@ -120,6 +132,51 @@ It can download and install various tools like kubectl, helm, terraform, and mor
 			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
+		if err := depshttp.SetMirrors(depsConfig.Settings.Mirrors); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := depshttp.SetNetwork(depsConfig.Settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := github.SetEnterprise(depsConfig.Settings.GitHub); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := gitlab.SetInstance(depsConfig.Settings.GitLab); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		credentials := gitlab.InstanceCredentials(depsConfig.Settings, github.EnterpriseCredentials(depsConfig.Settings, depsConfig.Registry))
+		if err := depshttp.SetCredentials(credentials); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		retry := depsConfig.Settings.Retry
+		if cmd.Flags().Changed("http-max-attempts") {
+			retry.MaxAttempts = httpAttempts
+		}
+		if cmd.Flags().Changed("http-backoff") {
+			retry.Backoff = httpBackoff
+		}
+		if err := depshttp.SetRetryPolicy(retry); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		responseCacheDir := cacheDir
+		if responseCacheDir == "" {
+			responseCacheDir = depsConfig.Settings.CacheDir
+		}
+		if err := depshttp.SetResponseCache(responseCacheDir, depsConfig.Settings.HTTPCache); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		depshttp.SetRefresh(refresh)
+		if err := cache.SetMaxSize(depsConfig.Settings.Cache.MaxSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
 
 		logger.Debugf("Using BIN_DIR: %s (%s/%s)", binDir, osOverride, archOverride)
 	},
@@ -178,6 +235,10 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Path to deps.yaml config file")
 	rootCmd.PersistentFlags().BoolVar(&systemInstall, "system", false, "Install system-wide (--bin-dir /usr/local/bin --app-dir /usr/local)")
 	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 5*time.Minute, "Timeout for downloads and installations")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Make no network calls, installing only from deps-lock.yaml and the download cache (env: DEPS_OFFLINE)")
+	rootCmd.PersistentFlags().IntVar(&httpAttempts, "http-max-attempts", 3, "Attempts per network request before giving up (settings.retry.max_attempts)")
+	rootCmd.PersistentFlags().DurationVar(&httpBackoff, "http-backoff", 500*time.Millisecond, "Delay before the first retry, doubled for each further retry (settings.retry.backoff)")
+	rootCmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "Revalidate cached API responses with the server instead of reusing them for settings.http_cache.ttl")
 }
 
 func groupedUsageFunc(cmd *cobra.Command) error {
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/installer"
+	"github.com/spf13/cobra"
+)
+
+var shimDir string
+
+var shimCmd = &cobra.Command{
+	Use:   "shim",
+	Short: "Manage shims that run the version pinned by the nearest deps.yaml",
+	Long: `Shims are tiny scripts that run 'deps exec <tool>', so invoking a tool anywhere picks
+the version pinned by the nearest deps-lock.yaml or deps.yaml, installing it if needed.
+
+Add the shim directory to the front of PATH once:
+  export PATH="$HOME/.deps/shims:$PATH"`,
+}
+
+var shimInstallCmd = &cobra.Command{
+	Use:          "install [tool...]",
+	Short:        "Write shims for tools (default: all dependencies of the nearest deps.yaml)",
+	SilenceUsage: true,
+	Long: `Write shims for the given tools into the shim directory.
+
+Without arguments, shims are written for every dependency in the nearest deps.yaml.
+
+Examples:
+  deps shim install
+  deps shim install kubectl helm terraform`,
+	RunE: runShimInstall,
+}
+
+var shimRemoveCmd = &cobra.Command{
+	Use:          "remove tool...",
+	Short:        "Remove shims",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runShimRemove,
+}
+
+var shimListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List installed shims",
+	SilenceUsage: true,
+	RunE:         runShimList,
+}
+
+func init() {
+	rootCmd.AddCommand(shimCmd)
+	shimCmd.AddCommand(shimInstallCmd, shimRemoveCmd, shimListCmd)
+	shimCmd.PersistentFlags().StringVar(&shimDir, "shim-dir", installer.DefaultShimDir(), "Directory to write shims to (env: DEPS_SHIM_DIR)")
+}
+
+func runShimInstall(cmd *cobra.Command, args []string) error {
+	tools := args
+	if len(tools) == 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		project, err := config.FindProject(cwd)
+		if err != nil {
+			return fmt.Errorf("no tools given: %w", err)
+		}
+		for name := range project.Config.Dependencies {
+			tools = append(tools, name)
+		}
+		sort.Strings(tools)
+	}
+
+	depsBinary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the deps binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(depsBinary); err == nil {
+		depsBinary = resolved
+	}
+
+	for _, name := range installer.ParseTools(tools) {
+		path, err := installer.WriteShim(shimDir, name.Name, depsBinary)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Created shim %s\n", path)
+	}
+	return nil
+}
+
+func runShimRemove(cmd *cobra.Command, args []string) error {
+	for _, name := range args {
+		if err := installer.RemoveShim(shimDir, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runShimList(cmd *cobra.Command, args []string) error {
+	names, err := installer.ListShims(shimDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		cmd.Println(name)
+	}
+	return nil
+}
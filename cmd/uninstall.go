@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/clicky"
+	"github.com/flanksource/clicky/task"
+	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/spf13/cobra"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:          "uninstall tool...",
+	Short:        "Uninstall tools previously installed by deps",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	Long: `Uninstall one or more tools using the install receipt written by 'deps install'.
+
+Only the binaries, symlinks, wrapper scripts and app-dir folders recorded in the
+receipt (~/.deps/receipts/<bin-dir key>/<tool>.json) are removed. Tools without a
+receipt, files that were replaced after install, and app-dir folders still recorded
+by a receipt of another bin-dir are left untouched.
+
+Examples:
+  deps uninstall jq
+  deps uninstall kubectl helm`,
+	RunE: runUninstall,
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+}
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	inst := newCLIInstaller()
+
+	for _, name := range args {
+		toolName := name
+		task.StartTask(toolName, func(ctx flanksourceContext.Context, t *task.Task) (interface{}, error) {
+			result, err := inst.Uninstall(toolName, t)
+			if err != nil {
+				return nil, fmt.Errorf("failed to uninstall %s: %w", toolName, err)
+			}
+			t.Infof("Removed %d paths for %s", len(result.Removed), toolName)
+			t.Success()
+			return result, nil
+		})
+	}
+
+	if exitCode := clicky.WaitForGlobalCompletion(); exitCode != 0 {
+		return fmt.Errorf("uninstall failed with exit code %d", exitCode)
+	}
+	return nil
+}
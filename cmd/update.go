@@ -95,6 +95,15 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Fetch the versions of all packages at once where the manager supports it
+	var pkgs []types.Package
+	for name := range depsToCheck {
+		if pkg, ok := depsConfig.Registry[name]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	managers.PrefetchVersions(ctx, pkgs)
+
 	// Check each dependency for updates
 	var updates []UpdateInfo
 	for name, constraint := range depsToCheck {
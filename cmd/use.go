@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/clicky"
+	"github.com/flanksource/clicky/task"
+	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/flanksource/deps/pkg/installer"
+	"github.com/spf13/cobra"
+)
+
+var useCmd = &cobra.Command{
+	Use:          "use tool@version...",
+	Short:        "Switch a tool to a previously installed version",
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	Long: `Switch the active version of one or more tools without downloading anything.
+
+Every install is kept in the version store of its bin-dir (~/.deps/versions),
+and 'deps use' atomically repoints the bin-dir symlink or wrapper script at the
+requested version. Partial versions and constraints select the newest matching
+installed version.
+
+Examples:
+  deps use kubectl@1.29.3
+  deps use kubectl@1.29           # newest installed 1.29.x
+  deps use helm@v3.14.0 terraform@1.7`,
+	RunE: runUse,
+}
+
+func init() {
+	rootCmd.AddCommand(useCmd)
+}
+
+func runUse(cmd *cobra.Command, args []string) error {
+	inst := newCLIInstaller()
+
+	for _, tool := range installer.ParseTools(args) {
+		if tool.Version == "" || tool.Version == "latest" {
+			return fmt.Errorf("a version is required, e.g. 'deps use %s@1.2.3'", tool.Name)
+		}
+		task.StartTask(tool.Name, func(ctx flanksourceContext.Context, t *task.Task) (interface{}, error) {
+			version, err := inst.Use(tool.Name, tool.Version, t)
+			if err != nil {
+				return nil, err
+			}
+			t.Infof("✓ Switched %s to %s", tool.Name, version)
+			t.Success()
+			return version, nil
+		})
+	}
+
+	if exitCode := clicky.WaitForGlobalCompletion(); exitCode != 0 {
+		return fmt.Errorf("use failed with exit code %d", exitCode)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/clicky"
+	"github.com/spf13/cobra"
+)
+
+// LocalVersionInfo represents a locally installed version of a tool
+type LocalVersionInfo struct {
+	Version string `json:"version" pretty:"label=Version"`
+	Active  string `json:"active" pretty:"label=Active"`
+	Path    string `json:"path" pretty:"label=Path"`
+}
+
+var versionsCmd = &cobra.Command{
+	Use:          "versions tool",
+	Short:        "List the locally installed versions of a tool",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	Long: `List the versions of a tool kept in the local version store, newest first.
+
+The active version is the one bin-dir currently points at; switch between them
+with 'deps use tool@version'.
+
+Examples:
+  deps versions kubectl`,
+	RunE: runVersions,
+}
+
+func init() {
+	rootCmd.AddCommand(versionsCmd)
+}
+
+func runVersions(cmd *cobra.Command, args []string) error {
+	installed, err := newCLIInstaller().InstalledVersions(args[0])
+	if err != nil {
+		return err
+	}
+	if len(installed) == 0 {
+		return fmt.Errorf("no versions of %s are installed, run 'deps install %s@<version>' first", args[0], args[0])
+	}
+
+	var rows []LocalVersionInfo
+	for _, v := range installed {
+		active := ""
+		if v.Active {
+			active = "*"
+		}
+		rows = append(rows, LocalVersionInfo{Version: v.Version, Active: active, Path: v.Path})
+	}
+
+	result, err := clicky.Format(rows)
+	if err != nil {
+		return err
+	}
+	cmd.Println(result)
+	return nil
+}
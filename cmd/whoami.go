@@ -3,17 +3,26 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	depshttp "github.com/flanksource/deps/pkg/http"
 	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/manager/gitea"
 	"github.com/flanksource/deps/pkg/manager/github"
+	"github.com/flanksource/deps/pkg/manager/gitlab"
+	"github.com/flanksource/deps/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 var whoamiCmd = &cobra.Command{
 	Use:   "whoami",
-	Short: "Show authentication status for package managers",
-	Long:  `whoami displays authentication status and user information for configured package managers like GitHub.`,
+	Short: "Show authentication status for package managers and the network configuration",
+	Long: `whoami displays the effective network configuration (proxy, CA bundle, client certificate,
+mirrors, credentials and retries) and the authentication status and user information for configured
+package managers like GitHub, GitLab and Gitea, including GitHub Enterprise, self-managed GitLab and
+Gitea or Forgejo instances used by packages.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runWhoAmI()
 	},
@@ -26,7 +35,9 @@ func init() {
 func runWhoAmI() error {
 	ctx := context.Background()
 
-	fmt.Println("Package Manager Authentication Status:")
+	printNetworkConfig()
+
+	fmt.Println("\nPackage Manager Authentication Status:")
 	fmt.Println("=====================================")
 
 	// Check GitHub authentication
@@ -34,6 +45,16 @@ func runWhoAmI() error {
 		return fmt.Errorf("failed to check GitHub authentication: %w", err)
 	}
 
+	// Check GitLab authentication
+	if err := checkGitLabAuth(ctx); err != nil {
+		return fmt.Errorf("failed to check GitLab authentication: %w", err)
+	}
+
+	// Check Gitea authentication
+	if err := checkGiteaAuth(ctx); err != nil {
+		return fmt.Errorf("failed to check Gitea authentication: %w", err)
+	}
+
 	return nil
 }
 
@@ -55,14 +76,46 @@ func checkGitHubAuth(ctx context.Context) error {
 		return nil
 	}
 
-	// Get authentication status
-	status := githubMgr.WhoAmI(ctx)
+	for _, baseURL := range githubBaseURLs() {
+		printGitHubAuth(githubMgr.WhoAmI(github.WithBaseURL(ctx, baseURL)), github.IsEnterprise(baseURL))
+	}
+
+	return nil
+}
+
+// githubBaseURLs returns github.com and every GitHub Enterprise Server in the settings or registry
+func githubBaseURLs() []string {
+	baseURLs := []string{github.DefaultBaseURL}
+	seen := map[string]bool{github.DefaultBaseURL: true}
+	add := func(baseURL string) {
+		if !seen[baseURL] {
+			seen[baseURL] = true
+			baseURLs = append(baseURLs, baseURL)
+		}
+	}
+	add(github.BaseURL(types.Package{}))
+
+	var enterprise []string
+	for _, pkg := range GetDepsConfig().Registry {
+		if pkg.BaseURL != "" && strings.HasPrefix(pkg.Manager, "github") {
+			enterprise = append(enterprise, github.BaseURL(pkg))
+		}
+	}
+	sort.Strings(enterprise)
+	for _, baseURL := range enterprise {
+		add(baseURL)
+	}
+	return baseURLs
+}
 
-	fmt.Printf("\n🔧 GitHub Release Manager:\n")
+func printGitHubAuth(status *types.AuthStatus, enterprise bool) {
+	fmt.Printf("\n🔧 %s Release Manager:\n", status.Service)
 
 	// Show token source
 	if status.TokenSource != "" {
 		fmt.Printf("  Token Source: %s\n", status.TokenSource)
+	} else if enterprise {
+		fmt.Printf("  Token Source: None (checked settings.github.token, GH_ENTERPRISE_TOKEN, GITHUB_ENTERPRISE_TOKEN)\n")
 	} else {
 		fmt.Printf("  Token Source: None (checked GITHUB_TOKEN, GH_TOKEN, GITHUB_ACCESS_TOKEN)\n")
 	}
@@ -125,17 +178,256 @@ func checkGitHubAuth(ctx context.Context) error {
 	}
 
 	// Provide helpful tips
-	if !status.Authenticated {
+	if !status.Authenticated && enterprise {
+		fmt.Printf("\n💡 Tips:\n")
+		fmt.Printf("  - Set GH_ENTERPRISE_TOKEN, or settings.github.token to the variable holding the token\n")
+		fmt.Printf("  - Use 'gh auth token --hostname <host>' if you have GitHub CLI installed\n")
+	} else if !status.Authenticated {
 		fmt.Printf("\n💡 Tips:\n")
 		fmt.Printf("  - Set GITHUB_TOKEN environment variable for authenticated access\n")
 		fmt.Printf("  - Use 'gh auth token' if you have GitHub CLI installed\n")
 		fmt.Printf("  - Create a personal access token at https://github.com/settings/tokens\n")
 		fmt.Printf("  - No special scopes required for public repository access\n")
 	}
+}
+
+func checkGitLabAuth(ctx context.Context) error {
+	mgr, exists := manager.GetGlobalRegistry().Get("gitlab")
+	if !exists {
+		fmt.Println("\n❌ GitLab Manager: Not available")
+		return nil
+	}
+
+	gitlabMgr, ok := mgr.(*gitlab.GitLabReleaseManager)
+	if !ok {
+		fmt.Println("\n❌ GitLab Manager: Invalid type")
+		return nil
+	}
+
+	for _, baseURL := range gitlabBaseURLs() {
+		printGitLabAuth(gitlabMgr.WhoAmI(gitlab.WithBaseURL(ctx, baseURL)), gitlab.IsSelfManaged(baseURL))
+	}
+
+	return nil
+}
+
+// gitlabBaseURLs returns gitlab.com and every self-managed GitLab instance in the settings or registry
+func gitlabBaseURLs() []string {
+	baseURLs := []string{gitlab.DefaultBaseURL}
+	seen := map[string]bool{gitlab.DefaultBaseURL: true}
+	add := func(baseURL string) {
+		if !seen[baseURL] {
+			seen[baseURL] = true
+			baseURLs = append(baseURLs, baseURL)
+		}
+	}
+	add(gitlab.BaseURL(types.Package{}))
+
+	var selfManaged []string
+	for _, pkg := range GetDepsConfig().Registry {
+		if pkg.BaseURL != "" && strings.HasPrefix(pkg.Manager, "gitlab") {
+			selfManaged = append(selfManaged, gitlab.BaseURL(pkg))
+		}
+	}
+	sort.Strings(selfManaged)
+	for _, baseURL := range selfManaged {
+		add(baseURL)
+	}
+	return baseURLs
+}
+
+func printGitLabAuth(status *types.AuthStatus, selfManaged bool) {
+	fmt.Printf("\n🔧 %s Release Manager:\n", status.Service)
+
+	if status.TokenSource != "" {
+		fmt.Printf("  Token Source: %s\n", status.TokenSource)
+	} else if selfManaged {
+		fmt.Printf("  Token Source: None (checked settings.gitlab.token and settings.credentials)\n")
+	} else {
+		fmt.Printf("  Token Source: None (checked GITLAB_TOKEN, GL_TOKEN, GITLAB_ACCESS_TOKEN)\n")
+	}
+
+	if status.Authenticated {
+		fmt.Printf("  Authenticated: ✅ Yes\n")
+	} else {
+		fmt.Printf("  Authenticated: ❌ No\n")
+		if status.Error != "" {
+			fmt.Printf("  Error: %s\n", status.Error)
+		}
+	}
+
+	if status.User != nil {
+		fmt.Printf("\n👤 User Information:\n")
+		fmt.Printf("  Username: %s\n", status.User.Username)
+		if status.User.Name != "" {
+			fmt.Printf("  Name: %s\n", status.User.Name)
+		}
+		if status.User.Email != "" {
+			fmt.Printf("  Email: %s\n", status.User.Email)
+		}
+		if status.User.CreatedAt != nil {
+			fmt.Printf("  Account Created: %s\n", status.User.CreatedAt.Format("2006-01-02"))
+		}
+	}
+
+	if status.RateLimit != nil {
+		fmt.Printf("\n📊 API Rate Limits:\n")
+		fmt.Printf("  Remaining: %d/%d\n", status.RateLimit.Remaining, status.RateLimit.Total)
+		if status.RateLimit.ResetTime != nil {
+			fmt.Printf("  Resets in: %s\n", formatRateLimitDuration(time.Until(*status.RateLimit.ResetTime)))
+		}
+	}
+
+	if !status.Authenticated && selfManaged {
+		fmt.Printf("\n💡 Tips:\n")
+		fmt.Printf("  - Set settings.gitlab.token to the variable holding a token for this instance\n")
+		fmt.Printf("  - Or add the host to settings.credentials for packages with their own base_url\n")
+	} else if !status.Authenticated {
+		fmt.Printf("\n💡 Tips:\n")
+		fmt.Printf("  - Set GITLAB_TOKEN environment variable for authenticated access\n")
+		fmt.Printf("  - Create a personal access token with the read_api scope at https://gitlab.com/-/user_settings/personal_access_tokens\n")
+		fmt.Printf("  - Public projects can be read without a token\n")
+	}
+}
+
+func checkGiteaAuth(ctx context.Context) error {
+	mgr, exists := manager.GetGlobalRegistry().Get("gitea_release")
+	if !exists {
+		fmt.Println("\n❌ Gitea Manager: Not available")
+		return nil
+	}
+
+	giteaMgr, ok := mgr.(*gitea.GiteaReleaseManager)
+	if !ok {
+		fmt.Println("\n❌ Gitea Manager: Invalid type")
+		return nil
+	}
+
+	for _, baseURL := range giteaBaseURLs() {
+		printGiteaAuth(giteaMgr.WhoAmI(gitea.WithBaseURL(ctx, baseURL)), baseURL)
+	}
 
 	return nil
 }
 
+// giteaBaseURLs returns Codeberg and every other Gitea or Forgejo instance in the registry
+func giteaBaseURLs() []string {
+	baseURLs := []string{gitea.DefaultBaseURL}
+	seen := map[string]bool{gitea.DefaultBaseURL: true}
+
+	var instances []string
+	for _, pkg := range GetDepsConfig().Registry {
+		if pkg.Manager != "gitea_release" {
+			continue
+		}
+		if baseURL := gitea.BaseURL(pkg); !seen[baseURL] {
+			seen[baseURL] = true
+			instances = append(instances, baseURL)
+		}
+	}
+	sort.Strings(instances)
+	return append(baseURLs, instances...)
+}
+
+func printGiteaAuth(status *types.AuthStatus, baseURL string) {
+	fmt.Printf("\n🔧 %s Release Manager:\n", status.Service)
+
+	if status.TokenSource != "" {
+		fmt.Printf("  Token Source: %s\n", status.TokenSource)
+	} else if baseURL == gitea.DefaultBaseURL {
+		fmt.Printf("  Token Source: None (checked CODEBERG_TOKEN, GITEA_TOKEN, FORGEJO_TOKEN)\n")
+	} else {
+		fmt.Printf("  Token Source: None (checked settings.credentials)\n")
+	}
+
+	if status.Authenticated {
+		fmt.Printf("  Authenticated: ✅ Yes\n")
+	} else {
+		fmt.Printf("  Authenticated: ❌ No\n")
+		if status.Error != "" {
+			fmt.Printf("  Error: %s\n", status.Error)
+		}
+	}
+
+	if status.User != nil {
+		fmt.Printf("\n👤 User Information:\n")
+		fmt.Printf("  Username: %s\n", status.User.Username)
+		if status.User.Name != "" {
+			fmt.Printf("  Name: %s\n", status.User.Name)
+		}
+		if status.User.Email != "" {
+			fmt.Printf("  Email: %s\n", status.User.Email)
+		}
+	}
+
+	if status.RateLimit != nil {
+		fmt.Printf("\n📊 API Rate Limits:\n")
+		if status.RateLimit.Total >= 0 {
+			fmt.Printf("  Remaining: %d/%d\n", status.RateLimit.Remaining, status.RateLimit.Total)
+		} else {
+			fmt.Printf("  Remaining: %d\n", status.RateLimit.Remaining)
+		}
+		if status.RateLimit.ResetTime != nil {
+			fmt.Printf("  Resets in: %s\n", formatRateLimitDuration(time.Until(*status.RateLimit.ResetTime)))
+		}
+	}
+
+	if !status.Authenticated {
+		fmt.Printf("\n💡 Tips:\n")
+		if baseURL == gitea.DefaultBaseURL {
+			fmt.Printf("  - Set CODEBERG_TOKEN for authenticated access and a higher rate limit\n")
+			fmt.Printf("  - Create an access token with read:repository scope at %s/user/settings/applications\n", baseURL)
+		} else {
+			fmt.Printf("  - Add a token for %s to settings.credentials\n", strings.TrimPrefix(baseURL, "https://"))
+		}
+		fmt.Printf("  - Public repositories can be read without a token\n")
+	}
+}
+
+// printNetworkConfig prints the network settings every download and API call uses
+func printNetworkConfig() {
+	network := depshttp.GetNetwork()
+
+	fmt.Println("Network Configuration:")
+	fmt.Println("======================")
+	if depshttp.IsOffline() {
+		fmt.Printf("  Offline: ✅ Yes, no network calls are made\n")
+	}
+	fmt.Printf("  Proxy: %s\n", withSource(network.Proxy, network.ProxySource, "None (direct connections)"))
+	if network.NoProxy != "" {
+		fmt.Printf("  No Proxy: %s\n", withSource(network.NoProxy, network.NoProxySource, ""))
+	}
+	fmt.Printf("  CA Bundle: %s\n", withSource(network.CAFile, network.CAFileSource, "System roots"))
+	fmt.Printf("  Client Certificate: %s\n", withSource(network.ClientCert, network.ClientCertSource, "None"))
+
+	if mirrors := GetDepsConfig().Settings.Mirrors; len(mirrors) > 0 {
+		var rules []string
+		for _, m := range mirrors {
+			rules = append(rules, fmt.Sprintf("%s → %s", m.Prefix, m.URL))
+		}
+		fmt.Printf("  Mirrors: %s\n", strings.Join(rules, ", "))
+	}
+
+	for _, cred := range depshttp.Credentials() {
+		fmt.Printf("  Credentials: %s (%s from %s)\n", cred.Pattern, cred.Type, cred.Source)
+	}
+
+	retry := depshttp.GetRetryPolicy()
+	fmt.Printf("  Retries: %d attempts, %s backoff (max %s, Retry-After up to %s) on %s\n",
+		retry.MaxAttempts, retry.Backoff, retry.MaxBackoff, retry.MaxRetryAfter, strings.Join(retry.Statuses, ", "))
+}
+
+// withSource formats a setting with where it came from, or fallback when it is not set
+func withSource(value, source, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	if source == "" {
+		return value
+	}
+	return fmt.Sprintf("%s (from %s)", value, source)
+}
+
 // formatRateLimitDuration formats a duration in a human-readable way for rate limits
 func formatRateLimitDuration(d time.Duration) string {
 	if d < 0 {
@@ -55,6 +55,15 @@ var (
 	WithOS             = installer.WithOS
 	WithTimeout        = installer.WithTimeout
 	WithProgress       = installer.WithProgress
+	WithFrozenLock     = installer.WithFrozenLock
+	WithOffline        = installer.WithOffline
+	WithBundle         = installer.WithBundle
+	WithReceiptsDir    = installer.WithReceiptsDir
+	WithVersionsDir    = installer.WithVersionsDir
+	WithLocksDir       = installer.WithLocksDir
+	WithLockTimeout    = installer.WithLockTimeout
+	WithJobs           = installer.WithJobs
+	WithHostJobs       = installer.WithHostJobs
 )
 
 // Install installs a package and returns detailed installation result.
@@ -0,0 +1,418 @@
+// Package bundle packs locked artifacts into a single archive for installing into
+// environments without network access.
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/download"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+const (
+	// FormatVersion is the bundle layout version written to the manifest
+	FormatVersion = 1
+
+	ManifestFile = "manifest.json"
+	LockFile     = "deps-lock.yaml"
+	ConfigFile   = "deps.yaml"
+	artifactsDir = "artifacts"
+)
+
+// Artifact is a locked download packed into the bundle
+type Artifact struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Platform string `json:"platform"`
+	URL      string `json:"url"`
+	Checksum string `json:"checksum"`
+	Size     int64  `json:"size"`
+	Path     string `json:"path"` // slash-separated path inside the bundle
+}
+
+// Manifest lists the contents of a bundle
+type Manifest struct {
+	Version   int        `json:"version"`
+	Created   time.Time  `json:"created"`
+	Platforms []string   `json:"platforms"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Options selects what Create packs and how artifacts are downloaded
+type Options struct {
+	Platforms   []string // Platforms to pack (default: every platform in the lock file)
+	Packages    []string // Packages to pack (default: every locked package)
+	CacheDir    string
+	Timeout     time.Duration
+	LockTimeout time.Duration
+}
+
+// Bundle is an extracted bundle whose artifacts have been verified
+type Bundle struct {
+	Dir      string
+	Manifest *Manifest
+	Lock     *types.LockFile
+	Config   *types.DepsConfig
+
+	artifacts map[string]string // url -> local path
+}
+
+// ArtifactPath returns the extracted file for a locked download URL.
+func (b *Bundle) ArtifactPath(url string) (string, bool) {
+	p, ok := b.artifacts[url]
+	return p, ok
+}
+
+// Create downloads every locked artifact for the selected platforms, verifying it against the
+// locked checksum, and writes them to the archive at dest together with the lock file, the
+// registry entries of the bundled packages and a manifest. Archives named *.gz or *.tgz are gzipped.
+func Create(dest string, lockFile *types.LockFile, depsConfig *types.DepsConfig, opts Options, t *task.Task) (*Manifest, error) {
+	names, err := selectPackages(lockFile, opts.Packages)
+	if err != nil {
+		return nil, err
+	}
+	platforms := opts.Platforms
+	if len(platforms) == 0 {
+		platforms = lockedPlatforms(lockFile, names)
+	}
+	sort.Strings(platforms)
+
+	staging, err := os.MkdirTemp(filepath.Dir(dest), ".deps-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	manifest := &Manifest{Version: FormatVersion, Created: time.Now(), Platforms: platforms}
+	bundledLock := &types.LockFile{
+		Version:         lockFile.Version,
+		Generated:       lockFile.Generated,
+		CurrentPlatform: lockFile.CurrentPlatform,
+		Dependencies:    make(map[string]types.LockEntry, len(names)),
+	}
+	bundledConfig := &types.DepsConfig{
+		Dependencies: make(map[string]string, len(names)),
+		Registry:     make(map[string]types.Package, len(names)),
+	}
+
+	var missing []string
+	for _, name := range names {
+		entry := lockFile.Dependencies[name]
+		pkg, ok := depsConfig.Registry[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is locked but not in the registry", name)
+		}
+
+		bundledEntry := types.LockEntry{Version: entry.Version, Platforms: make(map[string]types.PlatformEntry, len(platforms))}
+		for _, plat := range platforms {
+			locked, ok := entry.Platforms[plat]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (%s)", name, plat))
+				continue
+			}
+			if locked.Checksum == "" {
+				return nil, fmt.Errorf("%s: lock entry for %s has no checksum, re-run 'deps lock'", name, plat)
+			}
+			if oci.IsImageURL(locked.URL) {
+				return nil, fmt.Errorf("%s is pulled from the image %s, which cannot be bundled, publish it as an OCI artifact instead",
+					name, locked.URL)
+			}
+
+			artifact := Artifact{
+				Name:     name,
+				Version:  entry.Version,
+				Platform: plat,
+				URL:      locked.URL,
+				Checksum: locked.Checksum,
+				Path:     path.Join(artifactsDir, plat, name, artifactFileName(name, locked.URL)),
+			}
+			local := filepath.Join(staging, filepath.FromSlash(artifact.Path))
+			if t != nil {
+				t.SetDescription(fmt.Sprintf("Downloading %s@%s (%s)", name, entry.Version, plat))
+			}
+			if err := download.Download(locked.URL, local, t,
+				download.WithChecksum(locked.Checksum),
+				download.WithCacheDir(opts.CacheDir),
+				download.WithTimeout(opts.Timeout),
+				download.WithLockTimeout(opts.LockTimeout),
+			); err != nil {
+				return nil, fmt.Errorf("failed to download %s for %s: %w", name, plat, err)
+			}
+			info, err := os.Stat(local)
+			if err != nil {
+				return nil, err
+			}
+			artifact.Size = info.Size()
+
+			manifest.Artifacts = append(manifest.Artifacts, artifact)
+			bundledEntry.Platforms[plat] = locked
+		}
+
+		bundledLock.Dependencies[name] = bundledEntry
+		bundledConfig.Dependencies[name] = entry.Version
+		bundledConfig.Registry[name] = pkg
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("not locked: %s, run 'deps lock --platforms %s' first",
+			strings.Join(missing, ", "), strings.Join(platforms, ","))
+	}
+
+	if err := config.SaveLockFile(bundledLock, filepath.Join(staging, LockFile)); err != nil {
+		return nil, err
+	}
+	if err := config.SaveDepsConfig(bundledConfig, filepath.Join(staging, ConfigFile)); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, ManifestFile), data, 0644); err != nil {
+		return nil, err
+	}
+
+	files := []string{ManifestFile, LockFile, ConfigFile}
+	for _, a := range manifest.Artifacts {
+		files = append(files, a.Path)
+	}
+	if err := writeArchive(dest, staging, files); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Open extracts the bundle archive at src into dir and verifies every artifact against its
+// locked checksum.
+func Open(src, dir string) (*Bundle, error) {
+	if err := extractArchive(src, dir); err != nil {
+		return nil, fmt.Errorf("failed to extract bundle %s: %w", src, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a deps bundle: %w", src, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("bundle format %d is newer than supported (%d), upgrade deps", manifest.Version, FormatVersion)
+	}
+
+	lockFile, err := config.LoadLockFile(filepath.Join(dir, LockFile))
+	if err != nil {
+		return nil, err
+	}
+	depsConfig, err := config.LoadDepsConfig(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Dir: dir, Manifest: &manifest, Lock: lockFile, Config: depsConfig, artifacts: map[string]string{}}
+	for _, a := range manifest.Artifacts {
+		local, err := safeJoin(dir, a.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := checksum.VerifyChecksum(local, a.Checksum); err != nil {
+			return nil, fmt.Errorf("bundled %s (%s) failed verification: %w", a.Name, a.Platform, err)
+		}
+		b.artifacts[a.URL] = local
+	}
+	return b, nil
+}
+
+func selectPackages(lockFile *types.LockFile, packages []string) ([]string, error) {
+	if len(packages) == 0 {
+		names := make([]string, 0, len(lockFile.Dependencies))
+		for name := range lockFile.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	names := append([]string{}, packages...)
+	for _, name := range names {
+		if _, ok := lockFile.Dependencies[name]; !ok {
+			return nil, fmt.Errorf("%s: not found in lock file, run 'deps lock' first", name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// lockedPlatforms returns every platform any of names is locked for
+func lockedPlatforms(lockFile *types.LockFile, names []string) []string {
+	seen := map[string]bool{}
+	var platforms []string
+	for _, name := range names {
+		for plat := range lockFile.Dependencies[name].Platforms {
+			if !seen[plat] {
+				seen[plat] = true
+				platforms = append(platforms, plat)
+			}
+		}
+	}
+	return platforms
+}
+
+// artifactFileName keeps the file name (and so the extension) of the download URL
+func artifactFileName(name, rawURL string) string {
+	if oci.IsURL(rawURL) {
+		// Blobs are named by their digest only
+		return name
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "" && base != "/" && base != "." {
+			return base
+		}
+	}
+	return name
+}
+
+func isGzip(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// writeArchive writes files (slash-separated, relative to root) to a tar archive at dest
+func writeArchive(dest, root string, files []string) (err error) {
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer func() {
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if isGzip(dest) {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	for _, name := range files {
+		if err := addFile(tw, filepath.Join(root, filepath.FromSlash(name)), name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func addFile(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractArchive extracts the regular files of a tar (optionally gzipped) archive into dir
+func extractArchive(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dest, err := safeJoin(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, tr)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// safeJoin joins a slash-separated archive path onto dir, rejecting paths that escape it
+func safeJoin(dir, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid path %q in bundle", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,148 @@
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func serveArtifacts(t *testing.T, files map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sha(content string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+}
+
+func testLock(serverURL string) (*types.LockFile, *types.DepsConfig) {
+	lock := &types.LockFile{
+		Version: "1.0",
+		Dependencies: map[string]types.LockEntry{
+			"jq": {Version: "1.7.1", Platforms: map[string]types.PlatformEntry{
+				"linux-amd64":  {URL: serverURL + "/jq-linux-amd64", Checksum: sha("jq linux")},
+				"darwin-arm64": {URL: serverURL + "/jq-macos-arm64", Checksum: sha("jq darwin")},
+			}},
+			"yq": {Version: "4.44.1", Platforms: map[string]types.PlatformEntry{
+				"linux-amd64": {URL: serverURL + "/yq_linux_amd64.tar.gz", Checksum: sha("yq linux"), Archive: true},
+			}},
+		},
+	}
+	cfg := &types.DepsConfig{Registry: map[string]types.Package{
+		"jq": {Name: "jq", Manager: "github_release", Repo: "jqlang/jq"},
+		"yq": {Name: "yq", Manager: "github_release", Repo: "mikefarah/yq"},
+	}}
+	return lock, cfg
+}
+
+func TestCreateAndOpen(t *testing.T) {
+	server := serveArtifacts(t, map[string]string{
+		"/jq-linux-amd64":        "jq linux",
+		"/jq-macos-arm64":        "jq darwin",
+		"/yq_linux_amd64.tar.gz": "yq linux",
+	})
+	lock, cfg := testLock(server.URL)
+
+	archive := filepath.Join(t.TempDir(), "tools.tar.gz")
+	manifest, err := Create(archive, lock, cfg, Options{Platforms: []string{"linux-amd64"}}, nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(manifest.Artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts for linux-amd64, got %d", len(manifest.Artifacts))
+	}
+
+	b, err := Open(archive, t.TempDir())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, ok := b.Lock.Dependencies["jq"].Platforms["darwin-arm64"]; ok {
+		t.Fatalf("expected the bundled lock file to contain only the bundled platforms")
+	}
+	if b.Config.Dependencies["yq"] != "4.44.1" || b.Config.Registry["yq"].Repo != "mikefarah/yq" {
+		t.Fatalf("expected the registry of bundled packages, got %+v", b.Config)
+	}
+
+	local, ok := b.ArtifactPath(server.URL + "/yq_linux_amd64.tar.gz")
+	if !ok {
+		t.Fatalf("expected the yq artifact in the bundle")
+	}
+	if filepath.Base(local) != "yq_linux_amd64.tar.gz" {
+		t.Fatalf("expected the artifact to keep its file name, got %s", local)
+	}
+	if data, err := os.ReadFile(local); err != nil || string(data) != "yq linux" {
+		t.Fatalf("unexpected artifact content %q: %v", data, err)
+	}
+}
+
+func TestCreateVerifiesChecksums(t *testing.T) {
+	server := serveArtifacts(t, map[string]string{"/jq-linux-amd64": "tampered"})
+	lock, cfg := testLock(server.URL)
+
+	_, err := Create(filepath.Join(t.TempDir(), "tools.tar"), lock, cfg, Options{Platforms: []string{"linux-amd64"}, Packages: []string{"jq"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+}
+
+func TestCreateRequiresLockedPlatforms(t *testing.T) {
+	lock, cfg := testLock("https://example.com")
+
+	_, err := Create(filepath.Join(t.TempDir(), "tools.tar"), lock, cfg, Options{Platforms: []string{"windows-amd64"}, Packages: []string{"jq"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "jq (windows-amd64)") || !strings.Contains(err.Error(), "deps lock --platforms windows-amd64") {
+		t.Fatalf("expected the unlocked platform to be reported, got %v", err)
+	}
+}
+
+func TestOpenRejectsPathTraversal(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	content := []byte("evil")
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	_ = f.Close()
+
+	dir := filepath.Join(t.TempDir(), "bundle")
+	if _, err := Open(archive, dir); err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Fatalf("expected path traversal to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written outside the bundle directory")
+	}
+}
+
+func TestCreateRejectsImages(t *testing.T) {
+	lock, cfg := testLock("https://example.com")
+	lock.Dependencies["crane"] = types.LockEntry{Version: "0.20.2", Platforms: map[string]types.PlatformEntry{
+		"linux-amd64": {URL: "docker://gcr.io/go-containerregistry/crane@" + sha("manifest"), Checksum: sha("manifest")},
+	}}
+	cfg.Registry["crane"] = types.Package{Name: "crane", Manager: "docker"}
+
+	_, err := Create(filepath.Join(t.TempDir(), "tools.tar"), lock, cfg, Options{Platforms: []string{"linux-amd64"}, Packages: []string{"crane"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot be bundled") {
+		t.Fatalf("expected images to be rejected, got %v", err)
+	}
+}
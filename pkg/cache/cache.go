@@ -2,17 +2,55 @@ package cache
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/flanksource/deps/pkg/filelock"
 	"github.com/flanksource/deps/pkg/utils"
 )
 
-// GetCachePath generates a cache path for a URL and filename
-// Format: {cacheDir}/{url-hash}/{filename}
+// MaxSizeEnv caps the total size of cached artifacts, e.g. "10GB", overriding settings.cache.max_size
+const MaxSizeEnv = "DEPS_CACHE_MAX_SIZE"
+
+// configuredMaxSize is the limit set by settings.cache.max_size
+var configuredMaxSize atomic.Int64
+
+// Entry maps a URL to the content-addressed artifact downloaded from it
+type Entry struct {
+	URL      string    `json:"url"`
+	Filename string    `json:"filename"`
+	Digest   string    `json:"digest"` // sha256:<hex> of the artifact
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+
+	IndexPath string    `json:"-"`
+	BlobPath  string    `json:"-"`
+	LastUsed  time.Time `json:"-"` // When the artifact was last used, from its modification time
+}
+
+// The cache stores each artifact once under its SHA-256 digest, with a small index entry per URL:
+//
+//	{cacheDir}/blobs/sha256/{hex}
+//	{cacheDir}/urls/{url-hash}/{filename}.json
+//
+// so the same artifact fetched through a mirror and the origin is only stored once.
+const (
+	blobsDir   = "blobs"
+	urlsDir    = "urls"
+	partialDir = "partial"
+)
+
+// GetCachePath returns the index entry for a URL and filename
+// Format: {cacheDir}/urls/{url-hash}/{filename}.json
 func GetCachePath(cacheDir, url, filename string) string {
 	if cacheDir == "" {
 		return ""
@@ -20,46 +58,360 @@ func GetCachePath(cacheDir, url, filename string) string {
 
 	// Create a hash of the URL to avoid path length issues
 	urlHash := hashURL(url)
-	return filepath.Join(cacheDir, urlHash, filename)
+	return filepath.Join(cacheDir, urlsDir, urlHash, filename+".json")
+}
+
+// PartialPath returns where an interrupted download of a URL is kept until it is resumed
+// Format: {cacheDir}/partial/{url-hash}.part
+func PartialPath(cacheDir, url string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, partialDir, hashURL(url)+".part")
+}
+
+// BlobPath returns where the artifact with a sha256 digest ("sha256:<hex>" or "<hex>") is stored
+func BlobPath(cacheDir, digest string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, blobsDir, "sha256", strings.TrimPrefix(strings.ToLower(digest), "sha256:"))
 }
 
-// IsCached checks if a file exists in the cache
-// Returns the cache path and true if cached, empty string and false otherwise
+// IsCached checks if the artifact for a URL is in the cache with the size it was saved with
+// Returns the path of the cached artifact and true if cached, empty string and false otherwise.
+// Only the size is checked: callers verify the artifact against the checksum they expect, or
+// with VerifyBlob when they have none.
 func IsCached(cacheDir, url, filename string) (string, bool) {
 	if cacheDir == "" {
 		return "", false
 	}
 
-	cachePath := GetCachePath(cacheDir, url, filename)
-	if _, err := os.Stat(cachePath); err == nil {
-		return cachePath, true
+	indexPath := GetCachePath(cacheDir, url, filename)
+	entry, err := readEntry(indexPath)
+	if err != nil {
+		// The URL may have been saved under another filename, e.g. by 'deps cache import'
+		if found, ok := Lookup(cacheDir, url); ok {
+			entry, indexPath = found, found.IndexPath
+		} else if !migrateLegacy(cacheDir, url, filename) {
+			return "", false
+		} else if entry, err = readEntry(indexPath); err != nil {
+			return "", false
+		}
+	}
+
+	blobPath, ok := cachedBlob(cacheDir, entry.Digest, entry.Size)
+	if !ok {
+		_ = os.Remove(indexPath)
+		return "", false
 	}
-	return "", false
+	return blobPath, true
+}
+
+// IsCachedDigest checks if an artifact with a sha256 digest is in the cache, whichever URL it was
+// downloaded from. It is not hashed again, as callers verify it against the digest they looked up.
+func IsCachedDigest(cacheDir, digest string) (string, bool) {
+	return cachedBlob(cacheDir, digest, -1)
 }
 
-// SaveToCache copies a file to the cache
+// cachedBlob returns the artifact stored under digest, removing it when its size is not the
+// expected size (when known), as it can no longer match its digest
+func cachedBlob(cacheDir, digest string, size int64) (string, bool) {
+	if cacheDir == "" || !isSHA256(digest) {
+		return "", false
+	}
+
+	blobPath := BlobPath(cacheDir, digest)
+	info, err := os.Stat(blobPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	if size >= 0 && info.Size() != size {
+		_ = os.Remove(blobPath)
+		return "", false
+	}
+
+	// The modification time records when the artifact was last used, for eviction
+	now := time.Now()
+	_ = os.Chtimes(blobPath, now, now)
+	return blobPath, true
+}
+
+// VerifyDigest checks that the artifact with a sha256 digest is in the cache and still matches
+// it, re-hashing its content. An artifact that no longer matches is removed.
+func VerifyDigest(cacheDir, digest string) (string, bool) {
+	if cacheDir == "" || !isSHA256(digest) {
+		return "", false
+	}
+	blobPath := BlobPath(cacheDir, digest)
+	return blobPath, VerifyBlob(blobPath)
+}
+
+// VerifyBlob re-hashes an artifact returned by IsCached against the digest it is stored under,
+// for callers without a checksum of their own. An artifact that no longer matches is removed.
+func VerifyBlob(blobPath string) bool {
+	actual, _, err := hashFile(blobPath)
+	if err != nil {
+		return false
+	}
+	if actual != strings.ToLower(filepath.Base(blobPath)) {
+		_ = os.Remove(blobPath)
+		return false
+	}
+	return true
+}
+
+// Lock takes the cross-process lock for a cache entry, so concurrent downloads of the same URL
+// do not interleave writes to it. The returned function releases the lock.
+func Lock(cacheDir, url, filename string, timeout time.Duration, waiting func(*filelock.Holder)) (func(), error) {
+	if cacheDir == "" {
+		return func() {}, nil
+	}
+	return filelock.Acquire(GetCachePath(cacheDir, url, filename)+".lock", timeout, waiting)
+}
+
+// SaveToCache stores a file under its digest and indexes it for the URL, then evicts the least
+// recently used artifacts beyond the size limit
 func SaveToCache(cacheDir, url, sourcePath string) error {
 	if cacheDir == "" {
 		return nil // Caching disabled
 	}
 
+	digest, size, err := storeBlob(cacheDir, sourcePath)
+	if err != nil {
+		return err
+	}
+
 	filename := filepath.Base(sourcePath)
-	cachePath := GetCachePath(cacheDir, url, filename)
+	entry := Entry{URL: url, Filename: filename, Digest: "sha256:" + digest, Size: size, Created: time.Now()}
+	if err := writeEntry(GetCachePath(cacheDir, url, filename), entry); err != nil {
+		return fmt.Errorf("failed to index cached file: %w", err)
+	}
+
+	if maxSize := MaxSize(); maxSize > 0 {
+		if err := Evict(cacheDir, maxSize, digest); err != nil {
+			return fmt.Errorf("failed to evict cached files: %w", err)
+		}
+	}
+	return nil
+}
+
+// storeBlob copies a file into the blob store through a temp file and rename, so concurrent
+// writers and readers never see a partial artifact
+func storeBlob(cacheDir, sourcePath string) (string, int64, error) {
+	dir := filepath.Join(cacheDir, blobsDir, "sha256")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to copy to cache: %w", err)
+	}
+	defer func() { _ = src.Close() }()
 
-	// Create cache directory
-	cacheSubDir := filepath.Dir(cachePath)
-	if err := os.MkdirAll(cacheSubDir, 0755); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to copy to cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to copy to cache: %w", err)
+	}
+
+	digest := fmt.Sprintf("%x", hasher.Sum(nil))
+	blobPath := BlobPath(cacheDir, digest)
+	if _, ok := IsCachedDigest(cacheDir, digest); ok {
+		return digest, size, nil
+	}
+	if err := os.Rename(tmp.Name(), blobPath); err != nil {
+		return "", 0, fmt.Errorf("failed to copy to cache: %w", err)
+	}
+	return digest, size, nil
+}
+
+// migrateLegacy moves an artifact from the previous {cacheDir}/{url-hash}/{filename} layout into
+// the blob store, reporting whether there was one
+func migrateLegacy(cacheDir, url, filename string) bool {
+	legacyDir := filepath.Join(cacheDir, hashURL(url))
+	legacyPath := filepath.Join(legacyDir, filename)
+	if info, err := os.Stat(legacyPath); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if err := SaveToCache(cacheDir, url, legacyPath); err != nil {
+		return false
+	}
+	_ = os.Remove(legacyPath)
+	_ = os.Remove(legacyDir) // Only succeeds once the directory is empty
+	return true
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid cache entry %s: %w", path, err)
 	}
+	return &entry, nil
+}
 
-	// Copy file to cache
-	if err := utils.CopyFile(sourcePath, cachePath); err != nil {
-		return fmt.Errorf("failed to copy to cache: %w", err)
+func writeEntry(path string, entry Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
 	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
+// SetMaxSize sets the cache size limit from settings.cache.max_size, e.g. "10GB". An empty value
+// means no limit.
+func SetMaxSize(value string) error {
+	size, err := ParseSize(value)
+	if err != nil {
+		return fmt.Errorf("invalid settings.cache.max_size: %w", err)
+	}
+	configuredMaxSize.Store(size)
 	return nil
 }
 
+// MaxSize returns the cache size limit in bytes from DEPS_CACHE_MAX_SIZE or else
+// settings.cache.max_size, or 0 for no limit
+func MaxSize() int64 {
+	if value := os.Getenv(MaxSizeEnv); value != "" {
+		if size, err := ParseSize(value); err == nil {
+			return size
+		}
+	}
+	return configuredMaxSize.Load()
+}
+
+// ParseSize parses a size such as "512MB", "10GB" or "1073741824" (bytes), using 1024-based units
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	scale := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			scale = unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(scale)), nil
+}
+
+// Evict removes the least recently used artifacts until the blob store fits in maxSize bytes.
+// Artifacts listed in keep are never removed. Index entries of evicted artifacts are dropped
+// the next time their URL is looked up.
+func Evict(cacheDir string, maxSize int64, keep ...string) error {
+	dir := filepath.Join(cacheDir, blobsDir, "sha256")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	type blob struct {
+		path     string
+		size     int64
+		lastUsed time.Time
+	}
+	var blobs []blob
+	var total int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		if slices.Contains(keep, e.Name()) {
+			continue
+		}
+		blobs = append(blobs, blob{path: filepath.Join(dir, e.Name()), size: info.Size(), lastUsed: info.ModTime()})
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].lastUsed.Before(blobs[j].lastUsed) })
+	for _, b := range blobs {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= b.size
+	}
+	return nil
+}
+
+func isSHA256(digest string) bool {
+	hex := strings.TrimPrefix(strings.ToLower(digest), "sha256:")
+	if len(hex) != 64 {
+		return false
+	}
+	for _, c := range hex {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), size, nil
+}
+
 // hashURL creates a short hash of a URL for directory naming
 func hashURL(url string) string {
 	// Normalize URL by removing protocol and trailing slashes
@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGetCachePath(t *testing.T) {
@@ -52,12 +55,12 @@ func TestIsCached(t *testing.T) {
 	// Create a cached file
 	url := "https://example.com/test.tar.gz"
 	filename := "test.tar.gz"
-	cachePath := GetCachePath(tmpDir, url, filename)
-	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
-		t.Fatalf("Failed to create cache dir: %v", err)
+	srcFile := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(srcFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
 	}
-	if err := os.WriteFile(cachePath, []byte("test content"), 0644); err != nil {
-		t.Fatalf("Failed to create cache file: %v", err)
+	if err := SaveToCache(tmpDir, url, srcFile); err != nil {
+		t.Fatalf("Failed to cache file: %v", err)
 	}
 
 	tests := []struct {
@@ -145,11 +148,14 @@ func TestSaveToCache(t *testing.T) {
 			}
 
 			if !tt.wantError && tt.cacheDir != "" {
-				// Verify file was saved
+				// Verify file was saved under its digest
 				filename := filepath.Base(tt.srcPath)
-				cachePath := GetCachePath(tt.cacheDir, tt.url, filename)
-				if _, err := os.Stat(cachePath); os.IsNotExist(err) {
-					t.Errorf("SaveToCache() file not saved to %q", cachePath)
+				cachePath, cached := IsCached(tt.cacheDir, tt.url, filename)
+				if !cached {
+					t.Fatalf("SaveToCache() file not cached for %q", tt.url)
+				}
+				if want := BlobPath(tt.cacheDir, fmt.Sprintf("%x", sha256.Sum256(content))); cachePath != want {
+					t.Errorf("IsCached() path = %q, want %q", cachePath, want)
 				}
 
 				// Verify content matches
@@ -234,3 +240,263 @@ func TestHashURL(t *testing.T) {
 		}
 	}
 }
+
+func TestLockSerializesCacheEntry(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://example.com/file.tar.gz"
+
+	unlock, err := Lock(cacheDir, url, "file.tar.gz", time.Second, nil)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer unlock()
+
+	if _, err := Lock(cacheDir, url, "file.tar.gz", 100*time.Millisecond, nil); err == nil {
+		t.Error("Lock() on a held cache entry should time out")
+	}
+	other, err := Lock(cacheDir, "https://example.com/other.tar.gz", "other.tar.gz", 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Lock() on a different entry error = %v", err)
+	}
+	other()
+
+	// The lock file must not be mistaken for the cached file
+	if _, cached := IsCached(cacheDir, url, "file.tar.gz"); cached {
+		t.Error("IsCached() = true for an entry that was only locked")
+	}
+}
+
+func writeSource(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	return path
+}
+
+func TestSaveToCacheStoresArtifactOnce(t *testing.T) {
+	cacheDir := t.TempDir()
+	src := writeSource(t, "tool.tar.gz", "payload")
+
+	origin := "https://github.com/org/tool/releases/download/v1/tool.tar.gz"
+	mirror := "https://artifactory.example.com/github/org/tool/releases/download/v1/tool.tar.gz"
+	for _, url := range []string{origin, mirror} {
+		if err := SaveToCache(cacheDir, url, src); err != nil {
+			t.Fatalf("SaveToCache(%s) error = %v", url, err)
+		}
+	}
+
+	originPath, _ := IsCached(cacheDir, origin, "tool.tar.gz")
+	mirrorPath, _ := IsCached(cacheDir, mirror, "tool.tar.gz")
+	if originPath == "" || originPath != mirrorPath {
+		t.Fatalf("expected both URLs to share one artifact, got %q and %q", originPath, mirrorPath)
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload")))
+	if path, ok := IsCachedDigest(cacheDir, digest); !ok || path != originPath {
+		t.Fatalf("IsCachedDigest(%s) = %q, %v", digest, path, ok)
+	}
+	blobs, _ := os.ReadDir(filepath.Join(cacheDir, "blobs", "sha256"))
+	if len(blobs) != 1 {
+		t.Fatalf("expected a single stored artifact, got %d", len(blobs))
+	}
+}
+
+func TestIsCachedRejectsCorruptedArtifact(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://example.com/tool.tar.gz"
+	if err := SaveToCache(cacheDir, url, writeSource(t, "tool.tar.gz", "payload")); err != nil {
+		t.Fatal(err)
+	}
+	cachePath, _ := IsCached(cacheDir, url, "tool.tar.gz")
+	if err := os.WriteFile(cachePath, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, cached := IsCached(cacheDir, url, "tool.tar.gz"); cached {
+		t.Fatal("IsCached() = true for an artifact that no longer matches its digest")
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupted artifact to be removed, stat err = %v", err)
+	}
+}
+
+func TestVerifyDigestRejectsTamperedArtifact(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://example.com/tool.tar.gz"
+	if err := SaveToCache(cacheDir, url, writeSource(t, "tool.tar.gz", "payload")); err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload")))
+	cachePath, _ := IsCachedDigest(cacheDir, digest)
+	// Same size, so only re-hashing notices
+	if err := os.WriteFile(cachePath, []byte("PAYLOAD"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, cached := IsCached(cacheDir, url, "tool.tar.gz"); !cached {
+		t.Fatal("IsCached() should not re-hash artifacts of the expected size")
+	}
+	if VerifyBlob(cachePath) {
+		t.Fatal("VerifyBlob() = true for an artifact that no longer matches its digest")
+	}
+	if _, ok := VerifyDigest(cacheDir, digest); ok {
+		t.Fatal("VerifyDigest() = true for an artifact that no longer matches its digest")
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupted artifact to be removed, stat err = %v", err)
+	}
+}
+
+func TestIsCachedMigratesLegacyLayout(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://example.com/tool.tar.gz"
+	legacyPath := filepath.Join(cacheDir, hashURL(url), "tool.tar.gz")
+	if err := os.MkdirAll(filepath.Dir(legacyPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyPath, []byte("payload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath, cached := IsCached(cacheDir, url, "tool.tar.gz")
+	if !cached {
+		t.Fatal("IsCached() = false for an artifact in the legacy layout")
+	}
+	if content, _ := os.ReadFile(cachePath); string(content) != "payload" {
+		t.Errorf("migrated content = %q", content)
+	}
+	if _, err := os.Stat(filepath.Dir(legacyPath)); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy directory to be removed, stat err = %v", err)
+	}
+}
+
+func TestSaveToCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv(MaxSizeEnv, "10B")
+
+	old := "https://example.com/old.bin"
+	recent := "https://example.com/recent.bin"
+	if err := SaveToCache(cacheDir, old, writeSource(t, "old.bin", "0123456")); err != nil {
+		t.Fatal(err)
+	}
+	oldPath, _ := IsCached(cacheDir, old, "old.bin")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(oldPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveToCache(cacheDir, recent, writeSource(t, "recent.bin", "abcdef")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, cached := IsCached(cacheDir, old, "old.bin"); cached {
+		t.Error("expected the least recently used artifact to be evicted")
+	}
+	if _, cached := IsCached(cacheDir, recent, "recent.bin"); !cached {
+		t.Error("expected the artifact just saved to be kept")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	t.Setenv(MaxSizeEnv, "")
+	t.Cleanup(func() { _ = SetMaxSize("") })
+
+	if err := SetMaxSize("1GB"); err != nil {
+		t.Fatal(err)
+	}
+	if got := MaxSize(); got != 1<<30 {
+		t.Errorf("MaxSize() = %d from settings.cache.max_size, want %d", got, 1<<30)
+	}
+	t.Setenv(MaxSizeEnv, "10MB")
+	if got := MaxSize(); got != 10<<20 {
+		t.Errorf("MaxSize() = %d, want %s to override the setting", got, MaxSizeEnv)
+	}
+	if err := SetMaxSize("lots"); err == nil {
+		t.Error("SetMaxSize() should reject an invalid size")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"1024":  1024,
+		"10B":   10,
+		"512MB": 512 << 20,
+		"1.5gb": 3 << 29,
+		"2 G":   2 << 30,
+		"100KB": 100 << 10,
+	}
+	for value, want := range tests {
+		got, err := ParseSize(value)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("ParseSize(\"lots\") should fail")
+	}
+}
+
+func TestListAndLookup(t *testing.T) {
+	cacheDir := t.TempDir()
+	url := "https://example.com/releases/tool.tar.gz"
+	src := writeSource(t, "tool.tar.gz", "payload")
+	if err := SaveToCache(cacheDir, url, src); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := List(cacheDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List() = %v, %v; want one entry", entries, err)
+	}
+	if entries[0].URL != url || entries[0].Size != int64(len("payload")) || entries[0].LastUsed.IsZero() {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	// Installs look the URL up under their own download filename
+	if path, cached := IsCached(cacheDir, url, "deps-tool-v1.tar.gz"); !cached || path != entries[0].BlobPath {
+		t.Errorf("IsCached() with another filename = %q, %v", path, cached)
+	}
+	if _, found := Lookup(cacheDir, "https://example.com/releases/other.tar.gz"); found {
+		t.Error("Lookup() found an entry for a URL that was never cached")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	cacheDir := t.TempDir()
+	stale := "https://example.com/stale.bin"
+	locked := "https://example.com/locked.bin"
+	fresh := "https://example.com/fresh.bin"
+	for url, content := range map[string]string{stale: "stale", locked: "locked", fresh: "fresh"} {
+		if err := SaveToCache(cacheDir, url, writeSource(t, filepath.Base(url), content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	for _, url := range []string{stale, locked} {
+		entry, _ := Lookup(cacheDir, url)
+		if err := os.Chtimes(entry.BlobPath, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Prune(cacheDir, 24*time.Hour, func(e Entry) bool { return e.URL == locked })
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].URL != stale || result.Freed != int64(len("stale")) {
+		t.Fatalf("Prune() = %+v, want only %s removed", result, stale)
+	}
+	if _, found := Lookup(cacheDir, stale); found {
+		t.Error("expected the pruned entry to be gone")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "urls", hashURL(stale))); !os.IsNotExist(err) {
+		t.Errorf("expected the pruned URL directory to be removed, stat err = %v", err)
+	}
+	for _, url := range []string{locked, fresh} {
+		if _, found := Lookup(cacheDir, url); !found {
+			t.Errorf("expected %s to be kept", url)
+		}
+	}
+}
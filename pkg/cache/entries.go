@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// List returns the index entries of the cache whose artifacts are still present, sorted by URL.
+// BlobPath and LastUsed are filled in from the artifact.
+func List(cacheDir string) ([]Entry, error) {
+	if cacheDir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(cacheDir, urlsDir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if err != nil {
+			continue
+		}
+		entry.IndexPath = path
+		entry.BlobPath = BlobPath(cacheDir, entry.Digest)
+		info, err := os.Stat(entry.BlobPath)
+		if err != nil {
+			continue
+		}
+		entry.LastUsed = info.ModTime()
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries, nil
+}
+
+// Lookup returns the index entry for a URL, whatever filename it was saved under
+func Lookup(cacheDir, url string) (*Entry, bool) {
+	if cacheDir == "" {
+		return nil, false
+	}
+	paths, _ := filepath.Glob(filepath.Join(cacheDir, urlsDir, hashURL(url), "*.json"))
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if err != nil || entry.URL != url {
+			continue
+		}
+		entry.IndexPath = path
+		entry.BlobPath = BlobPath(cacheDir, entry.Digest)
+		if info, err := os.Stat(entry.BlobPath); err == nil {
+			entry.LastUsed = info.ModTime()
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// PruneResult reports what Prune removed
+type PruneResult struct {
+	Removed []Entry
+	Freed   int64
+}
+
+// Prune removes artifacts not used within olderThan, except those of entries for which keep
+// returns true, together with index entries left without an artifact, abandoned temp files and
+// interrupted downloads older than olderThan.
+func Prune(cacheDir string, olderThan time.Duration, keep func(Entry) bool) (*PruneResult, error) {
+	result := &PruneResult{}
+	if cacheDir == "" {
+		return result, nil
+	}
+	entries, err := List(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := map[string]bool{}
+	if keep != nil {
+		for _, entry := range entries {
+			if keep(entry) {
+				kept[entry.Digest] = true
+			}
+		}
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := map[string]bool{}
+	for _, entry := range entries {
+		if kept[entry.Digest] || !entry.LastUsed.Before(cutoff) {
+			continue
+		}
+		if !removed[entry.Digest] {
+			if err := os.Remove(entry.BlobPath); err != nil && !os.IsNotExist(err) {
+				return result, err
+			}
+			removed[entry.Digest] = true
+			result.Freed += entry.Size
+		}
+		result.Removed = append(result.Removed, entry)
+	}
+
+	// Temp files of writers that were killed mid-copy
+	temps, _ := filepath.Glob(filepath.Join(cacheDir, blobsDir, "sha256", ".tmp-*"))
+	for _, tmp := range temps {
+		if info, err := os.Stat(tmp); err == nil && info.ModTime().Before(time.Now().Add(-time.Hour)) {
+			_ = os.Remove(tmp)
+		}
+	}
+	// Interrupted downloads that were never resumed
+	partials, _ := filepath.Glob(filepath.Join(cacheDir, partialDir, "*"))
+	for _, partial := range partials {
+		if info, err := os.Stat(partial); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(partial)
+		}
+	}
+	return result, removeDangling(cacheDir)
+}
+
+// removeDangling deletes index entries whose artifact is gone, and URL directories left empty
+func removeDangling(cacheDir string) error {
+	dirs, err := os.ReadDir(filepath.Join(cacheDir, urlsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, dir := range dirs {
+		dirPath := filepath.Join(cacheDir, urlsDir, dir.Name())
+		paths, _ := filepath.Glob(filepath.Join(dirPath, "*.json"))
+		for _, path := range paths {
+			entry, err := readEntry(path)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(BlobPath(cacheDir, entry.Digest)); os.IsNotExist(err) {
+				_ = os.Remove(path)
+			}
+		}
+		_ = os.Remove(dirPath) // Only succeeds once the directory is empty
+	}
+	return nil
+}
@@ -105,6 +105,12 @@ func applyConfigPostProcessing(config *types.DepsConfig) {
 		}
 	}
 
+	if config.Settings.AppDir != "" && !filepath.IsAbs(config.Settings.AppDir) {
+		if abs, err := filepath.Abs(config.Settings.AppDir); err == nil {
+			config.Settings.AppDir = abs
+		}
+	}
+
 	if config.Settings.CacheDir != "" && !filepath.IsAbs(config.Settings.CacheDir) {
 		if config.Settings.CacheDir[0] == '~' {
 			if home, err := os.UserHomeDir(); err == nil {
@@ -197,46 +203,140 @@ func FindConfigFile() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if path := findUpwards(dir, DepsFile); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("deps.yaml not found in current directory or any parent directory")
+}
+
+// FindLockFile searches for deps-lock.yaml in the current and parent directories
+func FindLockFile() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if path := findUpwards(dir, LockFile); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("deps-lock.yaml not found in current directory or any parent directory")
+}
 
+// findUpwards returns the path of name in dir or its nearest parent, or "" if none has it
+func findUpwards(dir, name string) string {
 	for {
-		configPath := filepath.Join(dir, DepsFile)
-		if _, err := os.Stat(configPath); err == nil {
-			return configPath, nil
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
 
 		parent := filepath.Dir(dir)
 		if parent == dir {
 			// Reached root directory
-			break
+			return ""
 		}
 		dir = parent
 	}
+}
 
-	return "", fmt.Errorf("deps.yaml not found in current directory or any parent directory")
+// Project is the nearest directory containing a deps.yaml or deps-lock.yaml
+type Project struct {
+	// Dir is the project root directory
+	Dir string
+	// ConfigPath is the project's deps.yaml, empty if it only has a lock file
+	ConfigPath string
+	// LockPath is the project's deps-lock.yaml, empty if it has not been locked
+	LockPath string
+	// Config is the project's deps.yaml merged with the default registry
+	Config *types.DepsConfig
+	// Lock is the parsed lock file, nil if LockPath is empty
+	Lock *types.LockFile
 }
 
-// FindLockFile searches for deps-lock.yaml in the current and parent directories
-func FindLockFile() (string, error) {
-	dir, err := os.Getwd()
+// FindProject walks up from dir to the nearest directory containing deps.yaml or deps-lock.yaml
+// and loads both files from it.
+func FindProject(dir string) (*Project, error) {
+	start, err := filepath.Abs(dir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	dir = start
 	for {
-		lockPath := filepath.Join(dir, LockFile)
-		if _, err := os.Stat(lockPath); err == nil {
-			return lockPath, nil
+		project := &Project{Dir: dir}
+		if path := filepath.Join(dir, DepsFile); fileExists(path) {
+			project.ConfigPath = path
+		}
+		if path := filepath.Join(dir, LockFile); fileExists(path) {
+			project.LockPath = path
+		}
+		if project.ConfigPath != "" || project.LockPath != "" {
+			return project, project.load()
 		}
 
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			// Reached root directory
 			break
 		}
 		dir = parent
 	}
 
-	return "", fmt.Errorf("deps-lock.yaml not found in current directory or any parent directory")
+	return nil, fmt.Errorf("deps.yaml or deps-lock.yaml not found in %s or any parent directory", start)
+}
+
+func (p *Project) load() error {
+	defaultConfig, err := LoadDefaultConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load default config: %w", err)
+	}
+
+	// Relative directories in deps.yaml are relative to the project, not the current directory
+	userConfig := &types.DepsConfig{}
+	if p.ConfigPath != "" {
+		if userConfig, err = loadRawConfig(p.ConfigPath); err != nil {
+			return err
+		}
+	}
+	if userConfig.Settings.BinDir == "" {
+		userConfig.Settings.BinDir = DefaultBinDir
+	}
+	for _, dir := range []*string{&userConfig.Settings.BinDir, &userConfig.Settings.AppDir, &userConfig.Settings.CacheDir} {
+		if *dir != "" && !filepath.IsAbs(*dir) && (*dir)[0] != '~' {
+			*dir = filepath.Join(p.Dir, *dir)
+		}
+	}
+
+	p.Config = MergeWithDefaults(defaultConfig, userConfig)
+	applyConfigPostProcessing(p.Config)
+
+	if p.LockPath != "" {
+		lock, err := LoadLockFile(p.LockPath)
+		if err != nil {
+			return err
+		}
+		p.Lock = lock
+	}
+	return nil
+}
+
+// PinnedVersion returns the version the project pins name to: the locked version if
+// the lock file has one, otherwise the constraint from deps.yaml.
+func (p *Project) PinnedVersion(name string) (string, bool) {
+	if p.Lock != nil {
+		if entry, ok := p.Lock.Dependencies[name]; ok && entry.Version != "" {
+			return entry.Version, true
+		}
+	}
+	if p.ConfigPath != "" && p.Config != nil {
+		if constraint, ok := p.Config.Dependencies[name]; ok {
+			return constraint, true
+		}
+	}
+	return "", false
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
 
 // ValidateConfig validates the configuration for common errors
@@ -279,6 +379,18 @@ func ValidateConfig(config *types.DepsConfig) error {
 			if pkg.URLTemplate == "" {
 				return fmt.Errorf("package %s uses github_tags manager but has no url_template specified", name)
 			}
+		case "gitea_release":
+			if pkg.Repo == "" {
+				return fmt.Errorf("package %s uses gitea_release manager but has no repo specified", name)
+			}
+		case "gitlab_package":
+			if pkg.Repo == "" {
+				return fmt.Errorf("package %s uses gitlab_package manager but has no repo specified", name)
+			}
+		case "docker", "oci_artifact":
+			if image, _ := pkg.Extra["image"].(string); image == "" {
+				return fmt.Errorf("package %s uses %s manager but has no extra.image specified", name, pkg.Manager)
+			}
 		case "direct":
 			if pkg.URLTemplate == "" {
 				return fmt.Errorf("package %s uses direct manager but has no url_template specified", name)
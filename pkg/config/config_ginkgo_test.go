@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -220,4 +223,46 @@ var _ = Describe("Config", func() {
 			})
 		})
 	})
+
+	Describe("FindProject", func() {
+		var root string
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+		})
+
+		write := func(path, content string) {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		It("finds the nearest deps.yaml from a nested directory", func() {
+			write(filepath.Join(root, DepsFile), "dependencies:\n  jq: v1.6\n")
+			write(filepath.Join(root, "app", DepsFile), "dependencies:\n  jq: v1.7.1\n")
+			nested := filepath.Join(root, "app", "src", "pkg")
+			Expect(os.MkdirAll(nested, 0755)).To(Succeed())
+
+			project, err := FindProject(nested)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(project.Dir).To(Equal(filepath.Join(root, "app")))
+			version, ok := project.PinnedVersion("jq")
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal("v1.7.1"))
+		})
+
+		It("prefers the locked version over the deps.yaml constraint", func() {
+			write(filepath.Join(root, DepsFile), "dependencies:\n  kubectl: \">=1.28\"\n")
+			write(filepath.Join(root, LockFile), "dependencies:\n  kubectl:\n    version: v1.29.3\n")
+
+			project, err := FindProject(root)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(project.LockPath).To(Equal(filepath.Join(root, LockFile)))
+			version, ok := project.PinnedVersion("kubectl")
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal("v1.29.3"))
+
+			_, ok = project.PinnedVersion("helm")
+			Expect(ok).To(BeFalse())
+		})
+	})
 })
@@ -172,6 +172,9 @@ func mergePackage(defaultPkg, userPkg types.Package) types.Package {
 	if userPkg.Repo != "" {
 		merged.Repo = userPkg.Repo
 	}
+	if userPkg.BaseURL != "" {
+		merged.BaseURL = userPkg.BaseURL
+	}
 	if userPkg.URLTemplate != "" {
 		merged.URLTemplate = userPkg.URLTemplate
 	}
@@ -198,6 +201,19 @@ func mergePackage(defaultPkg, userPkg types.Package) types.Package {
 			merged.Extra[k] = v
 		}
 	}
+	if len(userPkg.Env) > 0 {
+		env := make(map[string]string, len(merged.Env)+len(userPkg.Env))
+		for k, v := range merged.Env {
+			env[k] = v
+		}
+		for k, v := range userPkg.Env {
+			env[k] = v
+		}
+		merged.Env = env
+	}
+	if len(userPkg.Requires) > 0 {
+		merged.Requires = userPkg.Requires
+	}
 	if userPkg.Service != nil {
 		merged.Service = userPkg.Service
 	}
@@ -244,6 +260,9 @@ func MergeWithDefaults(defaultConfig, userConfig *types.DepsConfig) *types.DepsC
 		if userConfig.Settings.BinDir != "" {
 			merged.Settings.BinDir = userConfig.Settings.BinDir
 		}
+		if userConfig.Settings.AppDir != "" {
+			merged.Settings.AppDir = userConfig.Settings.AppDir
+		}
 		if userConfig.Settings.CacheDir != "" {
 			merged.Settings.CacheDir = userConfig.Settings.CacheDir
 		}
@@ -253,6 +272,74 @@ func MergeWithDefaults(defaultConfig, userConfig *types.DepsConfig) *types.DepsC
 		if userConfig.Settings.Platform.Arch != "" {
 			merged.Settings.Platform.Arch = userConfig.Settings.Platform.Arch
 		}
+		if userConfig.Settings.Jobs > 0 {
+			merged.Settings.Jobs = userConfig.Settings.Jobs
+		}
+		if len(userConfig.Settings.Mirrors) > 0 {
+			merged.Settings.Mirrors = userConfig.Settings.Mirrors
+		}
+		if userConfig.Settings.Retry.MaxAttempts > 0 {
+			merged.Settings.Retry.MaxAttempts = userConfig.Settings.Retry.MaxAttempts
+		}
+		if userConfig.Settings.Retry.Backoff > 0 {
+			merged.Settings.Retry.Backoff = userConfig.Settings.Retry.Backoff
+		}
+		if userConfig.Settings.Retry.MaxBackoff > 0 {
+			merged.Settings.Retry.MaxBackoff = userConfig.Settings.Retry.MaxBackoff
+		}
+		if userConfig.Settings.Retry.MaxRetryAfter > 0 {
+			merged.Settings.Retry.MaxRetryAfter = userConfig.Settings.Retry.MaxRetryAfter
+		}
+		if len(userConfig.Settings.Retry.Statuses) > 0 {
+			merged.Settings.Retry.Statuses = userConfig.Settings.Retry.Statuses
+		}
+		if userConfig.Settings.Proxy != "" {
+			merged.Settings.Proxy = userConfig.Settings.Proxy
+		}
+		if userConfig.Settings.NoProxy != "" {
+			merged.Settings.NoProxy = userConfig.Settings.NoProxy
+		}
+		if userConfig.Settings.CAFile != "" {
+			merged.Settings.CAFile = userConfig.Settings.CAFile
+		}
+		if userConfig.Settings.ClientCert != "" {
+			merged.Settings.ClientCert = userConfig.Settings.ClientCert
+		}
+		if userConfig.Settings.ClientKey != "" {
+			merged.Settings.ClientKey = userConfig.Settings.ClientKey
+		}
+		if len(userConfig.Settings.Credentials) > 0 {
+			// Copy rather than write into the map shared with the default settings
+			credentials := make(map[string]types.Credential)
+			for pattern, cred := range merged.Settings.Credentials {
+				credentials[pattern] = cred
+			}
+			for pattern, cred := range userConfig.Settings.Credentials {
+				credentials[pattern] = cred
+			}
+			merged.Settings.Credentials = credentials
+		}
+		if userConfig.Settings.GitHub.BaseURL != "" {
+			merged.Settings.GitHub.BaseURL = userConfig.Settings.GitHub.BaseURL
+		}
+		if userConfig.Settings.GitHub.Token != "" {
+			merged.Settings.GitHub.Token = userConfig.Settings.GitHub.Token
+		}
+		if userConfig.Settings.GitLab.BaseURL != "" {
+			merged.Settings.GitLab.BaseURL = userConfig.Settings.GitLab.BaseURL
+		}
+		if userConfig.Settings.GitLab.Token != "" {
+			merged.Settings.GitLab.Token = userConfig.Settings.GitLab.Token
+		}
+		if userConfig.Settings.HTTPCache.TTL > 0 {
+			merged.Settings.HTTPCache.TTL = userConfig.Settings.HTTPCache.TTL
+		}
+		if userConfig.Settings.HTTPCache.Disabled {
+			merged.Settings.HTTPCache.Disabled = true
+		}
+		if userConfig.Settings.Cache.MaxSize != "" {
+			merged.Settings.Cache.MaxSize = userConfig.Settings.Cache.MaxSize
+		}
 		// Boolean settings from user take precedence
 		merged.Settings.Parallel = userConfig.Settings.Parallel
 		merged.Settings.SkipVerify = userConfig.Settings.SkipVerify
@@ -1,7 +1,9 @@
 package download
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -15,7 +17,9 @@ import (
 	"github.com/flanksource/clicky/task"
 	"github.com/flanksource/deps/pkg/cache"
 	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/filelock"
 	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/oci"
 	"github.com/flanksource/deps/pkg/utils"
 )
 
@@ -67,6 +71,9 @@ type downloadConfig struct {
 	os               string   // Operating system for CEL expressions
 	arch             string   // Architecture for CEL expressions
 	timeout          time.Duration
+	lockTimeout      time.Duration // How long to wait for another process downloading to the same cache entry
+	offline          bool          // Serve only from the cache, never fetch files or checksums
+	ctx              context.Context
 }
 
 // WithChecksum sets the expected checksum for verification
@@ -150,6 +157,57 @@ func WithTimeout(timeout time.Duration) DownloadOption {
 	}
 }
 
+// WithLockTimeout sets how long to wait for another process holding the cache entry's lock.
+func WithLockTimeout(timeout time.Duration) DownloadOption {
+	return func(c *downloadConfig) {
+		c.lockTimeout = timeout
+	}
+}
+
+// WithOffline serves the file from the cache only, without fetching it or its checksum files.
+// Offline mode is also enabled process-wide by depshttp.SetOffline or DEPS_OFFLINE.
+func WithOffline(offline bool) DownloadOption {
+	return func(c *downloadConfig) {
+		c.offline = offline
+	}
+}
+
+// WithContext sets the context of the download requests. Retries of the download are recorded
+// on the counter of a context from depshttp.CountRetries.
+func WithContext(ctx context.Context) DownloadOption {
+	return func(c *downloadConfig) {
+		c.ctx = ctx
+	}
+}
+
+// CacheMissError is returned by offline downloads when the file cannot be served from the cache
+type CacheMissError struct {
+	URL       string
+	CachePath string // Where the file was expected, empty when the cache is disabled
+	Reason    string
+}
+
+func (e *CacheMissError) Error() string {
+	if e.CachePath == "" {
+		return fmt.Sprintf("offline: %s %s", e.URL, e.Reason)
+	}
+	return fmt.Sprintf("offline: %s %s (%s)", e.URL, e.Reason, e.CachePath)
+}
+
+// interruptedError is a transfer that failed part way through, which is retried by resuming the .part file
+type interruptedError struct {
+	received int64
+	err      error
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("failed to download after %s: %v", utils.FormatBytes(e.received), e.err)
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
 // ProgressReader wraps an io.Reader and reports progress
 type ProgressReader struct {
 	io.Reader
@@ -302,7 +360,7 @@ func fetchChecksumFromMultipleURLs(checksumURLs []string, checksumNames []string
 
 		checksumValue, checksumHashType, discoveredURL, err := checksum.EvaluateCELExpression(vars, checksumExpr)
 		if discoveredURL != "" {
-			t.V(3).Infof("Evaluated checksum using expression: %s -> checksum=%s, type=%s, url=%s", checksumExpr, checksumValue, checksumHashType, discoveredURL)
+			t.V(3).Infof("Evaluated checksum using expression: %s -> checksum=%s, type=%s, url=%s", checksumExpr, checksumValue, checksumHashType, utils.RedactURL(discoveredURL))
 		} else {
 			t.V(3).Infof("Evaluated checksum using expression: %s -> %s, %s", checksumExpr, checksumValue, checksumHashType)
 		}
@@ -330,6 +388,9 @@ func getChecksum(config *downloadConfig, fileURL string, t *task.Task) (value, h
 	if config.expectedChecksum != "" {
 		return config.expectedChecksum, config.checksumType
 	}
+	if config.offline {
+		return "", ""
+	}
 
 	switch {
 	case config.checksumURL != "":
@@ -358,7 +419,7 @@ func getChecksum(config *downloadConfig, fileURL string, t *task.Task) (value, h
 // Download downloads a file with optional configuration
 func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 	// Parse options
-	config := &downloadConfig{}
+	config := &downloadConfig{ctx: context.Background()}
 	for _, opt := range opts {
 		opt(config)
 	}
@@ -367,10 +428,33 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 	if config.simpleMode {
 		t = nil
 	}
+	config.offline = config.offline || depshttp.IsOffline()
+	if oci.IsImageURL(url) {
+		return fmt.Errorf("%s is an image, its binaries are pulled by the docker manager rather than downloaded", url)
+	}
 
 	// Check cache first
 	filename := filepath.Base(dest)
-	if cachePath, isCached := cache.IsCached(config.cacheDir, url, filename); isCached {
+	if config.cacheDir != "" {
+		// Hold the cache entry while checking and filling it, so concurrent downloads of the same URL
+		// wait for the first one and then copy from the cache
+		unlock, err := cache.Lock(config.cacheDir, url, filename, config.lockTimeout, func(h *filelock.Holder) {
+			if t != nil {
+				t.Infof("Waiting for %s to be downloaded by %s", filename, h)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	missReason := "is not in the download cache"
+	cachePath, isCached := cache.IsCached(config.cacheDir, url, filename)
+	if !isCached && config.expectedChecksum != "" && (config.checksumType == "" || config.checksumType == "sha256") {
+		// The same artifact may already be cached from another URL, e.g. a mirror or a redirect
+		cachePath, isCached = cache.IsCachedDigest(config.cacheDir, config.expectedChecksum)
+	}
+	if isCached {
 		if t != nil {
 			t.V(3).Infof("Found in cache: %s", cachePath)
 		}
@@ -431,6 +515,7 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 								return nil
 							}
 						} else {
+							missReason = fmt.Sprintf("is in the download cache but does not match checksum %s", config.expectedChecksum)
 							if t != nil {
 								t.V(3).Infof("Cached file checksum mismatch, will re-download")
 							}
@@ -438,6 +523,12 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 					}
 				}
 			}
+		} else if !cache.VerifyBlob(cachePath) {
+			// Without a checksum to verify against, the artifact is checked against its digest
+			missReason = "is in the download cache but no longer matches its digest"
+			if t != nil {
+				t.V(3).Infof("Cached file no longer matches its digest, will re-download")
+			}
 		} else {
 			if err := cache.CopyFromCache(cachePath, dest); err != nil {
 				if t != nil {
@@ -446,7 +537,7 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 			} else {
 				// Log no checksum warning for cached file
 				if t != nil {
-					msg := api.Text{Content: "✗ No checksum available - copied from cache without validation", Style: "text-red-500"}
+					msg := api.Text{Content: "✗ No checksum available - copied from cache, checked only against the digest it was cached under", Style: "text-red-500"}
 					t.Infof("%s", msg.ANSI())
 					t.SetDescription(fmt.Sprintf("Copied from cache (%s)", utils.FormatBytes(0)))
 				}
@@ -455,23 +546,56 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 		}
 	}
 
+	if config.offline {
+		if config.cacheDir == "" {
+			return &CacheMissError{URL: url, Reason: "cannot be fetched, the download cache is disabled"}
+		}
+		return &CacheMissError{URL: url, CachePath: cache.GetCachePath(config.cacheDir, url, filename), Reason: missReason}
+	}
+
+	if oci.IsURL(url) {
+		return fetchBlob(url, dest, t, config)
+	}
+
+	// Requests are retried by the HTTP client; a transfer that fails part way through is retried
+	// here, resuming from the .part file when the server supports it
+	policy := depshttp.GetRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		// Each attempt starts from the options, as fetching checksums updates the config
+		attemptConfig := *config
+		err := fetch(url, dest, t, &attemptConfig)
+		var interrupted *interruptedError
+		if err == nil || !errors.As(err, &interrupted) || attempt >= policy.MaxAttempts || config.ctx.Err() != nil {
+			return err
+		}
+		wait := depshttp.Backoff(policy, attempt)
+		if t != nil {
+			t.Infof("Download interrupted after %s, retrying in %s (attempt %d/%d)",
+				utils.FormatBytes(interrupted.received), wait.Round(time.Millisecond), attempt+1, policy.MaxAttempts)
+		}
+		depshttp.RecordRetry(config.ctx)
+		if err := depshttp.Sleep(config.ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch downloads url into dest, verifying its checksum and saving it to the cache
+func fetch(url, dest string, t *task.Task, config *downloadConfig) error {
 	// Create destination directory if it doesn't exist
 	destDir := filepath.Dir(dest)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
 	}
 
-	// Create temporary file for atomic download
-	tempFile := dest + ".tmp"
-	out, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file %s: %w", tempFile, err)
-	}
+	// Download into a .part file with a journal, so an interrupted download resumes where it stopped.
+	// The .part file is only kept when the transfer itself fails.
+	partial := newPartialDownload(config.cacheDir, url, dest)
+	tempFile := partial.path
+	keepPartial := false
 	defer func() {
-		_ = out.Close()
-		// Clean up temp file if it still exists (not renamed)
-		if _, err := os.Stat(tempFile); err == nil {
-			_ = os.Remove(tempFile)
+		if !keepPartial {
+			partial.discard()
 		}
 	}()
 
@@ -501,7 +625,7 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 			if discoveredURL != "" {
 				actualDownloadURL = discoveredURL
 				if t != nil {
-					t.V(3).Infof("Using discovered URL from API: %s", discoveredURL)
+					t.V(3).Infof("Using discovered URL from API: %s", utils.RedactURL(discoveredURL))
 				}
 			}
 		} else if t != nil {
@@ -509,16 +633,51 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 		}
 	}
 
+	// Mirrors are applied by the HTTP client; the cache and the caller keep the original URL
+	if mirrored, ok := depshttp.MirrorURL(actualDownloadURL); ok && t != nil {
+		t.V(3).Infof("Downloading via mirror %s", utils.ShortenURL(mirrored))
+	}
+
 	// Create HTTP client with redirect logging
 	client := downloadHTTPClientFactory(t, config.timeout)
 
-	// Get the data using the actual download URL (either original or discovered from API)
-	resp, err := client.Get(actualDownloadURL)
+	// Get the data using the actual download URL (either original or discovered from API),
+	// asking only for the missing bytes when a previous attempt was interrupted
+	offset := partial.resumeOffset()
+	req, err := partial.request(config.ctx, actualDownloadURL, offset)
 	if err != nil {
 		return fmt.Errorf("failed to download from %s: %w", url, err)
 	}
+	resp, err := client.Do(req)
+	if err != nil {
+		keepPartial = offset > 0
+		return fmt.Errorf("failed to download from %s: %w", url, err)
+	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if partial.resumes(resp, offset) {
+		if t != nil {
+			t.Infof("Resuming download at %s", utils.FormatBytes(offset))
+		}
+	} else if offset > 0 {
+		if t != nil {
+			t.V(3).Infof("Cannot resume download (HTTP %d), restarting", resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			_ = resp.Body.Close()
+			req, err = partial.request(config.ctx, actualDownloadURL, 0)
+			if err != nil {
+				return fmt.Errorf("failed to download from %s: %w", url, err)
+			}
+			full, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("failed to download from %s: %w", url, err)
+			}
+			resp = full
+		}
+		offset = 0
+	}
+
 	// Capture final URL after redirects for checksum verification
 	// This ensures we match against the actual filename, not the original URL with query params
 	finalURL := resp.Request.URL.String()
@@ -550,10 +709,16 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 	}
 
 	// Check server response
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && (offset == 0 || resp.StatusCode != http.StatusPartialContent) {
 		return fmt.Errorf("download failed: HTTP %d %s for %s", resp.StatusCode, resp.Status, url)
 	}
 
+	out, err := partial.open(resp, offset)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+
 	var checksumType checksum.HashType
 	if config.expectedChecksum != "" {
 		var err error
@@ -586,15 +751,26 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 		if err != nil {
 			return fmt.Errorf("failed to create hasher: %w", err)
 		}
+		// The checksum covers the whole file, including the bytes of an earlier attempt
+		if offset > 0 {
+			if err := partial.hashPrefix(hasher, offset); err != nil {
+				return fmt.Errorf("failed to hash %s: %w", tempFile, err)
+			}
+		}
 		writer = io.MultiWriter(writer, hasher)
 	}
 
 	// Add progress tracking if task provided and not disabled
 	var pr *ProgressReader
 	if t != nil && !config.skipProgress {
+		total := resp.ContentLength
+		if total > 0 {
+			total += offset
+		}
 		pr = &ProgressReader{
 			Reader:     resp.Body,
-			total:      resp.ContentLength,
+			total:      total,
+			current:    offset,
 			task:       t,
 			depName:    t.Name(),
 			startTime:  time.Now(),
@@ -606,8 +782,13 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 	// Download
 	written, err := io.Copy(writer, reader)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+		keepPartial = partial.journal.AcceptRanges
+		if saveErr := partial.save(offset + written); saveErr != nil && t != nil {
+			t.V(3).Infof("Failed to record partial download: %v", saveErr)
+		}
+		return &interruptedError{received: offset + written, err: err}
 	}
+	written += offset
 
 	// Close the temp file before verification/rename
 	_ = out.Close()
@@ -643,7 +824,7 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 		}
 		// Note: discoveredURL from post-download is ignored since we already downloaded
 		if discoveredURL != "" && t != nil {
-			t.V(4).Infof("Discovered URL post-download (ignored): %s", discoveredURL)
+			t.V(4).Infof("Discovered URL post-download (ignored): %s", utils.RedactURL(discoveredURL))
 		}
 	}
 
@@ -713,7 +894,7 @@ func Download(url, dest string, t *task.Task, opts ...DownloadOption) error {
 	}
 
 	// Atomically move temp file to final destination
-	if err := os.Rename(tempFile, dest); err != nil {
+	if err := partial.complete(dest); err != nil {
 		return fmt.Errorf("failed to move temp file to destination: %w", err)
 	}
 
@@ -2,14 +2,25 @@ package download
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/cache"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/oci/ocitest"
+	"github.com/flanksource/deps/pkg/types"
 )
 
 func TestSimpleDownloadUsesSingleRequest(t *testing.T) {
@@ -54,6 +65,243 @@ func TestSimpleDownloadUsesSingleRequest(t *testing.T) {
 	}
 }
 
+func TestOfflineDownloadServesOnlyFromCache(t *testing.T) {
+	previousFactory := downloadHTTPClientFactory
+	t.Cleanup(func() {
+		downloadHTTPClientFactory = previousFactory
+	})
+	downloadHTTPClientFactory = func(_ *task.Task, _ time.Duration) *http.Client {
+		return &http.Client{
+			Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				t.Fatalf("offline download made a request to %s", req.URL)
+				return nil, nil
+			}),
+		}
+	}
+
+	url := "https://example.com/releases/tool.tar.gz"
+	cacheDir := t.TempDir()
+	dest := filepath.Join(t.TempDir(), "tool.tar.gz")
+
+	err := Download(url, dest, nil, WithCacheDir(cacheDir), WithOffline(true), WithChecksumURL(url+".sha256"))
+	var miss *CacheMissError
+	if !errors.As(err, &miss) {
+		t.Fatalf("expected a CacheMissError, got %v", err)
+	}
+	if miss.CachePath != cache.GetCachePath(cacheDir, url, "tool.tar.gz") {
+		t.Fatalf("expected the missing cache path in the error, got %q", miss.CachePath)
+	}
+	if !strings.Contains(err.Error(), url) {
+		t.Fatalf("expected the URL in the error, got %q", err)
+	}
+
+	source := filepath.Join(t.TempDir(), "tool.tar.gz")
+	if err := os.WriteFile(source, []byte("payload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SaveToCache(cacheDir, url, source); err != nil {
+		t.Fatalf("SaveToCache failed: %v", err)
+	}
+
+	err = Download(url, dest, nil, WithCacheDir(cacheDir), WithOffline(true), WithChecksum("sha256:"+strings.Repeat("0", 64)))
+	if !errors.As(err, &miss) || !strings.Contains(miss.Reason, "does not match") {
+		t.Fatalf("expected a checksum mismatch CacheMissError, got %v", err)
+	}
+
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload")))
+	if err := Download(url, dest, nil, WithCacheDir(cacheDir), WithOffline(true), WithChecksum(sum)); err != nil {
+		t.Fatalf("expected the cached file to be served offline: %v", err)
+	}
+	content, err := os.ReadFile(dest)
+	if err != nil || string(content) != "payload" {
+		t.Fatalf("unexpected downloaded content %q: %v", content, err)
+	}
+
+	// Without a checksum, a corrupted artifact of the same size is caught by its digest
+	cachePath, _ := cache.IsCached(cacheDir, url, "tool.tar.gz")
+	if err := os.WriteFile(cachePath, []byte("PAYLOAD"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = Download(url, dest, nil, WithCacheDir(cacheDir), WithOffline(true))
+	if !errors.As(err, &miss) || !strings.Contains(miss.Reason, "no longer matches its digest") {
+		t.Fatalf("expected the corrupted artifact to be a cache miss, got %v", err)
+	}
+}
+
+func TestOfflineDownloadWithoutCache(t *testing.T) {
+	err := Download("https://example.com/tool", filepath.Join(t.TempDir(), "tool"), nil, WithOffline(true))
+	var miss *CacheMissError
+	if !errors.As(err, &miss) || miss.CachePath != "" {
+		t.Fatalf("expected a CacheMissError without a cache path, got %v", err)
+	}
+}
+
+func TestDownloadOCIBlob(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	registry.RequireToken("secret")
+	blob := registry.PushBlob("application/octet-stream", []byte("binary"))
+	ref, err := oci.ParseReference(registry.Host + "/org/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := ref.WithDigest(blob.Digest).URL()
+	cacheDir := t.TempDir()
+	dest := filepath.Join(t.TempDir(), "tool")
+
+	if err := Download(url, dest, nil, WithCacheDir(cacheDir), WithChecksum(blob.Digest)); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if content, _ := os.ReadFile(dest); string(content) != "binary" {
+		t.Fatalf("unexpected downloaded content %q", content)
+	}
+
+	// The blob is served from the download cache once it has been fetched
+	offline := filepath.Join(t.TempDir(), "tool")
+	if err := Download(url, offline, nil, WithCacheDir(cacheDir), WithOffline(true), WithChecksum(blob.Digest)); err != nil {
+		t.Fatalf("expected the cached blob to be served offline: %v", err)
+	}
+	if content, _ := os.ReadFile(offline); string(content) != "binary" {
+		t.Fatalf("unexpected cached content %q", content)
+	}
+
+	err = Download(url, filepath.Join(t.TempDir(), "tool"), nil, WithChecksum("sha256:"+strings.Repeat("0", 64)))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum that does not match the pinned digest to fail, got %v", err)
+	}
+
+	err = Download(ref.WithDigest(blob.Digest).ImageURL(), filepath.Join(t.TempDir(), "tool"), nil, WithCacheDir(cacheDir))
+	if err == nil || !strings.Contains(err.Error(), "is an image") {
+		t.Fatalf("expected images to be rejected, got %v", err)
+	}
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+
+	var ranges []string
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		if len(ranges) == 1 {
+			// Drop the connection halfway through the first attempt
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	dest := filepath.Join(t.TempDir(), "tool.tar.gz")
+	url := server.URL + "/tool.tar.gz"
+
+	// Without retries the interrupted download fails, keeping the .part file for the next run
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 1})
+	if err := Download(url, dest, nil, WithCacheDir(cacheDir), WithChecksum(sum)); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	if info, err := os.Stat(cache.PartialPath(cacheDir, url)); err != nil || info.Size() != int64(len(content)/2) {
+		t.Fatalf("expected half the file to be kept in the .part file, got %v", err)
+	}
+
+	if err := Download(url, dest, nil, WithCacheDir(cacheDir), WithChecksum(sum)); err != nil {
+		t.Fatalf("expected the download to resume: %v", err)
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(content)/2); ranges[1] != want {
+		t.Fatalf("expected the second request to ask for %q, got %q", want, ranges[1])
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Fatalf("resumed download does not match the original (%d bytes)", len(got))
+	}
+	if _, err := os.Stat(cache.PartialPath(cacheDir, url)); !os.IsNotExist(err) {
+		t.Fatalf("expected the .part file to be removed once complete, stat err = %v", err)
+	}
+}
+
+func TestDownloadRetriesInterruptedTransfer(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if len(ranges) == 1 {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	ctx, retries := depshttp.CountRetries(context.Background())
+	dest := filepath.Join(t.TempDir(), "tool.tar.gz")
+	if err := Download(server.URL+"/tool.tar.gz", dest, nil, WithChecksum(sum), WithContext(ctx)); err != nil {
+		t.Fatalf("expected the interrupted download to be retried: %v", err)
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(content)/2); len(ranges) != 2 || ranges[1] != want {
+		t.Fatalf("expected the retry to resume with %q, got %q", want, ranges)
+	}
+	if retries.Load() != 1 {
+		t.Fatalf("expected 1 retry to be recorded, got %d", retries.Load())
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Fatalf("retried download does not match the original (%d bytes)", len(got))
+	}
+}
+
+func setRetryPolicy(t *testing.T, policy types.RetryPolicy) {
+	t.Helper()
+	if err := depshttp.SetRetryPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = depshttp.SetRetryPolicy(depshttp.DefaultRetryPolicy) })
+}
+
+func TestDownloadRestartsWhenFileChanged(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 1000)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "tool.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "tool.tar.gz")
+	url := server.URL + "/tool.tar.gz"
+
+	// A .part file left by an attempt against a previous version of the file
+	partial := newPartialDownload("", url, dest)
+	if err := os.WriteFile(partial.path, []byte("stale bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	partial.journal = partialJournal{URL: url, ETag: `"v1"`, AcceptRanges: true, Size: int64(len(content))}
+	if err := partial.save(int64(len("stale bytes"))); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	if err := Download(url, dest, nil, WithChecksum(sum)); err != nil {
+		t.Fatalf("expected the download to restart from zero: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] == "" {
+		t.Fatalf("expected a single ranged request answered with the whole file, got %q", ranges)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Fatal("restarted download does not match the new file")
+	}
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
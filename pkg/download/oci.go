@@ -0,0 +1,75 @@
+package download
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/cache"
+	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/oci"
+)
+
+// fetchBlob downloads the registry blob an oci:// URL is pinned to into dest and saves it to the
+// cache. The blob is verified against the digest in the URL, which must match the expected
+// checksum when there is one.
+func fetchBlob(url, dest string, t *task.Task, config *downloadConfig) error {
+	ref, err := oci.ParseReference(url)
+	if err != nil {
+		return err
+	}
+	if ref.Digest == "" {
+		return fmt.Errorf("%s is not pinned to a digest", url)
+	}
+	if config.expectedChecksum != "" {
+		value, hashType, err := checksum.ParseChecksumWithType(config.expectedChecksum)
+		if err != nil {
+			return fmt.Errorf("invalid checksum format: %w", err)
+		}
+		if expected := checksum.FormatChecksum(value, hashType); expected != ref.Digest {
+			return fmt.Errorf("checksum mismatch: %s is pinned to %s, expected %s", url, ref.Digest, expected)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dest), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if t != nil {
+		t.SetDescription("Downloading")
+	}
+	err = oci.NewClient().FetchBlob(config.ctx, ref, oci.Descriptor{Digest: ref.Digest}, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to move temp file to destination: %w", err)
+	}
+	if t != nil {
+		digestDisplay := ref.Digest
+		if len(digestDisplay) > len("sha256:")+16 {
+			digestDisplay = digestDisplay[:len("sha256:")+16] + "..."
+		}
+		t.Infof("✓ Checksum verified: %s (registry digest)", digestDisplay)
+	}
+
+	if config.cacheDir != "" {
+		if err := cache.SaveToCache(config.cacheDir, url, dest); err != nil {
+			if t != nil {
+				t.V(3).Infof("Failed to save to cache: %v", err)
+			}
+		} else if t != nil {
+			t.V(3).Infof("Saved to cache: %s", filepath.Base(dest))
+		}
+	}
+	return nil
+}
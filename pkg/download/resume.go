@@ -0,0 +1,175 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/deps/pkg/cache"
+	"github.com/flanksource/deps/pkg/utils"
+)
+
+// partialJournal records what is known about a .part file, so an interrupted download can be
+// resumed with a Range request as long as the file on the server has not changed
+type partialJournal struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	AcceptRanges bool      `json:"accept_ranges"`
+	Size         int64     `json:"size,omitempty"` // Total size, when the server reported it
+	Received     int64     `json:"received"`
+	Updated      time.Time `json:"updated"`
+}
+
+// partialDownload is a .part file and its journal. With a cache the files are kept in the cache,
+// so they survive the caller's temporary destination being cleaned up between attempts.
+type partialDownload struct {
+	url     string
+	path    string
+	journal partialJournal
+}
+
+func newPartialDownload(cacheDir, url, dest string) *partialDownload {
+	path := dest + ".part"
+	if cacheDir != "" {
+		path = cache.PartialPath(cacheDir, url)
+	}
+	return &partialDownload{url: url, path: path}
+}
+
+func (p *partialDownload) journalPath() string {
+	return p.path + ".json"
+}
+
+// resumeOffset returns how many bytes of a previous attempt can be kept, or 0 when the download
+// has to start over because there is no usable .part file, journal or validator.
+func (p *partialDownload) resumeOffset() int64 {
+	data, err := os.ReadFile(p.journalPath())
+	if err != nil || json.Unmarshal(data, &p.journal) != nil || p.journal.URL != p.url {
+		p.journal = partialJournal{URL: p.url}
+		return 0
+	}
+	info, err := os.Stat(p.path)
+	if err != nil || !p.journal.AcceptRanges || p.ifRange() == "" {
+		return 0
+	}
+	offset := min(info.Size(), p.journal.Received)
+	if p.journal.Size > 0 && offset >= p.journal.Size {
+		return 0
+	}
+	return offset
+}
+
+// ifRange returns the validator for an If-Range header: a strong ETag, or else Last-Modified
+func (p *partialDownload) ifRange() string {
+	if p.journal.ETag != "" && !strings.HasPrefix(p.journal.ETag, "W/") {
+		return p.journal.ETag
+	}
+	return p.journal.LastModified
+}
+
+// request creates the GET request, asking only for the bytes after offset when resuming.
+// With If-Range the server sends the whole file instead if it changed since the .part was written.
+func (p *partialDownload) request(ctx context.Context, url string, offset int64) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", p.ifRange())
+	}
+	return req, nil
+}
+
+// resumes reports whether a response continues the .part file at offset. A 200 means the server
+// ignored the range or the file changed, so the download restarts from zero.
+func (p *partialDownload) resumes(resp *http.Response, offset int64) bool {
+	if offset == 0 || resp.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes "), "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	return err == nil && n == offset
+}
+
+// open returns the .part file positioned at offset, truncating anything after it, and records
+// the validators of resp so a later attempt can resume
+func (p *partialDownload) open(resp *http.Response, offset int64) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", p.path, err)
+	}
+	out, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file %s: %w", p.path, err)
+	}
+	if err := out.Truncate(offset); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("failed to truncate %s: %w", p.path, err)
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		_ = out.Close()
+		return nil, fmt.Errorf("failed to seek %s: %w", p.path, err)
+	}
+
+	size := int64(0)
+	if resp.ContentLength >= 0 {
+		size = offset + resp.ContentLength
+	}
+	p.journal = partialJournal{
+		URL:          p.url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		AcceptRanges: resp.StatusCode == http.StatusPartialContent || strings.Contains(resp.Header.Get("Accept-Ranges"), "bytes"),
+		Size:         size,
+		Received:     offset,
+	}
+	return out, p.save(offset)
+}
+
+// save records how many bytes the .part file holds
+func (p *partialDownload) save(received int64) error {
+	p.journal.Received = received
+	p.journal.Updated = time.Now()
+	data, err := json.MarshalIndent(p.journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(p.journalPath(), data, 0644)
+}
+
+// hashPrefix feeds the bytes already in the .part file to w, so the checksum covers the whole file
+func (p *partialDownload) hashPrefix(w io.Writer, offset int64) error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.CopyN(w, f, offset)
+	return err
+}
+
+// complete moves the finished .part file to dest and drops its journal
+func (p *partialDownload) complete(dest string) error {
+	if err := os.Rename(p.path, dest); err != nil {
+		// The cache may be on another filesystem than dest
+		if copyErr := utils.CopyFile(p.path, dest); copyErr != nil {
+			return err
+		}
+	}
+	p.discard()
+	return nil
+}
+
+// discard removes the .part file and its journal
+func (p *partialDownload) discard() {
+	_ = os.Remove(p.path)
+	_ = os.Remove(p.journalPath())
+}
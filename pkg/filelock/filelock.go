@@ -0,0 +1,152 @@
+// Package filelock provides advisory, cross-process file locks. The holder of a lock records
+// who it is in the lock file, so processes left waiting can report what they are waiting on.
+package filelock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long Acquire waits for a lock held by another process
+const DefaultTimeout = 10 * time.Minute
+
+const (
+	minPoll = 50 * time.Millisecond
+	maxPoll = time.Second
+)
+
+// Holder describes the process holding a lock
+type Holder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+func (h *Holder) String() string {
+	if h == nil || h.PID == 0 {
+		return "another process"
+	}
+	s := fmt.Sprintf("pid %d", h.PID)
+	if h.Host != "" {
+		s += " on " + h.Host
+	}
+	if h.Command != "" {
+		s += fmt.Sprintf(" (%s)", h.Command)
+	}
+	if !h.Since.IsZero() {
+		s += fmt.Sprintf(" since %s", h.Since.Format(time.RFC3339))
+	}
+	return s
+}
+
+// TimeoutError is returned when a lock is still held once the timeout has elapsed
+type TimeoutError struct {
+	Path    string
+	Timeout time.Duration
+	Holder  *Holder
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %s, locked by %s", e.Timeout, e.Path, e.Holder)
+}
+
+// Timeout returns the lock timeout set by DEPS_LOCK_TIMEOUT (e.g. "30s"), or DefaultTimeout
+func Timeout() time.Duration {
+	if value := os.Getenv("DEPS_LOCK_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultTimeout
+}
+
+// Acquire takes an exclusive lock on path, creating it and its directory if needed, and returns
+// the function that releases it. While another process holds the lock Acquire polls until it is
+// released or timeout elapses (timeout <= 0 uses Timeout()); waiting, if set, is called once with
+// the current holder.
+func Acquire(path string, timeout time.Duration, waiting func(*Holder)) (func(), error) {
+	if timeout <= 0 {
+		timeout = Timeout()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	poll := minPoll
+	notified := false
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+
+		if !notified && waiting != nil {
+			waiting(ReadHolder(path))
+		}
+		notified = true
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, &TimeoutError{Path: path, Timeout: timeout, Holder: ReadHolder(path)}
+		}
+		time.Sleep(poll)
+		poll = min(poll*2, maxPoll)
+	}
+
+	writeHolder(f)
+	return func() {
+		_ = f.Truncate(0)
+		_ = unlock(f)
+		_ = f.Close()
+	}, nil
+}
+
+// ReadHolder returns the holder recorded in the lock file at path, or nil if it is unknown
+func ReadHolder(path string) *Holder {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil
+	}
+	return &h
+}
+
+func writeHolder(f *os.File) {
+	h := Holder{PID: os.Getpid(), Command: command(), Since: time.Now().Truncate(time.Second)}
+	h.Host, _ = os.Hostname()
+	data, err := json.Marshal(h)
+	if err != nil {
+		return
+	}
+	// Best effort: the lock itself does not depend on the holder being readable
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt(data, 0)
+}
+
+func command() string {
+	if len(os.Args) == 0 {
+		return ""
+	}
+	args := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+	cmd := strings.Join(args, " ")
+	if len(cmd) > 120 {
+		cmd = cmd[:117] + "..."
+	}
+	return cmd
+}
@@ -0,0 +1,64 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireTimesOutAndReportsHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "tool.lock")
+
+	release, err := Acquire(path, time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer release()
+
+	var waitedOn *Holder
+	_, err = Acquire(path, 200*time.Millisecond, func(h *Holder) { waitedOn = h })
+
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Acquire() error = %v, want a TimeoutError", err)
+	}
+	if timeout.Holder == nil || timeout.Holder.PID != os.Getpid() {
+		t.Errorf("Holder = %v, want pid %d", timeout.Holder, os.Getpid())
+	}
+	if waitedOn == nil || waitedOn.PID != os.Getpid() {
+		t.Errorf("waiting callback got %v, want pid %d", waitedOn, os.Getpid())
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool.lock")
+
+	release, err := Acquire(path, time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	time.AfterFunc(100*time.Millisecond, release)
+
+	again, err := Acquire(path, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	again()
+
+	if h := ReadHolder(path); h != nil {
+		t.Errorf("ReadHolder() = %v after release, want nil", h)
+	}
+}
+
+func TestTimeoutFromEnv(t *testing.T) {
+	t.Setenv("DEPS_LOCK_TIMEOUT", "30s")
+	if got := Timeout(); got != 30*time.Second {
+		t.Errorf("Timeout() = %v, want 30s", got)
+	}
+	t.Setenv("DEPS_LOCK_TIMEOUT", "bogus")
+	if got := Timeout(); got != DefaultTimeout {
+		t.Errorf("Timeout() = %v, want %v", got, DefaultTimeout)
+	}
+}
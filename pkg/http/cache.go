@@ -0,0 +1,288 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// DefaultResponseCacheTTL is how long cached responses are used without asking the server
+// when settings.http_cache.ttl is not set
+const DefaultResponseCacheTTL = 10 * time.Minute
+
+const (
+	// responseCacheDir holds the response cache under the download cache directory
+	responseCacheDir = "http"
+	// maxCachedResponse is the largest body kept; larger responses such as downloads pass through
+	maxCachedResponse = 8 << 20
+)
+
+var (
+	responseCacheMu   sync.RWMutex
+	responseCachePath string
+	responseCacheTTL  = DefaultResponseCacheTTL
+	refresh           atomic.Bool
+)
+
+// SetResponseCache keeps the API responses of every client from GetHttpClient in
+// {cacheDir}/http. Responses are reused for the TTL and then revalidated with their ETag or
+// Last-Modified, so unchanged release lists cost a 304 instead of a full response (GitHub does
+// not count 304s against the rate limit). An empty cacheDir or settings.Disabled turns it off.
+func SetResponseCache(cacheDir string, settings types.HTTPCacheSettings) error {
+	if settings.TTL < 0 {
+		return fmt.Errorf("settings.http_cache.ttl must not be negative: %s", settings.TTL)
+	}
+	ttl := settings.TTL
+	if ttl == 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	dir := ""
+	if cacheDir != "" && !settings.Disabled {
+		dir = filepath.Join(cacheDir, responseCacheDir)
+	}
+
+	responseCacheMu.Lock()
+	defer responseCacheMu.Unlock()
+	responseCachePath = dir
+	responseCacheTTL = ttl
+	return nil
+}
+
+// SetRefresh makes every cached response be revalidated with the server regardless of its age
+func SetRefresh(enabled bool) {
+	refresh.Store(enabled)
+}
+
+func responseCacheConfig() (string, time.Duration) {
+	responseCacheMu.RLock()
+	defer responseCacheMu.RUnlock()
+	return responseCachePath, responseCacheTTL
+}
+
+// cachedResponse is a response stored in the response cache
+type cachedResponse struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Stored     time.Time   `json:"stored"` // When the response was fetched or last revalidated
+}
+
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// cacheTransport answers API requests from the response cache while they are fresh and
+// revalidates them with conditional requests once they are not
+type cacheTransport struct {
+	next http.RoundTripper
+}
+
+func (t cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dir, ttl := responseCacheConfig()
+	if dir == "" || !cacheableRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+	req, key, err := responseCacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, key[:2], key+".json")
+
+	cached := readCachedResponse(path)
+	if cached != nil && time.Since(cached.Stored) < ttl && !refresh.Load() && !hasCacheDirective(req.Header, "no-cache") {
+		logger.V(4).Infof("Using cached response for %s from %s", req.URL, cached.Stored.Format(time.RFC3339))
+		return cached.response(req), nil
+	}
+
+	outgoing := req
+	if cached != nil {
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_ = resp.Body.Close()
+		// The 304 carries current headers such as rate limits
+		for name, values := range resp.Header {
+			if name != "Content-Length" {
+				cached.Header[name] = values
+			}
+		}
+		cached.Stored = time.Now()
+		if err := writeCachedResponse(path, cached); err != nil {
+			logger.V(3).Infof("Failed to update cached response for %s: %v", req.URL, err)
+		}
+		logger.V(4).Infof("Revalidated cached response for %s", req.URL)
+		return cached.response(req), nil
+	}
+	if !cacheableResponse(resp) {
+		return resp, nil
+	}
+	return storeResponse(path, req, resp)
+}
+
+// storeResponse caches resp if its body is small enough, returning it with the body intact
+func storeResponse(path string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponse+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedResponse {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	cached := &cachedResponse{URL: req.URL.String(), StatusCode: resp.StatusCode, Header: header, Body: body, Stored: time.Now()}
+	if err := writeCachedResponse(path, cached); err != nil {
+		logger.V(3).Infof("Failed to cache response for %s: %v", req.URL, err)
+	}
+	return resp, nil
+}
+
+// cacheableRequest reports whether req is an API request that can be answered from the cache:
+// a GET or a GraphQL query, without a Range or conditions of its own
+func cacheableRequest(req *http.Request) bool {
+	switch {
+	case req.Method == http.MethodGet:
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/graphql"):
+	default:
+		return false
+	}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if req.Header.Get(name) != "" {
+			return false
+		}
+	}
+	return !hasCacheDirective(req.Header, "no-store")
+}
+
+// cacheableResponse reports whether resp is a successful response worth caching. Only clients
+// created WithResponseCache get here, so downloads are left to the download cache.
+func cacheableResponse(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || hasCacheDirective(resp.Header, "no-store") {
+		return false
+	}
+	return resp.ContentLength <= maxCachedResponse
+}
+
+func hasCacheDirective(header http.Header, directive string) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// responseCacheKey returns the cache key of req: a hash of its method, URL, Accept header,
+// credentials and body. Requests with a body are returned with a body that can be read again.
+func responseCacheKey(req *http.Request) (*http.Request, string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\naccept: %s\n", req.Method, req.URL.String(), req.Header.Get("Accept"))
+	for _, name := range credentialHeaders() {
+		if value := req.Header.Get(name); value != "" {
+			// Responses fetched with different tokens may differ; the token itself is not stored
+			fmt.Fprintf(h, "%s: %x\n", name, sha256.Sum256([]byte(value)))
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return req, "", err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		h.Write(body)
+	}
+	return req, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readCachedResponse(path string) *cachedResponse {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Header == nil {
+		return nil
+	}
+	return &cached
+}
+
+// writeCachedResponse replaces the entry at path atomically, so concurrent runs never read a
+// partial entry
+func writeCachedResponse(path string, cached *cachedResponse) error {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setResponseCache(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	if err := SetResponseCache(t.TempDir(), types.HTTPCacheSettings{TTL: ttl}); err != nil {
+		t.Fatalf("SetResponseCache: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = SetResponseCache("", types.HTTPCacheSettings{})
+		SetRefresh(false)
+	})
+}
+
+// fetch returns the body of a request to url from an API client, failing the test on errors
+func fetch(t *testing.T, method, url, body string) string {
+	t.Helper()
+	return fetchWith(t, GetHttpClient(WithTimeout(time.Second), WithResponseCache()), method, url, body)
+}
+
+func fetchWith(t *testing.T, client *http.Client, method, url, body string) string {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: unexpected status %d", method, url, resp.StatusCode)
+	}
+	return string(data)
+}
+
+func TestResponseCache(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/releases":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("X-RateLimit-Remaining", "59")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"tag_name": "v1.0.0"}]`))
+		case "/install.sh":
+			// Served chunked, without a Content-Length
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("#!/bin/sh"))
+			w.(http.Flusher).Flush()
+		case "/graphql":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}
+	}))
+	t.Cleanup(server.Close)
+	setResponseCache(t, time.Hour)
+
+	// Fresh responses are served without a request
+	for i := 0; i < 2; i++ {
+		if body := fetch(t, http.MethodGet, server.URL+"/releases", ""); body != `[{"tag_name": "v1.0.0"}]` {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected the second request to be answered from the cache, got %d requests", len(requests))
+	}
+
+	// --refresh revalidates, and a 304 is answered with the cached body
+	SetRefresh(true)
+	if body := fetch(t, http.MethodGet, server.URL+"/releases", ""); body != `[{"tag_name": "v1.0.0"}]` {
+		t.Errorf("expected the cached body after a 304, got %q", body)
+	}
+	if len(requests) != 2 || requests[1].Header.Get("If-None-Match") != `"v1"` {
+		t.Errorf("expected a conditional request with the cached ETag")
+	}
+	SetRefresh(false)
+
+	// Downloads are not cached, whatever their content type
+	for i := 0; i < 2; i++ {
+		fetchWith(t, GetHttpClient(WithTimeout(time.Second)), http.MethodGet, server.URL+"/install.sh", "")
+	}
+	if len(requests) != 4 {
+		t.Errorf("expected every download to reach the server, got %d requests", len(requests)-2)
+	}
+
+	// GraphQL queries are cached by their body
+	fetch(t, http.MethodPost, server.URL+"/graphql", `{"query": "a"}`)
+	fetch(t, http.MethodPost, server.URL+"/graphql", `{"query": "b"}`)
+	if body := fetch(t, http.MethodPost, server.URL+"/graphql", `{"query": "a"}`); body != `{"query": "a"}` {
+		t.Errorf("unexpected cached GraphQL response %q", body)
+	}
+	if len(requests) != 6 {
+		t.Errorf("expected one request per distinct GraphQL query, got %d", len(requests)-4)
+	}
+}
+
+func TestResponseCacheKeepsCredentialsApart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	t.Cleanup(server.Close)
+	setResponseCache(t, time.Hour)
+	u, _ := url.Parse(server.URL)
+	if err := SetCredentials(map[string]types.Credential{u.Host: {Token: "${DEPS_TEST_CACHE_TOKEN}"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetCredentials(nil) })
+
+	t.Setenv("DEPS_TEST_CACHE_TOKEN", "first")
+	if body := fetch(t, http.MethodGet, server.URL+"/private", ""); body != "Bearer first" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	t.Setenv("DEPS_TEST_CACHE_TOKEN", "second")
+	if body := fetch(t, http.MethodGet, server.URL+"/private", ""); body != "Bearer second" {
+		t.Errorf("expected the response for another credential not to be served from the cache, got %q", body)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("x", requests)))
+	}))
+	t.Cleanup(server.Close)
+	setResponseCache(t, time.Nanosecond)
+
+	fetch(t, http.MethodGet, server.URL+"/maven-metadata.xml", "")
+	// Without validators an expired response is fetched again
+	if body := fetch(t, http.MethodGet, server.URL+"/maven-metadata.xml", ""); body != "xx" {
+		t.Errorf("expected the expired response to be refetched, got %q", body)
+	}
+}
+
+func TestSetResponseCacheRejectsNegativeTTL(t *testing.T) {
+	t.Cleanup(func() { _ = SetResponseCache("", types.HTTPCacheSettings{}) })
+	if err := SetResponseCache(t.TempDir(), types.HTTPCacheSettings{TTL: -time.Minute}); err == nil {
+		t.Errorf("expected an error for a negative TTL")
+	}
+}
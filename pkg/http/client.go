@@ -19,6 +19,7 @@ type clientConfig struct {
 	bodyLevel         logger.LogLevel
 	logMode           string
 	useLevelThreshold bool
+	responseCache     bool
 }
 
 // WithTimeout sets the request timeout
@@ -28,6 +29,15 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithResponseCache answers the client's requests from the response cache of SetResponseCache.
+// It is meant for API clients: their responses are small and read whole, unlike downloads,
+// which go through the download cache instead.
+func WithResponseCache() ClientOption {
+	return func(c *clientConfig) {
+		c.responseCache = true
+	}
+}
+
 // WithHttpLogging enables HTTP logging with specified levels
 func WithHttpLogging(headerLevel, bodyLevel logger.LogLevel) ClientOption {
 	return func(c *clientConfig) {
@@ -41,6 +51,11 @@ func WithHttpLogging(headerLevel, bodyLevel logger.LogLevel) ClientOption {
 // It uses the shared commons HTTP logger middleware for consistent HTTP logging.
 // We intentionally avoid using commons/http.Client directly as a stdlib Transport
 // because its request adaptation re-serializes existing query parameters.
+// Requests fail with an *OfflineError while offline mode is enabled, are sent to the first
+// matching mirror from SetMirrors, are retried as SetRetryPolicy configures, carry the
+// credentials from SetCredentials, are answered from the response cache of SetResponseCache
+// while fresh when the client uses WithResponseCache, and use the proxy and TLS settings from
+// SetNetwork.
 func GetHttpClient(opts ...ClientOption) *http.Client {
 	cfg := &clientConfig{
 		timeout:     30 * time.Second,
@@ -53,15 +68,19 @@ func GetHttpClient(opts ...ClientOption) *http.Client {
 		opt(cfg)
 	}
 
-	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
-	var transport http.RoundTripper = baseTransport
+	var transport http.RoundTripper = networkTransport{}
+	if cfg.responseCache {
+		// Below the credentials, so responses are cached per credential actually sent
+		transport = cacheTransport{next: transport}
+	}
+	transport = credentialTransport{next: transport}
 
 	if traceConfig, ok := resolveHTTPLogConfig(cfg); ok {
 		transport = httpmiddlewares.NewLogger(traceConfig)(transport)
 	}
 
 	return &http.Client{
-		Transport: transport,
+		Transport: offlineTransport{next: mirrorTransport{next: retryTransport{next: transport}}},
 		Timeout:   cfg.timeout,
 	}
 }
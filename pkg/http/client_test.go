@@ -1,7 +1,11 @@
 package http
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/properties"
@@ -104,3 +108,44 @@ func restoreHTTPLogProperties(t *testing.T) {
 	properties.Set("http.log", "")
 	properties.Set("http.logs", "")
 }
+
+func TestOfflineModeRefusesRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	SetOffline(true)
+	t.Cleanup(func() { SetOffline(false) })
+
+	_, err := GetHttpClient(WithTimeout(time.Second)).Get(server.URL)
+	var offlineErr *OfflineError
+	if !errors.As(err, &offlineErr) {
+		t.Fatalf("expected an OfflineError, got %v", err)
+	}
+	if offlineErr.URL != server.URL {
+		t.Fatalf("expected the refused URL %s, got %s", server.URL, offlineErr.URL)
+	}
+	if requests != 0 {
+		t.Fatalf("expected no requests while offline, got %d", requests)
+	}
+
+	SetOffline(false)
+	resp, err := GetHttpClient(WithTimeout(time.Second)).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected requests to succeed once online: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestOfflineEnv(t *testing.T) {
+	t.Setenv(OfflineEnv, "1")
+	if !IsOffline() {
+		t.Fatalf("expected %s=1 to enable offline mode", OfflineEnv)
+	}
+	t.Setenv(OfflineEnv, "false")
+	if IsOffline() {
+		t.Fatalf("expected %s=false to leave offline mode disabled", OfflineEnv)
+	}
+}
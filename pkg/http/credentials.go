@@ -0,0 +1,401 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// Credential types
+const (
+	CredentialBearer = "bearer"
+	CredentialBasic  = "basic"
+	CredentialHeader = "header"
+)
+
+// credentialHelperTimeout bounds how long a credential helper command may run
+const credentialHelperTimeout = 30 * time.Second
+
+// credentialRule is a credential with its parsed host pattern. The output of a credential
+// helper is kept for the rest of the process, so the command runs at most once.
+type credentialRule struct {
+	pattern string
+	host    string // Lower case hostname, "*.example.com" matches any subdomain
+	port    string // Empty matches any port
+	path    string // Path prefix, matched on segment boundaries
+	cred    types.Credential
+
+	helperOnce   sync.Once
+	helperOutput string
+	helperErr    error
+}
+
+var (
+	credentialsMu sync.RWMutex
+	credentials   []*credentialRule
+)
+
+// CredentialInfo describes a configured credential without its secret, for diagnostics
+type CredentialInfo struct {
+	Pattern string
+	Type    string
+	Source  string // Where the secret comes from, e.g. "$NEXUS_TOKEN", "~/.netrc" or "command"
+}
+
+// SetCredentials replaces the credentials added to the requests of every client from
+// GetHttpClient. Token and Password must reference environment variables, so secrets are never
+// written into deps.yaml.
+func SetCredentials(creds map[string]types.Credential) error {
+	rules := make([]*credentialRule, 0, len(creds))
+	for pattern, cred := range creds {
+		rule, err := newCredentialRule(pattern, cred)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	// The most specific pattern wins
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].pattern < rules[j].pattern
+	})
+
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	credentials = rules
+	return nil
+}
+
+// Credentials describes the credentials set by SetCredentials
+func Credentials() []CredentialInfo {
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+	infos := make([]CredentialInfo, 0, len(credentials))
+	for _, rule := range credentials {
+		infos = append(infos, CredentialInfo{Pattern: rule.pattern, Type: rule.cred.Type, Source: rule.source()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Pattern < infos[j].Pattern })
+	return infos
+}
+
+func newCredentialRule(pattern string, cred types.Credential) (*credentialRule, error) {
+	raw := strings.TrimSpace(pattern)
+	if _, rest, ok := strings.Cut(raw, "://"); ok {
+		raw = rest
+	}
+	hostPort, path, _ := strings.Cut(raw, "/")
+	host, port := hostPort, ""
+	if h, p, ok := strings.Cut(hostPort, ":"); ok {
+		host, port = h, p
+	}
+	if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return nil, fmt.Errorf("invalid credentials host pattern %q, use e.g. nexus.example.com or *.example.com", pattern)
+	}
+
+	if cred.Type == "" {
+		switch {
+		case cred.Header != "":
+			cred.Type = CredentialHeader
+		case cred.Username != "" || cred.Netrc:
+			cred.Type = CredentialBasic
+		default:
+			cred.Type = CredentialBearer
+		}
+	}
+	cred.Type = strings.ToLower(cred.Type)
+
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("credentials for %s: %s", pattern, fmt.Sprintf(format, args...))
+	}
+	for field, value := range map[string]string{"token": cred.Token, "password": cred.Password} {
+		if value != "" && !strings.Contains(value, "$") {
+			return nil, invalid("%s must reference an environment variable such as ${NEXUS_TOKEN}, not contain the secret", field)
+		}
+	}
+	switch cred.Type {
+	case CredentialBearer, CredentialHeader:
+		if cred.Token == "" && cred.Command == "" {
+			return nil, invalid("%s auth needs a token or a command", cred.Type)
+		}
+		if cred.Type == CredentialHeader && cred.Header == "" {
+			return nil, invalid("header auth needs the name of the header")
+		}
+	case CredentialBasic:
+		if !cred.Netrc && (cred.Username == "" || (cred.Password == "" && cred.Command == "")) {
+			return nil, invalid("basic auth needs a username and a password or command, or netrc")
+		}
+	default:
+		return nil, invalid("unknown type %q, use bearer, basic or header", cred.Type)
+	}
+
+	return &credentialRule{
+		pattern: pattern,
+		host:    strings.ToLower(host),
+		port:    port,
+		path:    strings.Trim(path, "/"),
+		cred:    cred,
+	}, nil
+}
+
+func (r *credentialRule) matches(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if wildcard, ok := strings.CutPrefix(r.host, "*."); ok {
+		if !strings.HasSuffix(host, "."+wildcard) {
+			return false
+		}
+	} else if host != r.host {
+		return false
+	}
+	if r.port != "" && u.Port() != r.port {
+		return false
+	}
+	if r.path == "" {
+		return true
+	}
+	path := strings.Trim(u.Path, "/")
+	return path == r.path || strings.HasPrefix(path, r.path+"/")
+}
+
+// source describes where the secret comes from without revealing it
+func (r *credentialRule) source() string {
+	switch {
+	case r.cred.Command != "":
+		return "command"
+	case r.cred.Netrc:
+		return "~/.netrc"
+	case r.cred.Token != "":
+		return r.cred.Token
+	default:
+		return r.cred.Password
+	}
+}
+
+// apply adds the credential to req, failing if its secret cannot be resolved
+func (r *credentialRule) apply(req *http.Request) error {
+	switch r.cred.Type {
+	case CredentialBasic:
+		username, password, err := r.basic(req.URL.Hostname())
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+	case CredentialHeader:
+		token, err := r.secret(r.cred.Token)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(r.cred.Header, token)
+	default:
+		token, err := r.secret(r.cred.Token)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+func (r *credentialRule) basic(host string) (string, string, error) {
+	if r.cred.Netrc {
+		login, password, err := netrcLogin(host)
+		if err != nil {
+			return "", "", err
+		}
+		if r.cred.Username != "" {
+			login = os.ExpandEnv(r.cred.Username)
+		}
+		return login, password, nil
+	}
+	password, err := r.secret(r.cred.Password)
+	return os.ExpandEnv(r.cred.Username), password, err
+}
+
+// secret returns the output of the credential helper if there is one, otherwise value with its
+// environment variables expanded
+func (r *credentialRule) secret(value string) (string, error) {
+	if r.cred.Command != "" {
+		r.helperOnce.Do(func() {
+			r.helperOutput, r.helperErr = runCredentialHelper(r.cred.Command)
+		})
+		return r.helperOutput, r.helperErr
+	}
+	var missing []string
+	expanded := os.Expand(value, func(name string) string {
+		v := os.Getenv(name)
+		if v == "" {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+func runCredentialHelper(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("credential helper failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	secret := strings.TrimSpace(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("credential helper printed nothing")
+	}
+	return secret, nil
+}
+
+// netrcLogin returns the login and password for host from $NETRC or ~/.netrc, falling back
+// to its default entry
+func netrcLogin(host string) (string, string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		path = filepath.Join(home, ".netrc")
+		if runtime.GOOS == "windows" {
+			if _, err := os.Stat(path); err != nil {
+				path = filepath.Join(home, "_netrc")
+			}
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read netrc: %w", err)
+	}
+
+	type entry struct{ login, password string }
+	var machine, fallback, current *entry
+	var tokens []string
+	inMacro := false
+	for _, line := range strings.Split(string(data), "\n") {
+		// A macro definition runs until the next blank line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				tokens = append(tokens, fields[:i]...)
+				inMacro = true
+				break
+			}
+		}
+		if !inMacro {
+			tokens = append(tokens, fields...)
+		}
+	}
+	next := func(i *int) string {
+		*i++
+		if *i < len(tokens) {
+			return tokens[*i]
+		}
+		return ""
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			current = nil
+			if strings.EqualFold(next(&i), host) && machine == nil {
+				machine = &entry{}
+				current = machine
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &entry{}
+				current = fallback
+			}
+		case "login":
+			if login := next(&i); current != nil {
+				current.login = login
+			}
+		case "password":
+			if password := next(&i); current != nil {
+				current.password = password
+			}
+		case "account":
+			next(&i)
+		}
+	}
+	if machine == nil {
+		machine = fallback
+	}
+	if machine == nil || machine.password == "" {
+		return "", "", fmt.Errorf("no netrc entry with a password for %s in %s", host, path)
+	}
+	return machine.login, machine.password, nil
+}
+
+// credentialHeaders returns the headers credentials may be sent in, so responses fetched with
+// different credentials are cached apart
+func credentialHeaders() []string {
+	headers := []string{"Authorization", "Private-Token", "Job-Token"}
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+	for _, rule := range credentials {
+		if rule.cred.Header != "" && !slices.Contains(headers, http.CanonicalHeaderKey(rule.cred.Header)) {
+			headers = append(headers, http.CanonicalHeaderKey(rule.cred.Header))
+		}
+	}
+	return headers
+}
+
+// credentialTransport authenticates requests to hosts with credentials from SetCredentials.
+// It runs after the HTTP logger, so the secrets it adds are never logged. Requests that are
+// already authenticated, e.g. by a GitHub token, are left alone.
+type credentialTransport struct {
+	next http.RoundTripper
+}
+
+func (t credentialTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	credentialsMu.RLock()
+	var rule *credentialRule
+	for _, r := range credentials {
+		if r.matches(req.URL) {
+			rule = r
+			break
+		}
+	}
+	credentialsMu.RUnlock()
+
+	if rule == nil || req.Header.Get("Authorization") != "" || (rule.cred.Header != "" && req.Header.Get(rule.cred.Header) != "") {
+		return t.next.RoundTrip(req)
+	}
+
+	authenticated := req.Clone(req.Context())
+	if err := rule.apply(authenticated); err != nil {
+		return nil, fmt.Errorf("credentials for %s: %w", rule.pattern, err)
+	}
+	logger.V(4).Infof("Using %s credentials for %s from %s", rule.cred.Type, req.URL.Host, rule.source())
+	return t.next.RoundTrip(authenticated)
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+type mirrorRule struct {
+	types.Mirror
+	pattern *regexp.Regexp
+}
+
+var (
+	mirrorsMu sync.RWMutex
+	mirrors   []mirrorRule
+)
+
+// SetMirrors replaces the mirror rules applied by every client from GetHttpClient.
+// Rules are tried in order and the first matching prefix wins.
+func SetMirrors(rules []types.Mirror) error {
+	compiled := make([]mirrorRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Prefix == "" || rule.URL == "" {
+			return fmt.Errorf("mirror requires both prefix and url: %+v", rule)
+		}
+		if _, err := url.Parse(rule.URL); err != nil {
+			return fmt.Errorf("invalid mirror url %s: %w", rule.URL, err)
+		}
+		compiled = append(compiled, mirrorRule{Mirror: rule, pattern: compileMirrorPrefix(rule.Prefix)})
+	}
+
+	mirrorsMu.Lock()
+	defer mirrorsMu.Unlock()
+	mirrors = compiled
+	return nil
+}
+
+// compileMirrorPrefix turns a prefix into an anchored pattern whose first group is the part
+// being replaced. The match must end on a path boundary so get.helm.sh does not match get.helm.sh.evil.com.
+func compileMirrorPrefix(prefix string) *regexp.Regexp {
+	prefix = stripScheme(prefix)
+	head, tail, wildcard := strings.Cut(prefix, "*")
+
+	expr := "^(" + regexp.QuoteMeta(head) + ")"
+	if wildcard {
+		parts := strings.Split(tail, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		expr += ".+?" + strings.Join(parts, ".+?")
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		expr += "(?:[/?#]|$)"
+	}
+	return regexp.MustCompile(expr)
+}
+
+func stripScheme(rawURL string) string {
+	if _, rest, ok := strings.Cut(rawURL, "://"); ok {
+		return rest
+	}
+	return rawURL
+}
+
+// matchMirror returns the rule matching rawURL and the mirrored URL, or false if no rule matches.
+func matchMirror(rawURL string) (mirrorRule, string, bool) {
+	mirrorsMu.RLock()
+	defer mirrorsMu.RUnlock()
+
+	target := stripScheme(rawURL)
+	for _, rule := range mirrors {
+		loc := rule.pattern.FindStringSubmatchIndex(target)
+		if loc == nil {
+			continue
+		}
+		rest := strings.TrimPrefix(target[loc[3]:], "/")
+		mirrored := strings.TrimSuffix(rule.URL, "/")
+		if rest != "" {
+			mirrored += "/" + rest
+		}
+		return rule, mirrored, true
+	}
+	return mirrorRule{}, "", false
+}
+
+// MirrorURL returns the mirror URL that requests for rawURL are sent to, or false if no mirror applies.
+// Lock files and receipts should keep rawURL.
+func MirrorURL(rawURL string) (string, bool) {
+	_, mirrored, ok := matchMirror(rawURL)
+	return mirrored, ok
+}
+
+// mirrorTransport sends requests to the first matching mirror, falling back to the original
+// URL when the rule allows it and the mirror fails or does not have the file
+type mirrorTransport struct {
+	next http.RoundTripper
+}
+
+func (t mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule, mirrored, ok := matchMirror(req.URL.String())
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	mirrorURL, err := url.Parse(mirrored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror url %s for %s: %w", mirrored, req.URL, err)
+	}
+
+	mirrorReq := req.Clone(req.Context())
+	mirrorReq.URL = mirrorURL
+	mirrorReq.Host = ""
+	// Like a cross-host redirect, credentials meant for the origin are not sent to the mirror
+	if mirrorURL.Host != req.URL.Host {
+		mirrorReq.Header.Del("Authorization")
+	}
+	logger.V(3).Infof("Using mirror %s for %s", mirrored, req.URL)
+
+	resp, err := t.next.RoundTrip(mirrorReq)
+	if !rule.Fallback || !mirrorFailed(resp, err) {
+		if err == nil {
+			absoluteLocation(resp, mirrorURL)
+		}
+		return resp, err
+	}
+
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		logger.V(2).Infof("Mirror %s returned %s, falling back to %s", mirrored, resp.Status, req.URL)
+	} else {
+		logger.V(2).Infof("Mirror %s failed (%v), falling back to %s", mirrored, err, req.URL)
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("mirror %s failed and the request body for %s cannot be replayed", mirrored, req.URL)
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.next.RoundTrip(req)
+}
+
+// mirrorFailed reports whether a mirror response should be retried against the origin
+func mirrorFailed(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusNotFound || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// absoluteLocation resolves relative redirects against the mirror, since the client would
+// otherwise resolve them against the original URL
+func absoluteLocation(resp *http.Response, base *url.URL) {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return
+	}
+	if u, err := base.Parse(loc); err == nil {
+		resp.Header.Set("Location", u.String())
+	}
+}
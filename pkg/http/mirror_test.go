@@ -0,0 +1,93 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setMirrors(t *testing.T, rules ...types.Mirror) {
+	t.Helper()
+	if err := SetMirrors(rules); err != nil {
+		t.Fatalf("SetMirrors: %v", err)
+	}
+	t.Cleanup(func() { _ = SetMirrors(nil) })
+}
+
+func TestMirrorURL(t *testing.T) {
+	setMirrors(t,
+		types.Mirror{Prefix: "github.com/*/releases/download", URL: "https://artifactory.example.com/github/"},
+		types.Mirror{Prefix: "get.helm.sh", URL: "https://nexus.example.com/helm"},
+	)
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://github.com/helm/helm/releases/download/v3.14.0/helm.tar.gz", "https://artifactory.example.com/github/helm/helm/releases/download/v3.14.0/helm.tar.gz"},
+		{"https://get.helm.sh/helm-v3.14.0-linux-amd64.tar.gz", "https://nexus.example.com/helm/helm-v3.14.0-linux-amd64.tar.gz"},
+		{"https://get.helm.sh", "https://nexus.example.com/helm"},
+		{"https://get.helm.sh.example.org/helm.tar.gz", ""},
+		{"https://github.com/helm/helm/archive/v3.14.0.tar.gz", ""},
+		{"https://api.github.com/repos/helm/helm/releases", ""},
+	}
+	for _, tt := range tests {
+		mirrored, ok := MirrorURL(tt.url)
+		if ok != (tt.expected != "") || mirrored != tt.expected {
+			t.Errorf("MirrorURL(%s) = %q, %v; expected %q", tt.url, mirrored, ok, tt.expected)
+		}
+	}
+}
+
+func TestSetMirrorsRejectsIncompleteRules(t *testing.T) {
+	if err := SetMirrors([]types.Mirror{{Prefix: "get.helm.sh"}}); err == nil {
+		t.Fatalf("expected an error for a mirror without a url")
+	}
+}
+
+func TestMirrorTransport(t *testing.T) {
+	var originRequests int
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originRequests++
+		_, _ = w.Write([]byte("origin"))
+	}))
+	defer origin.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("mirror " + r.URL.Path))
+	}))
+	defer mirror.Close()
+
+	get := func(url string) (string, int) {
+		t.Helper()
+		resp, err := GetHttpClient(WithTimeout(time.Second)).Get(url)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.StatusCode
+	}
+
+	setMirrors(t, types.Mirror{Prefix: origin.URL, URL: mirror.URL + "/proxy"})
+	if body, _ := get(origin.URL + "/files/tool"); body != "mirror /proxy/files/tool" {
+		t.Fatalf("expected the request to be served by the mirror, got %q", body)
+	}
+	if _, status := get(origin.URL + "/missing"); status != http.StatusNotFound || originRequests != 0 {
+		t.Fatalf("expected a 404 from the mirror without fallback, got %d with %d origin requests", status, originRequests)
+	}
+
+	setMirrors(t, types.Mirror{Prefix: origin.URL, URL: mirror.URL + "/proxy", Fallback: true})
+	if body, _ := get(origin.URL + "/missing"); body != "origin" || originRequests != 1 {
+		t.Fatalf("expected fallback to the origin, got %q with %d origin requests", body, originRequests)
+	}
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/deps/pkg/types"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Environment variables used when the matching setting is empty. Proxies are read from the
+// standard HTTPS_PROXY, HTTP_PROXY and NO_PROXY variables.
+const (
+	CAFileEnv     = "DEPS_CA_FILE"
+	ClientCertEnv = "DEPS_CLIENT_CERT"
+	ClientKeyEnv  = "DEPS_CLIENT_KEY"
+)
+
+// NetworkConfig is the effective network configuration of the HTTP clients. Each Source
+// names the setting or environment variable a value came from.
+type NetworkConfig struct {
+	Proxy            string // The HTTPS proxy, or else the HTTP proxy, with its password redacted
+	ProxySource      string
+	NoProxy          string
+	NoProxySource    string
+	CAFile           string
+	CAFileSource     string
+	ClientCert       string
+	ClientCertSource string
+	ClientKey        string
+}
+
+var (
+	networkMu sync.RWMutex
+	network   NetworkConfig
+	transport *http.Transport
+)
+
+// SetNetwork configures the proxy, trusted CAs and client certificate of every client from
+// GetHttpClient, including clients created before it is called. Settings left empty fall back
+// to their environment variables.
+func SetNetwork(settings types.Settings) error {
+	cfg := NetworkConfig{}
+	cfg.CAFile, cfg.CAFileSource = setting(settings.CAFile, "settings.ca_file", CAFileEnv)
+	cfg.ClientCert, cfg.ClientCertSource = setting(settings.ClientCert, "settings.client_cert", ClientCertEnv)
+	cfg.ClientKey, _ = setting(settings.ClientKey, "settings.client_key", ClientKeyEnv)
+
+	proxy := httpproxy.FromEnvironment()
+	cfg.ProxySource = proxyEnvSource()
+	if settings.Proxy != "" {
+		if err := validateProxy(settings.Proxy); err != nil {
+			return err
+		}
+		proxy.HTTPProxy, proxy.HTTPSProxy = settings.Proxy, settings.Proxy
+		cfg.ProxySource = "settings.proxy"
+	}
+	cfg.NoProxy, cfg.NoProxySource = setting(settings.NoProxy, "settings.no_proxy", "NO_PROXY", "no_proxy")
+	proxy.NoProxy = cfg.NoProxy
+	cfg.Proxy = redactProxy(proxy.HTTPSProxy)
+	if cfg.Proxy == "" {
+		cfg.Proxy = redactProxy(proxy.HTTPProxy)
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	proxyFunc := proxy.ProxyFunc()
+	base.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	tlsConfig, err := clientTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	base.TLSClientConfig = tlsConfig
+
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	if transport != nil {
+		transport.CloseIdleConnections()
+	}
+	network, transport = cfg, base
+	return nil
+}
+
+// GetNetwork returns the network configuration set by SetNetwork
+func GetNetwork() NetworkConfig {
+	if _, err := currentTransport(); err != nil {
+		return NetworkConfig{}
+	}
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return network
+}
+
+// currentTransport returns the transport set by SetNetwork, configuring one from the
+// environment if it has not been called
+func currentTransport() (*http.Transport, error) {
+	networkMu.RLock()
+	t := transport
+	networkMu.RUnlock()
+	if t != nil {
+		return t, nil
+	}
+	if err := SetNetwork(types.Settings{}); err != nil {
+		return nil, err
+	}
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return transport, nil
+}
+
+// networkTransport sends requests with the transport set by SetNetwork, so every client shares
+// its connections and picks up configuration loaded after the client was created
+type networkTransport struct{}
+
+func (networkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t, err := currentTransport()
+	if err != nil {
+		return nil, err
+	}
+	return t.RoundTrip(req)
+}
+
+func clientTLSConfig(cfg NetworkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle (%s): %w", cfg.CAFileSource, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s (%s)", cfg.CAFile, cfg.CAFileSource)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCert != "" {
+		key := cfg.ClientKey
+		if key == "" {
+			key = cfg.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate (%s): %w", cfg.ClientCertSource, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if cfg.ClientKey != "" {
+		return nil, fmt.Errorf("a client key is configured without a client certificate")
+	}
+	return tlsConfig, nil
+}
+
+// setting returns value if it is set, otherwise the first set environment variable, along with its source
+func setting(value, name string, envs ...string) (string, string) {
+	if value = strings.TrimSpace(value); value != "" {
+		return value, name
+	}
+	for _, env := range envs {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			return v, env
+		}
+	}
+	return "", ""
+}
+
+func proxyEnvSource() string {
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(env) != "" {
+			return env
+		}
+	}
+	return ""
+}
+
+func validateProxy(proxy string) error {
+	raw := proxy
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy %q, use e.g. http://proxy.example.com:3128", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	}
+	return fmt.Errorf("invalid proxy %q, the scheme must be http, https or socks5", proxy)
+}
+
+func redactProxy(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	raw := proxy
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return proxy
+	}
+	return u.Redacted()
+}
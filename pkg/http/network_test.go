@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setNetwork(t *testing.T, settings types.Settings) {
+	t.Helper()
+	if err := SetNetwork(settings); err != nil {
+		t.Fatalf("SetNetwork: %v", err)
+	}
+	t.Cleanup(func() { _ = SetNetwork(types.Settings{}) })
+}
+
+func TestNetworkProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	setNetwork(t, types.Settings{Proxy: proxy.URL, NoProxy: "internal.example.com"})
+	client := GetHttpClient(WithTimeout(time.Second))
+
+	resp, err := client.Get("http://downloads.example.com/tool.tar.gz")
+	if err != nil {
+		t.Fatalf("GET through the proxy: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "via proxy" || len(proxied) != 1 || proxied[0] != "http://downloads.example.com/tool.tar.gz" {
+		t.Fatalf("expected the request to go through the proxy, got %q with %v", body, proxied)
+	}
+
+	// Hosts in no_proxy are connected to directly, which fails for this unresolvable host
+	if resp, err := client.Get("http://internal.example.com/tool.tar.gz"); err == nil {
+		_ = resp.Body.Close()
+	}
+	if len(proxied) != 1 {
+		t.Fatalf("expected no_proxy hosts to bypass the proxy, got %v", proxied)
+	}
+
+	if network := GetNetwork(); network.Proxy != proxy.URL || network.ProxySource != "settings.proxy" {
+		t.Fatalf("unexpected effective proxy %q from %q", network.Proxy, network.ProxySource)
+	}
+}
+
+func TestNetworkCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	setNetwork(t, types.Settings{})
+	if resp, err := GetHttpClient(WithTimeout(time.Second)).Get(server.URL); err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("expected the self-signed certificate to be rejected without a CA bundle")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	setNetwork(t, types.Settings{CAFile: caFile})
+	resp, err := GetHttpClient(WithTimeout(time.Second)).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the certificate to be trusted with the CA bundle: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestSetNetworkRejectsInvalidSettings(t *testing.T) {
+	t.Cleanup(func() { _ = SetNetwork(types.Settings{}) })
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		settings types.Settings
+		expected string
+	}{
+		{types.Settings{Proxy: "ftp://proxy.example.com"}, "scheme"},
+		{types.Settings{CAFile: notPEM}, "no PEM certificates"},
+		{types.Settings{CAFile: notPEM + ".missing"}, "settings.ca_file"},
+		{types.Settings{ClientCert: notPEM}, "client certificate"},
+		{types.Settings{ClientKey: notPEM}, "without a client certificate"},
+	}
+	for _, tt := range tests {
+		if err := SetNetwork(tt.settings); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("SetNetwork(%+v) = %v, expected an error containing %q", tt.settings, err, tt.expected)
+		}
+	}
+}
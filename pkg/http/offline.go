@@ -0,0 +1,50 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// OfflineEnv enables offline mode for the whole process when set to a true value
+const OfflineEnv = "DEPS_OFFLINE"
+
+var offline atomic.Bool
+
+// SetOffline enables or disables offline mode, in which every client from GetHttpClient
+// refuses to make requests.
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// IsOffline reports whether offline mode is enabled by SetOffline or DEPS_OFFLINE.
+func IsOffline() bool {
+	if offline.Load() {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(os.Getenv(OfflineEnv))
+	return enabled
+}
+
+// OfflineError is returned for requests refused because offline mode is enabled
+type OfflineError struct {
+	URL string
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("offline mode: refusing to fetch %s", e.URL)
+}
+
+// offlineTransport refuses every request while offline mode is enabled
+type offlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if IsOffline() {
+		return nil, &OfflineError{URL: req.URL.String()}
+	}
+	return t.next.RoundTrip(req)
+}
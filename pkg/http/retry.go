@@ -0,0 +1,243 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// DefaultRetryPolicy is used for every setting a policy leaves empty
+var DefaultRetryPolicy = types.RetryPolicy{
+	MaxAttempts:   3,
+	Backoff:       500 * time.Millisecond,
+	MaxBackoff:    30 * time.Second,
+	MaxRetryAfter: 5 * time.Minute,
+	Statuses:      []string{"408", "429", "5xx"},
+}
+
+var (
+	retryMu     sync.RWMutex
+	retryPolicy = DefaultRetryPolicy
+)
+
+// SetRetryPolicy replaces the retry policy of every client from GetHttpClient, filling
+// in defaults for the settings it leaves empty.
+func SetRetryPolicy(policy types.RetryPolicy) error {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultRetryPolicy.Backoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if policy.MaxRetryAfter <= 0 {
+		policy.MaxRetryAfter = DefaultRetryPolicy.MaxRetryAfter
+	}
+	if len(policy.Statuses) == 0 {
+		policy.Statuses = DefaultRetryPolicy.Statuses
+	}
+	for _, status := range policy.Statuses {
+		if !validStatusPattern(status) {
+			return fmt.Errorf("invalid retry status %q, use a code such as 503 or a class such as 5xx", status)
+		}
+	}
+
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicy = policy
+	return nil
+}
+
+// GetRetryPolicy returns the retry policy set by SetRetryPolicy
+func GetRetryPolicy() types.RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return retryPolicy
+}
+
+// Backoff returns the wait before retry number attempt (1 for the first retry): the policy's
+// backoff doubled for each further retry plus up to 50% jitter, capped at its MaxBackoff.
+func Backoff(policy types.RetryPolicy, attempt int) time.Duration {
+	delay := policy.Backoff
+	for i := 1; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay/2) + 1))
+	}
+	return min(delay, policy.MaxBackoff)
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type retryContextKey int
+
+const (
+	retryCounterKey retryContextKey = iota
+	maxAttemptsKey
+)
+
+// CountRetries returns a context whose requests add every retry they make to the returned counter
+func CountRetries(ctx context.Context) (context.Context, *atomic.Int32) {
+	counter := &atomic.Int32{}
+	return context.WithValue(ctx, retryCounterKey, counter), counter
+}
+
+// RecordRetry adds a retry made outside the HTTP client, such as resuming an interrupted
+// transfer, to the counter of ctx
+func RecordRetry(ctx context.Context) {
+	if counter, ok := ctx.Value(retryCounterKey).(*atomic.Int32); ok {
+		counter.Add(1)
+	}
+}
+
+// WithMaxAttempts overrides the policy's MaxAttempts for requests made with the returned context
+func WithMaxAttempts(ctx context.Context, attempts int) context.Context {
+	return context.WithValue(ctx, maxAttemptsKey, attempts)
+}
+
+// retryTransport retries requests that fail with a transient error or a retryable status
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := GetRetryPolicy()
+	if attempts, ok := req.Context().Value(maxAttemptsKey).(int); ok && attempts > 0 {
+		policy.MaxAttempts = attempts
+	}
+	// Requests with a body can only be retried if it can be read again
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= policy.MaxAttempts || !replayable || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		var reason string
+		switch {
+		case err != nil:
+			if !isTransientError(err) {
+				return resp, err
+			}
+			wait, reason = Backoff(policy, attempt), err.Error()
+		case retryableStatus(policy.Statuses, resp.StatusCode):
+			wait, reason = Backoff(policy, attempt), resp.Status
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				// Retrying sooner than the server asked would only be rejected again
+				if after > policy.MaxRetryAfter {
+					logger.V(2).Infof("Not retrying %s: %s asked to retry in %s, more than the %s allowed", req.URL, resp.Status, after, policy.MaxRetryAfter)
+					return resp, nil
+				}
+				if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < after {
+					logger.V(2).Infof("Not retrying %s: %s asked to retry in %s, after the request times out", req.URL, resp.Status, after)
+					return resp, nil
+				}
+				wait = after
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		logger.V(2).Infof("Retrying %s in %s (attempt %d/%d): %s", req.URL, wait.Round(time.Millisecond), attempt+1, policy.MaxAttempts, reason)
+		RecordRetry(req.Context())
+		if err := Sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isTransientError reports whether a request error is worth retrying: timeouts, resets and
+// connections closed before the response was complete
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.Temporary()
+}
+
+// retryableStatus reports whether code matches one of the patterns, e.g. "503" or "5xx"
+func retryableStatus(patterns []string, code int) bool {
+	status := strconv.Itoa(code)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == status || (strings.HasSuffix(pattern, "xx") && len(pattern) == 3 && pattern[0] == status[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func validStatusPattern(pattern string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if len(pattern) != 3 || pattern[0] < '1' || pattern[0] > '5' {
+		return false
+	}
+	if pattern[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(pattern)
+	return err == nil
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setRetryPolicy(t *testing.T, policy types.RetryPolicy) {
+	t.Helper()
+	if err := SetRetryPolicy(policy); err != nil {
+		t.Fatalf("SetRetryPolicy: %v", err)
+	}
+	t.Cleanup(func() { _ = SetRetryPolicy(DefaultRetryPolicy) })
+}
+
+// failingServer responds with status to the first failures requests and with 200 afterwards
+func failingServer(t *testing.T, failures int, status int, header http.Header) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryTransport(t *testing.T) {
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		expected int // Status of the final response
+		requests int
+	}{
+		{"retries 5xx until it succeeds", 2, http.StatusServiceUnavailable, http.StatusOK, 3},
+		{"gives up after max attempts", 5, http.StatusBadGateway, http.StatusBadGateway, 3},
+		{"retries 429", 1, http.StatusTooManyRequests, http.StatusOK, 2},
+		{"does not retry 404", 1, http.StatusNotFound, http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := failingServer(t, tt.failures, tt.status, nil)
+			resp, err := GetHttpClient(WithTimeout(time.Second)).Get(server.URL)
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.expected || *requests != tt.requests {
+				t.Errorf("expected HTTP %d after %d requests, got HTTP %d after %d", tt.expected, tt.requests, resp.StatusCode, *requests)
+			}
+		})
+	}
+}
+
+func TestRetryTransportHonorsRetryAfter(t *testing.T) {
+	// Retry-After is honored in full, beyond the backoff limit
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	server, _ := failingServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	start := time.Now()
+	resp, err := GetHttpClient(WithTimeout(5 * time.Second)).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusOK || elapsed < time.Second {
+		t.Errorf("expected a 200 after waiting for Retry-After, got HTTP %d after %s", resp.StatusCode, elapsed)
+	}
+}
+
+func TestRetryTransportGivesUpOnLongRetryAfter(t *testing.T) {
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxRetryAfter: time.Minute})
+
+	server, requests := failingServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"3600"}})
+	start := time.Now()
+	resp, err := GetHttpClient(WithTimeout(5 * time.Second)).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusServiceUnavailable || *requests != 1 || elapsed > time.Second {
+		t.Errorf("expected the 503 without a retry, got HTTP %d after %d requests and %s", resp.StatusCode, *requests, elapsed)
+	}
+}
+
+func TestRetryTransportCountsRetries(t *testing.T) {
+	setRetryPolicy(t, types.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	server, _ := failingServer(t, 2, http.StatusInternalServerError, nil)
+	ctx, retries := CountRetries(context.Background())
+	req, _ := http.NewRequestWithContext(WithMaxAttempts(ctx, 2), http.MethodGet, server.URL, nil)
+	resp, err := GetHttpClient(WithTimeout(time.Second)).Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || retries.Load() != 1 {
+		t.Errorf("expected WithMaxAttempts to stop after 1 retry, got HTTP %d after %d retries", resp.StatusCode, retries.Load())
+	}
+}
+
+func TestSetRetryPolicyRejectsInvalidStatuses(t *testing.T) {
+	t.Cleanup(func() { _ = SetRetryPolicy(DefaultRetryPolicy) })
+	for _, status := range []string{"5x", "abc", "600"} {
+		if err := SetRetryPolicy(types.RetryPolicy{Statuses: []string{status}}); err == nil {
+			t.Errorf("expected an error for retry status %q", status)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("120"); !ok || d != 2*time.Minute {
+		t.Errorf("retryAfter(120) = %s, %v", d, ok)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Errorf("expected an HTTP date an hour from now to wait about an hour, got %s, %v", d, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Errorf("expected an invalid Retry-After to be ignored")
+	}
+}
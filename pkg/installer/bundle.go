@@ -0,0 +1,37 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/utils"
+)
+
+// copyFromBundle serves a download from the bundle's artifacts instead of the network.
+func (i *Installer) copyFromBundle(url, dest string, resolution *types.Resolution, t *task.Task) error {
+	src, ok := i.options.Bundle.ArtifactPath(url)
+	if !ok {
+		return fmt.Errorf("offline: %s is not in the bundle %s", url, i.options.Bundle.Dir)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dest, err)
+	}
+	if err := utils.CopyFile(src, dest); err != nil {
+		return fmt.Errorf("failed to copy %s from the bundle: %w", filepath.Base(src), err)
+	}
+
+	if !i.options.SkipChecksum && resolution.Checksum != "" {
+		if err := checksum.VerifyChecksum(dest, resolution.Checksum); err != nil {
+			_ = os.Remove(dest)
+			return err
+		}
+		t.V(3).Infof("✓ Checksum verified: %s (bundle)", resolution.Checksum)
+	}
+	t.SetDescription("Copied from bundle")
+	return nil
+}
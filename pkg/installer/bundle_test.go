@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/bundle"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bundle installs", func() {
+	var (
+		tmpDir  string
+		binDir  string
+		plat    platform.Platform
+		content []byte
+		pkg     types.Package
+		b       *bundle.Bundle
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "bundle-install-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		binDir = filepath.Join(tmpDir, "bin")
+		plat = platform.Platform{OS: "linux", Arch: "amd64"}
+		content = []byte("#!/bin/sh\necho bundled\n")
+		pkg = types.Package{Name: "tool", Manager: "github_release", Repo: "example/tool"}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(content)
+		}))
+		lock := &types.LockFile{Dependencies: map[string]types.LockEntry{
+			"tool": {Version: "1.2.3", Platforms: map[string]types.PlatformEntry{
+				plat.String(): {URL: server.URL + "/tool", Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256(content))},
+			}},
+		}}
+		archive := filepath.Join(tmpDir, "tools.tar")
+		_, err = bundle.Create(archive, lock, &types.DepsConfig{Registry: map[string]types.Package{"tool": pkg}}, bundle.Options{}, nil)
+		server.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err = bundle.Open(archive, filepath.Join(tmpDir, "extracted"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("installs from the bundle's lock file and artifacts", func() {
+		inst := NewWithConfig(b.Config, WithBinDir(binDir), WithTmpDir(tmpDir), WithCacheDir(""), WithOS(plat.OS, plat.Arch),
+			WithReceiptsDir(""), WithVersionsDir(""), WithBundle(b))
+		Expect(inst.offline()).To(BeTrue())
+
+		lockFile, err := inst.loadFrozenLock()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lockFile).To(BeIdenticalTo(b.Lock))
+
+		Expect(inst.installFromLock(context.Background(), "tool", pkg, lockFile, &task.Task{}, nil)).To(Succeed())
+		data, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+	})
+
+	It("names the URL missing from the bundle", func() {
+		inst := NewWithConfig(b.Config, WithBinDir(binDir), WithTmpDir(tmpDir), WithBundle(b))
+		err := inst.copyFromBundle("https://example.com/other", filepath.Join(tmpDir, "other"), &types.Resolution{}, &task.Task{})
+		Expect(err).To(MatchError(ContainSubstring("offline: https://example.com/other is not in the bundle")))
+	})
+})
@@ -1,6 +1,9 @@
 package installer
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type VersionMismatchError struct {
 	Tool, Expected, Got string
@@ -9,3 +12,22 @@ type VersionMismatchError struct {
 func (e *VersionMismatchError) Error() string {
 	return fmt.Sprintf("%s: version mismatch — installed %s, expected %s", e.Tool, e.Got, e.Expected)
 }
+
+// LockEntryMissingError is returned by frozen installs when deps-lock.yaml has
+// no entry for a dependency, or no entry for the target platform.
+type LockEntryMissingError struct {
+	Tool      string
+	Platform  string
+	Available []string
+}
+
+func (e *LockEntryMissingError) Error() string {
+	if e.Platform == "" {
+		return fmt.Sprintf("%s: not found in lock file, run 'deps lock' first", e.Tool)
+	}
+	msg := fmt.Sprintf("%s: platform %s not found in lock file", e.Tool, e.Platform)
+	if len(e.Available) > 0 {
+		msg += fmt.Sprintf(" (locked: %s)", strings.Join(e.Available, ", "))
+	}
+	return msg + ", run 'deps lock --platforms " + e.Platform + "' first"
+}
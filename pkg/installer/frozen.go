@@ -0,0 +1,197 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/config"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/utils"
+	versionpkg "github.com/flanksource/deps/pkg/version"
+)
+
+// offline reports whether network access is disabled, by WithOffline or for the whole process.
+func (i *Installer) offline() bool {
+	return i.options.Offline || depshttp.IsOffline()
+}
+
+// frozen reports whether installs must come from the lock file only. Offline installs are always
+// frozen, as resolving versions and URLs needs the network.
+func (i *Installer) frozen() bool {
+	return i.options.FrozenLock || i.offline()
+}
+
+// loadFrozenLock loads deps-lock.yaml (or the bundle's lock file) for a frozen install, failing if it is missing.
+func (i *Installer) loadFrozenLock() (*types.LockFile, error) {
+	if i.options.Bundle != nil {
+		return i.options.Bundle.Lock, nil
+	}
+	lockFile, err := config.LoadLockFile("")
+	if err != nil {
+		flag := "--frozen"
+		if i.offline() {
+			flag = "--offline"
+		}
+		return nil, fmt.Errorf("%s requires a lock file: %w", flag, err)
+	}
+	return lockFile, nil
+}
+
+// checkLockedVersion fails when requested, e.g. from kubectl@1.30, does not match the version
+// locked for name, so a frozen install never silently installs a different version
+func checkLockedVersion(lockFile *types.LockFile, name, requested string) error {
+	switch requested {
+	case "", "latest", "stable", "*":
+		return nil
+	}
+	entry, ok := lockFile.Dependencies[name]
+	if !ok {
+		// Reported by lockedPlatformEntry
+		return nil
+	}
+	if versionpkg.Normalize(requested) == versionpkg.Normalize(entry.Version) {
+		return nil
+	}
+	if constraint, err := versionpkg.ParseConstraint(requested); err == nil && constraint.Check(entry.Version) {
+		return nil
+	}
+	return fmt.Errorf("%s@%s was requested but the lock file has %s, run 'deps lock' to update it or install without --frozen", name, requested, entry.Version)
+}
+
+// validateFrozenLock checks that every dependency has a lock entry for the target
+// platform, so a frozen install fails before anything is downloaded.
+func (i *Installer) validateFrozenLock(lockFile *types.LockFile, names []string) error {
+	for _, name := range names {
+		if _, _, err := i.lockedPlatformEntry(lockFile, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockedPlatformEntry returns the locked version and platform entry for name on the target platform.
+func (i *Installer) lockedPlatformEntry(lockFile *types.LockFile, name string) (string, types.PlatformEntry, error) {
+	entry, ok := lockFile.Dependencies[name]
+	if !ok {
+		return "", types.PlatformEntry{}, &LockEntryMissingError{Tool: name}
+	}
+
+	plat := i.getPlatform().String()
+	platformEntry, ok := entry.Platforms[plat]
+	if !ok {
+		available := make([]string, 0, len(entry.Platforms))
+		for p := range entry.Platforms {
+			available = append(available, p)
+		}
+		sort.Strings(available)
+		return "", types.PlatformEntry{}, &LockEntryMissingError{Tool: name, Platform: plat, Available: available}
+	}
+
+	if platformEntry.URL == "" {
+		return "", types.PlatformEntry{}, fmt.Errorf("%s: lock entry for %s has no url", name, plat)
+	}
+	if platformEntry.Checksum == "" && !i.options.SkipChecksum {
+		return "", types.PlatformEntry{}, fmt.Errorf("%s: lock entry for %s has no checksum, re-run 'deps lock' or use --skip-checksum", name, plat)
+	}
+
+	return entry.Version, platformEntry, nil
+}
+
+// previewFromLock builds an install plan directly from the lock file, without
+// consulting the package manager for versions, URLs or checksums.
+func (i *Installer) previewFromLock(name string, pkg types.Package, lockFile *types.LockFile, t *task.Task) (*InstallPreview, error) {
+	version, locked, err := i.lockedPlatformEntry(lockFile, name)
+	if err != nil {
+		return nil, err
+	}
+
+	plat := i.getPlatform()
+
+	// Mirror the apache manager, which defaults its packages to directory mode at resolve time
+	resolvedPkg := pkg
+	if resolvedPkg.Manager == "apache" && resolvedPkg.Mode == "" {
+		resolvedPkg.Mode = "directory"
+	}
+
+	resolution := &types.Resolution{
+		Package:     resolvedPkg,
+		Version:     version,
+		Platform:    plat,
+		DownloadURL: locked.URL,
+		Checksum:    locked.Checksum,
+		Size:        locked.Size,
+		IsArchive:   locked.Archive,
+		BinaryPath:  locked.BinaryPath,
+	}
+	if pkg.Extract != nil {
+		resolution.IsArchive = *pkg.Extract
+	}
+
+	preview := &InstallPreview{
+		Package:          pkg,
+		Manager:          pkg.Manager,
+		Platform:         plat,
+		RequestedInput:   version,
+		RequestedVersion: version,
+		ResolvedVersion:  version,
+		EffectiveVersion: version,
+		Resolution:       resolution,
+	}
+
+	if !i.options.Force {
+		if existingVersion := versionpkg.CheckExistingInstallation(t, name, pkg, version, i.options.BinDir, i.options.OSOverride); existingVersion != "" {
+			preview.AlreadyInstalled = true
+			preview.ExistingVersion = existingVersion
+			if path, ok := i.getInstalledPath(name, pkg); ok {
+				preview.ExistingPath = path
+			}
+		}
+	}
+
+	return preview, nil
+}
+
+// installFromLock installs name using exactly the URL and checksum recorded in the lock file.
+// It makes no version discovery or resolve calls, so it never touches GitHub or other APIs.
+func (i *Installer) installFromLock(ctx context.Context, name string, pkg types.Package, lockFile *types.LockFile, t *task.Task, result *types.InstallResult) error {
+	if result != nil {
+		result.Package = pkg
+	}
+
+	preview, err := i.previewFromLock(name, pkg, lockFile, t)
+	if err != nil {
+		if result != nil {
+			result.Status = types.InstallStatusFailed
+		}
+		return err
+	}
+
+	if result != nil {
+		result.Version = types.Version{Version: preview.DisplayVersion()}
+	}
+
+	if preview.AlreadyInstalled {
+		t.Infof("✓ %s@%s is already installed", name, preview.ExistingVersion)
+		t.Success()
+		if result != nil {
+			result.Status = types.InstallStatusAlreadyInstalled
+		}
+		return nil
+	}
+
+	if i.activateStoredVersion(name, preview.DisplayVersion(), preview.Resolution.Package, t) {
+		if result != nil {
+			result.Status = types.InstallStatusAlreadyInstalled
+		}
+		return nil
+	}
+
+	t.SetName(fmt.Sprintf("%s@%s", name, preview.DisplayVersion()))
+	t.V(3).Infof("Installing %s from lock file: %s", name, utils.RedactURL(preview.Resolution.DownloadURL))
+
+	// Locked resolutions always carry a download URL, so no manager is needed
+	return i.executePackageInstallation(ctx, name, pkg, preview, nil, t, result)
+}
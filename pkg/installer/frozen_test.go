@@ -0,0 +1,237 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/oci/ocitest"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/receipt"
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Frozen lock installs", func() {
+	var (
+		tmpDir   string
+		binDir   string
+		plat     platform.Platform
+		testTask *task.Task
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "frozen-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		binDir = filepath.Join(tmpDir, "bin")
+		plat = platform.Platform{OS: "linux", Arch: "amd64"}
+		testTask = &task.Task{}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	lockWith := func(name string, entry types.PlatformEntry) *types.LockFile {
+		return &types.LockFile{
+			Dependencies: map[string]types.LockEntry{
+				name: {
+					Version:   "1.2.3",
+					Platforms: map[string]types.PlatformEntry{plat.String(): entry},
+				},
+			},
+		}
+	}
+
+	It("fails when the dependency is not locked", func() {
+		inst := New(WithBinDir(binDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true))
+		err := inst.validateFrozenLock(&types.LockFile{}, []string{"jq"})
+
+		var missing *LockEntryMissingError
+		Expect(err).To(BeAssignableToTypeOf(missing))
+		Expect(err.Error()).To(ContainSubstring("jq: not found in lock file"))
+	})
+
+	It("fails when the target platform is not locked", func() {
+		inst := New(WithBinDir(binDir), WithOS("darwin", "arm64"), WithFrozenLock(true))
+		lock := lockWith("jq", types.PlatformEntry{URL: "https://example.com/jq", Checksum: "sha256:abc"})
+
+		err := inst.validateFrozenLock(lock, []string{"jq"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("platform darwin-arm64 not found in lock file"))
+		Expect(err.Error()).To(ContainSubstring("linux-amd64"))
+	})
+
+	It("requires a locked checksum unless checksums are skipped", func() {
+		lock := lockWith("jq", types.PlatformEntry{URL: "https://example.com/jq"})
+
+		inst := New(WithBinDir(binDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true))
+		Expect(inst.validateFrozenLock(lock, []string{"jq"})).To(MatchError(ContainSubstring("has no checksum")))
+
+		inst = New(WithBinDir(binDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true), WithSkipChecksum(true))
+		Expect(inst.validateFrozenLock(lock, []string{"jq"})).To(Succeed())
+	})
+
+	It("fails when the requested version is not the locked one", func() {
+		lock := lockWith("kubectl", types.PlatformEntry{URL: "https://example.com/kubectl", Checksum: "sha256:abc"})
+
+		for _, requested := range []string{"", "latest", "1.2.3", "v1.2.3", "1.2", "^1.0"} {
+			Expect(checkLockedVersion(lock, "kubectl", requested)).To(Succeed(), requested)
+		}
+		Expect(checkLockedVersion(lock, "kubectl", "1.30")).To(MatchError(ContainSubstring("kubectl@1.30 was requested but the lock file has 1.2.3")))
+		Expect(checkLockedVersion(lock, "kubectl", ">=2.0.0")).To(HaveOccurred())
+	})
+
+	It("builds the resolution verbatim from the lock entry", func() {
+		lock := lockWith("helm", types.PlatformEntry{
+			URL:        "https://get.helm.sh/helm-v1.2.3-linux-amd64.tar.gz",
+			Checksum:   "sha256:abc",
+			Size:       42,
+			Archive:    true,
+			BinaryPath: "linux-amd64/helm",
+		})
+		inst := New(WithBinDir(binDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true))
+
+		preview, err := inst.previewFromLock("helm", types.Package{Name: "helm", Manager: "direct"}, lock, testTask)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(preview.DisplayVersion()).To(Equal("1.2.3"))
+		Expect(preview.Resolution.DownloadURL).To(Equal("https://get.helm.sh/helm-v1.2.3-linux-amd64.tar.gz"))
+		Expect(preview.Resolution.Checksum).To(Equal("sha256:abc"))
+		Expect(preview.Resolution.ChecksumURL).To(BeEmpty())
+		Expect(preview.Resolution.IsArchive).To(BeTrue())
+		Expect(preview.Resolution.BinaryPath).To(Equal("linux-amd64/helm"))
+	})
+
+	It("downloads the locked URL and verifies the locked checksum", func() {
+		content := []byte("#!/bin/sh\necho frozen\n")
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			_, _ = w.Write(content)
+		}))
+		defer server.Close()
+
+		sum := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+		lock := lockWith("tool", types.PlatformEntry{URL: server.URL + "/tool", Checksum: sum})
+		receiptsDir := filepath.Join(tmpDir, "receipts")
+		inst := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true),
+			WithReceiptsDir(receiptsDir), WithVersionsDir(""))
+
+		result := &types.InstallResult{}
+		err := inst.installFromLock(context.Background(), "tool", types.Package{Name: "tool", Manager: "github_release"}, lock, testTask, result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests.Load()).To(Equal(int32(1)))
+		Expect(result.Status).To(Equal(types.InstallStatusInstalled))
+		Expect(result.DownloadURL).To(Equal(server.URL + "/tool"))
+
+		data, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+
+		rec, err := receipt.Load(receiptsDir, binDir, "tool")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.Version).To(Equal("1.2.3"))
+		Expect(rec.SourceURL).To(Equal(server.URL + "/tool"))
+		Expect(rec.Checksum).To(Equal(sum))
+		Expect(rec.Files).To(ConsistOf(filepath.Join(binDir, "tool")))
+	})
+
+	It("fails when the download does not match the locked checksum", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("tampered"))
+		}))
+		defer server.Close()
+
+		lock := lockWith("tool", types.PlatformEntry{URL: server.URL + "/tool", Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("original")))})
+		inst := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithOS(plat.OS, plat.Arch), WithFrozenLock(true), WithReceiptsDir(""), WithVersionsDir(""))
+
+		err := inst.installFromLock(context.Background(), "tool", types.Package{Name: "tool"}, lock, testTask, nil)
+		Expect(err).To(MatchError(ContainSubstring("checksum mismatch")))
+		Expect(filepath.Join(binDir, "tool")).NotTo(BeAnExistingFile())
+	})
+
+	It("installs offline from the download cache only", func() {
+		content := []byte("#!/bin/sh\necho offline\n")
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			_, _ = w.Write(content)
+		}))
+		defer server.Close()
+
+		lock := lockWith("tool", types.PlatformEntry{URL: server.URL + "/tool", Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256(content))})
+		pkg := types.Package{Name: "tool", Manager: "github_release"}
+		cacheDir := filepath.Join(tmpDir, "cache")
+
+		online := New(WithBinDir(filepath.Join(tmpDir, "online")), WithTmpDir(tmpDir), WithCacheDir(cacheDir), WithOS(plat.OS, plat.Arch),
+			WithFrozenLock(true), WithReceiptsDir(""), WithVersionsDir(""))
+		Expect(online.installFromLock(context.Background(), "tool", pkg, lock, testTask, nil)).To(Succeed())
+		Expect(requests.Load()).To(Equal(int32(1)))
+
+		offline := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithCacheDir(cacheDir), WithOS(plat.OS, plat.Arch),
+			WithOffline(true), WithReceiptsDir(""), WithVersionsDir(""))
+		Expect(offline.frozen()).To(BeTrue())
+		Expect(offline.installFromLock(context.Background(), "tool", pkg, lock, testTask, nil)).To(Succeed())
+		Expect(requests.Load()).To(Equal(int32(1)))
+
+		data, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+	})
+
+	It("installs OCI artifacts through the download cache", func() {
+		registry := ocitest.NewRegistry(GinkgoT())
+		content := []byte("#!/bin/sh\necho artifact\n")
+		layer := registry.PushBlob("application/octet-stream", content)
+		lock := lockWith("tool", types.PlatformEntry{URL: "oci://" + registry.Host + "/org/tool@" + layer.Digest, Checksum: layer.Digest})
+		pkg := types.Package{Name: "tool", Manager: "oci_artifact", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+		cacheDir := filepath.Join(tmpDir, "cache")
+
+		online := New(WithBinDir(filepath.Join(tmpDir, "online")), WithTmpDir(tmpDir), WithCacheDir(cacheDir), WithOS(plat.OS, plat.Arch),
+			WithFrozenLock(true), WithReceiptsDir(""), WithVersionsDir(""))
+		Expect(online.installFromLock(context.Background(), "tool", pkg, lock, testTask, nil)).To(Succeed())
+
+		offline := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithCacheDir(cacheDir), WithOS(plat.OS, plat.Arch),
+			WithOffline(true), WithReceiptsDir(""), WithVersionsDir(""))
+		Expect(offline.installFromLock(context.Background(), "tool", pkg, lock, testTask, nil)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+		Expect(filepath.Join(binDir, stagingDirName)).NotTo(BeADirectory())
+	})
+
+	It("names the artifact missing from the cache when offline", func() {
+		lock := lockWith("tool", types.PlatformEntry{URL: "https://example.com/releases/tool", Checksum: "sha256:abc"})
+		inst := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithCacheDir(filepath.Join(tmpDir, "cache")), WithOS(plat.OS, plat.Arch),
+			WithOffline(true), WithReceiptsDir(""), WithVersionsDir(""))
+
+		err := inst.installFromLock(context.Background(), "tool", types.Package{Name: "tool"}, lock, testTask, nil)
+		Expect(err).To(MatchError(ContainSubstring("offline: https://example.com/releases/tool is not in the download cache")))
+		Expect(err.Error()).To(ContainSubstring(filepath.Join(tmpDir, "cache")))
+	})
+
+	It("explains that images cannot be installed offline", func() {
+		lock := lockWith("crane", types.PlatformEntry{URL: "docker://gcr.io/go-containerregistry/crane@sha256:abc", Checksum: "sha256:abc"})
+		inst := New(WithBinDir(binDir), WithTmpDir(tmpDir), WithCacheDir(filepath.Join(tmpDir, "cache")), WithOS(plat.OS, plat.Arch),
+			WithOffline(true), WithReceiptsDir(""), WithVersionsDir(""))
+
+		err := inst.installFromLock(context.Background(), "crane", types.Package{Name: "crane", Manager: "docker"}, lock, testTask, nil)
+		Expect(err).To(MatchError(ContainSubstring("cannot be installed offline or from a bundle")))
+	})
+
+	It("refuses to resolve versions when offline", func() {
+		inst := New(WithBinDir(binDir), WithOS(plat.OS, plat.Arch), WithOffline(true))
+
+		_, err := inst.previewPackageInstallation(context.Background(), "jq", "1.7.1", types.Package{Name: "jq", Manager: "github_release", Repo: "jqlang/jq"}, testTask)
+		Expect(err).To(MatchError(ContainSubstring("offline: cannot resolve jq@1.7.1")))
+	})
+})
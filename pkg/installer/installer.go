@@ -5,27 +5,33 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/flanksource/clicky/task"
 	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/flanksource/deps/pkg/checksum"
 	"github.com/flanksource/deps/pkg/config"
 	"github.com/flanksource/deps/pkg/download"
 	"github.com/flanksource/deps/pkg/extract"
+	depshttp "github.com/flanksource/deps/pkg/http"
 	"github.com/flanksource/deps/pkg/manager"
 	_ "github.com/flanksource/deps/pkg/manager/apache" // Register apache manager
 	_ "github.com/flanksource/deps/pkg/manager/direct" // Register direct manager
+	_ "github.com/flanksource/deps/pkg/manager/docker" // Register docker manager
+	_ "github.com/flanksource/deps/pkg/manager/gitea"  // Register gitea manager
 	_ "github.com/flanksource/deps/pkg/manager/github" // Register github managers
 	_ "github.com/flanksource/deps/pkg/manager/gitlab" // Register gitlab manager
 	_ "github.com/flanksource/deps/pkg/manager/golang" // Register golang manager
 	_ "github.com/flanksource/deps/pkg/manager/maven"  // Register maven manager
 	_ "github.com/flanksource/deps/pkg/manager/url"    // Register url manager
+	"github.com/flanksource/deps/pkg/oci"
 	"github.com/flanksource/deps/pkg/pipeline"
 	"github.com/flanksource/deps/pkg/platform"
 	"github.com/flanksource/deps/pkg/plugin"
 	_ "github.com/flanksource/deps/pkg/plugin/builtin" // Register built-in plugins
+	"github.com/flanksource/deps/pkg/receipt"
 	"github.com/flanksource/deps/pkg/system"
 	"github.com/flanksource/deps/pkg/types"
 	"github.com/flanksource/deps/pkg/utils"
@@ -52,6 +58,7 @@ type Installer struct {
 	plugins    *plugin.Registry
 	options    InstallOptions
 	depsConfig *types.DepsConfig
+	hosts      *hostLimiter
 }
 
 func (i Installer) GetOptions() InstallOptions {
@@ -60,17 +67,7 @@ func (i Installer) GetOptions() InstallOptions {
 
 // New creates a new installer with the given options
 func New(opts ...InstallOption) *Installer {
-	options := DefaultOptions()
-	for _, opt := range opts {
-		opt(&options)
-	}
-
-	return &Installer{
-		managers:   NewManagerRegistry(),
-		plugins:    GetPluginRegistry(),
-		options:    options,
-		depsConfig: nil, // Will be set via WithDepsConfig
-	}
+	return NewWithConfig(nil, opts...) // depsConfig will be set via WithDepsConfig
 }
 
 // NewWithConfig creates a new installer with the given options and config
@@ -85,6 +82,7 @@ func NewWithConfig(depsConfig *types.DepsConfig, opts ...InstallOption) *Install
 		plugins:    GetPluginRegistry(),
 		options:    options,
 		depsConfig: depsConfig,
+		hosts:      newHostLimiter(options.HostJobs),
 	}
 }
 
@@ -120,45 +118,98 @@ func ParseTools(args []string) []ToolSpec {
 	return tools
 }
 
-// Install installs a single tool with task progress tracking
+// Install installs a single tool, after the packages it requires, with task progress tracking
 func (i *Installer) Install(name, version string, t *task.Task) error {
+	if err := i.installRequirements(name, t); err != nil {
+		return err
+	}
 	return i.installTool(ToolSpec{Name: name, Version: version}, t)
 }
 
-// InstallWithResult installs a single tool and returns detailed installation result
+// InstallWithResult installs a single tool, after the packages it requires, and returns detailed installation result
 func (i *Installer) InstallWithResult(name, version string, t *task.Task) (*types.InstallResult, error) {
+	if err := i.installRequirements(name, t); err != nil {
+		return &types.InstallResult{Platform: i.getPlatform(), BinDir: i.options.BinDir, Status: types.InstallStatusFailed, Error: err}, err
+	}
 	return i.installToolWithResult(ToolSpec{Name: name, Version: version}, t)
 }
 
-// InstallMultiple installs multiple tools
+// InstallMultiple installs multiple tools, waiting for all of them and returning an error
+// that lists every failed install.
 func (i *Installer) InstallMultiple(tools []ToolSpec) error {
-	// Start tasks for each tool
-	for _, tool := range tools {
-		t := tool // Capture for closure
-		taskName := t.Name
-		if t.Version != "" {
-			taskName = fmt.Sprintf("%s@%s", t.Name, t.Version)
-		}
+	_, err := i.InstallMultipleWithResults(tools)
+	return err
+}
 
-		task.StartTask(taskName, func(ctx flanksourceContext.Context, task *task.Task) (interface{}, error) {
-			if err := i.installTool(t, task); err != nil {
-				return nil, fmt.Errorf("failed to install %s: %w", t.Name, err)
-			}
-			return nil, nil
-		})
+// InstallMultipleWithResults installs multiple tools and the packages they require, with at most
+// WithJobs installs running at once. Results are returned in install order: tools in the order given,
+// each preceded by the packages it requires.
+func (i *Installer) InstallMultipleWithResults(tools []ToolSpec) ([]*types.InstallResult, error) {
+	var registry map[string]types.Package
+	if i.depsConfig != nil {
+		registry = i.depsConfig.Registry
+	}
+	graph, err := resolveRequirements(tools, registry)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	jobs := make([]installJob, len(graph.order))
+	for idx, pkgName := range graph.order {
+		tool := ToolSpec{Name: pkgName, Version: graph.versions[pkgName]}
+		name := tool.Name
+		if tool.Version != "" {
+			name = fmt.Sprintf("%s@%s", tool.Name, tool.Version)
+		}
+		jobs[idx] = installJob{pkg: pkgName, name: name, requires: graph.requires[pkgName], run: func(ctx context.Context, t *task.Task) (*types.InstallResult, error) {
+			result, err := i.installToolWithResult(tool, t)
+			if err != nil {
+				return result, graph.explain(pkgName, fmt.Errorf("failed to install %s: %w", tool.Name, err))
+			}
+			return result, nil
+		}}
+	}
+	return i.runInstalls(jobs)
 }
 
-// InstallFromConfig installs all dependencies from deps.yaml
+// InstallFromConfig installs all dependencies from deps.yaml, waiting for all of them and returning
+// an error that lists every failed install.
 func (i *Installer) InstallFromConfig(t *task.Task) error {
+	_, err := i.InstallFromConfigWithResults(t)
+	return err
+}
 
-	// Load global config (defaults + user)
+// InstallFromConfigWithResults installs all dependencies from deps.yaml and the packages they require,
+// with at most WithJobs installs running at once. Results are returned in install order: dependencies
+// by name, each preceded by the packages it requires.
+func (i *Installer) InstallFromConfigWithResults(t *task.Task) ([]*types.InstallResult, error) {
+	// Load global config (defaults + user), or the registry packed into the bundle
 	depsConfig := config.GetGlobalRegistry()
+	if i.options.Bundle != nil {
+		depsConfig = i.options.Bundle.Config
+	}
 
 	if err := config.ValidateConfig(depsConfig); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	names := make([]string, 0, len(depsConfig.Dependencies))
+	for name := range depsConfig.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]ToolSpec, len(names))
+	for idx, name := range names {
+		tools[idx] = ToolSpec{Name: name, Version: depsConfig.Dependencies[name]}
+	}
+	graph, err := resolveRequirements(tools, depsConfig.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.frozen() {
+		return i.installFrozenFromConfig(depsConfig, graph)
 	}
 
 	// Try to load deps-lock.yaml for locked versions
@@ -167,69 +218,112 @@ func (i *Installer) InstallFromConfig(t *task.Task) error {
 		t.Debugf("No lock file found (%v), using version constraints from deps.yaml", lockErr)
 	}
 
-	// Create tasks for each dependency
-	for name, constraint := range depsConfig.Dependencies {
-		depName := name // Capture for closure
-		depConstraint := constraint
+	jobs := make([]installJob, 0, len(graph.order))
+	for _, depName := range graph.order {
+		depConstraint := graph.versions[depName]
 
 		var version string
-		var useNewPackageManager bool
-
 		// Check if we have this dependency in the lock file
 		if lockFile != nil {
 			if lockEntry, exists := lockFile.Dependencies[depName]; exists {
 				version = lockEntry.Version
-				useNewPackageManager = true
-			}
-		}
-
-		// If not in lock file, check if it's in the new registry format
-		if version == "" {
-			if _, exists := depsConfig.Registry[depName]; exists {
-				// Use new package manager system
-				useNewPackageManager = true
-				// Version resolution will be done inside the task where we have access to logging
 			}
 		}
 
-		// Create installation task
 		taskName := depName
 		if version != "" {
 			taskName = fmt.Sprintf("%s@%s", depName, version)
 		}
 
-		task.StartTask(taskName, func(ctx flanksourceContext.Context, task *task.Task) (interface{}, error) {
-			if useNewPackageManager {
-				pkg := depsConfig.Registry[depName]
-
-				// Resolve version constraint if not already resolved from lock file
-				resolvedVersion := version
-				if resolvedVersion == "" {
-					task.V(3).Infof("Resolving version constraint '%s' for %s", depConstraint, depName)
-					mgr, err := i.managers.GetForPackage(pkg)
-					if err != nil {
-						return nil, fmt.Errorf("failed to get package manager for %s: %w", depName, err)
-					}
-
-					resolvedVersion, err = i.resolveVersionConstraint(ctx.Context, mgr, pkg, depConstraint, task)
-					if err != nil {
-						return nil, fmt.Errorf("failed to resolve version constraint for %s: %w", depName, err)
-					}
-					task.Infof("Resolved %s version: %s -> %s", depName, depConstraint, resolvedVersion)
+		jobs = append(jobs, installJob{pkg: depName, name: taskName, requires: graph.requires[depName], run: func(ctx context.Context, task *task.Task) (*types.InstallResult, error) {
+			result := &types.InstallResult{Platform: i.getPlatform(), BinDir: i.options.BinDir}
+			installName, pkg, exists := findPackage(depsConfig.Registry, depName)
+			if !exists {
+				return result, fmt.Errorf("dependency %s not found in registry - please add it to deps.yaml registry section", depName)
+			}
+
+			// Resolve version constraint if not already resolved from lock file
+			resolvedVersion := version
+			if resolvedVersion == "" {
+				task.V(3).Infof("Resolving version constraint '%s' for %s", depConstraint, depName)
+				mgr, err := i.managers.GetForPackage(pkg)
+				if err != nil {
+					return result, fmt.Errorf("failed to get package manager for %s: %w", depName, err)
 				}
 
-				return nil, i.installWithNewPackageManager(ctx.Context, depName, resolvedVersion, pkg, task)
-			} else {
-				return nil, fmt.Errorf("dependency %s not found in registry - please add it to deps.yaml registry section", depName)
+				resolvedVersion, err = i.resolveVersionConstraint(ctx, mgr, pkg, depConstraint, task)
+				if err != nil {
+					return result, graph.explain(depName, fmt.Errorf("failed to resolve version constraint for %s: %w", depName, err))
+				}
+				task.Infof("Resolved %s version: %s -> %s", depName, depConstraint, resolvedVersion)
+			}
+			// A locked version may predate a constraint added by a package requiring it
+			if err := graph.check(depName, resolvedVersion); err != nil {
+				return result, err
 			}
-		})
+
+			return result, i.installWithNewPackageManagerWithResult(ctx, installName, resolvedVersion, pkg, task, result)
+		}})
 	}
 
-	return nil
+	return i.runInstalls(jobs)
+}
+
+// installFrozenFromConfig installs every dependency in deps.yaml, and the packages they require,
+// from the lock file only
+func (i *Installer) installFrozenFromConfig(depsConfig *types.DepsConfig, graph *requirementGraph) ([]*types.InstallResult, error) {
+	lockFile, err := i.loadFrozenLock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.validateFrozenLock(lockFile, graph.order); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]installJob, len(graph.order))
+	for idx, depName := range graph.order {
+		installName, pkg, _ := findPackage(depsConfig.Registry, depName)
+		jobs[idx] = installJob{pkg: depName, name: depName, requires: graph.requires[depName], run: func(ctx context.Context, t *task.Task) (*types.InstallResult, error) {
+			result := &types.InstallResult{Platform: i.getPlatform(), BinDir: i.options.BinDir}
+			return result, i.installFromLock(ctx, installName, pkg, lockFile, t, result)
+		}}
+	}
+
+	return i.runInstalls(jobs)
+}
+
+// installFrozenTool installs a single tool from the lock file only
+func (i *Installer) installFrozenTool(tool ToolSpec, t *task.Task, result *types.InstallResult) error {
+	lockFile, err := i.loadFrozenLock()
+	if err != nil {
+		return err
+	}
+
+	if i.depsConfig != nil {
+		if pkg, exists := i.depsConfig.Registry[tool.Name]; exists {
+			if err := checkLockedVersion(lockFile, tool.Name, tool.Version); err != nil {
+				return err
+			}
+			return i.installFromLock(context.Background(), tool.Name, pkg, lockFile, t, result)
+		}
+	}
+	if isGitHubRepoPattern(tool.Name) {
+		pkg := createGitHubPackage(tool.Name)
+		if err := checkLockedVersion(lockFile, pkg.Name, tool.Version); err != nil {
+			return err
+		}
+		return i.installFromLock(context.Background(), pkg.Name, pkg, lockFile, t, result)
+	}
+	return fmt.Errorf("tool %s not found in registry - please add it to deps.yaml registry section", tool.Name)
 }
 
 // installTool handles the installation of a single tool
 func (i *Installer) installTool(tool ToolSpec, t *task.Task) error {
+	if i.frozen() {
+		return i.installFrozenTool(tool, t, nil)
+	}
+
 	// Check if package is defined in new registry format first
 	if i.depsConfig != nil {
 		if pkg, exists := i.depsConfig.Registry[tool.Name]; exists {
@@ -255,6 +349,16 @@ func (i *Installer) installToolWithResult(tool ToolSpec, t *task.Task) (*types.I
 	}
 	startTime := time.Now()
 
+	if i.frozen() {
+		err := i.installFrozenTool(tool, t, result)
+		if err != nil {
+			result.Status = types.InstallStatusFailed
+		}
+		result.Duration = time.Since(startTime)
+		result.Error = err
+		return result, err
+	}
+
 	// Check if package is defined in new registry format first
 	if i.depsConfig != nil {
 		if pkg, exists := i.depsConfig.Registry[tool.Name]; exists {
@@ -384,6 +488,12 @@ func (i *Installer) preparePackageInstallation(ctx context.Context, name, versio
 		return nil, nil, err
 	}
 
+	if !preview.AlreadyInstalled && preview.Resolution != nil &&
+		i.activateStoredVersion(name, preview.DisplayVersion(), preview.Resolution.Package, t) {
+		preview.AlreadyInstalled = true
+		return preview, mgr, nil
+	}
+
 	if preview.AlreadyInstalled {
 		if preview.ExistingVersion != "" {
 			t.Infof("✓ %s@%s is already installed", name, preview.ExistingVersion)
@@ -411,7 +521,20 @@ func (i *Installer) executePackageInstallation(ctx context.Context, name string,
 		return fmt.Errorf("failed to resolve package %s: empty resolution", name)
 	}
 
+	unlock, err := i.lockPackage(name, t)
+	if err != nil {
+		if result != nil {
+			result.Status = types.InstallStatusFailed
+		}
+		return err
+	}
+	defer unlock()
+
+	// Count the requests retried while installing, so the result shows how many attempts it took
+	ctx, retries := depshttp.CountRetries(ctx)
+
 	if result != nil {
+		defer func() { result.Attempts = 1 + int(retries.Load()) }()
 		result.Version = types.Version{Version: actualVersion}
 		result.DownloadURL = resolution.DownloadURL
 		result.ChecksumURL = resolution.ChecksumURL
@@ -420,28 +543,63 @@ func (i *Installer) executePackageInstallation(ctx context.Context, name string,
 		}
 	}
 
-	if resolution.DownloadURL == "" {
-		t.Infof("Installing %s@%s using %s", name, actualVersion, mgr.Name())
+	rec := i.newReceipt(name, actualVersion, preview)
 
-		installOpts := types.InstallOptions{
-			BinDir: i.options.BinDir,
-			Force:  i.options.Force,
+	// Images pinned by digest are pulled by their manager; artifact blobs (oci://) are downloaded
+	if resolution.DownloadURL == "" || oci.IsImageURL(resolution.DownloadURL) {
+		if oci.IsImageURL(resolution.DownloadURL) && i.offline() {
+			if result != nil {
+				result.Status = types.InstallStatusFailed
+			}
+			return fmt.Errorf("%s is pulled from the image %s, which needs network access and cannot be installed offline or from a bundle",
+				name, resolution.DownloadURL)
+		}
+		if mgr == nil {
+			// Frozen installs skip the manager lookup
+			if mgr, err = i.managers.GetForPackage(pkg); err != nil {
+				if result != nil {
+					result.Status = types.InstallStatusFailed
+				}
+				return err
+			}
 		}
+		t.Infof("Installing %s@%s using %s", name, actualVersion, mgr.Name())
 
-		if err := mgr.Install(ctx, resolution, installOpts); err != nil {
+		// The manager installs into a staging directory, so a failed install or verification
+		// leaves the working version in place
+		finalPath := filepath.Join(i.options.BinDir, name)
+		stage, err := i.installWithManager(ctx, mgr, name, finalPath, resolution)
+		if err != nil {
 			if result != nil {
 				result.Status = types.InstallStatusFailed
 			}
 			return fmt.Errorf("installation failed: %w", err)
 		}
+		if err := i.verifyInstallation(actualVersion, stage.staged, pkg, t); err != nil {
+			stage.discard()
+			if result != nil {
+				result.Status = types.InstallStatusFailed
+			}
+			return err
+		}
+		if err := stage.promote(rec); err != nil {
+			if result != nil {
+				result.Status = types.InstallStatusFailed
+			}
+			return err
+		}
+		rec.AddFile(finalPath)
 
-		finalPath := filepath.Join(i.options.BinDir, name)
-		if err := i.finalizeInstallation(actualVersion, finalPath, pkg, t); err != nil {
+		if err := i.finalizeInstallation(actualVersion, finalPath, pkg, t, rec); err != nil {
 			if result != nil {
 				result.Status = types.InstallStatusFailed
 			}
 			return err
 		}
+		if err := i.storeInstalledVersion(name, actualVersion, finalPath, pkg, t, rec); err != nil {
+			t.Warnf("Failed to keep %s@%s in the version store: %v", name, actualVersion, err)
+		}
+		i.saveReceipt(rec, t)
 
 		if result != nil {
 			result.BinDir = i.options.BinDir
@@ -462,15 +620,39 @@ func (i *Installer) executePackageInstallation(ctx context.Context, name string,
 		return err
 	}
 
+	if sum, err := checksum.CalculateBinaryChecksum(downloadPath, checksum.HashTypeSHA256); err == nil {
+		rec.Checksum = checksum.FormatChecksum(sum, checksum.HashTypeSHA256)
+		if result != nil {
+			result.Checksum = rec.Checksum
+		}
+	}
+
 	var finalPath string
+	var stage *staging
 	if extract.IsSystemInstaller(downloadPath) {
 		finalPath, err = i.handleSystemInstaller(downloadPath, name, t)
 	} else if resolution.IsArchive {
-		finalPath, err = i.handleArchiveInstallation(downloadPath, name, actualVersion, resolution, pkg, t)
+		finalPath, stage, err = i.handleArchiveInstallation(downloadPath, name, actualVersion, resolution, pkg, t)
 	} else {
-		finalPath, err = i.handleDirectBinaryInstallation(downloadPath, name)
+		finalPath, stage, err = i.handleDirectBinaryInstallation(downloadPath, name, actualVersion, pkg)
 	}
 	if err != nil {
+		stage.discard()
+		if result != nil {
+			result.Status = types.InstallStatusFailed
+		}
+		return err
+	}
+
+	// Verify the staged copy, so a broken download never replaces a working install
+	if err := i.verifyInstallation(actualVersion, stage.locate(finalPath), pkg, t); err != nil {
+		stage.discard()
+		if result != nil {
+			result.Status = types.InstallStatusFailed
+		}
+		return err
+	}
+	if err := stage.promote(rec); err != nil {
 		if result != nil {
 			result.Status = types.InstallStatusFailed
 		}
@@ -481,12 +663,21 @@ func (i *Installer) executePackageInstallation(ctx context.Context, name string,
 		result.AppDir = filepath.Join(i.options.AppDir, resolution.Package.FolderName(actualVersion))
 	}
 
-	if err := i.finalizeInstallation(actualVersion, finalPath, pkg, t); err != nil {
+	if pkg.WrapperScript != "" && !resolution.IsArchive && strings.HasPrefix(finalPath, i.options.AppDir) {
+		rec.AddFile(filepath.Dir(finalPath))
+	}
+	rec.AddFile(finalPath)
+
+	if err := i.finalizeInstallation(actualVersion, finalPath, pkg, t, rec); err != nil {
 		if result != nil {
 			result.Status = types.InstallStatusFailed
 		}
 		return err
 	}
+	if err := i.storeInstalledVersion(name, actualVersion, finalPath, resolution.Package, t, rec); err != nil {
+		t.Warnf("Failed to keep %s@%s in the version store: %v", name, actualVersion, err)
+	}
+	i.saveReceipt(rec, t)
 
 	if result != nil {
 		result.BinDir = i.options.BinDir
@@ -500,6 +691,68 @@ func (i *Installer) executePackageInstallation(ctx context.Context, name string,
 	return nil
 }
 
+// installWithManager has mgr install the resolution into a directory of its own under the
+// staging directory of finalPath, and stages the binary it installed for promotion to finalPath.
+func (i *Installer) installWithManager(ctx context.Context, mgr manager.PackageManager, name, finalPath string, resolution *types.Resolution) (*staging, error) {
+	stage, err := newStaging(finalPath)
+	if err != nil {
+		return nil, err
+	}
+	workDir, err := os.MkdirTemp(filepath.Dir(stage.staged), name+"-*")
+	if err != nil {
+		stage.discard()
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+	fail := func(err error) (*staging, error) {
+		_ = os.RemoveAll(workDir)
+		stage.discard()
+		return nil, err
+	}
+
+	installOpts := types.InstallOptions{
+		BinDir:  workDir,
+		Force:   i.options.Force,
+		Offline: i.offline(),
+	}
+	if err := mgr.Install(ctx, resolution, installOpts); err != nil {
+		return fail(err)
+	}
+
+	installed, err := managerInstalledFile(workDir, name, resolution.Package.Name)
+	if err != nil {
+		return fail(fmt.Errorf("%s: %w", mgr.Name(), err))
+	}
+	if err := os.Rename(installed, stage.staged); err != nil {
+		return fail(fmt.Errorf("failed to stage %s: %w", name, err))
+	}
+	return stage, nil
+}
+
+// managerInstalledFile returns the binary a manager installed into dir: the file named after the
+// tool or its package, or else the only file there.
+func managerInstalledFile(dir string, names ...string) (string, error) {
+	for _, name := range names {
+		if info, err := os.Lstat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("expected %s to be installed, found %v", names[0], files)
+	}
+	return filepath.Join(dir, files[0]), nil
+}
+
 // moveExtractedDirectory moves an extracted archive directory to the target location
 // It finds the first directory in workDir and moves it to targetDir, renaming as needed
 func (i *Installer) moveExtractedDirectory(workDir, targetDir string, t *task.Task) error {
@@ -587,7 +840,7 @@ func (i *Installer) downloadPackage(ctx context.Context, name, resolvedVersion s
 	plat := resolution.Platform
 	pkg := resolution.Package
 
-	t.Infof("Downloading %s@%s (%s/%s) from %s", name, resolvedVersion, plat.OS, plat.Arch, resolution.DownloadURL)
+	t.Infof("Downloading %s@%s (%s/%s) from %s", name, resolvedVersion, plat.OS, plat.Arch, utils.RedactURL(resolution.DownloadURL))
 
 	// Create bin directory if it doesn't exist
 	if err := os.MkdirAll(i.options.BinDir, 0755); err != nil {
@@ -605,12 +858,15 @@ func (i *Installer) downloadPackage(ctx context.Context, name, resolvedVersion s
 		downloadPath = filepath.Join(i.options.TmpDir, fmt.Sprintf("deps-%s-%s%s", name, resolvedVersion, ext))
 
 		// Download with checksum verification if available
-		if err := i.downloadWithChecksum(resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
+		if err := i.downloadWithChecksum(ctx, resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
 			return "", err
 		}
 	} else if pkg.WrapperScript != "" {
-		// File with wrapper script - download to app directory
-		appPath := filepath.Join(i.options.AppDir, pkg.FolderName(resolvedVersion))
+		// File with wrapper script - download to a staged app directory
+		appPath, err := prepareStaging(filepath.Join(i.options.AppDir, pkg.FolderName(resolvedVersion)))
+		if err != nil {
+			return "", err
+		}
 		if err := os.MkdirAll(appPath, 0755); err != nil {
 			return "", fmt.Errorf("failed to create app directory: %w", err)
 		}
@@ -620,15 +876,18 @@ func (i *Installer) downloadPackage(ctx context.Context, name, resolvedVersion s
 		downloadPath = filepath.Join(appPath, filename)
 		t.SetDescription("Downloading")
 
-		if err := i.downloadWithChecksum(resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
+		if err := i.downloadWithChecksum(ctx, resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
 			return "", fmt.Errorf("failed to download %s: %w", name, err)
 		}
 	} else {
-		// Direct binary download - download directly to bin directory
-		downloadPath = filepath.Join(i.options.BinDir, name)
+		// Direct binary download - download to the staging path next to the binary
+		var err error
+		if downloadPath, err = prepareStaging(filepath.Join(i.options.BinDir, name)); err != nil {
+			return "", err
+		}
 		t.SetDescription("Downloading")
 
-		if err := i.downloadWithChecksum(resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
+		if err := i.downloadWithChecksum(ctx, resolution.DownloadURL, downloadPath, resolution.ChecksumURL, resolution, t); err != nil {
 			return "", fmt.Errorf("failed to download %s: %w", name, err)
 		}
 	}
@@ -663,18 +922,90 @@ func (i *Installer) executePostProcessing(pkg types.Package, workDir, targetPath
 	return evaluator.Execute(celPipeline)
 }
 
-// finalizeInstallation makes the binary executable and reports success.
-func (i *Installer) finalizeInstallation(resolvedVersion, finalPath string, pkg types.Package, t *task.Task) error {
+// verifyInstallation makes the binary at path executable and checks that it was built for the
+// target platform and reports the expected version. path is usually the staged copy, so that a
+// failed check leaves the previous install untouched.
+func (i *Installer) verifyInstallation(resolvedVersion, path string, pkg types.Package, t *task.Task) error {
 	// Make executable (skip for directories and when post-process was used)
 	if len(pkg.PostProcess) == 0 && pkg.Mode != "directory" {
-		fileInfo, err := os.Stat(finalPath)
+		fileInfo, err := os.Stat(path)
 		if err == nil && !fileInfo.IsDir() {
-			if err := os.Chmod(finalPath, 0755); err != nil {
+			if err := os.Chmod(path, 0755); err != nil {
 				return fmt.Errorf("failed to make binary executable: %w", err)
 			}
 		}
 	}
 
+	if pkg.Mode != "directory" && pkg.WrapperScript == "" {
+		if err := i.verifyBinaryPlatform(path, pkg); err != nil {
+			return err
+		}
+	}
+
+	// Verify installed version matches expected
+	// Skip for: cross-platform installs (can't execute), version aliases (nothing concrete to compare)
+	isAlias := resolvedVersion == "stable" || resolvedVersion == "latest" || resolvedVersion == "any"
+	if pkg.VersionCommand == "" || i.isCrossPlatform() || isAlias {
+		return nil
+	}
+	t.SetDescription("Verifying installed version")
+
+	// Resolve binary path and version command using the same logic as CheckExistingInstallation
+	binPath := path
+	versionCmd := pkg.VersionCommand
+	versionCheckMode := pkg.Mode
+
+	// For directory-mode with symlinks, run the file the bin-dir symlink will point to
+	if pkg.Mode == "directory" && len(pkg.Symlinks) > 0 && !versionpkg.ContainsShellOperators(pkg.VersionCommand) {
+		cmdParts := strings.Fields(pkg.VersionCommand)
+		if len(cmdParts) > 0 && cmdParts[0] != "bash" && cmdParts[0] != "sh" {
+			patterns := manager.FilterEntriesByPlatform(pkg.Symlinks, i.getPlatform())
+			if target := symlinkTarget(path, filepath.Base(cmdParts[0]), patterns); target != "" {
+				binPath = target
+				versionCmd = strings.Join(cmdParts[1:], " ")
+				versionCheckMode = ""
+			}
+		}
+	}
+
+	installedVersion, rawOutput, versionErr := versionpkg.GetInstalledVersionWithMode(t, binPath, versionCmd, pkg.VersionRegex, versionCheckMode)
+	if versionErr != nil {
+		if diagMsg := pipeline.DiagnoseLibraryIssues(binPath, t); diagMsg != "" {
+			return fmt.Errorf("%s: version check failed: %w\n%s", pkg.Name, versionErr, diagMsg)
+		}
+		return fmt.Errorf("%s: version check failed: %w", pkg.Name, versionErr)
+	}
+
+	if pkg.VerifyExpr != "" {
+		plat := i.getPlatform()
+		ok, verifyErr := versionpkg.EvaluateVerifyExpr(pkg.VerifyExpr, installedVersion, resolvedVersion, rawOutput, plat.OS, plat.Arch)
+		if verifyErr != nil {
+			return fmt.Errorf("%s: verify_expr failed: %w", pkg.Name, verifyErr)
+		}
+		if !ok {
+			return &VersionMismatchError{
+				Tool:     pkg.Name,
+				Expected: resolvedVersion,
+				Got:      installedVersion,
+			}
+		}
+	} else {
+		status, _ := versionpkg.CompareVersions(installedVersion, resolvedVersion)
+		if status != types.CheckStatusOK && status != types.CheckStatusNewer {
+			return &VersionMismatchError{
+				Tool:     pkg.Name,
+				Expected: resolvedVersion,
+				Got:      installedVersion,
+			}
+		}
+	}
+	return nil
+}
+
+// finalizeInstallation creates bin-dir symlinks and wrapper scripts for a verified install and reports success.
+// Symlinks and wrapper scripts it creates are recorded in rec when non-nil.
+func (i *Installer) finalizeInstallation(resolvedVersion, finalPath string, pkg types.Package, t *task.Task, rec *receipt.Receipt) error {
+
 	// Create symlinks for directory-mode packages
 	if pkg.Mode == "directory" && len(pkg.Symlinks) > 0 {
 		// Filter symlinks by platform
@@ -684,9 +1015,15 @@ func (i *Installer) finalizeInstallation(resolvedVersion, finalPath string, pkg
 		// Create symlinks if any patterns match
 		if len(symlinkPatterns) > 0 {
 			t.SetDescription("Creating symlinks")
-			if err := i.createSymlinks(finalPath, i.options.BinDir, symlinkPatterns, t); err != nil {
+			links, err := i.createSymlinks(finalPath, i.options.BinDir, symlinkPatterns, t)
+			if err != nil {
 				return fmt.Errorf("failed to create symlinks: %w", err)
 			}
+			if rec != nil {
+				for _, link := range links {
+					rec.AddSymlink(link)
+				}
+			}
 		}
 	}
 
@@ -696,64 +1033,8 @@ func (i *Installer) finalizeInstallation(resolvedVersion, finalPath string, pkg
 		if err := i.createWrapperScript(pkg, resolvedVersion, i.options.BinDir, t); err != nil {
 			return fmt.Errorf("failed to create wrapper script: %w", err)
 		}
-	}
-
-	// Verify installed version matches expected
-	// Skip for: cross-platform installs (can't execute), version aliases (nothing concrete to compare)
-	isCrossPlatform := (i.options.OSOverride != "" && i.options.OSOverride != runtime.GOOS) ||
-		(i.options.ArchOverride != "" && i.options.ArchOverride != runtime.GOARCH)
-	isAlias := resolvedVersion == "stable" || resolvedVersion == "latest" || resolvedVersion == "any"
-	if pkg.VersionCommand != "" && !isCrossPlatform && !isAlias {
-		t.SetDescription("Verifying installed version")
-
-		// Resolve binary path and version command using the same logic as CheckExistingInstallation
-		binPath := finalPath
-		versionCmd := pkg.VersionCommand
-		versionCheckMode := pkg.Mode
-
-		// For directory-mode with symlinks, resolve through the symlink in bin-dir
-		if pkg.Mode == "directory" && len(pkg.Symlinks) > 0 && !versionpkg.ContainsShellOperators(pkg.VersionCommand) {
-			cmdParts := strings.Fields(pkg.VersionCommand)
-			if len(cmdParts) > 0 && cmdParts[0] != "bash" && cmdParts[0] != "sh" {
-				symlinkPath := filepath.Join(i.options.BinDir, filepath.Base(cmdParts[0]))
-				if _, err := os.Stat(symlinkPath); err == nil {
-					binPath = symlinkPath
-					versionCmd = strings.Join(cmdParts[1:], " ")
-					versionCheckMode = ""
-				}
-			}
-		}
-
-		installedVersion, rawOutput, versionErr := versionpkg.GetInstalledVersionWithMode(t, binPath, versionCmd, pkg.VersionRegex, versionCheckMode)
-		if versionErr != nil {
-			if diagMsg := pipeline.DiagnoseLibraryIssues(binPath, t); diagMsg != "" {
-				return fmt.Errorf("%s: version check failed: %w\n%s", pkg.Name, versionErr, diagMsg)
-			}
-			return fmt.Errorf("%s: version check failed: %w", pkg.Name, versionErr)
-		}
-
-		if pkg.VerifyExpr != "" {
-			plat := i.getPlatform()
-			ok, verifyErr := versionpkg.EvaluateVerifyExpr(pkg.VerifyExpr, installedVersion, resolvedVersion, rawOutput, plat.OS, plat.Arch)
-			if verifyErr != nil {
-				return fmt.Errorf("%s: verify_expr failed: %w", pkg.Name, verifyErr)
-			}
-			if !ok {
-				return &VersionMismatchError{
-					Tool:     pkg.Name,
-					Expected: resolvedVersion,
-					Got:      installedVersion,
-				}
-			}
-		} else {
-			status, _ := versionpkg.CompareVersions(installedVersion, resolvedVersion)
-			if status != types.CheckStatusOK && status != types.CheckStatusNewer {
-				return &VersionMismatchError{
-					Tool:     pkg.Name,
-					Expected: resolvedVersion,
-					Got:      installedVersion,
-				}
-			}
+		if rec != nil {
+			rec.AddFile(filepath.Join(i.options.BinDir, pkg.Name))
 		}
 	}
 
@@ -765,12 +1046,13 @@ func (i *Installer) finalizeInstallation(resolvedVersion, finalPath string, pkg
 }
 
 // handleArchiveInstallation processes an archive download (extraction, binary finding, post-processing).
-func (i *Installer) handleArchiveInstallation(downloadPath, name, resolvedVersion string, resolution *types.Resolution, pkg types.Package, t *task.Task) (string, error) {
+// The result is staged next to the returned final path and still has to be promoted.
+func (i *Installer) handleArchiveInstallation(downloadPath, name, resolvedVersion string, resolution *types.Resolution, pkg types.Package, t *task.Task) (string, *staging, error) {
 	// Auto-extract archive to working directory immediately after download
 	workDir := filepath.Join(i.options.TmpDir, fmt.Sprintf("deps-extract-%s-%s", name, resolvedVersion))
 
 	if _, extractErr := extract.Extract(downloadPath, workDir, t, extract.WithFullExtract()); extractErr != nil {
-		return "", extractErr
+		return "", nil, extractErr
 	}
 
 	// Set up cleanup
@@ -788,48 +1070,52 @@ func (i *Installer) handleArchiveInstallation(downloadPath, name, resolvedVersio
 	if resolvedPkg.Mode == "directory" {
 		t.SetDescription("Installing directory")
 
-		// Move entire directory to app-dir/{package-name}/ (or app-dir/{name}{version}/ when versioned)
-		targetDir := filepath.Join(i.options.AppDir, resolvedPkg.FolderName(resolvedVersion))
-		if err := i.moveExtractedDirectory(workDir, targetDir, t); err != nil {
-			return "", fmt.Errorf("failed to move directory: %w", err)
+		// Stage the directory next to app-dir/{package-name}/ (or app-dir/{name}{version}/ when versioned)
+		finalPath = filepath.Join(i.options.AppDir, resolvedPkg.FolderName(resolvedVersion))
+		stage, err := newStaging(finalPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := i.moveExtractedDirectory(workDir, stage.staged, t); err != nil {
+			return "", stage, fmt.Errorf("failed to move directory: %w", err)
 		}
 
-		finalPath = targetDir
-
-		// Run post-process operations inside the moved directory (sandboxed)
-		if err := i.executePostProcessing(resolvedPkg, targetDir, targetDir, t); err != nil {
-			return "", fmt.Errorf("failed to execute post-process pipeline: %w", err)
+		// Run post-process operations inside the staged directory (sandboxed)
+		if err := i.executePostProcessing(resolvedPkg, stage.staged, stage.staged, t); err != nil {
+			return "", stage, fmt.Errorf("failed to execute post-process pipeline: %w", err)
 		}
+		return finalPath, stage, nil
+	}
 
-	} else {
-		finalPath = filepath.Join(i.options.BinDir, name)
+	finalPath = filepath.Join(i.options.BinDir, name)
+	stage, err := newStaging(finalPath)
+	if err != nil {
+		return "", nil, err
+	}
 
-		if err := i.executePostProcessing(resolvedPkg, workDir, finalPath, t); err != nil {
-			return "", err
-		}
+	if err := i.executePostProcessing(resolvedPkg, workDir, stage.staged, t); err != nil {
+		return "", stage, err
+	}
 
-		// Find and copy the binary from the extract dir to finalPath.
-		{
-			t.SetDescription("Searching for binary")
-			binaryPath, err := extract.FindBinaryInDir(workDir, resolution.BinaryPath, t)
-			if err != nil {
-				return "", fmt.Errorf("failed to find binary %s: %w", name, err)
-			}
-			utils.LogFileFound(t, binaryPath, "binary")
-			t.SetDescription("Installing binary")
-			if err := utils.CopyFile(binaryPath, finalPath); err != nil {
-				return "", fmt.Errorf("failed to install binary: %w", err)
-			}
-		}
+	// Find and copy the binary from the extract dir to the staging path.
+	t.SetDescription("Searching for binary")
+	binaryPath, err := extract.FindBinaryInDir(workDir, resolution.BinaryPath, t)
+	if err != nil {
+		return "", stage, fmt.Errorf("failed to find binary %s: %w", name, err)
+	}
+	utils.LogFileFound(t, binaryPath, "binary")
+	t.SetDescription("Installing binary")
+	if err := utils.CopyFile(binaryPath, stage.staged); err != nil {
+		return "", stage, fmt.Errorf("failed to install binary: %w", err)
+	}
 
-		if fileInfo, err := os.Stat(finalPath); err == nil && !fileInfo.IsDir() {
-			if err := os.Chmod(finalPath, 0755); err != nil {
-				return "", fmt.Errorf("failed to make binary executable: %w", err)
-			}
+	if fileInfo, err := os.Stat(stage.staged); err == nil && !fileInfo.IsDir() {
+		if err := os.Chmod(stage.staged, 0755); err != nil {
+			return "", stage, fmt.Errorf("failed to make binary executable: %w", err)
 		}
 	}
 
-	return finalPath, nil
+	return finalPath, stage, nil
 }
 
 // handleSystemInstaller handles system installer files (.pkg/.msi)
@@ -866,35 +1152,39 @@ func (i *Installer) handleSystemInstaller(installerPath, name string, t *task.Ta
 	return markerPath, nil
 }
 
-// handleDirectBinaryInstallation handles direct binary downloads (no extraction needed)
-func (i *Installer) handleDirectBinaryInstallation(downloadPath, name string) (string, error) {
-	// If download path is in app-dir (for wrapper scripts), return that path
-	// Otherwise return bin-dir path
+// handleDirectBinaryInstallation handles direct binary downloads (no extraction needed),
+// which downloadPackage has already written to the staging path.
+func (i *Installer) handleDirectBinaryInstallation(downloadPath, name, resolvedVersion string, pkg types.Package) (string, *staging, error) {
+	// Wrapper script downloads are staged as a whole app-dir folder
 	if strings.HasPrefix(downloadPath, i.options.AppDir) {
-		return downloadPath, nil
+		live := filepath.Join(i.options.AppDir, pkg.FolderName(resolvedVersion))
+		stage := &staging{staged: stagedPath(live), live: live}
+		return filepath.Join(live, filepath.Base(downloadPath)), stage, nil
 	}
 
-	// For direct binary downloads to bin-dir, the path is already correct
 	finalPath := filepath.Join(i.options.BinDir, name)
-	return finalPath, nil
+	return finalPath, &staging{staged: downloadPath, live: finalPath}, nil
 }
 
 // createSymlinks creates symlinks from app directory to bin directory based on glob patterns
 // Supports two formats:
 // 1. Pattern only: "bin/tool" creates symlink with basename "tool"
 // 2. Name->Pattern: "custom-name->bin/tool" creates symlink with name "custom-name"
-func (i *Installer) createSymlinks(appPath, binDir string, patterns []string, t *task.Task) error {
+// Returns the paths of the symlinks that were created.
+func (i *Installer) createSymlinks(appPath, binDir string, patterns []string, t *task.Task) ([]string, error) {
 	if len(patterns) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	t.V(3).Infof("Creating symlinks from %s to %s", appPath, binDir)
 
 	// Ensure bin directory exists
 	if err := os.MkdirAll(binDir, 0755); err != nil {
-		return fmt.Errorf("failed to create bin directory: %w", err)
+		return nil, fmt.Errorf("failed to create bin directory: %w", err)
 	}
 
+	var created []string
+
 	for _, pattern := range patterns {
 		// Parse symlink name mapping (e.g., "as.sh->arthas" or just "bin/tool")
 		var linkName, targetPattern string
@@ -912,7 +1202,7 @@ func (i *Installer) createSymlinks(appPath, binDir string, patterns []string, t
 
 		matches, err := filepath.Glob(fullPattern)
 		if err != nil {
-			return fmt.Errorf("failed to glob pattern %s: %w", targetPattern, err)
+			return created, fmt.Errorf("failed to glob pattern %s: %w", targetPattern, err)
 		}
 
 		if len(matches) == 0 {
@@ -942,14 +1232,6 @@ func (i *Installer) createSymlinks(appPath, binDir string, patterns []string, t
 			}
 			linkPath := filepath.Join(binDir, symlinkName)
 
-			// Remove existing symlink or file if it exists
-			if _, err := os.Lstat(linkPath); err == nil {
-				if err := os.Remove(linkPath); err != nil {
-					t.Warnf("Failed to remove existing symlink %s: %v", linkPath, err)
-					continue
-				}
-			}
-
 			// Ensure match is absolute
 			absMatch := match
 			if !filepath.IsAbs(match) {
@@ -979,17 +1261,40 @@ func (i *Installer) createSymlinks(appPath, binDir string, patterns []string, t
 				continue
 			}
 
-			// Create symlink with relative path
-			if err := os.Symlink(relTarget, linkPath); err != nil {
+			// Create symlink with relative path, atomically replacing any existing symlink or file
+			if err := utils.ReplaceSymlink(relTarget, linkPath); err != nil {
 				t.Warnf("Failed to create symlink %s -> %s: %v", linkPath, relTarget, err)
 				continue
 			}
 
 			t.V(3).Infof("Created symlink: %s -> %s", linkPath, relTarget)
+			created = append(created, linkPath)
 		}
 	}
 
-	return nil
+	return created, nil
+}
+
+// symlinkTarget returns the file in appPath that createSymlinks would link to bin-dir as linkName,
+// or "" if no pattern produces that link.
+func symlinkTarget(appPath, linkName string, patterns []string) string {
+	for _, pattern := range patterns {
+		name, targetPattern := "", pattern
+		if parts := strings.SplitN(pattern, "->", 2); len(parts) == 2 {
+			name, targetPattern = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+		matches, _ := filepath.Glob(filepath.Join(appPath, targetPattern))
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			base := filepath.Base(match)
+			if name == linkName || (name == "" && (base == linkName || base == linkName+".exe")) {
+				return match
+			}
+		}
+	}
+	return ""
 }
 
 // createWrapperScript creates a wrapper script in the bin directory based on the template
@@ -1027,15 +1332,8 @@ func (i *Installer) createWrapperScript(pkg types.Package, resolvedVersion, binD
 	scriptName := pkg.Name
 	scriptPath := filepath.Join(binDir, scriptName)
 
-	// Remove existing script if it exists
-	if _, err := os.Lstat(scriptPath); err == nil {
-		if err := os.Remove(scriptPath); err != nil {
-			return fmt.Errorf("failed to remove existing wrapper script %s: %w", scriptPath, err)
-		}
-	}
-
-	// Write the wrapper script
-	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0755); err != nil {
+	// Write the wrapper script, atomically replacing any existing one
+	if err := utils.WriteFileAtomic(scriptPath, []byte(scriptContent), 0755); err != nil {
 		return fmt.Errorf("failed to write wrapper script %s: %w", scriptPath, err)
 	}
 
@@ -1073,24 +1371,42 @@ func (i *Installer) resolveVersionConstraint(ctx context.Context, mgr manager.Pa
 	return resolver.ResolveConstraint(ctx, pkg, constraint, i.getPlatform())
 }
 
+// downloadOptions returns opts plus the context, cache, timeout and offline settings every download shares
+func (i *Installer) downloadOptions(ctx context.Context, opts ...download.DownloadOption) []download.DownloadOption {
+	return append(opts,
+		download.WithContext(ctx),
+		download.WithCacheDir(i.options.CacheDir),
+		download.WithTimeout(i.options.Timeout),
+		download.WithLockTimeout(i.options.LockTimeout),
+		download.WithOffline(i.offline()),
+	)
+}
+
 // downloadWithChecksum attempts to download with checksum verification
 // using the provided checksum URL first, then falling back to URL pattern detection
-func (i *Installer) downloadWithChecksum(url, dest, checksumURL string, resolution *types.Resolution, t *task.Task) error {
+func (i *Installer) downloadWithChecksum(ctx context.Context, url, dest, checksumURL string, resolution *types.Resolution, t *task.Task) error {
+	if i.options.Bundle != nil {
+		return i.copyFromBundle(url, dest, resolution, t)
+	}
+
+	release := i.hosts.acquire(url, t)
+	defer release()
+
 	// Skip checksum verification if requested
 	if i.options.SkipChecksum {
 		t.Debugf("Skipping checksum verification (--skip-checksum)")
-		return download.Download(url, dest, t, download.WithCacheDir(i.options.CacheDir), download.WithTimeout(i.options.Timeout))
+		return download.Download(url, dest, t, i.downloadOptions(ctx)...)
 	}
 
 	// Priority 1: Use checksum from resolution if available (e.g., from GitHub GraphQL digest)
 	if resolution.Checksum != "" {
 		t.V(3).Infof("Using checksum from resolution: %s", resolution.Checksum)
-		return download.Download(url, dest, t, download.WithChecksum(resolution.Checksum), download.WithCacheDir(i.options.CacheDir), download.WithTimeout(i.options.Timeout))
+		return download.Download(url, dest, t, i.downloadOptions(ctx, download.WithChecksum(resolution.Checksum))...)
 	}
 
 	// Priority 2: Try the provided checksum URL if configured
 	if checksumURL != "" {
-		t.V(3).Infof("Using configured checksum URL: %s", checksumURL)
+		t.V(3).Infof("Using configured checksum URL: %s", utils.RedactURL(checksumURL))
 
 		// Split comma-separated checksum URLs
 		checksumURLs := strings.Split(checksumURL, ",")
@@ -1111,10 +1427,10 @@ func (i *Installer) downloadWithChecksum(url, dest, checksumURL string, resoluti
 			}
 
 			// Use multi-file checksum with CEL support
-			err = download.Download(url, dest, t, download.WithChecksumURLsAndNames(checksumURLs, checksumNames, checksumExpr), download.WithPlatform(resolution.Platform.OS, resolution.Platform.Arch), download.WithCacheDir(i.options.CacheDir), download.WithTimeout(i.options.Timeout))
+			err = download.Download(url, dest, t, i.downloadOptions(ctx, download.WithChecksumURLsAndNames(checksumURLs, checksumNames, checksumExpr), download.WithPlatform(resolution.Platform.OS, resolution.Platform.Arch))...)
 		} else {
 			// Use single checksum file
-			err = download.Download(url, dest, t, download.WithChecksumURL(checksumURL), download.WithCacheDir(i.options.CacheDir), download.WithTimeout(i.options.Timeout))
+			err = download.Download(url, dest, t, i.downloadOptions(ctx, download.WithChecksumURL(checksumURL))...)
 		}
 
 		if err == nil {
@@ -1132,7 +1448,7 @@ func (i *Installer) downloadWithChecksum(url, dest, checksumURL string, resoluti
 	}
 
 	// Download without checksum verification (only reached in non-strict mode or when no checksum is configured)
-	return download.Download(url, dest, t, download.WithCacheDir(i.options.CacheDir), download.WithTimeout(i.options.Timeout))
+	return download.Download(url, dest, t, i.downloadOptions(ctx)...)
 }
 
 // archMatches returns true if nativeArch (e.g. "x86_64", "arm64") corresponds
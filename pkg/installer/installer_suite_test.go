@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"os"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,3 +12,21 @@ func TestInstaller(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Installer Suite")
 }
+
+// The default receipts, versions and locks directories live under the home directory,
+// so point it at a temporary one to keep the tests away from the real ~/.deps
+var _ = BeforeSuite(func() {
+	home, err := os.MkdirTemp("", "installer-home-*")
+	Expect(err).NotTo(HaveOccurred())
+	previous, hadHome := os.LookupEnv("HOME")
+	Expect(os.Setenv("HOME", home)).To(Succeed())
+
+	DeferCleanup(func() {
+		if hadHome {
+			_ = os.Setenv("HOME", previous)
+		} else {
+			_ = os.Unsetenv("HOME")
+		}
+		_ = os.RemoveAll(home)
+	})
+})
@@ -0,0 +1,27 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/filelock"
+)
+
+// lockPackage takes the cross-process lock for a package, serializing changes to its bin-dir,
+// app-dir and version store entries between concurrent installs sharing them.
+// The returned function releases the lock; locking is disabled when LocksDir is empty.
+func (i *Installer) lockPackage(name string, t *task.Task) (func(), error) {
+	if i.options.LocksDir == "" {
+		return func() {}, nil
+	}
+	path := filepath.Join(i.options.LocksDir, strings.ReplaceAll(name, "/", "_")+".lock")
+	unlock, err := filelock.Acquire(path, i.options.LockTimeout, func(holder *filelock.Holder) {
+		t.Infof("Waiting for %s, locked by %s", name, holder)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	return unlock, nil
+}
@@ -0,0 +1,42 @@
+package installer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/filelock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Package locks", func() {
+	It("makes other installs of the same package wait and report the holder", func() {
+		locksDir := GinkgoT().TempDir()
+		inst := New(WithLocksDir(locksDir), WithLockTimeout(200*time.Millisecond))
+
+		unlock, err := inst.lockPackage("kubectl", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = inst.lockPackage("kubectl", &task.Task{})
+		var timeout *filelock.TimeoutError
+		Expect(errors.As(err, &timeout)).To(BeTrue(), "expected a lock timeout, got %v", err)
+		Expect(timeout.Holder).NotTo(BeNil())
+
+		other, err := inst.lockPackage("helm", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		other()
+
+		unlock()
+		again, err := inst.lockPackage("kubectl", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		again()
+	})
+
+	It("is a no-op when locks are disabled", func() {
+		inst := New(WithLocksDir(""))
+		unlock, err := inst.lockPackage("owner/repo", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		unlock()
+	})
+})
@@ -2,8 +2,12 @@ package installer
 
 import (
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/flanksource/deps/pkg/bundle"
+	"github.com/flanksource/deps/pkg/filelock"
+	"github.com/flanksource/deps/pkg/receipt"
 	"github.com/flanksource/deps/pkg/types"
 )
 
@@ -13,13 +17,22 @@ type InstallOptions struct {
 	AppDir          string
 	TmpDir          string
 	CacheDir        string
+	ReceiptsDir     string        // Directory for install receipts used by uninstall (empty disables receipts)
+	VersionsDir     string        // Directory where installed versions are kept side by side (empty disables)
+	LocksDir        string        // Directory for the per-package locks shared by concurrent installs (empty disables)
+	LockTimeout     time.Duration // How long to wait for a package or cache entry locked by another process
+	Jobs            int           // Maximum packages installed at once (0 = from settings: DefaultJobs, or 1 unless parallel)
+	HostJobs        int           // Maximum concurrent downloads from a single host
 	Force           bool
 	SkipChecksum    bool
 	StrictChecksum  bool // If true, checksum failures cause installation to fail
 	Debug           bool
 	OSOverride      string
 	ArchOverride    string
-	IterateVersions int // Number of releases to try when 'latest' has no matching assets (0 = disabled)
+	IterateVersions int            // Number of releases to try when 'latest' has no matching assets (0 = disabled)
+	FrozenLock      bool           // If true, install exactly the URLs and checksums recorded in deps-lock.yaml
+	Offline         bool           // If true, make no network calls: install from deps-lock.yaml and the download cache only
+	Bundle          *bundle.Bundle // If set, install offline from the bundle's lock file and artifacts
 	// Legacy compatibility
 	VersionCheck types.VersionCheckMode
 	Timeout      time.Duration
@@ -58,6 +71,49 @@ func WithCacheDir(dir string) InstallOption {
 	}
 }
 
+// WithReceiptsDir sets the directory where install receipts are stored
+func WithReceiptsDir(dir string) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.ReceiptsDir = dir
+	}
+}
+
+// WithVersionsDir sets the directory where installed versions are kept for 'deps use'
+func WithVersionsDir(dir string) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.VersionsDir = dir
+	}
+}
+
+// WithLocksDir sets the directory holding the per-package locks that serialize concurrent installs
+func WithLocksDir(dir string) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.LocksDir = dir
+	}
+}
+
+// WithLockTimeout sets how long to wait for a package or cache entry locked by another process
+func WithLockTimeout(timeout time.Duration) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.LockTimeout = timeout
+	}
+}
+
+// WithJobs sets how many packages InstallMultiple and InstallFromConfig install at once.
+// Zero uses settings.jobs from deps.yaml, or DefaultJobs (1 when settings.parallel is off).
+func WithJobs(jobs int) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.Jobs = jobs
+	}
+}
+
+// WithHostJobs sets how many downloads may run at once against a single host
+func WithHostJobs(jobs int) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.HostJobs = jobs
+	}
+}
+
 // WithForce enables or disables forced reinstallation
 func WithForce(force bool) InstallOption {
 	return func(opts *InstallOptions) {
@@ -96,6 +152,32 @@ func WithIterateVersions(n int) InstallOption {
 	}
 }
 
+// WithFrozenLock installs exactly what deps-lock.yaml records for the target platform.
+// No versions are resolved and no APIs are called; a dependency or platform missing
+// from the lock file is an error.
+func WithFrozenLock(frozen bool) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.FrozenLock = frozen
+	}
+}
+
+// WithOffline installs from deps-lock.yaml and the download cache without any network access.
+// It implies WithFrozenLock; an artifact missing from the cache is an error.
+func WithOffline(offline bool) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.Offline = offline
+	}
+}
+
+// WithBundle installs from an extracted 'deps bundle' archive: versions and URLs come from the
+// bundle's lock file and every download is served from its artifacts. It implies WithOffline.
+func WithBundle(b *bundle.Bundle) InstallOption {
+	return func(opts *InstallOptions) {
+		opts.Bundle = b
+		opts.Offline = true
+	}
+}
+
 // WithOS sets OS and architecture overrides
 func WithOS(os, arch string) InstallOption {
 	return func(opts *InstallOptions) {
@@ -138,11 +220,21 @@ func DefaultOptions() InstallOptions {
 	if err == nil && os.Geteuid() != 0 {
 		defaultAppDir = home + "/.local/opt"
 	}
+	defaultVersionsDir, defaultLocksDir := "", ""
+	if err == nil {
+		defaultVersionsDir = filepath.Join(home, ".deps", "versions")
+		defaultLocksDir = filepath.Join(home, ".deps", "locks")
+	}
 
 	return InstallOptions{
 		BinDir:         "/usr/local/bin",
 		AppDir:         defaultAppDir,
 		TmpDir:         os.TempDir(),
+		ReceiptsDir:    receipt.DefaultDir(),
+		VersionsDir:    defaultVersionsDir,
+		LocksDir:       defaultLocksDir,
+		LockTimeout:    filelock.Timeout(),
+		HostJobs:       DefaultHostJobs,
 		Force:          false,
 		SkipChecksum:   false,
 		StrictChecksum: true, // Default to strict checksum validation
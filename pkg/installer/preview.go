@@ -9,6 +9,7 @@ import (
 
 	"github.com/flanksource/clicky/task"
 	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/oci"
 	"github.com/flanksource/deps/pkg/pipeline"
 	"github.com/flanksource/deps/pkg/platform"
 	"github.com/flanksource/deps/pkg/types"
@@ -54,7 +55,7 @@ func (p *InstallPreview) InstallMethod() string {
 		return "plugin"
 	case p.Resolution == nil:
 		return ""
-	case p.Resolution.DownloadURL == "":
+	case p.Resolution.DownloadURL == "", oci.IsImageURL(p.Resolution.DownloadURL):
 		return "manager"
 	default:
 		return "download"
@@ -121,6 +122,10 @@ func (i *Installer) previewPackageInstallation(ctx context.Context, name, versio
 	}
 	preview.RequestedVersion = requestedVersion
 
+	if i.offline() {
+		return nil, fmt.Errorf("offline: cannot resolve %s@%s without network access, lock it with 'deps lock' first", name, requestedVersion)
+	}
+
 	t.SetDescription(fmt.Sprintf("Resolving version %s", requestedVersion))
 	resolvedVersion, err := i.resolveVersionConstraint(ctx, mgr, pkg, requestedVersion, t)
 	if err != nil {
@@ -0,0 +1,124 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/types"
+	versionpkg "github.com/flanksource/deps/pkg/version"
+)
+
+// ProjectExecutable returns the executable for name at the version pinned by the project's
+// deps-lock.yaml or deps.yaml, installing it into the version store first if it is missing.
+// Unlike Install it leaves the active version in bin-dir untouched, so projects pinning
+// different versions of the same tool do not interfere with each other.
+// Tools the project does not pin (or a nil project) resolve to the active bin-dir version.
+func (i *Installer) ProjectExecutable(project *config.Project, name string, t *task.Task) (string, error) {
+	name, pkg, err := i.lookupPackage(name)
+	if err != nil {
+		return "", err
+	}
+
+	var pinned string
+	var ok bool
+	if project != nil {
+		pinned, ok = project.PinnedVersion(name)
+	}
+	if !ok || isVersionAlias(pinned) {
+		if path, found := i.getInstalledPath(name, pkg); found {
+			return path, nil
+		}
+		if !ok {
+			return "", fmt.Errorf("%s is not installed and no deps.yaml pins a version for it", name)
+		}
+	}
+
+	if path := i.pinnedExecutable(name, pinned, pkg); path != "" {
+		return path, nil
+	}
+
+	// The active version may already be the pinned one, e.g. when it was installed before the version store existed
+	if existing := versionpkg.CheckExistingInstallation(t, name, pkg, pinned, i.options.BinDir, i.options.OSOverride); existing != "" {
+		if path, found := i.getInstalledPath(name, pkg); found {
+			return path, nil
+		}
+	}
+
+	if i.options.VersionsDir == "" {
+		return "", fmt.Errorf("%s@%s is not installed and the version store is disabled", name, pinned)
+	}
+
+	previous := i.currentVersion(name)
+	t.Infof("Installing %s@%s pinned by %s", name, pinned, project.Dir)
+	if err := i.installPinned(project, name, pinned, pkg, t); err != nil {
+		return "", err
+	}
+
+	installed := i.currentVersion(name)
+	if previous != "" && previous != installed {
+		if err := i.activateVersion(name, previous, pkg, t); err != nil {
+			t.Warnf("Failed to restore %s@%s as the active version: %v", name, previous, err)
+		}
+	}
+
+	path := i.storedExecutable(name, installed, pkg)
+	if path == "" {
+		return "", fmt.Errorf("%s@%s was installed but no executable was found in %s", name, pinned, i.versionStoreDir(name))
+	}
+	return path, nil
+}
+
+// pinnedExecutable returns the stored executable for an exact version or the newest stored
+// version matching a constraint, or "" if none is installed.
+func (i *Installer) pinnedExecutable(name, pinned string, pkg types.Package) string {
+	if i.options.VersionsDir == "" || isVersionAlias(pinned) {
+		return ""
+	}
+	installed, err := i.InstalledVersions(name)
+	if err != nil || len(installed) == 0 {
+		return ""
+	}
+	version, err := matchInstalledVersion(installed, pinned)
+	if err != nil {
+		return ""
+	}
+	return i.storedExecutable(name, version, pkg)
+}
+
+// installPinned installs the pinned version, using the lock file's URL and checksum when it has
+// an entry for the target platform.
+func (i *Installer) installPinned(project *config.Project, name, pinned string, pkg types.Package, t *task.Task) error {
+	if project.Lock != nil {
+		if _, _, err := i.lockedPlatformEntry(project.Lock, name); err == nil {
+			return i.installFromLock(context.Background(), name, pkg, project.Lock, t, nil)
+		}
+	}
+	return i.installWithNewPackageManager(context.Background(), name, pinned, pkg, t)
+}
+
+// storedExecutable returns the executable to run for a stored version: the stored binary,
+// the stored wrapper script, or the file a directory-mode package symlinks as name.
+func (i *Installer) storedExecutable(name, version string, pkg types.Package) string {
+	if version == "" {
+		return ""
+	}
+	path := i.storedVersionPath(name, version, pkg)
+	if path == "" || !usesAppDir(pkg) {
+		return path
+	}
+
+	if pkg.WrapperScript != "" {
+		wrapper := filepath.Join(i.versionStoreDir(name), version, name)
+		if _, err := os.Stat(wrapper); err == nil {
+			return wrapper
+		}
+		return ""
+	}
+
+	return symlinkTarget(path, name, manager.FilterEntriesByPlatform(pkg.Symlinks, i.getPlatform()))
+}
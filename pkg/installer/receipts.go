@@ -0,0 +1,110 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/receipt"
+)
+
+// newReceipt starts the install receipt for a package about to be installed.
+func (i *Installer) newReceipt(name, version string, preview *InstallPreview) *receipt.Receipt {
+	rec := &receipt.Receipt{
+		Name:     name,
+		Version:  version,
+		Manager:  preview.Manager,
+		Platform: preview.Platform,
+		BinDir:   i.options.BinDir,
+		AppDir:   i.options.AppDir,
+		StoreDir: i.options.VersionsDir,
+	}
+	if preview.Resolution != nil {
+		rec.SourceURL = preview.Resolution.DownloadURL
+		rec.Checksum = preview.Resolution.Checksum
+	}
+	if i.options.ReceiptsDir != "" {
+		if previous, err := receipt.Load(i.options.ReceiptsDir, i.options.BinDir, name); err == nil && previous.Version != version {
+			rec.PreviousVersion = previous.Version
+		}
+	}
+	return rec
+}
+
+// saveReceipt persists the receipt, keeping still-present paths from an earlier install.
+// Failing to write a receipt does not fail the install.
+func (i *Installer) saveReceipt(rec *receipt.Receipt, t *task.Task) {
+	if i.options.ReceiptsDir == "" {
+		return
+	}
+	if previous, err := receipt.Load(i.options.ReceiptsDir, rec.BinDir, rec.Name); err == nil {
+		rec.Merge(previous)
+	}
+	rec.InstalledAt = time.Now()
+	if err := rec.Save(i.options.ReceiptsDir); err != nil {
+		t.Warnf("Failed to save install receipt for %s: %v", rec.Name, err)
+		return
+	}
+	t.V(3).Infof("Saved install receipt for %s (%d files, %d symlinks)", rec.Name, len(rec.Files), len(rec.Symlinks))
+}
+
+// Uninstall removes the files, directories and symlinks recorded in a tool's install receipt.
+// Tools without a receipt were not installed by deps and are refused.
+func (i *Installer) Uninstall(name string, t *task.Task) (*receipt.UninstallResult, error) {
+	if i.options.ReceiptsDir == "" {
+		return nil, fmt.Errorf("install receipts are disabled, cannot uninstall %s", name)
+	}
+
+	unlock, err := i.lockPackage(name, t)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	rec, err := receipt.Load(i.options.ReceiptsDir, i.options.BinDir, name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s has no install receipt for %s in %s, refusing to remove files deps did not create", name, i.options.BinDir, i.options.ReceiptsDir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The app-dir is shared by every bin-dir, so folders other receipts still record are kept
+	others, err := i.otherReceipts(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	t.SetDescription(fmt.Sprintf("Uninstalling %s@%s", name, rec.Version))
+	result, err := rec.Uninstall(others...)
+	if err != nil {
+		return result, err
+	}
+	for path, reason := range result.Skipped {
+		t.Warnf("Skipped %s: %s", path, reason)
+	}
+	for _, path := range result.Removed {
+		t.V(2).Infof("Removed %s", path)
+	}
+
+	if err := receipt.Delete(i.options.ReceiptsDir, i.options.BinDir, name); err != nil {
+		return result, fmt.Errorf("failed to delete receipt for %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// otherReceipts returns every receipt except rec, across bin-dirs
+func (i *Installer) otherReceipts(rec *receipt.Receipt) ([]*receipt.Receipt, error) {
+	all, err := receipt.List(i.options.ReceiptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list install receipts: %w", err)
+	}
+	var others []*receipt.Receipt
+	for _, other := range all {
+		if other.Name == rec.Name && receipt.BinDirKey(other.BinDir) == receipt.BinDirKey(rec.BinDir) {
+			continue
+		}
+		others = append(others, other)
+	}
+	return others, nil
+}
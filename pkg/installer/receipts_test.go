@@ -0,0 +1,74 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/receipt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Uninstall", func() {
+	It("only removes the files installed in its own bin dir", func() {
+		root := GinkgoT().TempDir()
+		receiptsDir := filepath.Join(root, "receipts")
+		binDirs := []string{filepath.Join(root, "a", "bin"), filepath.Join(root, "b", "bin")}
+
+		for _, binDir := range binDirs {
+			Expect(os.MkdirAll(binDir, 0755)).To(Succeed())
+			jq := filepath.Join(binDir, "jq")
+			Expect(os.WriteFile(jq, []byte("jq"), 0755)).To(Succeed())
+			rec := &receipt.Receipt{Name: "jq", Version: "1.7.1", BinDir: binDir}
+			rec.AddFile(jq)
+			Expect(rec.Save(receiptsDir)).To(Succeed())
+		}
+
+		inst := New(WithBinDir(binDirs[0]), WithReceiptsDir(receiptsDir), WithVersionsDir(""), WithLocksDir(""))
+		result, err := inst.Uninstall("jq", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Removed).To(ConsistOf(filepath.Join(binDirs[0], "jq")))
+
+		Expect(filepath.Join(binDirs[0], "jq")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(binDirs[1], "jq")).To(BeAnExistingFile())
+		_, err = receipt.Load(receiptsDir, binDirs[1], "jq")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = inst.Uninstall("jq", &task.Task{})
+		Expect(err).To(MatchError(ContainSubstring("has no install receipt for " + binDirs[0])))
+	})
+
+	It("keeps app-dir folders that another bin-dir still uses", func() {
+		root := GinkgoT().TempDir()
+		receiptsDir := filepath.Join(root, "receipts")
+		appDir := filepath.Join(root, "opt")
+		jdk := filepath.Join(appDir, "jdk")
+		Expect(os.MkdirAll(filepath.Join(jdk, "bin"), 0755)).To(Succeed())
+		binDirs := []string{filepath.Join(root, "a", "bin"), filepath.Join(root, "b", "bin")}
+
+		for _, binDir := range binDirs {
+			Expect(os.MkdirAll(binDir, 0755)).To(Succeed())
+			java := filepath.Join(binDir, "java")
+			Expect(os.Symlink(filepath.Join(jdk, "bin", "java"), java)).To(Succeed())
+			rec := &receipt.Receipt{Name: "jdk", Version: "21", BinDir: binDir, AppDir: appDir}
+			rec.AddFile(jdk)
+			rec.AddSymlink(java)
+			Expect(rec.Save(receiptsDir)).To(Succeed())
+		}
+
+		inst := New(WithBinDir(binDirs[0]), WithAppDir(appDir), WithReceiptsDir(receiptsDir), WithVersionsDir(""), WithLocksDir(""))
+		result, err := inst.Uninstall("jdk", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Removed).To(ConsistOf(filepath.Join(binDirs[0], "java")))
+		Expect(result.Skipped).To(HaveKeyWithValue(jdk, ContainSubstring("still used by jdk in "+binDirs[1])))
+		Expect(jdk).To(BeADirectory())
+
+		// The last bin-dir using it removes the folder
+		inst = New(WithBinDir(binDirs[1]), WithAppDir(appDir), WithReceiptsDir(receiptsDir), WithVersionsDir(""), WithLocksDir(""))
+		result, err = inst.Uninstall("jdk", &task.Task{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Removed).To(ContainElement(jdk))
+		Expect(jdk).NotTo(BeADirectory())
+	})
+})
@@ -0,0 +1,237 @@
+package installer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/types"
+	versionpkg "github.com/flanksource/deps/pkg/version"
+)
+
+// requirementGraph is a set of packages and the packages they require, in install order
+type requirementGraph struct {
+	// order lists every package with its requirements before it
+	order []string
+	// versions is the version or constraint to install each package at, satisfying every request for it
+	versions map[string]string
+	// requires lists the direct requirements of each package
+	requires map[string][]string
+	// requests lists every version asked for each package, directly or by the packages requiring it
+	requests map[string][]versionRequest
+}
+
+// versionRequest is a version constraint on a package, requested directly or by a chain of requirements
+type versionRequest struct {
+	constraint string
+	// chain lists the packages whose requirements led to the request, empty when requested directly
+	chain []string
+}
+
+// describe returns the request for name as "jq@1.7 requested" or "a -> b requires jq@1.7"
+func (r versionRequest) describe(name string) string {
+	if len(r.chain) == 0 {
+		return fmt.Sprintf("%s@%s requested", name, r.constraint)
+	}
+	return fmt.Sprintf("%s requires %s@%s", strings.Join(r.chain, " -> "), name, r.constraint)
+}
+
+// resolveRequirements expands tools with the packages they require (recursively) and orders them
+// so that requirements come first. Each package is installed at the intersection of the version
+// requested for it and the constraints of every package requiring it. Cycles, unknown packages and
+// conflicting versions are errors naming the chain of requirements that led to them.
+func resolveRequirements(tools []ToolSpec, registry map[string]types.Package) (*requirementGraph, error) {
+	graph := &requirementGraph{versions: map[string]string{}, requires: map[string][]string{}, requests: map[string][]versionRequest{}}
+	for _, tool := range tools {
+		graph.requests[tool.Name] = append(graph.requests[tool.Name], versionRequest{constraint: tool.Version})
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		chain = append(chain, name)
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(chain, " -> "))
+		}
+
+		_, pkg, ok := findPackage(registry, name)
+		if !ok {
+			if len(chain) > 1 {
+				return fmt.Errorf("%s: %s not found in registry", strings.Join(chain, " -> "), name)
+			}
+			// Unknown tools fail when their install runs, like they did before requires existed
+			state[name] = visited
+			graph.order = append(graph.order, name)
+			return nil
+		}
+
+		state[name] = visiting
+		for _, req := range ParseTools(pkg.Requires) {
+			graph.requests[req.Name] = append(graph.requests[req.Name], versionRequest{constraint: req.Version, chain: chain})
+			graph.requires[name] = append(graph.requires[name], req.Name)
+			if err := visit(req.Name, chain); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		graph.order = append(graph.order, name)
+		return nil
+	}
+
+	for _, tool := range tools {
+		if err := visit(tool.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range graph.order {
+		version, err := graph.intersect(name)
+		if err != nil {
+			return nil, err
+		}
+		graph.versions[name] = version
+	}
+	return graph, nil
+}
+
+// intersect returns the version to install name at: the exact version requested, which must
+// satisfy every other constraint, or else all the constraints on it combined.
+func (g *requirementGraph) intersect(name string) (string, error) {
+	var constraints []string
+	for _, req := range g.requests[name] {
+		if !anyVersion(req.constraint) && !slices.Contains(constraints, req.constraint) {
+			constraints = append(constraints, req.constraint)
+		}
+	}
+	switch len(constraints) {
+	case 0:
+		// Keeps the version requested directly, such as "latest" or "stable"
+		return g.requests[name][0].constraint, nil
+	case 1:
+		return constraints[0], nil
+	}
+
+	for _, constraint := range constraints {
+		// Exact versions, including ones that are not semver, are the only candidate
+		if _, err := versionpkg.ParseConstraint(constraint); err != nil || versionpkg.LooksLikeExactVersion(constraint) {
+			return constraint, g.check(name, constraint)
+		}
+	}
+	return strings.Join(constraints, ", "), nil
+}
+
+// check returns an error naming every request for name that version does not satisfy
+func (g *requirementGraph) check(name, version string) error {
+	var unsatisfied []string
+	for _, req := range g.requests[name] {
+		if anyVersion(req.constraint) {
+			continue
+		}
+		if ok, err := versionpkg.SatisfiesConstraint(version, req.constraint); err != nil || !ok {
+			unsatisfied = append(unsatisfied, req.describe(name))
+		}
+	}
+	if len(unsatisfied) > 0 {
+		return fmt.Errorf("conflicting versions of %s: %s does not satisfy %s", name, version, strings.Join(unsatisfied, ", "))
+	}
+	return nil
+}
+
+// explain adds the requests for name to err when more than one of them constrains its version,
+// so a version that cannot be resolved names the packages asking for it
+func (g *requirementGraph) explain(name string, err error) error {
+	var requests []string
+	for _, req := range g.requests[name] {
+		if !anyVersion(req.constraint) {
+			requests = append(requests, req.describe(name))
+		}
+	}
+	if len(requests) < 2 {
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, strings.Join(requests, ", "))
+}
+
+// anyVersion reports whether constraint accepts every version
+func anyVersion(constraint string) bool {
+	switch strings.TrimSpace(constraint) {
+	case "", "*", "any", "latest", "stable":
+		return true
+	}
+	return false
+}
+
+// installRequirements installs the packages name requires, one at a time in dependency order,
+// for single installs that do not go through runInstalls.
+func (i *Installer) installRequirements(name string, t *task.Task) error {
+	var registry map[string]types.Package
+	if i.depsConfig != nil {
+		registry = i.depsConfig.Registry
+	}
+	graph, err := resolveRequirements([]ToolSpec{{Name: name}}, registry)
+	if err != nil {
+		return err
+	}
+	// The tool itself is always last
+	for _, req := range graph.order[:len(graph.order)-1] {
+		if err := i.installTool(ToolSpec{Name: req, Version: graph.versions[req]}, t); err != nil {
+			return fmt.Errorf("failed to install %s required by %s: %w", req, name, err)
+		}
+	}
+	return nil
+}
+
+// findPackage looks a package up in the registry, falling back to owner/repo GitHub references.
+// It returns the name the tool is installed under along with the package.
+func findPackage(registry map[string]types.Package, name string) (string, types.Package, bool) {
+	if pkg, ok := registry[name]; ok {
+		if pkg.Name == "" {
+			pkg.Name = name
+		}
+		return name, pkg, true
+	}
+	if isGitHubRepoPattern(name) {
+		pkg := createGitHubPackage(name)
+		return pkg.Name, pkg, true
+	}
+	return "", types.Package{}, false
+}
+
+// RequirementNode is a package in the tree of packages required by another
+type RequirementNode struct {
+	Name       string             `json:"name"`
+	Constraint string             `json:"constraint,omitempty"`
+	Requires   []*RequirementNode `json:"requires,omitempty"`
+}
+
+// RequirementTree returns the packages name requires, recursively.
+// Packages required through more than one path appear under each of them.
+func (i *Installer) RequirementTree(name string) (*RequirementNode, error) {
+	var registry map[string]types.Package
+	if i.depsConfig != nil {
+		registry = i.depsConfig.Registry
+	}
+	// Validates the whole graph, so cycles are reported before the tree is built
+	if _, err := resolveRequirements([]ToolSpec{{Name: name}}, registry); err != nil {
+		return nil, err
+	}
+
+	var build func(name, constraint string) *RequirementNode
+	build = func(name, constraint string) *RequirementNode {
+		node := &RequirementNode{Name: name, Constraint: constraint}
+		_, pkg, _ := findPackage(registry, name)
+		for _, req := range ParseTools(pkg.Requires) {
+			node.Requires = append(node.Requires, build(req.Name, req.Version))
+		}
+		return node
+	}
+	return build(name, ""), nil
+}
@@ -0,0 +1,97 @@
+package installer
+
+import (
+	"errors"
+
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Package requirements", func() {
+	registry := map[string]types.Package{
+		"kubebuilder": {Name: "kubebuilder", Requires: []string{"go@>=1.22", "kubectl"}},
+		"go":          {Name: "go"},
+		"kubectl":     {Name: "kubectl"},
+		"helm-diff":   {Name: "helm-diff", Requires: []string{"helm"}},
+		"helm":        {Name: "helm"},
+	}
+
+	It("orders requirements before the packages needing them", func() {
+		graph, err := resolveRequirements([]ToolSpec{{Name: "helm-diff"}, {Name: "kubebuilder"}, {Name: "kubectl", Version: "1.30.0"}}, registry)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.order).To(Equal([]string{"helm", "helm-diff", "go", "kubectl", "kubebuilder"}))
+		Expect(graph.requires["kubebuilder"]).To(Equal([]string{"go", "kubectl"}))
+		Expect(graph.versions["go"]).To(Equal(">=1.22"))
+		// Explicitly requested versions win over requirement constraints
+		Expect(graph.versions["kubectl"]).To(Equal("1.30.0"))
+	})
+
+	It("installs requirements at the intersection of every constraint on them", func() {
+		constrained := map[string]types.Package{
+			"kubebuilder": {Name: "kubebuilder", Requires: []string{"go@>=1.22", "kubectl"}},
+			"controller":  {Name: "controller", Requires: []string{"go@^1", "kubectl@1.30"}},
+			"go":          {Name: "go"},
+			"kubectl":     {Name: "kubectl"},
+		}
+		graph, err := resolveRequirements([]ToolSpec{{Name: "kubebuilder"}, {Name: "controller"}}, constrained)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.versions["go"]).To(Equal(">=1.22, ^1"))
+		Expect(graph.versions["kubectl"]).To(Equal("1.30"))
+
+		graph, err = resolveRequirements([]ToolSpec{{Name: "controller"}, {Name: "go", Version: "1.23.4"}}, constrained)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.versions["go"]).To(Equal("1.23.4"))
+		Expect(graph.check("go", "2.0.0")).To(MatchError("conflicting versions of go: 2.0.0 does not satisfy go@1.23.4 requested, controller requires go@^1"))
+	})
+
+	It("reports versions that conflict with the chain of packages requiring them", func() {
+		conflicting := map[string]types.Package{
+			"a":  {Name: "a", Requires: []string{"b"}},
+			"b":  {Name: "b", Requires: []string{"go@>=1.22"}},
+			"c":  {Name: "c", Requires: []string{"go@1.21.5"}},
+			"go": {Name: "go"},
+		}
+		_, err := resolveRequirements([]ToolSpec{{Name: "a"}, {Name: "go", Version: "1.21.0"}}, conflicting)
+		Expect(err).To(MatchError("conflicting versions of go: 1.21.0 does not satisfy a -> b requires go@>=1.22"))
+
+		_, err = resolveRequirements([]ToolSpec{{Name: "a"}, {Name: "c"}}, conflicting)
+		Expect(err).To(MatchError("conflicting versions of go: 1.21.5 does not satisfy a -> b requires go@>=1.22"))
+
+		// Ranges are resolved together, and a failure names everything constraining the version
+		graph, err := resolveRequirements([]ToolSpec{{Name: "a"}, {Name: "go", Version: "<1.20"}}, conflicting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.versions["go"]).To(Equal("<1.20, >=1.22"))
+		Expect(graph.explain("go", errors.New("no versions satisfy constraint <1.20, >=1.22"))).
+			To(MatchError("no versions satisfy constraint <1.20, >=1.22 (go@<1.20 requested, a -> b requires go@>=1.22)"))
+	})
+
+	It("reports cycles with the chain that formed them", func() {
+		cyclic := map[string]types.Package{
+			"a": {Name: "a", Requires: []string{"b"}},
+			"b": {Name: "b", Requires: []string{"c@^1"}},
+			"c": {Name: "c", Requires: []string{"a"}},
+		}
+		_, err := resolveRequirements([]ToolSpec{{Name: "a"}}, cyclic)
+		Expect(err).To(MatchError("dependency cycle: a -> b -> c -> a"))
+	})
+
+	It("reports unknown requirements with the chain that needs them", func() {
+		broken := map[string]types.Package{
+			"a": {Name: "a", Requires: []string{"b"}},
+			"b": {Name: "b", Requires: []string{"missing"}},
+		}
+		_, err := resolveRequirements([]ToolSpec{{Name: "a"}}, broken)
+		Expect(err).To(MatchError(ContainSubstring("a -> b -> missing: missing not found in registry")))
+	})
+
+	It("builds the requirement tree shown by deps info", func() {
+		inst := NewWithConfig(&types.DepsConfig{Registry: registry})
+		tree, err := inst.RequirementTree("kubebuilder")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Requires).To(HaveLen(2))
+		Expect(tree.Requires[0].Name).To(Equal("go"))
+		Expect(tree.Requires[0].Constraint).To(Equal(">=1.22"))
+		Expect(tree.Requires[1].Name).To(Equal("kubectl"))
+	})
+})
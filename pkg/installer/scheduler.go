@@ -0,0 +1,190 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/clicky/task"
+	flanksourceContext "github.com/flanksource/commons/context"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+const (
+	// DefaultJobs is how many packages are installed at once when settings.parallel is enabled
+	DefaultJobs = 4
+	// DefaultHostJobs is how many downloads may run at once against a single host
+	DefaultHostJobs = 2
+)
+
+// installJob is one package install run by runInstalls
+type installJob struct {
+	// pkg is the package name other jobs refer to in requires
+	pkg string
+	// name is the task name, e.g. kubectl@1.29
+	name string
+	// requires lists the packages that must be installed before this one
+	requires []string
+	run      func(ctx context.Context, t *task.Task) (*types.InstallResult, error)
+}
+
+// jobs returns how many packages may be installed at once: WithJobs, then settings.jobs,
+// then DefaultJobs, or 1 when settings.parallel is disabled.
+func (i *Installer) jobs() int {
+	if i.options.Jobs > 0 {
+		return i.options.Jobs
+	}
+	if i.depsConfig != nil {
+		if !i.depsConfig.Settings.Parallel {
+			return 1
+		}
+		if i.depsConfig.Settings.Jobs > 0 {
+			return i.depsConfig.Settings.Jobs
+		}
+	}
+	return DefaultJobs
+}
+
+// runInstalls runs the jobs, at most jobs() at a time, and waits for all of them.
+// Jobs must be ordered with their requirements first; a job only starts once its requirements
+// are installed and is skipped if one of them failed. Results are returned in the order of jobs,
+// and every failure is reported in the returned error.
+//
+// Each job is shown as a task but installs on its own goroutine: the task manager runs tasks on
+// a fixed pool of workers, which would otherwise cap installs at the size of that pool.
+func (i *Installer) runInstalls(jobs []installJob) ([]*types.InstallResult, error) {
+	slots := make(chan struct{}, i.jobs())
+
+	results := make([]*types.InstallResult, len(jobs))
+	done := make([]chan struct{}, len(jobs))
+	index := map[string]int{}
+	for idx, job := range jobs {
+		done[idx] = make(chan struct{})
+		index[job.pkg] = idx
+	}
+
+	tasks := make([]task.TypedTask[*types.InstallResult], len(jobs))
+	for idx, job := range jobs {
+		// The task only reports the install, so it must not be retried on its own
+		tasks[idx] = task.StartTask(job.name, func(ctx flanksourceContext.Context, t *task.Task) (*types.InstallResult, error) {
+			<-done[idx]
+			return results[idx], results[idx].Error
+		}, task.WithRetryConfig(task.RetryConfig{}))
+
+		go func(idx int, job installJob, t *task.Task) {
+			defer close(done[idx])
+			results[idx] = i.runInstall(job, t, slots, func(req string) *types.InstallResult {
+				dep, ok := index[req]
+				if !ok || dep == idx {
+					return nil
+				}
+				<-done[dep]
+				return results[dep]
+			})
+		}(idx, job, tasks[idx].Task)
+	}
+
+	for idx := range jobs {
+		<-done[idx]
+		tasks[idx].WaitFor()
+	}
+	return results, summarizeFailures(jobs, results)
+}
+
+// runInstall waits for the requirements of job, resolved by required, and for a free slot, then
+// installs it. The result is never nil and carries the error of a failed install.
+func (i *Installer) runInstall(job installJob, t *task.Task, slots chan struct{}, required func(string) *types.InstallResult) *types.InstallResult {
+	failed := func(err error) *types.InstallResult {
+		return &types.InstallResult{Platform: i.getPlatform(), BinDir: i.options.BinDir, Status: types.InstallStatusFailed, Error: err}
+	}
+
+	// Name the requirement that failed rather than reporting a bare cancellation
+	for _, req := range job.requires {
+		if result := required(req); result != nil && (result.Status == types.InstallStatusFailed || result.Error != nil) {
+			return failed(fmt.Errorf("skipped, required %s failed", req))
+		}
+	}
+
+	ctx := t.Context()
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return failed(ctx.Err())
+	}
+	defer func() { <-slots }()
+	t.SetStatus(task.StatusRunning)
+
+	start := time.Now()
+	result, err := job.run(ctx, t)
+	if result == nil {
+		result = &types.InstallResult{Platform: i.getPlatform(), BinDir: i.options.BinDir}
+	}
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+	if err != nil {
+		result.Error = err
+		result.Status = types.InstallStatusFailed
+	}
+	return result
+}
+
+// summarizeFailures returns an error listing every failed install, or nil if all succeeded
+func summarizeFailures(jobs []installJob, results []*types.InstallResult) error {
+	var failed []string
+	for idx, result := range results {
+		if result.Status != types.InstallStatusFailed && result.Error == nil {
+			continue
+		}
+		reason := "failed"
+		if result.Error != nil {
+			reason = result.Error.Error()
+		}
+		failed = append(failed, fmt.Sprintf("  %s: %s", jobs[idx].name, reason))
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d installs failed:\n%s", len(failed), len(results), strings.Join(failed, "\n"))
+}
+
+// hostLimiter bounds the number of concurrent downloads from each host
+type hostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, slots: map[string]chan struct{}{}}
+}
+
+// acquire waits for a download slot on the host of rawURL and returns the function releasing it
+func (h *hostLimiter) acquire(rawURL string, t *task.Task) func() {
+	if h == nil || h.limit <= 0 {
+		return func() {}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return func() {}
+	}
+
+	h.mu.Lock()
+	slots, ok := h.slots[u.Host]
+	if !ok {
+		slots = make(chan struct{}, h.limit)
+		h.slots[u.Host] = slots
+	}
+	h.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		t.V(3).Infof("Waiting for a download slot on %s", u.Host)
+		slots <- struct{}{}
+	}
+	return func() { <-slots }
+}
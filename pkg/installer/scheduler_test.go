@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Install scheduler", func() {
+	It("takes the job count from options, then settings", func() {
+		Expect(New(WithJobs(3)).jobs()).To(Equal(3))
+		Expect(New().jobs()).To(Equal(DefaultJobs))
+
+		serial := &types.DepsConfig{Settings: types.Settings{Parallel: false, Jobs: 8}}
+		Expect(NewWithConfig(serial).jobs()).To(Equal(1))
+		Expect(NewWithConfig(serial, WithJobs(2)).jobs()).To(Equal(2))
+
+		parallel := &types.DepsConfig{Settings: types.Settings{Parallel: true, Jobs: 8}}
+		Expect(NewWithConfig(parallel).jobs()).To(Equal(8))
+	})
+
+	It("bounds concurrency and returns every result in order", func() {
+		inst := New(WithJobs(2))
+
+		var running, peak atomic.Int32
+		job := func(name string, fail bool) installJob {
+			return installJob{name: name, run: func(ctx context.Context, t *task.Task) (*types.InstallResult, error) {
+				now := running.Add(1)
+				for {
+					old := peak.Load()
+					if now <= old || peak.CompareAndSwap(old, now) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				running.Add(-1)
+				if fail {
+					return nil, errors.New("boom")
+				}
+				return &types.InstallResult{Package: types.Package{Name: name}, Status: types.InstallStatusInstalled}, nil
+			}}
+		}
+
+		results, err := inst.runInstalls([]installJob{
+			job("a", false), job("b", true), job("c", false), job("d", true), job("e", false),
+		})
+		Expect(peak.Load()).To(BeNumerically("<=", 2))
+		Expect(results).To(HaveLen(5))
+		Expect(results[0].Package.Name).To(Equal("a"))
+		Expect(results[1].Status).To(Equal(types.InstallStatusFailed))
+		Expect(results[4].Package.Name).To(Equal("e"))
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("2 of 5 installs failed"))
+		Expect(err.Error()).To(ContainSubstring("b: boom"))
+		Expect(err.Error()).To(ContainSubstring("d: boom"))
+	})
+
+	It("runs more installs at once than the task manager has workers", func() {
+		inst := New(WithJobs(8))
+
+		var running, peak atomic.Int32
+		jobs := make([]installJob, 8)
+		for idx := range jobs {
+			jobs[idx] = installJob{name: string(rune('a' + idx)), run: func(ctx context.Context, t *task.Task) (*types.InstallResult, error) {
+				now := running.Add(1)
+				for {
+					old := peak.Load()
+					if now <= old || peak.CompareAndSwap(old, now) {
+						break
+					}
+				}
+				time.Sleep(200 * time.Millisecond)
+				running.Add(-1)
+				return &types.InstallResult{Status: types.InstallStatusInstalled}, nil
+			}}
+		}
+
+		_, err := inst.runInstalls(jobs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(peak.Load()).To(BeNumerically("==", 8))
+	})
+
+	It("limits concurrent downloads per host", func() {
+		hosts := newHostLimiter(1)
+		release := hosts.acquire("https://github.com/a/b/releases/download/v1/a.tar.gz", &task.Task{})
+
+		acquired := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			hosts.acquire("https://github.com/c/d/releases/download/v1/c.tar.gz", &task.Task{})()
+			close(acquired)
+		}()
+		Consistently(acquired, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		// Other hosts are not blocked
+		hosts.acquire("https://dl.k8s.io/kubectl", &task.Task{})()
+
+		release()
+		Eventually(acquired).Should(BeClosed())
+	})
+})
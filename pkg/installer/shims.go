@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/flanksource/deps/pkg/utils"
+)
+
+// shimMarker identifies files written by WriteShim, so RemoveShim never deletes anything else
+const shimMarker = "deps shim"
+
+// DefaultShimDir returns the global directory shims are written to (~/.deps/shims)
+func DefaultShimDir() string {
+	if dir := os.Getenv("DEPS_SHIM_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".deps", "shims")
+}
+
+func shimPath(shimDir, name string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(shimDir, name+".cmd")
+	}
+	return filepath.Join(shimDir, name)
+}
+
+// WriteShim writes a shim for name into shimDir that runs 'deps exec name' with the given deps binary,
+// so the version pinned by the nearest deps.yaml is used wherever the tool is invoked.
+func WriteShim(shimDir, name, depsBinary string) (string, error) {
+	if err := os.MkdirAll(shimDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create shim directory: %w", err)
+	}
+
+	var content string
+	if runtime.GOOS == "windows" {
+		content = fmt.Sprintf("@echo off\r\nrem %s: runs the %s version pinned by the nearest deps.yaml\r\n\"%s\" exec %s -- %%*\r\n",
+			shimMarker, name, depsBinary, name)
+	} else {
+		content = fmt.Sprintf("#!/bin/sh\n# %s: runs the %s version pinned by the nearest deps.yaml\nexec \"%s\" exec %s -- \"$@\"\n",
+			shimMarker, name, depsBinary, name)
+	}
+
+	path := shimPath(shimDir, name)
+	if err := utils.WriteFileAtomic(path, []byte(content), 0755); err != nil {
+		return "", fmt.Errorf("failed to write shim %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// RemoveShim removes the shim for name from shimDir, refusing to remove files deps did not write.
+func RemoveShim(shimDir, name string) error {
+	path := shimPath(shimDir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), shimMarker) {
+		return fmt.Errorf("%s is not a deps shim", path)
+	}
+	return os.Remove(path)
+}
+
+// ListShims returns the names of the tools with a shim in shimDir
+func ListShims(shimDir string) ([]string, error) {
+	entries, err := os.ReadDir(shimDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(shimDir, entry.Name()))
+		if err != nil || !strings.Contains(string(data), shimMarker) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".cmd"))
+	}
+	return names, nil
+}
@@ -0,0 +1,46 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shims", func() {
+	var shimDir string
+
+	BeforeEach(func() {
+		shimDir = filepath.Join(GinkgoT().TempDir(), "shims")
+	})
+
+	It("writes shims that exec the pinned version via deps exec", func() {
+		path, err := WriteShim(shimDir, "kubectl", "/usr/local/bin/deps")
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"/usr/local/bin/deps" exec kubectl --`))
+
+		names, err := ListShims(shimDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"kubectl"}))
+
+		Expect(RemoveShim(shimDir, "kubectl")).To(Succeed())
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("refuses to remove files that are not shims", func() {
+		Expect(os.MkdirAll(shimDir, 0755)).To(Succeed())
+		path := shimPath(shimDir, "helm")
+		Expect(os.WriteFile(path, []byte("real binary"), 0755)).To(Succeed())
+
+		Expect(RemoveShim(shimDir, "helm")).To(MatchError(ContainSubstring("not a deps shim")))
+		Expect(path).To(BeAnExistingFile())
+
+		names, err := ListShims(shimDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(BeEmpty())
+	})
+})
@@ -0,0 +1,254 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/receipt"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/utils"
+	"github.com/flanksource/deps/pkg/verify"
+)
+
+const (
+	// stagingDirName holds new versions next to bin-dir/app-dir entries until they pass verification
+	stagingDirName = ".deps-staging"
+	// previousDirName holds the version that was replaced, for 'deps rollback'
+	previousDirName = ".deps-previous"
+)
+
+// stagedPath returns where a new version of live is prepared before being swapped in.
+// It is a sibling of live (so the swap is a rename on the same filesystem) and keeps the
+// base name, as some tools behave differently depending on argv[0].
+func stagedPath(live string) string {
+	return filepath.Join(filepath.Dir(live), stagingDirName, filepath.Base(live))
+}
+
+// backupPath returns where the version replaced at live is kept for rollback.
+func backupPath(live string) string {
+	return filepath.Join(filepath.Dir(live), previousDirName, filepath.Base(live))
+}
+
+// prepareStaging returns an empty staging path for live.
+func prepareStaging(live string) (string, error) {
+	staged := stagedPath(live)
+	if err := os.RemoveAll(staged); err != nil {
+		return "", fmt.Errorf("failed to clear staging path %s: %w", staged, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(staged), 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return staged, nil
+}
+
+// staging is a new version prepared at staged, moved to live only once it has been verified.
+// A nil staging (e.g. for system installers, which install in place) is a no-op.
+type staging struct {
+	staged string
+	live   string
+}
+
+func newStaging(live string) (*staging, error) {
+	staged, err := prepareStaging(live)
+	if err != nil {
+		return nil, err
+	}
+	return &staging{staged: staged, live: live}, nil
+}
+
+// locate maps a path under live to the same path under the staged copy.
+func (s *staging) locate(path string) string {
+	if s == nil {
+		return path
+	}
+	rel, err := filepath.Rel(s.live, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.Join(s.staged, rel)
+}
+
+// discard removes a staged version that failed verification.
+func (s *staging) discard() {
+	if s == nil {
+		return
+	}
+	_ = os.RemoveAll(s.staged)
+	_ = os.Remove(filepath.Dir(s.staged))
+}
+
+// promote swaps the verified staged version into live. Whatever was at live is kept at its
+// backup path and recorded in rec, so the install can be rolled back.
+func (s *staging) promote(rec *receipt.Receipt) error {
+	if s == nil {
+		return nil
+	}
+	backup := backupPath(s.live)
+	hadLive, err := keepBackup(s.live, backup)
+	if err != nil {
+		s.discard()
+		return err
+	}
+
+	if err := os.Rename(s.staged, s.live); err != nil {
+		// Directories cannot be renamed over, so they were moved aside by keepBackup
+		if hadLive {
+			_ = restoreMovedBackup(backup, s.live)
+		}
+		s.discard()
+		return fmt.Errorf("failed to move %s into place: %w", s.live, err)
+	}
+	_ = os.Remove(filepath.Dir(s.staged))
+
+	if hadLive && rec != nil {
+		rec.AddBackup(s.live, backup)
+	}
+	return nil
+}
+
+// keepBackup saves the current live path at backup. Files and symlinks are linked or copied so
+// live keeps working until the staged version is renamed over it; directories are moved aside.
+func keepBackup(live, backup string) (bool, error) {
+	info, err := os.Lstat(live)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := os.RemoveAll(backup); err != nil {
+		return false, fmt.Errorf("failed to remove old backup %s: %w", backup, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+		return false, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(live)
+		if err != nil {
+			return false, err
+		}
+		err = os.Symlink(target, backup)
+		return err == nil, err
+	case info.IsDir():
+		if err := os.Rename(live, backup); err != nil {
+			return false, fmt.Errorf("failed to move %s aside: %w", live, err)
+		}
+		return true, nil
+	default:
+		if err := os.Link(live, backup); err == nil {
+			return true, nil
+		}
+		if err := utils.CopyFile(live, backup); err != nil {
+			return false, fmt.Errorf("failed to back up %s: %w", live, err)
+		}
+		return true, os.Chmod(backup, info.Mode().Perm())
+	}
+}
+
+func restoreMovedBackup(backup, live string) error {
+	if info, err := os.Lstat(backup); err != nil || !info.IsDir() {
+		return err
+	}
+	return os.Rename(backup, live)
+}
+
+// swapWithBackup exchanges live and its backup, so calling it twice restores the original state.
+func swapWithBackup(live, backup string) error {
+	if _, err := os.Lstat(backup); err != nil {
+		return fmt.Errorf("backup %s is missing: %w", backup, err)
+	}
+	tmp, err := prepareStaging(live)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(live); err == nil {
+		if err := os.Rename(live, tmp); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", live, err)
+		}
+	}
+	if err := os.Rename(backup, live); err != nil {
+		_ = os.Rename(tmp, live)
+		return fmt.Errorf("failed to restore %s: %w", live, err)
+	}
+	if _, err := os.Lstat(tmp); err == nil {
+		if err := os.Rename(tmp, backup); err != nil {
+			return fmt.Errorf("failed to keep %s as the backup: %w", live, err)
+		}
+	}
+	_ = os.Remove(filepath.Dir(tmp))
+	return nil
+}
+
+// verifyBinaryPlatform rejects a staged binary built for a different OS or architecture than
+// the target platform. Scripts and unknown formats are accepted, as are amd64 binaries on
+// arm64 macOS and Windows, which run under emulation.
+func (i *Installer) verifyBinaryPlatform(path string, pkg types.Package) error {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	detected, err := verify.DetectBinaryPlatform(path)
+	if err != nil || detected.Type == "unknown" {
+		return nil
+	}
+
+	plat := i.getPlatform()
+	if detected.OS != "" && detected.OS != plat.OS {
+		return fmt.Errorf("%s: downloaded binary is for %s, expected %s", pkg.Name, detected.OS, plat.OS)
+	}
+	emulated := detected.Arch == "amd64" && plat.Arch == "arm64" && (plat.OS == "darwin" || plat.OS == "windows")
+	if detected.Arch != "universal" && !archMatches(detected.Arch, plat.Arch) && !emulated {
+		return fmt.Errorf("%s: downloaded binary is for %s/%s, expected %s", pkg.Name, detected.OS, detected.Arch, plat)
+	}
+	return nil
+}
+
+// Rollback restores the version a tool had before its last install or 'deps use'.
+// Versions kept in the version store are switched back to directly; otherwise the
+// files kept by the last install are swapped back into place.
+func (i *Installer) Rollback(name string, t *task.Task) (string, error) {
+	name, pkg, err := i.lookupPackage(name)
+	if err != nil {
+		return "", err
+	}
+
+	if i.options.VersionsDir != "" && !i.isCrossPlatform() {
+		if previous := i.previousVersion(name); previous != "" && i.storedVersionPath(name, previous, pkg) != "" {
+			t.SetDescription(fmt.Sprintf("Rolling back %s to %s", name, previous))
+			if err := i.activateVersion(name, previous, pkg, t); err != nil {
+				return "", err
+			}
+			return previous, nil
+		}
+	}
+
+	if i.options.ReceiptsDir == "" {
+		return "", fmt.Errorf("no previous version of %s to roll back to", name)
+	}
+	unlock, err := i.lockPackage(name, t)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	rec, err := receipt.Load(i.options.ReceiptsDir, i.options.BinDir, name)
+	if err != nil || len(rec.Backups) == 0 {
+		return "", fmt.Errorf("no previous version of %s to roll back to", name)
+	}
+	t.SetDescription(fmt.Sprintf("Rolling back %s to %s", name, rec.PreviousVersion))
+	for live, backup := range rec.Backups {
+		if err := swapWithBackup(live, backup); err != nil {
+			return "", err
+		}
+	}
+	rec.Version, rec.PreviousVersion = rec.PreviousVersion, rec.Version
+	if err := rec.Save(i.options.ReceiptsDir); err != nil {
+		t.Warnf("Failed to update install receipt for %s: %v", name, err)
+	}
+	return rec.Version, nil
+}
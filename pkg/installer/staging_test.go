@@ -0,0 +1,121 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/receipt"
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// scriptManager installs a script printing version, like managers that build from source
+type scriptManager struct {
+	previewResolverManager
+	version string
+}
+
+func (m *scriptManager) Install(ctx context.Context, resolution *types.Resolution, opts types.InstallOptions) error {
+	return os.WriteFile(filepath.Join(opts.BinDir, resolution.Package.Name), []byte("#!/bin/sh\necho "+m.version+"\n"), 0755)
+}
+
+var _ = Describe("Staged installs", func() {
+	var (
+		binDir   string
+		live     string
+		testTask *task.Task
+	)
+
+	BeforeEach(func() {
+		binDir = GinkgoT().TempDir()
+		live = filepath.Join(binDir, "tool")
+		Expect(os.WriteFile(live, []byte("#!/bin/sh\necho 1.0.0\n"), 0755)).To(Succeed())
+		testTask = &task.Task{}
+	})
+
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("keeps the previous version when promoting and swaps it back on rollback", func() {
+		stage, err := newStaging(live)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(stage.staged, []byte("#!/bin/sh\necho 2.0.0\n"), 0755)).To(Succeed())
+
+		rec := &receipt.Receipt{BinDir: binDir}
+		Expect(stage.promote(rec)).To(Succeed())
+		Expect(read(live)).To(ContainSubstring("2.0.0"))
+		Expect(rec.Backups).To(HaveKeyWithValue(live, backupPath(live)))
+		Expect(read(backupPath(live))).To(ContainSubstring("1.0.0"))
+		Expect(filepath.Join(binDir, stagingDirName)).NotTo(BeADirectory())
+
+		Expect(swapWithBackup(live, backupPath(live))).To(Succeed())
+		Expect(read(live)).To(ContainSubstring("1.0.0"))
+		Expect(read(backupPath(live))).To(ContainSubstring("2.0.0"))
+	})
+
+	It("replaces directories and keeps the old one aside", func() {
+		appDir := filepath.Join(binDir, "jdk")
+		Expect(os.MkdirAll(filepath.Join(appDir, "bin"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(appDir, "release"), []byte("17"), 0644)).To(Succeed())
+
+		stage, err := newStaging(appDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(stage.staged, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(stage.staged, "release"), []byte("21"), 0644)).To(Succeed())
+		Expect(stage.locate(filepath.Join(appDir, "release"))).To(Equal(filepath.Join(stage.staged, "release")))
+
+		Expect(stage.promote(nil)).To(Succeed())
+		Expect(read(filepath.Join(appDir, "release"))).To(Equal("21"))
+		Expect(read(filepath.Join(backupPath(appDir), "release"))).To(Equal("17"))
+	})
+
+	It("leaves the installed version untouched when the staged version fails verification", func() {
+		if runtime.GOOS == "windows" {
+			Skip("shell scripts are not executable on windows")
+		}
+		inst := New(WithBinDir(binDir))
+		pkg := types.Package{Name: "tool", VersionCommand: "--version"}
+
+		stage, err := newStaging(live)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(stage.staged, []byte("#!/bin/sh\necho 1.5.0\n"), 0755)).To(Succeed())
+
+		err = inst.verifyInstallation("2.0.0", stage.locate(live), pkg, testTask)
+		var mismatch *VersionMismatchError
+		Expect(err).To(BeAssignableToTypeOf(mismatch))
+		stage.discard()
+
+		Expect(read(live)).To(ContainSubstring("1.0.0"))
+		Expect(stage.staged).NotTo(BeAnExistingFile())
+	})
+
+	It("stages manager installs and keeps the installed version when verification fails", func() {
+		if runtime.GOOS == "windows" {
+			Skip("shell scripts are not executable on windows")
+		}
+		inst := New(WithBinDir(binDir), WithReceiptsDir(""), WithVersionsDir(""), WithLocksDir(""))
+		pkg := types.Package{Name: "tool", VersionCommand: "--version"}
+		install := func(mgr *scriptManager, version string) error {
+			preview := &InstallPreview{Package: pkg, ResolvedVersion: version, Resolution: &types.Resolution{Package: pkg, Version: version}}
+			return inst.executePackageInstallation(context.Background(), "tool", pkg, preview, mgr, testTask, nil)
+		}
+
+		// A build that reports the wrong version is discarded
+		Expect(install(&scriptManager{previewResolverManager: previewResolverManager{name: "script"}, version: "1.5.0"}, "2.0.0")).
+			To(MatchError(ContainSubstring("1.5.0")))
+		Expect(read(live)).To(ContainSubstring("1.0.0"))
+		Expect(filepath.Join(binDir, stagingDirName)).NotTo(BeADirectory())
+
+		Expect(install(&scriptManager{previewResolverManager: previewResolverManager{name: "script"}, version: "2.0.0"}, "2.0.0")).To(Succeed())
+		Expect(read(live)).To(ContainSubstring("2.0.0"))
+		Expect(read(backupPath(live))).To(ContainSubstring("1.0.0"))
+		Expect(filepath.Join(binDir, stagingDirName)).NotTo(BeADirectory())
+	})
+})
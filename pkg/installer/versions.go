@@ -0,0 +1,344 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/receipt"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/utils"
+	versionpkg "github.com/flanksource/deps/pkg/version"
+)
+
+const (
+	// currentVersionFile records the active version inside the store directory of a tool
+	currentVersionFile = "current"
+	// previousVersionFile records the version that was active before it
+	previousVersionFile = "previous"
+)
+
+// InstalledVersion is a version of a tool kept in the local version store
+type InstalledVersion struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	Active  bool   `json:"active,omitempty"`
+}
+
+// isCrossPlatform reports whether the target platform differs from the host,
+// in which case installed binaries cannot be executed or switched between locally.
+func (i *Installer) isCrossPlatform() bool {
+	return (i.options.OSOverride != "" && i.options.OSOverride != runtime.GOOS) ||
+		(i.options.ArchOverride != "" && i.options.ArchOverride != runtime.GOARCH)
+}
+
+func isVersionAlias(version string) bool {
+	return version == "" || version == "stable" || version == "latest" || version == "any"
+}
+
+// usesAppDir reports whether the package is installed into app-dir and exposed via symlinks or a wrapper
+func usesAppDir(pkg types.Package) bool {
+	return pkg.Mode == "directory" || pkg.WrapperScript != ""
+}
+
+// versionStoreDir returns {VersionsDir}/{bin-dir key}/{name}. Each bin-dir has its own store, so
+// installing or switching a version in one project never changes the active version of another.
+func (i *Installer) versionStoreDir(name string) string {
+	return filepath.Join(i.options.VersionsDir, receipt.BinDirKey(i.options.BinDir), name)
+}
+
+// storeInstalledVersion keeps the freshly installed version in the version store so that
+// later installs of other versions do not overwrite it. Single binaries are kept in
+// {versionStoreDir}/{version}/ and bin-dir gets a symlink to them; directory-mode and wrapper
+// packages already live in a per-version app-dir folder when versioned_folder is set.
+func (i *Installer) storeInstalledVersion(name, version, finalPath string, pkg types.Package, t *task.Task, rec *receipt.Receipt) error {
+	if i.options.VersionsDir == "" || i.isCrossPlatform() || isVersionAlias(version) {
+		return nil
+	}
+
+	versionDir := filepath.Join(i.versionStoreDir(name), version)
+	if usesAppDir(pkg) {
+		// Without versioned_folder every version shares one folder, so there is nothing to switch between
+		if !pkg.VersionedFolder {
+			return nil
+		}
+		if err := os.MkdirAll(versionDir, 0755); err != nil {
+			return fmt.Errorf("failed to create version store %s: %w", versionDir, err)
+		}
+		// Keep a copy of the wrapper so 'deps exec' can run this version without switching bin-dir
+		if pkg.WrapperScript != "" {
+			if err := i.createWrapperScript(pkg, version, versionDir, t); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Only binaries installed directly into bin-dir are stored; system installers and markers are left alone
+		info, err := os.Lstat(finalPath)
+		if err != nil || !info.Mode().IsRegular() || filepath.Dir(finalPath) != filepath.Clean(i.options.BinDir) {
+			return nil
+		}
+		if err := os.MkdirAll(versionDir, 0755); err != nil {
+			return fmt.Errorf("failed to create version store %s: %w", versionDir, err)
+		}
+
+		stored := filepath.Join(versionDir, filepath.Base(finalPath))
+		_ = os.Remove(stored)
+		// Link (or copy) rather than move, so the bin-dir entry never disappears before the symlink replaces it
+		if err := os.Link(finalPath, stored); err != nil {
+			if err := utils.CopyFile(finalPath, stored); err != nil {
+				return fmt.Errorf("failed to store %s@%s: %w", name, version, err)
+			}
+			if err := os.Chmod(stored, 0755); err != nil {
+				return fmt.Errorf("failed to make stored binary executable: %w", err)
+			}
+		}
+		if err := utils.ReplaceSymlink(stored, finalPath); err != nil {
+			return fmt.Errorf("failed to link %s to %s: %w", finalPath, stored, err)
+		}
+		if rec != nil {
+			rec.MarkSymlink(finalPath)
+		}
+	}
+
+	if err := i.setCurrentVersion(name, version); err != nil {
+		return err
+	}
+	if rec != nil {
+		rec.AddFile(i.versionStoreDir(name))
+	}
+	t.V(3).Infof("Stored %s@%s in %s", name, version, versionDir)
+	return nil
+}
+
+// setCurrentVersion records version as active, remembering the version it replaces for 'deps rollback'
+func (i *Installer) setCurrentVersion(name, version string) error {
+	if current := i.currentVersion(name); current != "" && current != version {
+		if err := utils.WriteFileAtomic(filepath.Join(i.versionStoreDir(name), previousVersionFile), []byte(current+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	return utils.WriteFileAtomic(filepath.Join(i.versionStoreDir(name), currentVersionFile), []byte(version+"\n"), 0644)
+}
+
+func (i *Installer) previousVersion(name string) string {
+	data, err := os.ReadFile(filepath.Join(i.versionStoreDir(name), previousVersionFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (i *Installer) currentVersion(name string) string {
+	data, err := os.ReadFile(filepath.Join(i.versionStoreDir(name), currentVersionFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// storedVersionPath returns the binary or app-dir folder backing a stored version,
+// or "" if that version is not (or no longer) available locally.
+func (i *Installer) storedVersionPath(name, version string, pkg types.Package) string {
+	if _, err := os.Stat(filepath.Join(i.versionStoreDir(name), version)); err != nil {
+		return ""
+	}
+	path := filepath.Join(i.versionStoreDir(name), version, name)
+	if usesAppDir(pkg) {
+		path = filepath.Join(i.options.AppDir, pkg.FolderName(version))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// activateStoredVersion switches to an already stored version instead of downloading it again.
+// Returns false when the version is not in the store or switching failed.
+func (i *Installer) activateStoredVersion(name, version string, pkg types.Package, t *task.Task) bool {
+	if i.options.VersionsDir == "" || i.options.Force || i.isCrossPlatform() || isVersionAlias(version) {
+		return false
+	}
+	if i.storedVersionPath(name, version, pkg) == "" {
+		return false
+	}
+	if err := i.activateVersion(name, version, pkg, t); err != nil {
+		t.V(2).Infof("Failed to switch to stored %s@%s, reinstalling: %v", name, version, err)
+		return false
+	}
+	t.Infof("✓ Switched %s to installed version %s", name, version)
+	t.Success()
+	return true
+}
+
+// activateVersion atomically repoints bin-dir at a stored version.
+func (i *Installer) activateVersion(name, version string, pkg types.Package, t *task.Task) error {
+	path := i.storedVersionPath(name, version, pkg)
+	if path == "" {
+		return fmt.Errorf("%s@%s is not installed in %s", name, version, i.versionStoreDir(name))
+	}
+	unlock, err := i.lockPackage(name, t)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.MkdirAll(i.options.BinDir, 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory: %w", err)
+	}
+
+	if usesAppDir(pkg) {
+		if pkg.Mode == "directory" && len(pkg.Symlinks) > 0 {
+			patterns := manager.FilterEntriesByPlatform(pkg.Symlinks, i.getPlatform())
+			if _, err := i.createSymlinks(path, i.options.BinDir, patterns, t); err != nil {
+				return fmt.Errorf("failed to create symlinks: %w", err)
+			}
+		}
+		if pkg.WrapperScript != "" {
+			if err := i.createWrapperScript(pkg, version, i.options.BinDir, t); err != nil {
+				return fmt.Errorf("failed to create wrapper script: %w", err)
+			}
+		}
+	} else if err := utils.ReplaceSymlink(path, filepath.Join(i.options.BinDir, name)); err != nil {
+		return fmt.Errorf("failed to link %s@%s: %w", name, version, err)
+	}
+
+	if err := i.setCurrentVersion(name, version); err != nil {
+		return err
+	}
+
+	if i.options.ReceiptsDir != "" {
+		if rec, err := receipt.Load(i.options.ReceiptsDir, i.options.BinDir, name); err == nil {
+			rec.Version = version
+			if err := rec.Save(i.options.ReceiptsDir); err != nil {
+				t.Warnf("Failed to update install receipt for %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// InstalledVersions lists the versions of a tool kept in the version store, newest first.
+func (i *Installer) InstalledVersions(name string) ([]InstalledVersion, error) {
+	if i.options.VersionsDir == "" {
+		return nil, fmt.Errorf("version store is disabled")
+	}
+	name, pkg, err := i.lookupPackage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(i.versionStoreDir(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	current := i.currentVersion(name)
+	var versions []InstalledVersion
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := i.storedVersionPath(name, entry.Name(), pkg)
+		if path == "" {
+			continue
+		}
+		versions = append(versions, InstalledVersion{
+			Version: entry.Name(),
+			Path:    path,
+			Active:  entry.Name() == current,
+		})
+	}
+
+	names := make([]string, len(versions))
+	for idx, v := range versions {
+		names[idx] = v.Version
+	}
+	if sorted, err := versionpkg.SortVersionsDescending(names); err == nil && len(sorted) == len(names) {
+		rank := make(map[string]int, len(sorted))
+		for idx, v := range sorted {
+			rank[v] = idx
+		}
+		sort.Slice(versions, func(a, b int) bool { return rank[versions[a].Version] < rank[versions[b].Version] })
+	} else {
+		sort.Slice(versions, func(a, b int) bool { return versions[a].Version > versions[b].Version })
+	}
+	return versions, nil
+}
+
+// Use switches the active version of a tool to an already installed version.
+// The version may be exact ("1.7.1", "v1.7.1") or a partial/constraint ("1.7", "^1"),
+// in which case the newest matching installed version is used.
+func (i *Installer) Use(name, version string, t *task.Task) (string, error) {
+	name, pkg, err := i.lookupPackage(name)
+	if err != nil {
+		return "", err
+	}
+	if i.isCrossPlatform() {
+		return "", fmt.Errorf("cannot switch versions for a cross-platform target")
+	}
+
+	installed, err := i.InstalledVersions(name)
+	if err != nil {
+		return "", err
+	}
+	match, err := matchInstalledVersion(installed, version)
+	if err != nil {
+		available := make([]string, len(installed))
+		for idx, v := range installed {
+			available[idx] = v.Version
+		}
+		if len(available) == 0 {
+			return "", fmt.Errorf("%s@%s is not installed, run 'deps install %s@%s' first", name, version, name, version)
+		}
+		return "", fmt.Errorf("%s@%s is not installed (installed: %s), run 'deps install %s@%s' first",
+			name, version, strings.Join(available, ", "), name, version)
+	}
+
+	t.SetDescription(fmt.Sprintf("Switching %s to %s", name, match))
+	if err := i.activateVersion(name, match, pkg, t); err != nil {
+		return "", err
+	}
+	return match, nil
+}
+
+// matchInstalledVersion picks the installed version matching the requested one,
+// preferring an exact match and otherwise the newest version satisfying it as a constraint.
+func matchInstalledVersion(installed []InstalledVersion, requested string) (string, error) {
+	for _, v := range installed {
+		if v.Version == requested || utils.Normalize(v.Version) == utils.Normalize(requested) {
+			return v.Version, nil
+		}
+	}
+
+	constraint, err := versionpkg.ParseConstraint(requested)
+	if err != nil {
+		return "", err
+	}
+	// installed is sorted newest first
+	for _, v := range installed {
+		if constraint.Check(v.Version) {
+			return v.Version, nil
+		}
+	}
+	return "", fmt.Errorf("no installed version matches %s", requested)
+}
+
+// lookupPackage finds a package definition by name in the registry or as an owner/repo GitHub reference.
+// It returns the name the tool is installed under along with the package.
+func (i *Installer) lookupPackage(name string) (string, types.Package, error) {
+	var registry map[string]types.Package
+	if i.depsConfig != nil {
+		registry = i.depsConfig.Registry
+	}
+	if installName, pkg, ok := findPackage(registry, name); ok {
+		return installName, pkg, nil
+	}
+	return "", types.Package{}, fmt.Errorf("tool %s not found in registry - please add it to deps.yaml registry section", name)
+}
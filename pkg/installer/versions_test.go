@@ -0,0 +1,137 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/clicky/task"
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/receipt"
+	"github.com/flanksource/deps/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Version store", func() {
+	var (
+		tmpDir      string
+		binDir      string
+		versionsDir string
+		storeDir    string
+		inst        *Installer
+		pkg         types.Package
+		testTask    *task.Task
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "versions-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		binDir = filepath.Join(tmpDir, "bin")
+		versionsDir = filepath.Join(tmpDir, "versions")
+		storeDir = filepath.Join(versionsDir, receipt.BinDirKey(binDir), "tool")
+		Expect(os.MkdirAll(binDir, 0755)).To(Succeed())
+
+		pkg = types.Package{Name: "tool", Manager: "github_release"}
+		inst = NewWithConfig(&types.DepsConfig{Registry: map[string]types.Package{"tool": pkg}},
+			WithBinDir(binDir), WithVersionsDir(versionsDir), WithReceiptsDir(""))
+		testTask = &task.Task{}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	install := func(version string) {
+		// Installs are staged and renamed over the bin-dir symlink, never written through it
+		binPath := filepath.Join(binDir, "tool")
+		stage, err := newStaging(binPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(stage.staged, []byte("#!/bin/sh\necho "+version+"\n"), 0755)).To(Succeed())
+		Expect(stage.promote(nil)).To(Succeed())
+		Expect(inst.storeInstalledVersion("tool", version, binPath, pkg, testTask, nil)).To(Succeed())
+	}
+
+	readActive := func() string {
+		data, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("keeps every installed version side by side", func() {
+		install("1.28.4")
+		install("1.29.3")
+
+		versions, err := inst.InstalledVersions("tool")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[0].Version).To(Equal("1.29.3"))
+		Expect(versions[0].Active).To(BeTrue())
+		Expect(versions[1].Version).To(Equal("1.28.4"))
+		Expect(versions[1].Active).To(BeFalse())
+		Expect(readActive()).To(ContainSubstring("1.29.3"))
+	})
+
+	It("switches bin-dir to a stored version", func() {
+		install("1.28.4")
+		install("1.29.3")
+
+		version, err := inst.Use("tool", "1.28", testTask)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("1.28.4"))
+		Expect(readActive()).To(ContainSubstring("1.28.4"))
+
+		target, err := os.Readlink(filepath.Join(binDir, "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal(filepath.Join(storeDir, "1.28.4", "tool")))
+		Expect(inst.currentVersion("tool")).To(Equal("1.28.4"))
+	})
+
+	It("does not overwrite stored versions when installing another", func() {
+		install("1.28.4")
+		install("1.29.3")
+
+		data, err := os.ReadFile(filepath.Join(storeDir, "1.28.4", "tool"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("1.28.4"))
+	})
+
+	It("keeps a separate store for each bin dir", func() {
+		install("1.28.4")
+
+		otherBinDir := filepath.Join(tmpDir, "other", "bin")
+		other := NewWithConfig(&types.DepsConfig{Registry: map[string]types.Package{"tool": pkg}},
+			WithBinDir(otherBinDir), WithVersionsDir(versionsDir), WithReceiptsDir(""))
+		versions, err := other.InstalledVersions("tool")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(BeEmpty())
+		Expect(other.currentVersion("tool")).To(BeEmpty())
+
+		_, err = other.Use("tool", "1.28.4", testTask)
+		Expect(err).To(HaveOccurred())
+		Expect(filepath.Join(otherBinDir, "tool")).NotTo(BeAnExistingFile())
+		Expect(inst.currentVersion("tool")).To(Equal("1.28.4"))
+	})
+
+	It("fails for versions that are not installed", func() {
+		install("1.28.4")
+
+		_, err := inst.Use("tool", "1.30", testTask)
+		Expect(err).To(MatchError(ContainSubstring("installed: 1.28.4")))
+	})
+
+	It("runs the project's pinned version without switching bin-dir", func() {
+		install("1.28.4")
+		install("1.29.3")
+
+		project := &config.Project{
+			Dir:        tmpDir,
+			ConfigPath: filepath.Join(tmpDir, config.DepsFile),
+			Config:     &types.DepsConfig{Dependencies: map[string]string{"tool": "1.28"}},
+		}
+		path, err := inst.ProjectExecutable(project, "tool", testTask)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(filepath.Join(storeDir, "1.28.4", "tool")))
+		Expect(readActive()).To(ContainSubstring("1.29.3"))
+	})
+})
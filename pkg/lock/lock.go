@@ -10,6 +10,7 @@ import (
 	flanksourceContext "github.com/flanksource/commons/context"
 	"github.com/flanksource/deps/pkg/checksum"
 	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/manager/github"
 	"github.com/flanksource/deps/pkg/platform"
 	"github.com/flanksource/deps/pkg/types"
 	"github.com/flanksource/deps/pkg/version"
@@ -48,6 +49,7 @@ func (g *Generator) Generate(ctx context.Context, deps map[string]string, regist
 	filteredDeps := g.filterDependencies(deps, opts.Packages)
 
 	mainTask.Infof("Locking %d dependencies for %d platforms", len(filteredDeps), len(platforms))
+	g.prefetchVersions(ctx, filteredDeps, registry)
 
 	// Start individual tasks for each dependency-platform combination
 	for name, versionConstraint := range filteredDeps {
@@ -129,6 +131,7 @@ func (g *Generator) Update(ctx context.Context, existingLock *types.LockFile, de
 	filteredDeps := g.filterDependencies(deps, opts.Packages)
 
 	mainTask.Infof("Updating %d dependencies for %d platforms", len(filteredDeps), len(platforms))
+	g.prefetchVersions(ctx, filteredDeps, registry)
 
 	// Process only dependencies that are in the current deps.yaml and match filter
 	for name, versionConstraint := range filteredDeps {
@@ -289,6 +292,9 @@ func (g *Generator) resolveDependencyForPlatform(ctx context.Context, pkg types.
 			Tag:          version,
 			ChecksumFile: pkg.ChecksumFile,
 		}
+		if baseURL := github.BaseURL(pkg); github.IsEnterprise(baseURL) {
+			entry.GitHub.BaseURL = baseURL
+		}
 	}
 
 	// Resolve this single platform
@@ -444,6 +450,18 @@ func formatPackageIdentifier(pkg types.Package) string {
 	return fmt.Sprintf("%s (%s)", pkg.Name, pkg.Manager)
 }
 
+// prefetchVersions fetches the versions of deps in batches where their managers support it,
+// instead of with a request per dependency
+func (g *Generator) prefetchVersions(ctx context.Context, deps map[string]string, registry map[string]types.Package) {
+	pkgs := make([]types.Package, 0, len(deps))
+	for name := range deps {
+		if pkg, ok := registry[name]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	g.managers.PrefetchVersions(ctx, pkgs)
+}
+
 // filterDependencies filters dependencies based on package names
 func (g *Generator) filterDependencies(deps map[string]string, packages []string) map[string]string {
 	// If no specific packages requested, return all dependencies
@@ -30,7 +30,7 @@ type ApacheManager struct {
 // NewApacheManager creates a new Apache archives manager
 func NewApacheManager() *ApacheManager {
 	return &ApacheManager{
-		client:             depshttp.GetHttpClient(),
+		client:             depshttp.GetHttpClient(depshttp.WithResponseCache()),
 		defaultURLTemplate: "https://archive.apache.org/dist/{{.name}}/{{.asset}}",
 	}
 }
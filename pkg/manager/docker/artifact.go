@@ -0,0 +1,224 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/download"
+	"github.com/flanksource/deps/pkg/extract"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/platform"
+	depstemplate "github.com/flanksource/deps/pkg/template"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/version"
+)
+
+// ArtifactManager implements the PackageManager interface for files pushed to a registry as OCI
+// artifacts, e.g. with `oras push`, where each layer is a file named by its
+// org.opencontainers.image.title annotation
+type ArtifactManager struct {
+	client *oci.Client
+}
+
+// NewArtifactManager creates a new OCI artifact manager
+func NewArtifactManager() *ArtifactManager {
+	return &ArtifactManager{client: oci.NewClient()}
+}
+
+// Name returns the manager identifier
+func (m *ArtifactManager) Name() string {
+	return "oci_artifact"
+}
+
+// DiscoverVersions returns the versions of the artifact tags, newest first
+func (m *ArtifactManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	return discoverVersions(ctx, m.client, pkg, limit)
+}
+
+// Resolve finds the layer of the artifact tagged versionStr for the platform. The download URL
+// is the artifact repository pinned to the layer digest, e.g. oci://ghcr.io/org/tool@sha256:...,
+// and the digest is the checksum recorded in the lock file.
+func (m *ArtifactManager) Resolve(ctx context.Context, pkg types.Package, versionStr string, plat platform.Platform) (*types.Resolution, error) {
+	for _, tag := range candidateTags(versionStr) {
+		ref, err := imageReference(pkg, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest, _, err := m.client.PlatformManifest(ctx, ref, plat)
+		if errors.Is(err, oci.ErrNotFound) {
+			continue
+		}
+		var platformErr *oci.ErrPlatformNotFound
+		if errors.As(err, &platformErr) {
+			return nil, &manager.ErrPlatformNotSupported{
+				Package:            pkg.Name,
+				Platform:           plat.String(),
+				AvailablePlatforms: platformErr.Available,
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resolvedVersion := version.Normalize(tag)
+		layer, err := selectLayer(pkg, manifest, resolvedVersion, tag, plat)
+		if err != nil {
+			return nil, err
+		}
+
+		resolution := &types.Resolution{
+			Package:     pkg,
+			Version:     resolvedVersion,
+			Platform:    plat,
+			DownloadURL: ref.WithDigest(layer.Digest).URL(),
+			Checksum:    layer.Digest,
+			Size:        layer.Size,
+			IsArchive:   extract.IsArchive(layer.Title()),
+		}
+		if pkg.Extract != nil {
+			resolution.IsArchive = *pkg.Extract
+		}
+		if resolution.IsArchive {
+			resolution.BinaryPath = binaryPath(pkg, resolvedVersion, tag, plat)
+		}
+		logger.Debugf("Resolved %s", resolution.Pretty().ANSI())
+		return resolution, nil
+	}
+	return nil, &manager.ErrVersionNotFound{Package: pkg.Name, Version: versionStr}
+}
+
+// Install downloads the pinned layer by its digest into the bin directory, extracting the
+// binary first when the layer is an archive. The installer downloads oci:// URLs itself, through
+// the download cache and staging, so this is only used when the manager is called directly.
+func (m *ArtifactManager) Install(ctx context.Context, resolution *types.Resolution, opts types.InstallOptions) error {
+	if _, err := pinnedReference(resolution); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "deps-"+resolution.Package.Name+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	layer := filepath.Join(tmpDir, "layer")
+	if err := download.Download(resolution.DownloadURL, layer, nil,
+		download.WithContext(ctx), download.WithChecksum(resolution.Checksum)); err != nil {
+		return err
+	}
+
+	binary := layer
+	if resolution.IsArchive {
+		// The archive format is detected from its content, since the blob has no file name
+		if binary, err = extract.Extract(layer, filepath.Join(tmpDir, "extract"), nil, extract.WithBinaryPath(resolution.BinaryPath)); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", resolution.DownloadURL, err)
+		}
+	}
+	return installFile(resolution, opts, func(w io.Writer) error {
+		f, err := os.Open(binary)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+// GetChecksums returns the digests of the layers of the artifact tagged versionStr, keyed by
+// their title, across the platforms of an image index
+func (m *ArtifactManager) GetChecksums(ctx context.Context, pkg types.Package, versionStr string) (map[string]string, error) {
+	ref, err := imageReference(pkg, versionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, _, err := m.client.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	manifests := []*oci.Manifest{manifest}
+	if manifest.IsIndex() {
+		manifests = nil
+		for _, desc := range manifest.Manifests {
+			if desc.Platform == nil || desc.Platform.OS == "unknown" {
+				continue
+			}
+			platformManifest, _, err := m.client.Manifest(ctx, ref.WithDigest(desc.Digest))
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, platformManifest)
+		}
+	}
+
+	checksums := make(map[string]string)
+	for _, manifest := range manifests {
+		for _, layer := range manifest.Layers {
+			if title := layer.Title(); title != "" {
+				checksums[title] = layer.Digest
+			}
+		}
+	}
+	return checksums, nil
+}
+
+// Verify checks if an installed binary matches the expected version/checksum
+func (m *ArtifactManager) Verify(ctx context.Context, binaryPath string, pkg types.Package) (*types.InstalledInfo, error) {
+	return nil, fmt.Errorf("verify not implemented for OCI artifact manager")
+}
+
+// selectLayer picks the layer for plat: the one titled as asset_patterns names, else the only
+// layer, or else the only one left after filtering the titles by OS and architecture
+func selectLayer(pkg types.Package, manifest *oci.Manifest, resolvedVersion, tag string, plat platform.Platform) (*oci.Descriptor, error) {
+	titles := make([]string, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		if layer.Title() != "" {
+			titles = append(titles, layer.Title())
+		}
+	}
+
+	assetPattern, err := manager.ResolveAssetPattern(pkg.AssetPatterns, plat, pkg.Name)
+	if err != nil && len(pkg.AssetPatterns) > 0 {
+		return nil, err
+	}
+	if assetPattern != "" {
+		pattern, err := depstemplate.TemplateString(assetPattern, map[string]string{
+			"name": pkg.Name, "version": resolvedVersion, "tag": tag,
+			"os": plat.OS, "arch": plat.Arch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to template asset pattern: %w", err)
+		}
+		for i, layer := range manifest.Layers {
+			if ok, _ := filepath.Match(pattern, layer.Title()); ok || layer.Title() == pattern {
+				return &manifest.Layers[i], nil
+			}
+		}
+		return nil, &manager.ErrAssetNotFound{Package: pkg.Name, AssetPattern: pattern, Platform: plat.String(), AvailableAssets: titles}
+	}
+
+	if len(manifest.Layers) == 1 {
+		return &manifest.Layers[0], nil
+	}
+
+	assets := make([]manager.AssetInfo, len(titles))
+	for i, title := range titles {
+		assets[i] = manager.AssetInfo{Name: title}
+	}
+	if filtered, err := manager.FilterAssetsByPlatform(assets, plat.OS, plat.Arch); err == nil && len(filtered) == 1 {
+		for i, layer := range manifest.Layers {
+			if layer.Title() == filtered[0].Name {
+				return &manifest.Layers[i], nil
+			}
+		}
+	}
+	return nil, &manager.ErrAssetNotFound{Package: pkg.Name, AssetPattern: "{{.os}}-{{.arch}}", Platform: plat.String(), AvailableAssets: titles}
+}
@@ -0,0 +1,150 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/oci/ocitest"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// pushArtifact pushes files as an artifact like `oras push`, one layer per file titled by its name
+func pushArtifact(t *testing.T, registry *ocitest.Registry, repo, tag string, files map[string]string) oci.Descriptor {
+	manifest := oci.Manifest{
+		ArtifactType: "application/vnd.example.tool",
+		Config:       registry.PushBlob("application/vnd.oci.empty.v1+json", []byte("{}")),
+	}
+	for name, content := range files {
+		layer := registry.PushBlob("application/octet-stream", []byte(content))
+		layer.Annotations = map[string]string{oci.AnnotationTitle: name}
+		manifest.Layers = append(manifest.Layers, layer)
+	}
+	return registry.PushManifest(t, repo, tag, manifest)
+}
+
+func TestArtifactResolveAndInstall(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	pushArtifact(t, registry, "org/tool", "v1.0.0", map[string]string{
+		"tool_linux_amd64":  "linux binary",
+		"tool_darwin_arm64": "darwin binary",
+		"README.md":         "docs",
+	})
+	pkg := types.Package{Name: "tool", Manager: "oci_artifact", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+	m := NewArtifactManager()
+	ctx := context.Background()
+
+	// Without asset_patterns the layer is picked by the OS and architecture in its title
+	resolution, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	ref, err := oci.ParseReference(resolution.DownloadURL)
+	if err != nil {
+		t.Fatalf("expected an oci:// download URL, got %q: %v", resolution.DownloadURL, err)
+	}
+	if ref.Digest == "" || resolution.Checksum != ref.Digest || resolution.Size != int64(len("linux binary")) || resolution.IsArchive {
+		t.Errorf("expected the resolution to be pinned to the layer digest, got %+v", resolution)
+	}
+
+	binDir := t.TempDir()
+	if err := m.Install(ctx, resolution, types.InstallOptions{BinDir: binDir}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(binDir, "tool")); string(content) != "linux binary" {
+		t.Errorf("installed the wrong layer: %q", content)
+	}
+
+	pkg.AssetPatterns = map[string]string{"darwin-*": "tool_{{.os}}_{{.arch}}"}
+	resolution, err = m.Resolve(ctx, pkg, "v1.0.0", platform.Platform{OS: "darwin", Arch: "arm64"})
+	if err != nil {
+		t.Fatalf("Resolve with asset_patterns: %v", err)
+	}
+	checksums, err := m.GetChecksums(ctx, pkg, "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetChecksums: %v", err)
+	}
+	if len(checksums) != 3 || checksums["tool_darwin_arm64"] != resolution.Checksum {
+		t.Errorf("unexpected checksums %v for %s", checksums, resolution.Checksum)
+	}
+
+	var platformErr *manager.ErrPlatformNotSupported
+	if _, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "windows", Arch: "amd64"}); !errors.As(err, &platformErr) {
+		t.Errorf("expected platforms missing from asset_patterns to be unsupported, got %v", err)
+	}
+
+	var notFound *manager.ErrVersionNotFound
+	if _, err := m.Resolve(ctx, pkg, "2.0.0", platform.Platform{OS: "darwin", Arch: "arm64"}); !errors.As(err, &notFound) {
+		t.Errorf("expected a missing tag to be reported, got %v", err)
+	}
+}
+
+func TestArtifactVerifiesDigest(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	pushArtifact(t, registry, "org/tool", "v1.0.0", map[string]string{"tool": "binary"})
+	pkg := types.Package{Name: "tool", Manager: "oci_artifact", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+	m := NewArtifactManager()
+	ctx := context.Background()
+
+	resolution, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	binDir := t.TempDir()
+
+	// A lock entry whose URL does not match its checksum
+	tampered := *resolution
+	tampered.Checksum = "sha256:" + "0000000000000000000000000000000000000000000000000000000000000000"
+	if err := m.Install(ctx, &tampered, types.InstallOptions{BinDir: binDir}); err == nil {
+		t.Error("expected a checksum that does not match the pinned digest to fail")
+	}
+
+	registry.ReplaceBlob(resolution.Checksum, []byte("BINARY"))
+	if err := m.Install(ctx, resolution, types.InstallOptions{BinDir: binDir}); err == nil {
+		t.Error("expected a layer that does not match its digest to fail")
+	}
+	if _, err := os.Stat(filepath.Join(binDir, "tool")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be installed, got %v", err)
+	}
+}
+
+func TestArtifactArchiveByPlatform(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	var manifests []oci.Descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		archive := registry.PushBlob(oci.MediaTypeImageLayerGzip, ocitest.Layer(t, ocitest.File{Name: "tool-" + arch + "/tool", Content: "tool " + arch}))
+		archive.Annotations = map[string]string{oci.AnnotationTitle: "tool.tar.gz"}
+		desc := registry.PushManifest(t, "org/tool", "", oci.Manifest{
+			Config: registry.PushBlob("application/vnd.oci.empty.v1+json", []byte("{}")),
+			Layers: []oci.Descriptor{archive},
+		})
+		desc.Platform = &oci.Platform{OS: "linux", Architecture: arch}
+		manifests = append(manifests, desc)
+	}
+	registry.PushManifest(t, "org/tool", "v1.0.0", oci.Manifest{MediaType: oci.MediaTypeImageIndex, Manifests: manifests})
+
+	pkg := types.Package{Name: "tool", Manager: "oci_artifact", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+	m := NewArtifactManager()
+	ctx := context.Background()
+
+	resolution, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "linux", Arch: "arm64"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !resolution.IsArchive || resolution.BinaryPath != "tool" {
+		t.Errorf("expected the tool.tar.gz layer to be extracted, got %+v", resolution)
+	}
+
+	binDir := t.TempDir()
+	if err := m.Install(ctx, resolution, types.InstallOptions{BinDir: binDir}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(binDir, "tool")); string(content) != "tool arm64" {
+		t.Errorf("installed the wrong binary: %q", content)
+	}
+}
@@ -0,0 +1,266 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/platform"
+	depstemplate "github.com/flanksource/deps/pkg/template"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/version"
+)
+
+// DockerManager implements the PackageManager interface for binaries shipped inside container
+// images, set by extra.image. It pulls from the registry HTTP API, so no Docker daemon is needed.
+type DockerManager struct {
+	client *oci.Client
+}
+
+// NewDockerManager creates a new Docker image manager
+func NewDockerManager() *DockerManager {
+	return &DockerManager{client: oci.NewClient()}
+}
+
+// Name returns the manager identifier
+func (m *DockerManager) Name() string {
+	return "docker"
+}
+
+// DiscoverVersions returns the versions of the image tags, newest first
+func (m *DockerManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	return discoverVersions(ctx, m.client, pkg, limit)
+}
+
+// Resolve finds the image manifest of the platform for the tag of versionStr. The download URL
+// is the image pinned to the manifest digest, e.g. docker://ghcr.io/org/tool@sha256:..., and the
+// digest is the checksum recorded in the lock file.
+func (m *DockerManager) Resolve(ctx context.Context, pkg types.Package, versionStr string, plat platform.Platform) (*types.Resolution, error) {
+	for _, tag := range candidateTags(versionStr) {
+		ref, err := imageReference(pkg, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest, digest, err := m.client.PlatformManifest(ctx, ref, plat)
+		if errors.Is(err, oci.ErrNotFound) {
+			continue
+		}
+		var platformErr *oci.ErrPlatformNotFound
+		if errors.As(err, &platformErr) {
+			return nil, &manager.ErrPlatformNotSupported{
+				Package:            pkg.Name,
+				Platform:           plat.String(),
+				AvailablePlatforms: platformErr.Available,
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resolvedVersion := version.Normalize(tag)
+		resolution := &types.Resolution{
+			Package:     pkg,
+			Version:     resolvedVersion,
+			Platform:    plat,
+			DownloadURL: ref.WithDigest(digest).ImageURL(),
+			Checksum:    digest,
+			Size:        manifest.Size(),
+			BinaryPath:  binaryPath(pkg, resolvedVersion, tag, plat),
+		}
+		logger.Debugf("Resolved %s", resolution.Pretty().ANSI())
+		return resolution, nil
+	}
+	return nil, &manager.ErrVersionNotFound{Package: pkg.Name, Version: versionStr}
+}
+
+// Install extracts the binary_path of the resolution from the layers of the pinned image into
+// the bin directory
+func (m *DockerManager) Install(ctx context.Context, resolution *types.Resolution, opts types.InstallOptions) error {
+	ref, err := pinnedReference(resolution)
+	if err != nil {
+		return err
+	}
+
+	manifest, _, err := m.client.PlatformManifest(ctx, ref, resolution.Platform)
+	if err != nil {
+		return err
+	}
+
+	filePath := resolution.BinaryPath
+	if filePath == "" {
+		filePath = binaryPath(resolution.Package, resolution.Version, resolution.Version, resolution.Platform)
+	}
+	return installFile(resolution, opts, func(w io.Writer) error {
+		extracted, err := m.client.ExtractFile(ctx, ref, manifest, filePath, w)
+		if err == nil {
+			logger.Debugf("Extracted /%s from %s", extracted, ref)
+		}
+		return err
+	})
+}
+
+// GetChecksums returns the manifest digests of the image for versionStr, keyed by platform
+func (m *DockerManager) GetChecksums(ctx context.Context, pkg types.Package, versionStr string) (map[string]string, error) {
+	ref, err := imageReference(pkg, versionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, digest, err := m.client.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !manifest.IsIndex() {
+		plat, err := m.client.ImagePlatform(ctx, ref, manifest)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{plat.String(): digest}, nil
+	}
+
+	checksums := make(map[string]string)
+	for _, desc := range manifest.Manifests {
+		if desc.Platform == nil || desc.Platform.OS == "unknown" {
+			continue
+		}
+		checksums[desc.Platform.String()] = desc.Digest
+	}
+	return checksums, nil
+}
+
+// Verify checks if an installed binary matches the expected version/checksum
+func (m *DockerManager) Verify(ctx context.Context, binaryPath string, pkg types.Package) (*types.InstalledInfo, error) {
+	return nil, fmt.Errorf("verify not implemented for Docker manager")
+}
+
+// discoverVersions returns the versions of the tags of the repository of extra.image
+func discoverVersions(ctx context.Context, client *oci.Client, pkg types.Package, limit int) ([]types.Version, error) {
+	ref, err := imageReference(pkg, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := client.Tags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]types.Version, 0, len(tags))
+	for _, tag := range tags {
+		versions = append(versions, types.ParseVersion(version.Normalize(tag), tag))
+	}
+	return version.FinalizeDiscoveredVersions(versions, pkg, limit)
+}
+
+// candidateTags returns the tags tried for versionStr, with and without a v prefix
+func candidateTags(versionStr string) []string {
+	if strings.HasPrefix(versionStr, "v") {
+		return []string{versionStr, strings.TrimPrefix(versionStr, "v")}
+	}
+	return []string{versionStr, "v" + versionStr}
+}
+
+// pinnedReference returns the reference of the oci:// or docker:// download URL of resolution, which
+// must be pinned to the digest recorded as its checksum
+func pinnedReference(resolution *types.Resolution) (oci.Reference, error) {
+	ref, err := oci.ParseReference(resolution.DownloadURL)
+	if err != nil {
+		return oci.Reference{}, err
+	}
+	if ref.Digest == "" {
+		return oci.Reference{}, fmt.Errorf("%s is not pinned to a digest", resolution.DownloadURL)
+	}
+	if resolution.Checksum != "" && resolution.Checksum != ref.Digest {
+		return oci.Reference{}, fmt.Errorf("%s does not match the checksum %s", resolution.DownloadURL, resolution.Checksum)
+	}
+	return ref, nil
+}
+
+// installFile installs the binary written by write to the bin directory. It is written next to
+// the target and renamed, so a failed pull leaves the installed binary alone.
+func installFile(resolution *types.Resolution, opts types.InstallOptions, write func(io.Writer) error) error {
+	if opts.BinDir == "" {
+		return fmt.Errorf("bin_dir is required to install %s", resolution.Package.Name)
+	}
+	if err := os.MkdirAll(opts.BinDir, 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(opts.BinDir, "."+resolution.Package.Name+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	target := filepath.Join(opts.BinDir, resolution.Package.Name)
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to install %s: %w", target, err)
+	}
+	return nil
+}
+
+// imageReference returns the reference to tag in the image of extra.image. An image templated
+// with {{.version}} or {{.tag}} keeps the tag it templates to, e.g. "{{.version}}-alpine".
+func imageReference(pkg types.Package, tag string) (oci.Reference, error) {
+	image, _ := pkg.Extra["image"].(string)
+	if image == "" {
+		return oci.Reference{}, fmt.Errorf("package %s has no extra.image", pkg.Name)
+	}
+	templated := strings.Contains(image, "{{")
+	if templated {
+		result, err := depstemplate.TemplateString(image, map[string]string{
+			"name": pkg.Name, "version": version.Normalize(tag), "tag": tag,
+		})
+		if err != nil {
+			return oci.Reference{}, fmt.Errorf("failed to template image of %s: %w", pkg.Name, err)
+		}
+		image = result
+	}
+
+	ref, err := oci.ParseReference(image)
+	if err != nil {
+		return oci.Reference{}, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	if tag != "" && (ref.Tag == "" || !templated) {
+		ref = ref.WithTag(tag)
+	}
+	return ref, nil
+}
+
+// binaryPath returns the path of the binary in the image: binary_path, evaluated as a CEL
+// expression or template, or else the binary name, which matches the first file with that name
+func binaryPath(pkg types.Package, resolvedVersion, tag string, plat platform.Platform) string {
+	if pkg.BinaryPath != "" {
+		result, err := depstemplate.EvaluateCELOrTemplate(pkg.BinaryPath, map[string]interface{}{
+			"os": plat.OS, "arch": plat.Arch, "name": pkg.Name,
+			"version": resolvedVersion, "tag": tag,
+		})
+		if err == nil && result != "" {
+			return result
+		}
+		return pkg.BinaryPath
+	}
+	name := pkg.Name
+	if pkg.BinaryName != "" {
+		name = pkg.BinaryName
+	}
+	return plat.AddExtension(name)
+}
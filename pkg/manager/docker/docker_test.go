@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/oci/ocitest"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// pushTool pushes an index of org/tool:tag for linux/amd64 and linux/arm64 with the binary at
+// /usr/local/bin/tool
+func pushTool(t *testing.T, registry *ocitest.Registry, tag string) oci.Descriptor {
+	var manifests []oci.Descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		desc := registry.PushImage(t, "org/tool", "",
+			ocitest.Layer(t, ocitest.File{Name: "etc/os-release", Content: "test"}),
+			ocitest.Layer(t, ocitest.File{Name: "usr/local/bin/tool", Content: "tool " + tag + " " + arch}),
+		)
+		desc.Platform = &oci.Platform{OS: "linux", Architecture: arch}
+		manifests = append(manifests, desc)
+	}
+	return registry.PushManifest(t, "org/tool", tag, oci.Manifest{MediaType: oci.MediaTypeImageIndex, Manifests: manifests})
+}
+
+func TestDiscoverVersions(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "latest", "sha256-0123.sig"} {
+		pushTool(t, registry, tag)
+	}
+	pkg := types.Package{Name: "tool", Manager: "docker", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+
+	versions, err := NewDockerManager().DiscoverVersions(context.Background(), pkg, platform.Platform{OS: "linux", Arch: "amd64"}, 0)
+	if err != nil {
+		t.Fatalf("DiscoverVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Tag != "v1.1.0" || versions[1].Tag != "v1.0.0" {
+		t.Errorf("expected the two version tags newest first, got %+v", versions)
+	}
+}
+
+func TestResolveAndInstall(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	pushTool(t, registry, "v1.0.0")
+	pkg := types.Package{Name: "tool", Manager: "docker", Extra: map[string]interface{}{"image": registry.Host + "/org/tool:latest"}}
+	m := NewDockerManager()
+	ctx := context.Background()
+	plat := platform.Platform{OS: "linux", Arch: "arm64"}
+
+	resolution, err := m.Resolve(ctx, pkg, "1.0.0", plat)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	ref, err := oci.ParseReference(resolution.DownloadURL)
+	if err != nil || !oci.IsImageURL(resolution.DownloadURL) {
+		t.Fatalf("expected a docker:// download URL, got %q: %v", resolution.DownloadURL, err)
+	}
+	if ref.Digest == "" || resolution.Checksum != ref.Digest || resolution.Version != "1.0.0" || resolution.BinaryPath != "tool" {
+		t.Errorf("expected the resolution to be pinned to the arm64 manifest digest, got %+v", resolution)
+	}
+
+	binDir := t.TempDir()
+	if err := m.Install(ctx, resolution, types.InstallOptions{BinDir: binDir}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(binDir, "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "tool v1.0.0 arm64" {
+		t.Errorf("installed the wrong binary: %q", content)
+	}
+
+	// An explicit binary_path that is not in the image
+	resolution.BinaryPath = "/usr/bin/tool"
+	if err := m.Install(ctx, resolution, types.InstallOptions{BinDir: binDir}); !errors.Is(err, oci.ErrFileNotFound) {
+		t.Errorf("expected a missing binary_path to be reported, got %v", err)
+	}
+
+	var platformErr *manager.ErrPlatformNotSupported
+	if _, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "darwin", Arch: "arm64"}); !errors.As(err, &platformErr) {
+		t.Errorf("expected platforms missing from the index to be unsupported, got %v", err)
+	}
+
+	var notFound *manager.ErrVersionNotFound
+	if _, err := m.Resolve(ctx, pkg, "2.0.0", plat); !errors.As(err, &notFound) {
+		t.Errorf("expected a missing tag to be reported, got %v", err)
+	}
+
+	checksums, err := m.GetChecksums(ctx, pkg, "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetChecksums: %v", err)
+	}
+	if len(checksums) != 2 || checksums["linux-arm64"] != resolution.Checksum {
+		t.Errorf("unexpected checksums %v", checksums)
+	}
+}
+
+func TestSinglePlatformChecksums(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	desc := registry.PushImage(t, "org/tool", "v1.0.0", ocitest.Layer(t, ocitest.File{Name: "tool", Content: "amd64"}))
+	pkg := types.Package{Name: "tool", Manager: "docker", Extra: map[string]interface{}{"image": registry.Host + "/org/tool"}}
+
+	checksums, err := NewDockerManager().GetChecksums(context.Background(), pkg, "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetChecksums: %v", err)
+	}
+	if len(checksums) != 1 || checksums["linux-amd64"] != desc.Digest {
+		t.Errorf("expected the image to be keyed by the platform of its config, got %v", checksums)
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	pkg := types.Package{Name: "tool", Extra: map[string]interface{}{"image": "ghcr.io/org/tool:{{.version}}-alpine"}}
+	ref, err := imageReference(pkg, "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.String() != "ghcr.io/org/tool:1.2.0-alpine" {
+		t.Errorf("expected the templated tag, got %s", ref)
+	}
+
+	if _, err := imageReference(types.Package{Name: "tool"}, "v1.2.0"); err == nil {
+		t.Error("expected packages without extra.image to fail")
+	}
+}
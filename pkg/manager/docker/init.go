@@ -0,0 +1,8 @@
+package docker
+
+import "github.com/flanksource/deps/pkg/manager"
+
+func init() {
+	manager.Register(NewDockerManager())
+	manager.Register(NewArtifactManager())
+}
@@ -0,0 +1,168 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// DefaultBaseURL is the instance used by packages without a base_url. Codeberg hosts most of the
+// public projects on Forgejo, which keeps the Gitea API.
+const DefaultBaseURL = "https://codeberg.org"
+
+// errNotFound is returned for API requests answered with 404
+var errNotFound = errors.New("not found")
+
+// RateLimitError is returned when an instance refuses API requests until its rate limit resets
+type RateLimitError struct {
+	BaseURL       string
+	Reset         *time.Time
+	Authenticated bool
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("%s API rate limit exceeded", e.BaseURL)
+	if e.Reset != nil {
+		msg += fmt.Sprintf(", resets in %s", time.Until(*e.Reset).Round(time.Second))
+	}
+	switch {
+	case e.Authenticated:
+	case e.BaseURL == DefaultBaseURL:
+		msg += "; set CODEBERG_TOKEN, GITEA_TOKEN or FORGEJO_TOKEN for a higher limit"
+	default:
+		msg += "; add a token for the host to settings.credentials for a higher limit"
+	}
+	return msg
+}
+
+// detectGiteaToken checks the environment variables holding a token for DefaultBaseURL
+func detectGiteaToken() (token, source string) {
+	for _, envVar := range []string{"CODEBERG_TOKEN", "GITEA_TOKEN", "FORGEJO_TOKEN"} {
+		if token := os.Getenv(envVar); token != "" {
+			return token, envVar
+		}
+	}
+	return "", ""
+}
+
+// instance is the Gitea instance used by packages without a base_url. There is no setting for it:
+// packages on other instances name theirs in base_url.
+var instance = &manager.Instance{
+	Name:           "Gitea",
+	DefaultBaseURL: DefaultBaseURL,
+	APIPaths:       []string{"/api/v1"},
+}
+
+// BaseURL returns the web URL of the instance hosting pkg: its base_url, else https://codeberg.org
+func BaseURL(pkg types.Package) string {
+	return instance.BaseURL(pkg)
+}
+
+// WithBaseURL returns a context for the instance at baseURL, used by WhoAmI
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return instance.WithBaseURL(ctx, baseURL)
+}
+
+// repoPath returns the API path of a repository given as owner/name
+func repoPath(repo string) (string, error) {
+	parts := strings.Split(strings.Trim(repo, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid repository %q, expected owner/name", repo)
+	}
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(parts[0]), url.PathEscape(parts[1])), nil
+}
+
+// tokenFor returns the token sent to the instance at baseURL. The token from the environment is
+// only sent to DefaultBaseURL; other instances are authenticated by settings.credentials.
+func (m *GiteaReleaseManager) tokenFor(baseURL string) (string, string) {
+	if baseURL == DefaultBaseURL {
+		return m.token, m.tokenSource
+	}
+	return "", ""
+}
+
+// get decodes the response of an API request to the instance at baseURL into result
+func (m *GiteaReleaseManager) get(ctx context.Context, baseURL, endpoint string, result interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/v1"+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token, _ := m.tokenFor(baseURL)
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case isRateLimited(resp):
+		return resp, &RateLimitError{
+			BaseURL:       baseURL,
+			Reset:         extractRateLimit(resp.Header).ResetTime,
+			Authenticated: token != "",
+		}
+	case resp.StatusCode == http.StatusNotFound:
+		return resp, fmt.Errorf("%s%s: %w", baseURL, endpoint, errNotFound)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return resp, fmt.Errorf("%s rejected the token for %s: %s", baseURL, endpoint, resp.Status)
+	default:
+		return resp, fmt.Errorf("%s%s returned %s", baseURL, endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return resp, fmt.Errorf("failed to decode %s%s: %w", baseURL, endpoint, err)
+	}
+	return resp, nil
+}
+
+// isRateLimited reports whether resp refused a request because the rate limit was reached.
+// Gitea and Forgejo answer 429; proxies in front of them sometimes answer 403 with no requests left.
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+// extractRateLimit reads the X-RateLimit-* and Retry-After headers. Remaining and Total are -1
+// when the instance does not report them.
+func extractRateLimit(header http.Header) *types.RateLimit {
+	rateLimit := &types.RateLimit{Remaining: -1, Total: -1}
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil {
+		rateLimit.Remaining = remaining
+	}
+	if limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit")); err == nil {
+		rateLimit.Total = limit
+	}
+
+	var reset time.Time
+	if value, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// Either a Unix timestamp or the seconds left
+		if value > 1e9 {
+			reset = time.Unix(value, 0)
+		} else {
+			reset = time.Now().Add(time.Duration(value) * time.Second)
+		}
+	} else if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	if !reset.IsZero() {
+		rateLimit.ResetTime = &reset
+	}
+	return rateLimit
+}
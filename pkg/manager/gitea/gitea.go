@@ -0,0 +1,444 @@
+package gitea
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/checksum"
+	"github.com/flanksource/deps/pkg/extract"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/platform"
+	depstemplate "github.com/flanksource/deps/pkg/template"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/version"
+)
+
+const (
+	// releasesPageSize is the number of releases requested per page, the default maximum of Gitea
+	releasesPageSize = 50
+	// maxReleasePages caps the releases listed for version discovery
+	maxReleasePages = 4
+	// maxChecksumFile is the largest checksum file read
+	maxChecksumFile = 1 << 20
+)
+
+// checksumFiles are the names of the checksum assets looked for when checksum_file is not set
+var checksumFiles = []string{"SHA256SUMS", "SHA256SUMS.txt", "sha256sums.txt", "checksums.txt", "*_checksums.txt", "*_SHA256SUMS"}
+
+// GiteaReleaseManager implements the PackageManager interface for releases on Gitea and Forgejo
+// instances such as Codeberg
+type GiteaReleaseManager struct {
+	client      *http.Client
+	token       string
+	tokenSource string
+
+	checksumsMu sync.Mutex
+	checksums   map[string]map[string]string // Parsed checksum files by URL
+}
+
+// Release is a release returned by the Gitea API
+type Release struct {
+	ID          int64     `json:"id"`
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	CreatedAt   time.Time `json:"created_at"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// NewGiteaReleaseManager creates a new Gitea release manager
+func NewGiteaReleaseManager(token, tokenSource string) *GiteaReleaseManager {
+	return &GiteaReleaseManager{
+		client:      depshttp.GetHttpClient(depshttp.WithResponseCache()),
+		token:       token,
+		tokenSource: tokenSource,
+		checksums:   make(map[string]map[string]string),
+	}
+}
+
+// Name returns the manager identifier
+func (m *GiteaReleaseManager) Name() string {
+	return "gitea_release"
+}
+
+// DiscoverVersions returns the versions of the published releases, newest first
+func (m *GiteaReleaseManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	path, err := repoPath(pkg.Repo)
+	if err != nil {
+		return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	baseURL := BaseURL(pkg)
+
+	var versions []types.Version
+	for page := 1; page <= maxReleasePages; page++ {
+		var releases []Release
+		endpoint := fmt.Sprintf("%s/releases?draft=false&page=%d&limit=%d", path, page, releasesPageSize)
+		if _, err := m.get(ctx, baseURL, endpoint, &releases); err != nil {
+			return nil, fmt.Errorf("failed to list releases of %s: %w", pkg.Repo, err)
+		}
+		for _, release := range releases {
+			if release.Draft || release.TagName == "" {
+				continue
+			}
+			v := types.ParseVersion(version.Normalize(release.TagName), release.TagName)
+			v.Prerelease = v.Prerelease || release.Prerelease
+			v.Published = release.PublishedAt
+			versions = append(versions, v)
+		}
+		if len(releases) < releasesPageSize || (limit > 0 && len(versions) >= limit && pkg.VersionExpr == "") {
+			break
+		}
+	}
+	return version.FinalizeDiscoveredVersions(versions, pkg, limit)
+}
+
+// Resolve finds the release asset for the platform and its checksum from a checksum file
+// attached to the same release
+func (m *GiteaReleaseManager) Resolve(ctx context.Context, pkg types.Package, versionStr string, plat platform.Platform) (*types.Resolution, error) {
+	release, err := m.findRelease(ctx, pkg, versionStr)
+	if err != nil {
+		return nil, err
+	}
+	resolvedVersion := version.Normalize(release.TagName)
+
+	asset, err := selectAsset(pkg, release, resolvedVersion, plat)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := &types.Resolution{
+		Package:     pkg,
+		Version:     resolvedVersion,
+		Platform:    plat,
+		DownloadURL: asset.BrowserDownloadURL,
+		Size:        asset.Size,
+		IsArchive:   extract.IsArchive(asset.Name),
+	}
+	if pkg.Extract != nil {
+		resolution.IsArchive = *pkg.Extract
+	}
+	if resolution.IsArchive {
+		resolution.BinaryPath = binaryPath(pkg, asset.Name, resolvedVersion, release.TagName, plat)
+	}
+
+	if checksumAsset := findChecksumAsset(pkg, release, resolvedVersion, plat); checksumAsset != nil {
+		checksums, err := m.checksumFile(ctx, checksumAsset.BrowserDownloadURL)
+		if err != nil {
+			logger.Warnf("Failed to read %s of %s %s: %v", checksumAsset.Name, pkg.Name, release.TagName, err)
+		} else if value, ok := checksums[asset.Name]; ok {
+			resolution.Checksum = value
+		}
+		if resolution.Checksum == "" {
+			// Leave it to checksum discovery at download time
+			resolution.ChecksumURL = checksumAsset.BrowserDownloadURL
+		}
+	}
+
+	logger.Debugf("Resolved %s", resolution.Pretty().ANSI())
+	return resolution, nil
+}
+
+// Install downloads and installs a binary for the given resolution
+func (m *GiteaReleaseManager) Install(ctx context.Context, resolution *types.Resolution, opts types.InstallOptions) error {
+	return fmt.Errorf("install not implemented for Gitea manager")
+}
+
+// GetChecksums returns the checksums of the release assets from the checksum files attached to
+// the release, keyed by asset name
+func (m *GiteaReleaseManager) GetChecksums(ctx context.Context, pkg types.Package, versionStr string) (map[string]string, error) {
+	release, err := m.findRelease(ctx, pkg, versionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	for _, asset := range release.Assets {
+		if !isChecksumFile(pkg, asset.Name, version.Normalize(release.TagName)) {
+			continue
+		}
+		parsed, err := m.checksumFile(ctx, asset.BrowserDownloadURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", asset.Name, err)
+		}
+		for name, value := range parsed {
+			checksums[name] = value
+		}
+	}
+	return checksums, nil
+}
+
+// Verify checks if an installed binary matches the expected version/checksum
+func (m *GiteaReleaseManager) Verify(ctx context.Context, binaryPath string, pkg types.Package) (*types.InstalledInfo, error) {
+	return nil, fmt.Errorf("verify not implemented for Gitea manager")
+}
+
+// WhoAmI returns the authentication status for the instance of ctx, from WithBaseURL
+func (m *GiteaReleaseManager) WhoAmI(ctx context.Context) *types.AuthStatus {
+	baseURL := instance.BaseURLFromContext(ctx)
+	_, source := m.tokenFor(baseURL)
+	status := &types.AuthStatus{
+		Service:     fmt.Sprintf("Gitea (%s)", strings.TrimPrefix(baseURL, "https://")),
+		TokenSource: source,
+	}
+
+	var user struct {
+		Login    string     `json:"login"`
+		FullName string     `json:"full_name"`
+		Email    string     `json:"email"`
+		Created  *time.Time `json:"created"`
+	}
+	resp, err := m.get(ctx, baseURL, "/user", &user)
+	if resp != nil {
+		if rateLimit := extractRateLimit(resp.Header); rateLimit.Remaining >= 0 {
+			status.RateLimit = rateLimit
+		}
+	}
+	if err != nil {
+		status.Error = fmt.Sprintf("Failed to get user info: %v", err)
+		return status
+	}
+
+	status.Authenticated = true
+	status.HasPermissions = true
+	if status.TokenSource == "" {
+		// Authenticated by an entry in settings.credentials for the host
+		status.TokenSource = "settings.credentials"
+	}
+	status.User = &types.UserInfo{
+		Username:  user.Login,
+		Name:      user.FullName,
+		Email:     user.Email,
+		CreatedAt: user.Created,
+	}
+	return status
+}
+
+// findRelease returns the published release tagged versionStr, with or without a v prefix
+func (m *GiteaReleaseManager) findRelease(ctx context.Context, pkg types.Package, versionStr string) (*Release, error) {
+	path, err := repoPath(pkg.Repo)
+	if err != nil {
+		return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	baseURL := BaseURL(pkg)
+
+	tags := []string{versionStr}
+	if strings.HasPrefix(versionStr, "v") {
+		tags = append(tags, strings.TrimPrefix(versionStr, "v"))
+	} else {
+		tags = append(tags, "v"+versionStr)
+	}
+	for _, tag := range tags {
+		var release Release
+		_, err := m.get(ctx, baseURL, fmt.Sprintf("%s/releases/tags/%s", path, url.PathEscape(tag)), &release)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get release %s of %s: %w", tag, pkg.Repo, err)
+		}
+		if release.Draft {
+			continue
+		}
+		return &release, nil
+	}
+	return nil, &manager.ErrVersionNotFound{Package: pkg.Name, Version: versionStr}
+}
+
+// selectAsset picks the asset for plat: the one named by asset_patterns, or else the only one
+// left after filtering by OS and architecture
+func selectAsset(pkg types.Package, release *Release, resolvedVersion string, plat platform.Platform) (*Asset, error) {
+	assetPattern, err := manager.ResolveAssetPattern(pkg.AssetPatterns, plat, pkg.Name)
+	if err != nil {
+		var platformErr *manager.ErrPlatformNotSupported
+		if errors.As(err, &platformErr) && len(pkg.AssetPatterns) > 0 {
+			return nil, err
+		}
+	}
+	if assetPattern == "" {
+		assetPattern = "{{.name}}-{{.os}}-{{.arch}}"
+	}
+	pattern, err := depstemplate.TemplateString(assetPattern, map[string]string{
+		"name": pkg.Name, "version": resolvedVersion, "tag": release.TagName,
+		"os": plat.OS, "arch": plat.Arch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to template asset pattern: %w", err)
+	}
+
+	for i, asset := range release.Assets {
+		if asset.Name == pattern {
+			return &release.Assets[i], nil
+		}
+		if strings.ContainsAny(pattern, "*?") {
+			if ok, _ := filepath.Match(pattern, asset.Name); ok {
+				return &release.Assets[i], nil
+			}
+		}
+	}
+
+	assets := make([]manager.AssetInfo, len(release.Assets))
+	names := make([]string, len(release.Assets))
+	for i, asset := range release.Assets {
+		assets[i] = manager.AssetInfo{Name: asset.Name, DownloadURL: asset.BrowserDownloadURL}
+		names[i] = asset.Name
+	}
+	if filtered, err := manager.FilterAssetsByPlatform(assets, plat.OS, plat.Arch); err == nil && len(filtered) == 1 {
+		for i, asset := range release.Assets {
+			if asset.Name == filtered[0].Name {
+				return &release.Assets[i], nil
+			}
+		}
+	}
+
+	return nil, manager.EnhanceAssetNotFoundError(pkg.Name, pattern, plat.String(), names,
+		&manager.ErrAssetNotFound{
+			Package:         fmt.Sprintf("%s@%s", pkg.Name, release.TagName),
+			AssetPattern:    pattern,
+			Platform:        plat.String(),
+			AvailableAssets: names,
+		})
+}
+
+// checksumFilePatterns returns the checksum file names to look for on plat: the entries of
+// checksum_file, which may be limited to platforms like "windows*: SHA256SUMS-windows", or
+// else the usual names of checksum files
+func checksumFilePatterns(pkg types.Package, resolvedVersion string, plat platform.Platform) []string {
+	if pkg.ChecksumFile == "" {
+		return checksumFiles
+	}
+	var patterns []string
+	for _, entry := range manager.FilterEntriesByPlatform(strings.Split(pkg.ChecksumFile, ","), plat) {
+		name, err := depstemplate.TemplateString(strings.TrimSpace(entry), map[string]string{
+			"name": pkg.Name, "version": resolvedVersion, "os": plat.OS, "arch": plat.Arch,
+		})
+		if err != nil {
+			logger.Warnf("Failed to template checksum_file %q of %s: %v", entry, pkg.Name, err)
+			continue
+		}
+		patterns = append(patterns, name)
+	}
+	return patterns
+}
+
+// findChecksumAsset returns the checksum file attached to the release, if any
+func findChecksumAsset(pkg types.Package, release *Release, resolvedVersion string, plat platform.Platform) *Asset {
+	for _, pattern := range checksumFilePatterns(pkg, resolvedVersion, plat) {
+		for i, asset := range release.Assets {
+			if ok, _ := filepath.Match(pattern, asset.Name); ok {
+				return &release.Assets[i]
+			}
+		}
+	}
+	return nil
+}
+
+// isChecksumFile reports whether name is a checksum file on any platform
+func isChecksumFile(pkg types.Package, name, resolvedVersion string) bool {
+	patterns := checksumFiles
+	if pkg.ChecksumFile != "" {
+		patterns = nil
+		for _, entry := range strings.Split(pkg.ChecksumFile, ",") {
+			_, value, _, _ := manager.ParsePlatformEntry(entry)
+			if pattern, err := depstemplate.TemplateString(value, map[string]string{"name": pkg.Name, "version": resolvedVersion}); err == nil {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checksumFile downloads and parses a checksum file in the "<hash>  <file>" format of sha256sum,
+// returning "sha256:<hash>" style checksums by file name. Files are read once per process.
+func (m *GiteaReleaseManager) checksumFile(ctx context.Context, fileURL string) (map[string]string, error) {
+	m.checksumsMu.Lock()
+	cached, ok := m.checksums[fileURL]
+	m.checksumsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", fileURL, resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFile))
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		value, hashType := checksum.ParseChecksum(fields[0])
+		if _, err := hex.DecodeString(value); err != nil || len(value) < 32 {
+			continue
+		}
+		// "*" marks files hashed in binary mode, and some tools list paths relative to a dist directory
+		name := filepath.Base(strings.TrimPrefix(strings.Join(fields[1:], " "), "*"))
+		checksums[name] = checksum.FormatChecksum(value, hashType)
+	}
+
+	m.checksumsMu.Lock()
+	m.checksums[fileURL] = checksums
+	m.checksumsMu.Unlock()
+	return checksums, nil
+}
+
+// binaryPath returns the path of the binary inside an archive: binary_path, evaluated as CEL or
+// a template, else binary_name, else the package name
+func binaryPath(pkg types.Package, assetName, resolvedVersion, tag string, plat platform.Platform) string {
+	if pkg.BinaryPath != "" {
+		result, err := depstemplate.EvaluateCELOrTemplate(pkg.BinaryPath, map[string]interface{}{
+			"os": plat.OS, "arch": plat.Arch, "name": pkg.Name,
+			"version": resolvedVersion, "tag": tag, "asset": assetName,
+		})
+		if err == nil && result != "" {
+			return result
+		}
+		return pkg.BinaryPath
+	}
+	if pkg.BinaryName != "" {
+		return pkg.BinaryName
+	}
+	if plat.IsWindows() {
+		return pkg.Name + ".exe"
+	}
+	return pkg.Name
+}
@@ -0,0 +1,165 @@
+package gitea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+const sha256Linux = "1111111111111111111111111111111111111111111111111111111111111111"
+
+func newGiteaServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release := func(tag string, draft, prerelease bool) string {
+			return fmt.Sprintf(`{"tag_name": %q, "draft": %t, "prerelease": %t, "published_at": "2024-01-01T00:00:00Z", "assets": [
+				{"name": "tool_%[4]s_linux_amd64.tar.gz", "size": 42, "browser_download_url": "%[5]s/dl/tool_linux_amd64.tar.gz"},
+				{"name": "tool_%[4]s_darwin_arm64.tar.gz", "size": 43, "browser_download_url": "%[5]s/dl/tool_darwin_arm64.tar.gz"},
+				{"name": "SHA256SUMS", "size": 100, "browser_download_url": "%[5]s/dl/SHA256SUMS"}
+			]}`, tag, draft, prerelease, strings.TrimPrefix(tag, "v"), server.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/tool/releases":
+			_, _ = fmt.Fprintf(w, "[%s, %s, %s]", release("v1.1.0-rc.1", false, true), release("v1.0.0", false, false), release("v0.9.0", true, false))
+		case "/api/v1/repos/owner/tool/releases/tags/v1.0.0":
+			_, _ = w.Write([]byte(release("v1.0.0", false, false)))
+		case "/dl/SHA256SUMS":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprintf(w, "%s  tool_1.0.0_linux_amd64.tar.gz\n%s *dist/tool_1.0.0_darwin_arm64.tar.gz\n", sha256Linux, strings.Repeat("2", 64))
+		case "/api/v1/user":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestManager(server *httptest.Server) *GiteaReleaseManager {
+	m := NewGiteaReleaseManager("secret", "CODEBERG_TOKEN")
+	m.client = server.Client()
+	return m
+}
+
+func TestDiscoverVersions(t *testing.T) {
+	server := newGiteaServer(t)
+	m := newTestManager(server)
+	pkg := types.Package{Name: "tool", Manager: "gitea_release", Repo: "owner/tool", BaseURL: server.URL}
+
+	versions, err := m.DiscoverVersions(context.Background(), pkg, platform.Platform{OS: "linux", Arch: "amd64"}, 0)
+	if err != nil {
+		t.Fatalf("DiscoverVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected the two published releases, got %+v", versions)
+	}
+	if versions[0].Tag != "v1.1.0-rc.1" || !versions[0].Prerelease || versions[1].Tag != "v1.0.0" {
+		t.Errorf("unexpected versions %+v", versions)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	server := newGiteaServer(t)
+	m := newTestManager(server)
+	pkg := types.Package{Name: "tool", Manager: "gitea_release", Repo: "owner/tool", BaseURL: server.URL}
+	ctx := context.Background()
+
+	// Without asset_patterns the asset is picked by OS and architecture
+	resolution, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if resolution.DownloadURL != server.URL+"/dl/tool_linux_amd64.tar.gz" || !resolution.IsArchive || resolution.Size != 42 {
+		t.Errorf("unexpected resolution %+v", resolution)
+	}
+	if resolution.Checksum != "sha256:"+sha256Linux {
+		t.Errorf("expected the checksum from SHA256SUMS, got %q", resolution.Checksum)
+	}
+
+	pkg.AssetPatterns = map[string]string{"darwin-*": "tool_{{.version}}_{{.os}}_{{.arch}}.tar.gz"}
+	resolution, err = m.Resolve(ctx, pkg, "v1.0.0", platform.Platform{OS: "darwin", Arch: "arm64"})
+	if err != nil {
+		t.Fatalf("Resolve with asset_patterns: %v", err)
+	}
+	if resolution.Checksum != "sha256:"+strings.Repeat("2", 64) {
+		t.Errorf("expected the checksum of the dist/ entry, got %q", resolution.Checksum)
+	}
+
+	var platformErr *manager.ErrPlatformNotSupported
+	if _, err := m.Resolve(ctx, pkg, "1.0.0", platform.Platform{OS: "windows", Arch: "amd64"}); !errors.As(err, &platformErr) {
+		t.Errorf("expected platforms missing from asset_patterns to be unsupported, got %v", err)
+	}
+
+	var notFound *manager.ErrVersionNotFound
+	if _, err := m.Resolve(ctx, pkg, "2.0.0", platform.Platform{OS: "darwin", Arch: "arm64"}); !errors.As(err, &notFound) {
+		t.Errorf("expected a missing release to be reported, got %v", err)
+	}
+
+	checksums, err := m.GetChecksums(ctx, pkg, "1.0.0")
+	if err != nil {
+		t.Fatalf("GetChecksums: %v", err)
+	}
+	if len(checksums) != 2 || checksums["tool_1.0.0_linux_amd64.tar.gz"] != "sha256:"+sha256Linux {
+		t.Errorf("unexpected checksums %v", checksums)
+	}
+}
+
+func TestChecksumFileEntries(t *testing.T) {
+	pkg := types.Package{Name: "tool", ChecksumFile: "windows*: tool_{{.version}}_windows.sha256, !windows*: tool_{{.version}}_SHA256SUMS"}
+	release := &Release{Assets: []Asset{{Name: "tool_1.0.0_windows.sha256"}, {Name: "tool_1.0.0_SHA256SUMS"}}}
+
+	if asset := findChecksumAsset(pkg, release, "1.0.0", platform.Platform{OS: "windows", Arch: "amd64"}); asset == nil || asset.Name != "tool_1.0.0_windows.sha256" {
+		t.Errorf("expected the windows checksum file, got %+v", asset)
+	}
+	if asset := findChecksumAsset(pkg, release, "1.0.0", platform.Platform{OS: "linux", Arch: "amd64"}); asset == nil || asset.Name != "tool_1.0.0_SHA256SUMS" {
+		t.Errorf("expected the SHA256SUMS file, got %+v", asset)
+	}
+	if !isChecksumFile(pkg, "tool_1.0.0_windows.sha256", "1.0.0") || isChecksumFile(pkg, "tool.tar.gz", "1.0.0") {
+		t.Errorf("unexpected checksum file detection")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := newGiteaServer(t)
+	m := newTestManager(server)
+
+	status := m.WhoAmI(WithBaseURL(context.Background(), server.URL))
+	if status.Authenticated || !strings.Contains(status.Error, "rate limit exceeded") {
+		t.Errorf("expected a rate limit error, got %+v", status)
+	}
+	if !strings.Contains(status.Error, "settings.credentials") {
+		t.Errorf("expected a hint for instances other than Codeberg, got %q", status.Error)
+	}
+	if status.RateLimit == nil || status.RateLimit.Remaining != 0 {
+		t.Errorf("expected the remaining requests to be reported, got %+v", status.RateLimit)
+	}
+
+	err := &RateLimitError{BaseURL: DefaultBaseURL}
+	if !strings.Contains(err.Error(), "CODEBERG_TOKEN") {
+		t.Errorf("expected a hint to set a token, got %q", err)
+	}
+}
+
+func TestTokenIsOnlySentToCodeberg(t *testing.T) {
+	m := NewGiteaReleaseManager("secret", "CODEBERG_TOKEN")
+	if token, source := m.tokenFor(DefaultBaseURL); token != "secret" || source != "CODEBERG_TOKEN" {
+		t.Errorf("expected the token for Codeberg, got %q from %q", token, source)
+	}
+	if token, _ := m.tokenFor("https://gitea.example.com"); token != "" {
+		t.Errorf("expected no token for other instances, got %q", token)
+	}
+	if got := BaseURL(types.Package{BaseURL: "gitea.example.com/api/v1/"}); got != "https://gitea.example.com" {
+		t.Errorf("unexpected base URL %s", got)
+	}
+}
@@ -0,0 +1,8 @@
+package gitea
+
+import "github.com/flanksource/deps/pkg/manager"
+
+func init() {
+	token, tokenSource := detectGiteaToken()
+	manager.Register(NewGiteaReleaseManager(token, tokenSource))
+}
@@ -1,17 +1,17 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
-	"time"
 
+	"github.com/flanksource/commons/logger"
 	depshttp "github.com/flanksource/deps/pkg/http"
 	"github.com/google/go-github/v57/github"
 	"golang.org/x/oauth2"
@@ -19,6 +19,7 @@ import (
 
 // GitHubClient is a singleton wrapper for GitHub API clients
 type GitHubClient struct {
+	baseURL     string // Web URL of the GitHub instance, empty for github.com
 	client      *github.Client
 	httpClient  *http.Client
 	token       string
@@ -34,14 +35,13 @@ var (
 // GetClient returns the singleton GitHubClient instance
 func GetClient() *GitHubClient {
 	clientOnce.Do(func() {
-		clientInstance = newClient("${GITHUB_TOKEN}", "${GH_TOKEN}", "${GITHUB_ACCESS_TOKEN}")
+		clientInstance = newClient(DefaultBaseURL, "${GITHUB_TOKEN}", "${GH_TOKEN}", "${GITHUB_ACCESS_TOKEN}")
 	})
 	return clientInstance
 }
 
-// newClient creates a GitHub client with token resolution
-func newClient(tokenSources ...string) *GitHubClient {
-	var client *github.Client
+// newClient creates a client for the GitHub instance at baseURL with token resolution
+func newClient(baseURL string, tokenSources ...string) *GitHubClient {
 	var httpClient *http.Client
 	var token string
 	var tokenSource string
@@ -57,10 +57,10 @@ func newClient(tokenSources ...string) *GitHubClient {
 	}
 
 	httpClient = newGitHubHTTPClient(token)
-	client = github.NewClient(httpClient)
 
 	return &GitHubClient{
-		client:      client,
+		baseURL:     baseURL,
+		client:      newGitHubAPIClient(baseURL, httpClient),
 		httpClient:  httpClient,
 		token:       token,
 		tokenSource: tokenSource,
@@ -74,7 +74,7 @@ func (c *GitHubClient) SetToken(token string) {
 
 	if token != "" {
 		httpClient := newGitHubHTTPClient(token)
-		c.client = github.NewClient(httpClient)
+		c.client = newGitHubAPIClient(c.baseURL, httpClient)
 		c.httpClient = httpClient
 		c.token = token
 		c.tokenSource = "CLI-provided"
@@ -82,7 +82,7 @@ func (c *GitHubClient) SetToken(token string) {
 }
 
 func newGitHubHTTPClient(token string) *http.Client {
-	httpClient := depshttp.GetHttpClient()
+	httpClient := depshttp.GetHttpClient(depshttp.WithResponseCache())
 	if token == "" {
 		return httpClient
 	}
@@ -94,6 +94,28 @@ func newGitHubHTTPClient(token string) *http.Client {
 	return httpClient
 }
 
+// newGitHubAPIClient creates a go-github client for the REST API of the instance at baseURL
+func newGitHubAPIClient(baseURL string, httpClient *http.Client) *github.Client {
+	client := github.NewClient(httpClient)
+	if !IsEnterprise(baseURL) {
+		return client
+	}
+	enterprise, err := client.WithEnterpriseURLs(apiURL(baseURL)+"/", baseURL+"/api/uploads/")
+	if err != nil {
+		logger.Warnf("Invalid GitHub Enterprise URL %s: %v", baseURL, err)
+		return client
+	}
+	return enterprise
+}
+
+// BaseURL returns the web URL of the GitHub instance the client talks to
+func (c *GitHubClient) BaseURL() string {
+	if c.baseURL == "" {
+		return DefaultBaseURL
+	}
+	return c.baseURL
+}
+
 // Client returns the REST API client
 func (c *GitHubClient) Client() *github.Client {
 	c.mu.RLock()
@@ -115,53 +137,82 @@ func (c *GitHubClient) Token() string {
 	return c.token
 }
 
-// isRetryableError checks if an error is a retryable HTTP error
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := err.Error()
-	return strings.Contains(errStr, "504 Gateway Timeout") ||
-		strings.Contains(errStr, "502 Bad Gateway") ||
-		strings.Contains(errStr, "503 Service Unavailable")
+// RESTRequest makes a REST API request to GitHub, retried as the shared retry policy configures
+func (c *GitHubClient) RESTRequest(ctx context.Context, method, endpoint string, result interface{}) error {
+	return c.doRESTRequest(ctx, method, endpoint, result)
 }
 
-// RESTRequest makes a REST API request to GitHub with retry logic
-func (c *GitHubClient) RESTRequest(ctx context.Context, method, endpoint string, result interface{}) error {
-	return c.RESTRequestWithRetry(ctx, method, endpoint, result, 3)
+// RESTRequestWithRetry makes a REST API request with at most maxAttempts attempts, overriding
+// the shared retry policy
+func (c *GitHubClient) RESTRequestWithRetry(ctx context.Context, method, endpoint string, result interface{}, maxAttempts int) error {
+	return c.doRESTRequest(depshttp.WithMaxAttempts(ctx, maxAttempts), method, endpoint, result)
 }
 
-// RESTRequestWithRetry makes a REST API request with configurable retries
-func (c *GitHubClient) RESTRequestWithRetry(ctx context.Context, method, endpoint string, result interface{}, maxRetries int) error {
-	baseDelay := 500 * time.Millisecond
+// GraphQLRequest runs a GraphQL query against the instance of the client and decodes its data
+// into result. Errors about single fields, such as a repository that does not exist, leave
+// those fields null rather than failing the request.
+func (c *GitHubClient) GraphQLRequest(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to encode GraphQL query: %w", err)
+	}
 
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := c.doRESTRequest(ctx, method, endpoint, result)
-		if err == nil {
-			return nil
-		}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphqlURL(c.baseURL), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-		if !isRetryableError(err) {
-			return err
-		}
+	c.mu.RLock()
+	httpClient := c.httpClient
+	c.mu.RUnlock()
 
-		lastErr = err
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
 
-		// Don't sleep after the last attempt
-		if attempt < maxRetries-1 {
-			delay := baseDelay * time.Duration(1<<attempt)
-			jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-			time.Sleep(delay + jitter)
-		}
+	if resp.StatusCode == 401 {
+		return fmt.Errorf("GraphQL API requires a valid token")
+	}
+	if resp.StatusCode == 403 {
+		remaining := resp.Header.Get("X-RateLimit-Remaining")
+		return fmt.Errorf("GraphQL API forbidden (remaining=%s)", remaining)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("GraphQL API returned HTTP %d", resp.StatusCode)
 	}
 
-	return lastErr
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		if len(response.Data) == 0 || string(response.Data) == "null" {
+			return fmt.Errorf("GraphQL API error: %s", response.Errors[0].Message)
+		}
+		for _, e := range response.Errors {
+			logger.V(3).Infof("GraphQL %s: %s", e.Type, e.Message)
+		}
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Data, result); err != nil {
+			return fmt.Errorf("failed to decode GraphQL data: %w", err)
+		}
+	}
+	return nil
 }
 
 // doRESTRequest performs a single REST API request
 func (c *GitHubClient) doRESTRequest(ctx context.Context, method, endpoint string, result interface{}) error {
-	url := "https://api.github.com" + endpoint
+	url := apiURL(c.baseURL) + endpoint
 
 	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
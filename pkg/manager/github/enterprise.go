@@ -0,0 +1,140 @@
+package github
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// DefaultBaseURL is the web URL of github.com, used when neither a package nor
+// settings.github.base_url names a GitHub Enterprise Server
+const DefaultBaseURL = "https://github.com"
+
+// enterpriseTokenSources are checked for the token of a GitHub Enterprise Server when
+// settings.github.token is not set, matching the gh CLI
+var enterpriseTokenSources = []string{"${GH_ENTERPRISE_TOKEN}", "${GITHUB_ENTERPRISE_TOKEN}"}
+
+// instance is the GitHub instance used by packages without a base_url
+var instance = &manager.Instance{
+	Name:           "GitHub",
+	SelfHostedName: "GitHub Enterprise",
+	Setting:        "github",
+	TokenExample:   "GHE_TOKEN",
+	DefaultBaseURL: DefaultBaseURL,
+	PublicHosts:    []string{"www.github.com", "api.github.com"},
+	APIPaths:       []string{"/api/v3"},
+}
+
+var (
+	enterpriseMu      sync.Mutex
+	enterpriseClients = make(map[string]*GitHubClient)
+)
+
+// SetEnterprise sets the GitHub instance used by packages without a base_url and the token
+// used for it. The token must reference an environment variable, like settings.credentials.
+func SetEnterprise(settings types.GitHubSettings) error {
+	if err := instance.Set(settings.BaseURL, settings.Token); err != nil {
+		return err
+	}
+
+	enterpriseMu.Lock()
+	defer enterpriseMu.Unlock()
+	// Clients created with the previous token are rebuilt on next use
+	enterpriseClients = make(map[string]*GitHubClient)
+	return nil
+}
+
+// BaseURL returns the web URL of the GitHub instance hosting pkg: its base_url, else
+// settings.github.base_url, else https://github.com
+func BaseURL(pkg types.Package) string {
+	return instance.BaseURL(pkg)
+}
+
+// IsEnterprise reports whether baseURL is a GitHub Enterprise Server rather than github.com
+func IsEnterprise(baseURL string) bool {
+	return instance.IsSelfHosted(baseURL)
+}
+
+// WithBaseURL returns a context whose API, git and download requests go to the GitHub
+// instance at baseURL
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return instance.WithBaseURL(ctx, baseURL)
+}
+
+// withPackage returns a context for the requests about pkg
+func withPackage(ctx context.Context, pkg types.Package) context.Context {
+	return WithBaseURL(ctx, BaseURL(pkg))
+}
+
+// GetClientFor returns the client for the GitHub instance at baseURL. github.com uses the
+// GetClient singleton, enterprise instances get a client of their own with the token from
+// settings.github.token, GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN.
+func GetClientFor(baseURL string) *GitHubClient {
+	if !IsEnterprise(baseURL) {
+		return GetClient()
+	}
+
+	enterpriseMu.Lock()
+	defer enterpriseMu.Unlock()
+	if client, ok := enterpriseClients[baseURL]; ok {
+		return client
+	}
+	tokenSources := enterpriseTokenSources
+	if _, token := instance.Configured(); token != "" {
+		tokenSources = []string{token}
+	}
+	client := newClient(baseURL, tokenSources...)
+	enterpriseClients[baseURL] = client
+	return client
+}
+
+// clientFor returns the client for the GitHub instance of ctx
+func clientFor(ctx context.Context) *GitHubClient {
+	return GetClientFor(instance.BaseURLFromContext(ctx))
+}
+
+// EnterpriseCredentials returns creds with a token added for the host of settings.github.base_url
+// and of every GitHub package in registry with a base_url of its own, so release asset downloads
+// from a GitHub Enterprise Server are authenticated like its API calls. An entry already
+// configured for the host is kept.
+func EnterpriseCredentials(settings types.Settings, registry map[string]types.Package) map[string]types.Credential {
+	baseURLs := []string{settings.GitHub.BaseURL}
+	for _, pkg := range registry {
+		if pkg.BaseURL != "" && strings.HasPrefix(pkg.Manager, "github") {
+			baseURLs = append(baseURLs, pkg.BaseURL)
+		}
+	}
+	// Registry order is random; keep which token wins for a host stable
+	sort.Strings(baseURLs[1:])
+
+	return instance.Credentials(settings.Credentials, func(baseURL string) (types.Credential, bool) {
+		token := GetClientFor(baseURL).TokenSource()
+		if token == "" {
+			return types.Credential{}, false
+		}
+		if !strings.Contains(token, "$") {
+			token = "${" + token + "}"
+		}
+		return types.Credential{Username: "x-access-token", Password: token}, true
+	}, baseURLs...)
+}
+
+// apiURL returns the REST API root of the GitHub instance at baseURL
+func apiURL(baseURL string) string {
+	if !IsEnterprise(baseURL) {
+		return "https://api.github.com"
+	}
+	return baseURL + "/api/v3"
+}
+
+// graphqlURL returns the GraphQL endpoint of the GitHub instance at baseURL
+func graphqlURL(baseURL string) string {
+	if !IsEnterprise(baseURL) {
+		return "https://api.github.com/graphql"
+	}
+	return baseURL + "/api/graphql"
+}
@@ -0,0 +1,137 @@
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setEnterprise(t *testing.T, settings types.GitHubSettings) {
+	t.Helper()
+	if err := SetEnterprise(settings); err != nil {
+		t.Fatalf("SetEnterprise: %v", err)
+	}
+	t.Cleanup(func() { _ = SetEnterprise(types.GitHubSettings{}) })
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		settings types.GitHubSettings
+		pkg      types.Package
+		expected string
+	}{
+		{types.GitHubSettings{}, types.Package{}, "https://github.com"},
+		{types.GitHubSettings{BaseURL: "github.example.com"}, types.Package{}, "https://github.example.com"},
+		{types.GitHubSettings{BaseURL: "https://github.example.com/api/v3/"}, types.Package{}, "https://github.example.com"},
+		{types.GitHubSettings{BaseURL: "https://github.example.com"}, types.Package{BaseURL: "https://ghe.internal"}, "https://ghe.internal"},
+		{types.GitHubSettings{BaseURL: "https://github.example.com"}, types.Package{BaseURL: "https://api.github.com"}, "https://github.com"},
+	}
+	for _, tt := range tests {
+		setEnterprise(t, tt.settings)
+		if got := BaseURL(tt.pkg); got != tt.expected {
+			t.Errorf("BaseURL(%+v) with %+v = %q, expected %q", tt.pkg, tt.settings, got, tt.expected)
+		}
+	}
+}
+
+func TestSetEnterpriseRejectsInvalidSettings(t *testing.T) {
+	t.Cleanup(func() { _ = SetEnterprise(types.GitHubSettings{}) })
+	if err := SetEnterprise(types.GitHubSettings{BaseURL: "ftp://github.example.com"}); err == nil {
+		t.Errorf("expected an error for a base URL that is not http(s)")
+	}
+	err := SetEnterprise(types.GitHubSettings{BaseURL: "github.example.com", Token: "ghp_secret"})
+	if err == nil || strings.Contains(err.Error(), "ghp_secret") {
+		t.Errorf("expected an error that does not reveal the token, got %v", err)
+	}
+}
+
+func TestEnterpriseRequests(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	setEnterprise(t, types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"})
+	ctx := withPackage(context.Background(), types.Package{Repo: "tools/cli"})
+
+	client := clientFor(ctx)
+	if client.BaseURL() != "https://github.example.com" || client.Token() != "ghe-t0ken" || client.TokenSource() != "GHE_TEST_TOKEN" {
+		t.Fatalf("unexpected client for %s with token from %s", client.BaseURL(), client.TokenSource())
+	}
+	if GetClientFor(DefaultBaseURL) != GetClient() {
+		t.Fatalf("expected github.com to use the default client")
+	}
+
+	var requested []*http.Request
+	respond := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req)
+		body := "[]"
+		if strings.HasSuffix(req.URL.Path, "/info/refs") {
+			body = pktLine(strings.Repeat("1", 40)+" refs/tags/v1.2.3\n") + "0000"
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})
+
+	rest := &GitHubClient{baseURL: client.BaseURL(), httpClient: &http.Client{Transport: respond}}
+	if err := rest.doRESTRequest(ctx, http.MethodGet, "/repos/tools/cli/releases", nil); err != nil {
+		t.Fatalf("doRESTRequest: %v", err)
+	}
+	if got := requested[0].URL.String(); got != "https://github.example.com/api/v3/repos/tools/cli/releases" {
+		t.Errorf("expected the REST request to go to the enterprise API, got %s", got)
+	}
+
+	previousClient := gitRefsHTTPClient
+	defer func() { gitRefsHTTPClient = previousClient }()
+	gitRefsHTTPClient = func() *http.Client { return &http.Client{Transport: respond} }
+
+	versions, err := DiscoverVersionsViaGit(ctx, "tools", "cli")
+	if err != nil {
+		t.Fatalf("DiscoverVersionsViaGit: %v", err)
+	}
+	refs := requested[1]
+	if refs.URL.Host != "github.example.com" || refs.URL.Path != "/tools/cli.git/info/refs" {
+		t.Errorf("expected the git refs request to go to the enterprise host, got %s", refs.URL)
+	}
+	if _, password, ok := refs.BasicAuth(); !ok || password != "ghe-t0ken" {
+		t.Errorf("expected the git refs request to carry the enterprise token")
+	}
+	if len(versions) != 1 || versions[0].Tag != "v1.2.3" {
+		t.Errorf("unexpected versions %v", versions)
+	}
+}
+
+func TestEnterpriseCredentials(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	settings := types.Settings{GitHub: types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"}}
+	setEnterprise(t, settings.GitHub)
+
+	creds := EnterpriseCredentials(settings, nil)
+	if cred := creds["github.example.com"]; cred.Password != "${GHE_TEST_TOKEN}" || cred.Username != "x-access-token" {
+		t.Errorf("expected a credential for the enterprise host referencing its token, got %+v", cred)
+	}
+
+	settings.Credentials = map[string]types.Credential{"github.example.com": {Token: "${OTHER_TOKEN}"}}
+	if cred := EnterpriseCredentials(settings, nil)["github.example.com"]; cred.Token != "${OTHER_TOKEN}" {
+		t.Errorf("expected the configured credential to be kept, got %+v", cred)
+	}
+}
+
+func TestEnterpriseCredentialsForPackageBaseURLs(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	settings := types.Settings{GitHub: types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"}}
+	setEnterprise(t, settings.GitHub)
+
+	creds := EnterpriseCredentials(settings, map[string]types.Package{
+		"tool":   {Name: "tool", Manager: "github_release", Repo: "org/tool", BaseURL: "https://ghe2.example.com/api/v3"},
+		"public": {Name: "public", Manager: "github_release", Repo: "org/public", BaseURL: "https://github.com"},
+		"mirror": {Name: "mirror", Manager: "direct", BaseURL: "https://files.example.com"},
+	})
+	for _, host := range []string{"github.example.com", "ghe2.example.com"} {
+		if cred := creds[host]; cred.Password != "${GHE_TEST_TOKEN}" {
+			t.Errorf("expected a credential for %s, got %+v", host, cred)
+		}
+	}
+	if len(creds) != 2 {
+		t.Errorf("expected credentials only for the enterprise hosts, got %v", creds)
+	}
+}
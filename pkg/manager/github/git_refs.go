@@ -31,32 +31,39 @@ type DiscoverVersionsViaGitOptions struct {
 }
 
 var gitRefsHTTPClient = func() *http.Client {
-	return depshttp.GetHttpClient()
+	return depshttp.GetHttpClient(depshttp.WithResponseCache())
 }
 
-func gitRefsURL(owner, repo string) string {
-	u := &url.URL{
-		Scheme: "https",
-		Host:   "github.com",
-		Path:   fmt.Sprintf("/%s/%s.git/info/refs", owner, repo),
-	}
-
+func gitRefsURL(baseURL, owner, repo string) string {
 	query := url.Values{}
 	query.Set("service", "git-upload-pack")
-	u.RawQuery = query.Encode()
-	return u.String()
+	return fmt.Sprintf("%s/%s/%s.git/info/refs?%s", baseURL, owner, repo, query.Encode())
+}
+
+// authenticateWebRequest adds the token of an enterprise instance to a git or web request,
+// which GitHub Enterprise Server in private mode rejects without one. github.com requests are
+// left unauthenticated, as they are not rate limited.
+func authenticateWebRequest(ctx context.Context, req *http.Request) {
+	baseURL := instance.BaseURLFromContext(ctx)
+	if !IsEnterprise(baseURL) {
+		return
+	}
+	if token := GetClientFor(baseURL).Token(); token != "" {
+		req.SetBasicAuth("x-access-token", token)
+	}
 }
 
 // ResolveLatestTagViaRedirect resolves the "latest" tag with no REST API call by reading
-// the Location header of the github.com/{owner}/{repo}/releases/latest 302 redirect.
+// the Location header of the {base_url}/{owner}/{repo}/releases/latest 302 redirect.
 func ResolveLatestTagViaRedirect(ctx context.Context, owner, repo string) (string, error) {
-	releaseURL := fmt.Sprintf("https://github.com/%s/%s/releases/latest", owner, repo)
+	releaseURL := fmt.Sprintf("%s/%s/%s/releases/latest", instance.BaseURLFromContext(ctx), owner, repo)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodHead, releaseURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "flanksource/deps")
+	authenticateWebRequest(ctx, req)
 
 	client := gitRefsHTTPClient()
 	// Capture the redirect instead of following it (following it would hit the
@@ -95,14 +102,14 @@ func ResolveLatestTagViaRedirect(ctx context.Context, owner, repo string) (strin
 
 // DiscoverVersionsViaGit fetches tags from a GitHub repository using the git HTTP protocol.
 // This avoids GitHub API rate limits by using the git-upload-pack protocol.
-// URL format: https://github.com/{owner}/{repo}.git/info/refs?service=git-upload-pack
+// URL format: {base_url}/{owner}/{repo}.git/info/refs?service=git-upload-pack
 func DiscoverVersionsViaGit(ctx context.Context, owner, repo string, opts ...DiscoverVersionsViaGitOptions) ([]types.Version, error) {
 	var options DiscoverVersionsViaGitOptions
 	if len(opts) > 0 {
 		options = opts[0]
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", gitRefsURL(owner, repo), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", gitRefsURL(instance.BaseURLFromContext(ctx), owner, repo), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -110,6 +117,7 @@ func DiscoverVersionsViaGit(ctx context.Context, owner, repo string, opts ...Dis
 	// GitHub checks user-agent to determine response format
 	req.Header.Set("User-Agent", "git/2.20.1")
 	req.Header.Set("Accept", "application/x-git-upload-pack-advertisement, */*")
+	authenticateWebRequest(ctx, req)
 
 	client := gitRefsHTTPClient()
 	resp, err := client.Do(req)
@@ -342,7 +350,7 @@ var (
 
 // DiscoverVersionsViaGitCached is like DiscoverVersionsViaGit but with caching
 func DiscoverVersionsViaGitCached(ctx context.Context, owner, repo string, limit int) ([]types.Version, error) {
-	cacheKey := fmt.Sprintf("%s/%s", owner, repo)
+	cacheKey := fmt.Sprintf("%s/%s/%s", instance.BaseURLFromContext(ctx), owner, repo)
 
 	// Check cache with read lock
 	gitRefsCacheMu.RLock()
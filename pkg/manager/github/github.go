@@ -39,6 +39,9 @@ type restRelease struct {
 	Assets          []restAsset `json:"assets"`
 	Author          *restUser   `json:"author,omitempty"`
 	TargetCommitish string      `json:"target_commitish,omitempty"`
+
+	// assetsTruncated is set on prefetched releases with more assets than the GraphQL query returned
+	assetsTruncated bool
 }
 
 // restAsset represents a release asset from REST API (includes digest field)
@@ -84,6 +87,8 @@ func (m *GitHubReleaseManager) Name() string {
 // DiscoverVersions returns the most recent versions from GitHub using git HTTP protocol.
 // Falls back to REST API if git HTTP fails.
 func (m *GitHubReleaseManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	ctx = withPackage(ctx, pkg)
+
 	if pkg.Repo == "" {
 		return nil, fmt.Errorf("repo is required for GitHub releases")
 	}
@@ -99,12 +104,19 @@ func (m *GitHubReleaseManager) DiscoverVersions(ctx context.Context, pkg types.P
 		SkipSemverFilter: pkg.VersionExpr != "",
 	}
 
-	// Use git HTTP protocol with fallback to REST API
-	versions, err := DiscoverVersionsViaGitWithFallback(ctx, owner, repo, limit, func() ([]types.Version, error) {
-		return m.discoverVersionsViaREST(ctx, owner, repo, limit)
-	}, opts)
-	if err != nil {
-		return nil, err
+	var versions []types.Version
+	if releases, ok := cachedReleases(ctx, owner, repo, limit); ok {
+		// Prefetched with the other packages by PrefetchVersions
+		versions = versionsFromReleases(releases)
+	} else {
+		// Use git HTTP protocol with fallback to REST API
+		var err error
+		versions, err = DiscoverVersionsViaGitWithFallback(ctx, owner, repo, limit, func() ([]types.Version, error) {
+			return m.discoverVersionsViaREST(ctx, owner, repo, limit)
+		}, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Apply version expression filtering if specified
@@ -136,12 +148,15 @@ func (m *GitHubReleaseManager) discoverVersionsViaREST(ctx context.Context, owne
 		perPage = 100
 	}
 
-	endpoint := fmt.Sprintf("/repos/%s/%s/releases?per_page=%d", owner, repo, perPage)
-	var releases []restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &releases); err != nil {
+	releases, err := listReleases(ctx, owner, repo, perPage)
+	if err != nil {
 		return nil, fmt.Errorf("failed to list releases for %s/%s: %w", owner, repo, err)
 	}
+	return versionsFromReleases(releases), nil
+}
 
+// versionsFromReleases returns the versions of releases, newest first
+func versionsFromReleases(releases []restRelease) []types.Version {
 	var versions []types.Version
 	for _, release := range releases {
 		if release.Draft {
@@ -159,11 +174,13 @@ func (m *GitHubReleaseManager) discoverVersionsViaREST(ctx context.Context, owne
 	// Sort versions in descending order (newest first)
 	versionpkg.SortVersions(versions)
 
-	return versions, nil
+	return versions
 }
 
 // Resolve gets the download URL and metadata for a specific version and platform
 func (m *GitHubReleaseManager) Resolve(ctx context.Context, pkg types.Package, version string, plat platform.Platform) (*types.Resolution, error) {
+	ctx = withPackage(ctx, pkg)
+
 	parts := strings.Split(pkg.Repo, "/")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid repo format: %s", pkg.Repo)
@@ -310,11 +327,17 @@ func (m *GitHubReleaseManager) Resolve(ctx context.Context, pkg types.Package, v
 }
 
 // fetchReleaseViaREST fetches a release using REST API (includes digest field).
-// endpoint is either "latest" or "tags/{tagName}"
+// endpoint is either "latest" or "tags/{tagName}"; tags are served from the prefetched releases
+// when PrefetchVersions fetched them.
 func (m *GitHubReleaseManager) fetchReleaseViaREST(ctx context.Context, owner, repo, endpoint string) (*restRelease, error) {
+	if tag, ok := strings.CutPrefix(endpoint, "tags/"); ok {
+		if release, ok := cachedRelease(ctx, owner, repo, tag); ok {
+			return release, nil
+		}
+	}
 	url := fmt.Sprintf("/repos/%s/%s/releases/%s", owner, repo, endpoint)
 	var release restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", url, &release); err != nil {
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", url, &release); err != nil {
 		return nil, err
 	}
 	return &release, nil
@@ -322,15 +345,18 @@ func (m *GitHubReleaseManager) fetchReleaseViaREST(ctx context.Context, owner, r
 
 // fetchLatestStableRelease fetches the most recent non-prerelease release
 func (m *GitHubReleaseManager) fetchLatestStableRelease(ctx context.Context, owner, repo string) (*restRelease, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/releases?per_page=20", owner, repo)
-	var releases []restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &releases); err != nil {
+	releases, err := listReleases(ctx, owner, repo, 20)
+	if err != nil {
 		return nil, err
 	}
 	for i := range releases {
-		if !releases[i].Draft && !releases[i].Prerelease {
-			return &releases[i], nil
+		if releases[i].Draft || releases[i].Prerelease {
+			continue
+		}
+		if releases[i].assetsTruncated {
+			return m.fetchReleaseViaREST(ctx, owner, repo, "tags/"+releases[i].TagName)
 		}
+		return &releases[i], nil
 	}
 	return nil, fmt.Errorf("no stable releases found")
 }
@@ -669,10 +695,9 @@ assetFound:
 func (m *GitHubReleaseManager) findReleaseByVersion(ctx context.Context, owner, repo, targetVersion, versionExpr string) (string, error) {
 	logger.V(3).Infof("GitHub fetching releases for %s/%s, looking for version: %s", owner, repo, targetVersion)
 
-	// Fetch releases via REST API
-	endpoint := fmt.Sprintf("/repos/%s/%s/releases?per_page=100", owner, repo)
-	var releases []restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &releases); err != nil {
+	// Fetch releases via REST API, unless they were prefetched
+	releases, err := listReleases(ctx, owner, repo, 100)
+	if err != nil {
 		return "", fmt.Errorf("failed to list releases: %w", err)
 	}
 
@@ -760,7 +785,7 @@ func (m *GitHubReleaseManager) findReleaseByVersion(ctx context.Context, owner,
 func fetchAllReleaseAssets(ctx context.Context, owner, repo, tagName string) ([]AssetInfo, error) {
 	endpoint := fmt.Sprintf("/repos/%s/%s/releases/tags/%s", owner, repo, tagName)
 	var release restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &release); err != nil {
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &release); err != nil {
 		return nil, fmt.Errorf("failed to fetch release: %w", err)
 	}
 
@@ -833,9 +858,8 @@ func (i *githubReleaseIterator) FetchReleases(ctx context.Context, limit int) ([
 		fetchLimit = limit * 2
 	}
 
-	endpoint := fmt.Sprintf("/repos/%s/%s/releases?per_page=%d", i.owner, i.repo, fetchLimit)
-	var releases []restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &releases); err != nil {
+	releases, err := listReleases(ctx, i.owner, i.repo, fetchLimit)
+	if err != nil {
 		return nil, fmt.Errorf("failed to list releases: %w", err)
 	}
 
@@ -897,10 +921,11 @@ func (m *GitHubReleaseManager) Verify(ctx context.Context, binaryPath string, pk
 
 // WhoAmI returns authentication status and user information for GitHub
 func (m *GitHubReleaseManager) WhoAmI(ctx context.Context) *types.AuthStatus {
-	client := GetClient().Client()
+	gh := clientFor(ctx)
+	client := gh.Client()
 	status := &types.AuthStatus{
-		Service:     "GitHub",
-		TokenSource: GetClient().TokenSource(),
+		Service:     instance.ServiceName(gh.BaseURL()),
+		TokenSource: gh.TokenSource(),
 	}
 
 	user, response, err := client.Users.Get(ctx, "")
@@ -1131,7 +1156,7 @@ func (m *GitHubReleaseManager) buildChecksumURL(pkg types.Package, assetName, ve
 			urls = append(urls, checksumFile)
 		} else {
 			// Otherwise it is a release asset name - build the GitHub release download URL.
-			urls = append(urls, fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", pkg.Repo, tag, checksumFile))
+			urls = append(urls, fmt.Sprintf("%s/%s/releases/download/%s/%s", BaseURL(pkg), pkg.Repo, tag, checksumFile))
 		}
 	}
 
@@ -1191,7 +1216,7 @@ func (m *GitHubReleaseManager) buildDeterministicResolution(pkg types.Package, v
 		}
 	} else {
 		// Build GitHub release download URL from repo and asset pattern
-		downloadURL = fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", pkg.Repo, tagName, templatedPattern)
+		downloadURL = fmt.Sprintf("%s/%s/releases/download/%s/%s", BaseURL(pkg), pkg.Repo, tagName, templatedPattern)
 	}
 
 	resolution := &types.Resolution{
@@ -105,6 +105,8 @@ func parseAssetName(assetName string) (version, buildDate, platformStr string, e
 
 // DiscoverVersions returns available software versions from the latest build
 func (m *GitHubBuildManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	ctx = withPackage(ctx, pkg)
+
 	if pkg.Repo == "" {
 		return nil, fmt.Errorf("repo is required for GitHub build manager")
 	}
@@ -120,7 +122,7 @@ func (m *GitHubBuildManager) DiscoverVersions(ctx context.Context, pkg types.Pac
 	// Get the latest release via REST API
 	endpoint := fmt.Sprintf("/repos/%s/%s/releases/latest", owner, repo)
 	var release restRelease
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &release); err != nil {
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &release); err != nil {
 		return nil, fmt.Errorf("failed to get latest release for %s: %w", pkg.Repo, err)
 	}
 
@@ -224,6 +226,8 @@ func (m *GitHubBuildManager) parseAssetsWithDigests(assets []AssetInfo, plat pla
 
 // Resolve gets the download URL and metadata for a specific version and platform
 func (m *GitHubBuildManager) Resolve(ctx context.Context, pkg types.Package, version string, plat platform.Platform) (*types.Resolution, error) {
+	ctx = withPackage(ctx, pkg)
+
 	parts := strings.Split(pkg.Repo, "/")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid repo format: %s", pkg.Repo)
@@ -242,7 +246,7 @@ func (m *GitHubBuildManager) Resolve(ctx context.Context, pkg types.Package, ver
 		// Get latest release tag using REST API
 		endpoint := fmt.Sprintf("/repos/%s/%s/releases/latest", owner, repo)
 		var release restRelease
-		if err := GetClient().RESTRequest(ctx, "GET", endpoint, &release); err != nil {
+		if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &release); err != nil {
 			return nil, fmt.Errorf("failed to get latest release for %s: %w", pkg.Repo, err)
 		}
 		tagName = release.TagName
@@ -327,6 +331,8 @@ func (m *GitHubBuildManager) Resolve(ctx context.Context, pkg types.Package, ver
 // ResolveVersionConstraint resolves a version constraint to a concrete software version
 // Handles build tags (YYYYMMDD) by resolving them to actual software versions
 func (m *GitHubBuildManager) ResolveVersionConstraint(ctx context.Context, pkg types.Package, constraint string, plat platform.Platform) (string, error) {
+	ctx = withPackage(ctx, pkg)
+
 	// Parse the constraint to understand what type it is
 	buildTag, softwareVersion := parseVersion(constraint)
 
@@ -29,7 +29,7 @@ type GitHubTagsManager struct {
 // NewGitHubTagsManager creates a new GitHub tags manager.
 func NewGitHubTagsManager() *GitHubTagsManager {
 	return &GitHubTagsManager{
-		client: depshttp.GetHttpClient(),
+		client: depshttp.GetHttpClient(depshttp.WithResponseCache()),
 	}
 }
 
@@ -41,6 +41,8 @@ func (m *GitHubTagsManager) Name() string {
 // DiscoverVersions returns the most recent versions from GitHub tags using git HTTP protocol.
 // Falls back to GraphQL if git HTTP fails.
 func (m *GitHubTagsManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	ctx = withPackage(ctx, pkg)
+
 	if pkg.Repo == "" {
 		return nil, fmt.Errorf("repo is required for GitHub tags")
 	}
@@ -98,7 +100,7 @@ func (m *GitHubTagsManager) discoverVersionsViaREST(ctx context.Context, owner,
 	// Use REST API to get tags
 	endpoint := fmt.Sprintf("/repos/%s/%s/tags?per_page=%d", owner, repo, perPage)
 	var tags []restTag
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &tags); err != nil {
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &tags); err != nil {
 		err = m.enhanceRateLimitError(ctx, err)
 		return nil, fmt.Errorf("failed to list tags for %s/%s: %w", owner, repo, err)
 	}
@@ -131,6 +133,8 @@ type restTagCommit struct {
 
 // Resolve gets the download URL and metadata for a specific version and platform
 func (m *GitHubTagsManager) Resolve(ctx context.Context, pkg types.Package, version string, plat platform.Platform) (*types.Resolution, error) {
+	ctx = withPackage(ctx, pkg)
+
 	if pkg.URLTemplate == "" {
 		return nil, fmt.Errorf("url_template is required for github_tags manager")
 	}
@@ -362,10 +366,11 @@ func (m *GitHubTagsManager) Verify(ctx context.Context, binaryPath string, pkg t
 
 // WhoAmI returns authentication status and user information for GitHub
 func (m *GitHubTagsManager) WhoAmI(ctx context.Context) *types.AuthStatus {
-	client := GetClient().Client()
+	gh := clientFor(ctx)
+	client := gh.Client()
 	status := &types.AuthStatus{
-		Service:     "GitHub",
-		TokenSource: GetClient().TokenSource(),
+		Service:     instance.ServiceName(gh.BaseURL()),
+		TokenSource: gh.TokenSource(),
 	}
 
 	// Get authenticated user information
@@ -559,7 +564,7 @@ func (m *GitHubTagsManager) buildFallbackResolution(pkg types.Package, ver strin
 		}
 	} else {
 		// Build GitHub release download URL from repo and asset pattern
-		downloadURL = fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", pkg.Repo, tagName, templatedPattern)
+		downloadURL = fmt.Sprintf("%s/%s/releases/download/%s/%s", BaseURL(pkg), pkg.Repo, tagName, templatedPattern)
 	}
 
 	resolution := &types.Resolution{
@@ -598,7 +603,7 @@ func (m *GitHubTagsManager) findTagByVersion(ctx context.Context, pkg types.Pack
 	// Fetch tags via REST API
 	endpoint := fmt.Sprintf("/repos/%s/%s/tags?per_page=100", owner, repo)
 	var tags []restTag
-	if err := GetClient().RESTRequest(ctx, "GET", endpoint, &tags); err != nil {
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &tags); err != nil {
 		err = m.enhanceRateLimitError(ctx, err)
 		return nil, fmt.Errorf("failed to query tags: %w", err)
 	}
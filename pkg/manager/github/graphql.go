@@ -0,0 +1,276 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// Batched release discovery. One GraphQL query fetches a page of releases for up to
+// graphqlBatchSize repositories, keeping each query well under GitHub's limit of 500,000 nodes
+// with up to 100 assets per release.
+const (
+	graphqlBatchSize  = 25
+	releasesPageSize  = 50
+	prefetchReleases  = 100 // Releases fetched per repository, newest first
+	releaseAssetsPage = 100
+)
+
+// releasesQueryFields selects a page of releases of the repository aliased r<i>
+const releasesQueryFields = `releases(first: %d, after: $c%d, orderBy: {field: CREATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        tagName
+        isPrerelease
+        isDraft
+        publishedAt
+        tagCommit { oid }
+        releaseAssets(first: %d) { pageInfo { hasNextPage } nodes { name downloadUrl digest size contentType } }
+      }
+    }`
+
+type graphqlReleases struct {
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+	Nodes []struct {
+		TagName      string    `json:"tagName"`
+		IsPrerelease bool      `json:"isPrerelease"`
+		IsDraft      bool      `json:"isDraft"`
+		PublishedAt  time.Time `json:"publishedAt"`
+		TagCommit    *struct {
+			Oid string `json:"oid"`
+		} `json:"tagCommit"`
+		ReleaseAssets struct {
+			PageInfo struct {
+				HasNextPage bool `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Nodes []struct {
+				Name        string `json:"name"`
+				DownloadURL string `json:"downloadUrl"`
+				Digest      string `json:"digest"`
+				Size        int    `json:"size"`
+				ContentType string `json:"contentType"`
+			} `json:"nodes"`
+		} `json:"releaseAssets"`
+	} `json:"nodes"`
+}
+
+// repoRef is a repository whose releases are fetched, from cursor onwards
+type repoRef struct {
+	owner  string
+	repo   string
+	cursor string
+}
+
+// releaseCacheEntry holds the prefetched releases of a repository, newest first
+type releaseCacheEntry struct {
+	releases  []restRelease
+	complete  bool // Every release of the repository is cached
+	fetchedAt time.Time
+}
+
+var (
+	releaseCache    = make(map[string]*releaseCacheEntry)
+	releaseCacheMu  sync.RWMutex
+	releaseCacheTTL = 5 * time.Minute
+)
+
+func releaseCacheKey(baseURL, owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s", baseURL, owner, repo)
+}
+
+// cachedReleases returns the prefetched releases of owner/repo if there are at least count of
+// them, or all of them when count is 0
+func cachedReleases(ctx context.Context, owner, repo string, count int) ([]restRelease, bool) {
+	releaseCacheMu.RLock()
+	defer releaseCacheMu.RUnlock()
+	entry, ok := releaseCache[releaseCacheKey(instance.BaseURLFromContext(ctx), owner, repo)]
+	if !ok || time.Since(entry.fetchedAt) > releaseCacheTTL {
+		return nil, false
+	}
+	if entry.complete || (count > 0 && len(entry.releases) >= count) {
+		return entry.releases, true
+	}
+	return nil, false
+}
+
+// cachedRelease returns the prefetched release of owner/repo tagged tag, unless it has more
+// assets than were prefetched
+func cachedRelease(ctx context.Context, owner, repo, tag string) (*restRelease, bool) {
+	releases, ok := cachedReleases(ctx, owner, repo, 1)
+	if !ok {
+		return nil, false
+	}
+	for i := range releases {
+		if releases[i].TagName == tag {
+			return &releases[i], !releases[i].assetsTruncated
+		}
+	}
+	return nil, false
+}
+
+// listReleases returns the perPage most recent releases of owner/repo, from the prefetched
+// releases when they cover them and otherwise with the REST API
+func listReleases(ctx context.Context, owner, repo string, perPage int) ([]restRelease, error) {
+	if releases, ok := cachedReleases(ctx, owner, repo, perPage); ok {
+		if len(releases) > perPage {
+			releases = releases[:perPage]
+		}
+		return releases, nil
+	}
+	endpoint := fmt.Sprintf("/repos/%s/%s/releases?per_page=%d", owner, repo, perPage)
+	var releases []restRelease
+	if err := clientFor(ctx).RESTRequest(ctx, "GET", endpoint, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// PrefetchVersions fetches the releases of pkgs with batched GraphQL queries, so that
+// DiscoverVersions and Resolve need no REST call for them. The GraphQL API requires a token:
+// without one, or when a query fails, the packages that were not fetched are resolved one by
+// one as before.
+func (m *GitHubReleaseManager) PrefetchVersions(ctx context.Context, pkgs []types.Package) error {
+	byBaseURL := make(map[string][]repoRef)
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		parts := strings.Split(pkg.Repo, "/")
+		if len(parts) != 2 {
+			continue
+		}
+		baseURL := BaseURL(pkg)
+		key := releaseCacheKey(baseURL, parts[0], parts[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := cachedReleases(WithBaseURL(ctx, baseURL), parts[0], parts[1], prefetchReleases); ok {
+			continue
+		}
+		byBaseURL[baseURL] = append(byBaseURL[baseURL], repoRef{owner: parts[0], repo: parts[1]})
+	}
+
+	var errs []error
+	for baseURL, repos := range byBaseURL {
+		client := GetClientFor(baseURL)
+		if client.Token() == "" {
+			logger.V(3).Infof("Skipping batched release discovery for %d repositories on %s: the GraphQL API requires a token", len(repos), baseURL)
+			continue
+		}
+		if err := fetchReleasesGraphQL(ctx, client, repos, prefetchReleases); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", instance.ServiceName(baseURL), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetchReleasesGraphQL caches up to maxReleases releases of each repository, following the
+// pagination of the repositories that have more. Repositories that cannot be read are left out.
+func fetchReleasesGraphQL(ctx context.Context, client *GitHubClient, repos []repoRef, maxReleases int) error {
+	entries := make(map[string]*releaseCacheEntry)
+	defer func() {
+		// Keep whatever was fetched, even if a later page failed
+		releaseCacheMu.Lock()
+		defer releaseCacheMu.Unlock()
+		for key, entry := range entries {
+			entry.fetchedAt = time.Now()
+			releaseCache[key] = entry
+		}
+	}()
+
+	pending := repos
+	for queries := 1; len(pending) > 0; queries++ {
+		batch := pending[:min(graphqlBatchSize, len(pending))]
+		pending = pending[len(batch):]
+
+		query, variables := releasesQuery(batch)
+		var data map[string]*struct {
+			Releases graphqlReleases `json:"releases"`
+		}
+		if err := client.GraphQLRequest(ctx, query, variables, &data); err != nil {
+			return fmt.Errorf("failed to fetch releases of %d repositories: %w", len(batch), err)
+		}
+
+		for i, ref := range batch {
+			node := data[fmt.Sprintf("r%d", i)]
+			if node == nil {
+				logger.V(3).Infof("GraphQL returned no releases for %s/%s", ref.owner, ref.repo)
+				continue
+			}
+			key := releaseCacheKey(client.BaseURL(), ref.owner, ref.repo)
+			entry, ok := entries[key]
+			if !ok {
+				entry = &releaseCacheEntry{}
+				entries[key] = entry
+			}
+			entry.releases = append(entry.releases, toRESTReleases(node.Releases)...)
+			entry.complete = !node.Releases.PageInfo.HasNextPage
+			if !entry.complete && len(entry.releases) < maxReleases {
+				pending = append(pending, repoRef{owner: ref.owner, repo: ref.repo, cursor: node.Releases.PageInfo.EndCursor})
+			}
+		}
+		logger.V(3).Infof("GraphQL query %d fetched releases of %d repositories, %d pages pending", queries, len(batch), len(pending))
+	}
+	return nil
+}
+
+// releasesQuery builds a query for a page of releases of each repository in batch, aliased
+// r0, r1, ... with the owners, names and cursors passed as variables
+func releasesQuery(batch []repoRef) (string, map[string]interface{}) {
+	params := make([]string, 0, len(batch))
+	fields := make([]string, 0, len(batch))
+	variables := make(map[string]interface{}, 3*len(batch))
+	for i, ref := range batch {
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!, $c%d: String", i, i, i))
+		fields = append(fields, fmt.Sprintf("  r%d: repository(owner: $o%d, name: $n%d) {\n    %s\n  }",
+			i, i, i, fmt.Sprintf(releasesQueryFields, releasesPageSize, i, releaseAssetsPage)))
+		variables[fmt.Sprintf("o%d", i)] = ref.owner
+		variables[fmt.Sprintf("n%d", i)] = ref.repo
+		if ref.cursor != "" {
+			variables[fmt.Sprintf("c%d", i)] = ref.cursor
+		} else {
+			variables[fmt.Sprintf("c%d", i)] = nil
+		}
+	}
+	return fmt.Sprintf("query(%s) {\n%s\n}", strings.Join(params, ", "), strings.Join(fields, "\n")), variables
+}
+
+// toRESTReleases converts a page of GraphQL releases to the REST form the resolvers use,
+// leaving out drafts
+func toRESTReleases(page graphqlReleases) []restRelease {
+	releases := make([]restRelease, 0, len(page.Nodes))
+	for _, node := range page.Nodes {
+		if node.IsDraft {
+			continue
+		}
+		release := restRelease{
+			TagName:     node.TagName,
+			Prerelease:  node.IsPrerelease,
+			PublishedAt: node.PublishedAt,
+			// Releases with more assets are looked up again with the REST API
+			assetsTruncated: node.ReleaseAssets.PageInfo.HasNextPage,
+		}
+		if node.TagCommit != nil {
+			release.TargetCommitish = node.TagCommit.Oid
+		}
+		for _, asset := range node.ReleaseAssets.Nodes {
+			release.Assets = append(release.Assets, restAsset{
+				Name:               asset.Name,
+				BrowserDownloadURL: asset.DownloadURL,
+				Digest:             asset.Digest,
+				Size:               asset.Size,
+				ContentType:        asset.ContentType,
+			})
+		}
+		releases = append(releases, release)
+	}
+	return releases
+}
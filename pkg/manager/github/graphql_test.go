@@ -0,0 +1,190 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+const releasesPage = `{"data": {
+  "r0": {"releases": {
+    "pageInfo": {"hasNextPage": %s, "endCursor": "cursor-1"},
+    "nodes": [%s]
+  }},
+  "r1": null
+}}`
+
+const releaseNode = `{"tagName": "%s", "isPrerelease": false, "isDraft": %s, "publishedAt": "2025-01-02T03:04:05Z",
+  "tagCommit": {"oid": "abc123"},
+  "releaseAssets": {"nodes": [{"name": "cli-linux-amd64.tar.gz", "downloadUrl": "https://github.example.com/tools/cli/releases/download/%s/cli-linux-amd64.tar.gz", "digest": "sha256:f00d", "size": 42, "contentType": "application/gzip"}]}}`
+
+func resetReleaseCache(t *testing.T) {
+	releaseCacheMu.Lock()
+	releaseCache = make(map[string]*releaseCacheEntry)
+	releaseCacheMu.Unlock()
+	t.Cleanup(func() {
+		releaseCacheMu.Lock()
+		releaseCache = make(map[string]*releaseCacheEntry)
+		releaseCacheMu.Unlock()
+	})
+}
+
+func TestPrefetchVersions(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	setEnterprise(t, types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"})
+	resetReleaseCache(t)
+
+	var queries []map[string]interface{}
+	var restPaths []string
+	client := GetClientFor("https://github.example.com")
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := "[]"
+		if req.URL.Path == "/api/graphql" {
+			var query struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&query); err != nil {
+				t.Fatalf("decoding query: %v", err)
+			}
+			queries = append(queries, query.Variables)
+			if query.Variables["c0"] == nil {
+				body = fmt.Sprintf(releasesPage, "true", fmt.Sprintf(releaseNode, "v1.1.0", "false", "v1.1.0")+","+fmt.Sprintf(releaseNode, "v1.2.0-draft", "true", "v1.2.0-draft"))
+			} else {
+				body = fmt.Sprintf(releasesPage, "false", fmt.Sprintf(releaseNode, "v1.0.0", "false", "v1.0.0"))
+			}
+		} else {
+			restPaths = append(restPaths, req.URL.Path)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+
+	pkgs := []types.Package{
+		{Name: "cli", Repo: "tools/cli"},
+		{Name: "cli-dup", Repo: "tools/cli"},
+		{Name: "missing", Repo: "tools/missing"},
+	}
+	mgr := &GitHubReleaseManager{}
+	if err := mgr.PrefetchVersions(context.Background(), pkgs); err != nil {
+		t.Fatalf("PrefetchVersions: %v", err)
+	}
+
+	if len(queries) != 2 {
+		t.Fatalf("expected one batched query and one for the next page, got %d", len(queries))
+	}
+	if queries[0]["o0"] != "tools" || queries[0]["n0"] != "cli" || queries[0]["n1"] != "missing" || queries[0]["n2"] != nil {
+		t.Errorf("unexpected variables of the batched query: %v", queries[0])
+	}
+	if queries[1]["n0"] != "cli" || queries[1]["c0"] != "cursor-1" || queries[1]["n1"] != nil {
+		t.Errorf("expected the second query to fetch the next page of tools/cli only, got %v", queries[1])
+	}
+
+	ctx := withPackage(context.Background(), pkgs[0])
+	releases, err := listReleases(ctx, "tools", "cli", 100)
+	if err != nil {
+		t.Fatalf("listReleases: %v", err)
+	}
+	if len(releases) != 2 || releases[0].TagName != "v1.1.0" || releases[1].TagName != "v1.0.0" {
+		t.Errorf("expected the prefetched releases without drafts, got %+v", releases)
+	}
+	release, err := mgr.fetchReleaseViaREST(ctx, "tools", "cli", "tags/v1.0.0")
+	if err != nil {
+		t.Fatalf("fetchReleaseViaREST: %v", err)
+	}
+	if release.TargetCommitish != "abc123" || len(release.Assets) != 1 || release.Assets[0].Digest != "sha256:f00d" {
+		t.Errorf("unexpected prefetched release %+v", release)
+	}
+	if len(restPaths) != 0 {
+		t.Errorf("expected prefetched releases to need no REST call, got %v", restPaths)
+	}
+
+	// Repositories GraphQL could not read fall back to REST
+	if _, err := listReleases(ctx, "tools", "missing", 100); err != nil {
+		t.Fatalf("listReleases: %v", err)
+	}
+	if len(restPaths) != 1 || restPaths[0] != "/api/v3/repos/tools/missing/releases" {
+		t.Errorf("expected a REST call for tools/missing, got %v", restPaths)
+	}
+}
+
+func TestPrefetchVersionsFallsBackToREST(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	setEnterprise(t, types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"})
+	resetReleaseCache(t)
+
+	client := GetClientFor("https://github.example.com")
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	err := (&GitHubReleaseManager{}).PrefetchVersions(context.Background(), []types.Package{{Name: "cli", Repo: "tools/cli"}})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Errorf("expected the GraphQL error to be returned, got %v", err)
+	}
+	ctx := WithBaseURL(context.Background(), "https://github.example.com")
+	if _, ok := cachedReleases(ctx, "tools", "cli", 1); ok {
+		t.Errorf("expected nothing to be cached after a failed query")
+	}
+}
+
+func TestPrefetchVersionsNeedsToken(t *testing.T) {
+	setEnterprise(t, types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_UNSET_TOKEN}"})
+	resetReleaseCache(t)
+
+	client := GetClientFor("https://github.example.com")
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s without a token", req.URL)
+		return nil, io.EOF
+	})}
+
+	if err := (&GitHubReleaseManager{}).PrefetchVersions(context.Background(), []types.Package{{Name: "cli", Repo: "tools/cli"}}); err != nil {
+		t.Errorf("expected packages to be left to REST without a token, got %v", err)
+	}
+}
+
+func TestPrefetchedReleaseWithMoreAssetsUsesREST(t *testing.T) {
+	t.Setenv("GHE_TEST_TOKEN", "ghe-t0ken")
+	setEnterprise(t, types.GitHubSettings{BaseURL: "https://github.example.com", Token: "${GHE_TEST_TOKEN}"})
+	resetReleaseCache(t)
+
+	var restPaths []string
+	client := GetClientFor("https://github.example.com")
+	client.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"tag_name": "v1.1.0", "assets": [{"name": "cli-windows-arm64.zip", "digest": "sha256:beef"}]}`
+		if req.URL.Path == "/api/graphql" {
+			node := strings.Replace(fmt.Sprintf(releaseNode, "v1.1.0", "false", "v1.1.0"), `"releaseAssets": {`, `"releaseAssets": {"pageInfo": {"hasNextPage": true}, `, 1)
+			body = fmt.Sprintf(releasesPage, "false", node)
+		} else {
+			restPaths = append(restPaths, req.URL.Path)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+
+	mgr := &GitHubReleaseManager{}
+	if err := mgr.PrefetchVersions(context.Background(), []types.Package{{Name: "cli", Repo: "tools/cli"}}); err != nil {
+		t.Fatalf("PrefetchVersions: %v", err)
+	}
+
+	ctx := WithBaseURL(context.Background(), "https://github.example.com")
+	for _, release := range []func() (*restRelease, error){
+		func() (*restRelease, error) { return mgr.fetchReleaseViaREST(ctx, "tools", "cli", "tags/v1.1.0") },
+		func() (*restRelease, error) { return mgr.fetchLatestStableRelease(ctx, "tools", "cli") },
+	} {
+		restPaths = nil
+		release, err := release()
+		if err != nil {
+			t.Fatalf("fetching release: %v", err)
+		}
+		if len(release.Assets) != 1 || release.Assets[0].Name != "cli-windows-arm64.zip" {
+			t.Errorf("expected the assets from the REST API, got %+v", release.Assets)
+		}
+		if len(restPaths) != 1 || restPaths[0] != "/api/v3/repos/tools/cli/releases/tags/v1.1.0" {
+			t.Errorf("expected the release to be looked up with the REST API, got %v", restPaths)
+		}
+	}
+}
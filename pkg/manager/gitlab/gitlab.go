@@ -138,7 +138,7 @@ const graphQLReleasesQuery = `query allReleases($fullPath: ID!, $first: Int, $la
 // NewGitLabReleaseManager creates a new GitLab release manager
 func NewGitLabReleaseManager(token, tokenSource string) *GitLabReleaseManager {
 	return &GitLabReleaseManager{
-		client:      depshttp.GetHttpClient(),
+		client:      depshttp.GetHttpClient(depshttp.WithResponseCache()),
 		token:       token,
 		tokenSource: tokenSource,
 	}
@@ -155,7 +155,7 @@ func (m *GitLabReleaseManager) DiscoverVersions(ctx context.Context, pkg types.P
 		return nil, fmt.Errorf("package %s has no repository specified", pkg.Name)
 	}
 
-	releases, err := m.fetchReleases(ctx, pkg.Repo)
+	releases, err := m.fetchReleases(ctx, pkg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch GitLab releases for %s: %w", pkg.Repo, err)
 	}
@@ -175,7 +175,7 @@ func (m *GitLabReleaseManager) DiscoverVersions(ctx context.Context, pkg types.P
 // Resolve gets the download URL and checksum for a specific version and platform
 func (m *GitLabReleaseManager) Resolve(ctx context.Context, pkg types.Package, versionStr string, plat platform.Platform) (*types.Resolution, error) {
 	// Find the matching version by exact tag or normalized version
-	releases, err := m.fetchReleases(ctx, pkg.Repo)
+	releases, err := m.fetchReleases(ctx, pkg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch GitLab releases: %w", err)
 	}
@@ -303,7 +303,7 @@ func (m *GitLabReleaseManager) Resolve(ctx context.Context, pkg types.Package, v
 	if downloadURL == "" {
 		// Fallback to constructed URL - GitLab uses different URL structure
 		repoPath := url.PathEscape(pkg.Repo)
-		downloadURL = fmt.Sprintf("https://gitlab.com/%s/-/releases/%s/downloads/%s", repoPath, targetRelease.TagName, assetName)
+		downloadURL = fmt.Sprintf("%s/%s/-/releases/%s/downloads/%s", BaseURL(pkg), repoPath, targetRelease.TagName, assetName)
 	}
 
 	resolution := &types.Resolution{
@@ -341,7 +341,7 @@ func (m *GitLabReleaseManager) GetChecksums(ctx context.Context, pkg types.Packa
 	}
 
 	// Find the release for this version
-	releases, err := m.fetchReleases(ctx, pkg.Repo)
+	releases, err := m.fetchReleases(ctx, pkg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch GitLab releases: %w", err)
 	}
@@ -379,13 +379,20 @@ func (m *GitLabReleaseManager) Verify(ctx context.Context, binaryPath string, pk
 	return nil, fmt.Errorf("verify not implemented for GitLab manager")
 }
 
-// fetchReleases retrieves releases from GitLab GraphQL API
-func (m *GitLabReleaseManager) fetchReleases(ctx context.Context, repo string) ([]GitLabRelease, error) {
+// WhoAmI returns the authentication status for the GitLab instance of ctx, from WithBaseURL
+func (m *GitLabReleaseManager) WhoAmI(ctx context.Context) *types.AuthStatus {
+	return whoAmI(ctx, m.client, m.token, m.tokenSource)
+}
+
+// fetchReleases retrieves the releases of pkg from the GraphQL API of its GitLab instance
+func (m *GitLabReleaseManager) fetchReleases(ctx context.Context, pkg types.Package) ([]GitLabRelease, error) {
+	baseURL := BaseURL(pkg)
+
 	// Prepare GraphQL request
 	graphQLReq := GraphQLRequest{
 		OperationName: "allReleases",
 		Variables: GraphQLVariables{
-			FullPath: repo,
+			FullPath: pkg.Repo,
 			First:    10, // Default limit, can be made configurable
 			Sort:     "RELEASED_AT_DESC",
 		},
@@ -399,13 +406,15 @@ func (m *GitLabReleaseManager) fetchReleases(ctx context.Context, repo string) (
 	}
 
 	// Create HTTP POST request
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://gitlab.com/api/graphql", bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", graphqlURL(baseURL), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, err
 	}
 
 	// Set required headers
 	req.Header.Set("Content-Type", "application/json")
+	token, _ := tokenFor(baseURL, m.token, m.tokenSource)
+	authenticate(req, token)
 
 	resp, err := m.client.Do(req)
 	if err != nil {
@@ -414,7 +423,7 @@ func (m *GitLabReleaseManager) fetchReleases(ctx context.Context, repo string) (
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitLab GraphQL API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("GitLab GraphQL API at %s returned status %d", baseURL, resp.StatusCode)
 	}
 
 	var graphQLResp GraphQLResponse
@@ -10,6 +10,7 @@ func init() {
 	// Register GitLab manager with token from multiple possible environment variables
 	token, tokenSource := detectGitLabToken()
 	manager.Register(NewGitLabReleaseManager(token, tokenSource))
+	manager.Register(NewGenericPackageManager(token, tokenSource))
 }
 
 // detectGitLabToken checks multiple environment variables for GitLab tokens
@@ -0,0 +1,100 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// DefaultBaseURL is the web URL of gitlab.com, used when neither a package nor
+// settings.gitlab.base_url names a self-managed instance
+const DefaultBaseURL = "https://gitlab.com"
+
+// instance is the GitLab instance used by packages without a base_url
+var instance = &manager.Instance{
+	Name:           "GitLab",
+	Setting:        "gitlab",
+	TokenExample:   "GITLAB_TOKEN",
+	DefaultBaseURL: DefaultBaseURL,
+	PublicHosts:    []string{"www.gitlab.com"},
+	APIPaths:       []string{"/api/v4", "/api/graphql"},
+}
+
+// SetInstance sets the GitLab instance used by packages without a base_url and the token used
+// for it. The token must reference an environment variable, like settings.credentials.
+func SetInstance(settings types.GitLabSettings) error {
+	return instance.Set(settings.BaseURL, settings.Token)
+}
+
+// BaseURL returns the web URL of the GitLab instance hosting pkg: its base_url, else
+// settings.gitlab.base_url, else https://gitlab.com
+func BaseURL(pkg types.Package) string {
+	return instance.BaseURL(pkg)
+}
+
+// IsSelfManaged reports whether baseURL is a self-managed instance rather than gitlab.com
+func IsSelfManaged(baseURL string) bool {
+	return instance.IsSelfHosted(baseURL)
+}
+
+// WithBaseURL returns a context for the GitLab instance at baseURL, used by WhoAmI
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return instance.WithBaseURL(ctx, baseURL)
+}
+
+// tokenFor returns the token sent to the GitLab instance at baseURL and where it came from.
+// gitlab.com and settings.gitlab.base_url use envToken, found in GITLAB_TOKEN, GL_TOKEN or
+// GITLAB_ACCESS_TOKEN, unless settings.gitlab.token names another variable for the latter.
+// Other instances get no token here: their requests are authenticated by settings.credentials.
+func tokenFor(baseURL, envToken, envSource string) (string, string) {
+	configured, token := instance.Configured()
+	switch {
+	case baseURL == configured && token != "":
+		return os.ExpandEnv(token), strings.Trim(strings.TrimPrefix(token, "$"), "{}")
+	case baseURL == configured || baseURL == DefaultBaseURL:
+		return envToken, envSource
+	}
+	return "", ""
+}
+
+// authenticate adds token to an API request unless it already carries credentials
+func authenticate(req *http.Request, token string) {
+	if token != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// InstanceCredentials returns creds with the token of settings.gitlab.base_url added for its
+// host, so release and package downloads from a self-managed instance are authenticated like
+// its API calls. An entry already configured for the host is kept.
+func InstanceCredentials(settings types.Settings, creds map[string]types.Credential) map[string]types.Credential {
+	return instance.Credentials(creds, func(string) (types.Credential, bool) {
+		token := settings.GitLab.Token
+		if token == "" {
+			if _, source := detectGitLabToken(); source != "" {
+				token = "${" + source + "}"
+			}
+		}
+		return types.Credential{Token: token}, token != ""
+	}, settings.GitLab.BaseURL)
+}
+
+// apiURL returns the REST API root of the GitLab instance at baseURL
+func apiURL(baseURL string) string {
+	return baseURL + "/api/v4"
+}
+
+// graphqlURL returns the GraphQL endpoint of the GitLab instance at baseURL
+func graphqlURL(baseURL string) string {
+	return baseURL + "/api/graphql"
+}
+
+// projectID returns the URL-encoded path of a project, usable wherever the API takes an ID
+func projectID(repo string) string {
+	return url.PathEscape(strings.Trim(repo, "/"))
+}
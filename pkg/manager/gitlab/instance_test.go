@@ -0,0 +1,140 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func setInstance(t *testing.T, settings types.GitLabSettings) {
+	t.Helper()
+	if err := SetInstance(settings); err != nil {
+		t.Fatalf("SetInstance: %v", err)
+	}
+	t.Cleanup(func() { _ = SetInstance(types.GitLabSettings{}) })
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"gitlab.example.com":                     "https://gitlab.example.com",
+		"https://gitlab.example.com/":            "https://gitlab.example.com",
+		"https://gitlab.example.com/api/v4":      "https://gitlab.example.com",
+		"https://gitlab.example.com/api/graphql": "https://gitlab.example.com",
+		"https://example.com/gitlab/api/v4/":     "https://example.com/gitlab",
+		"https://gitlab.com/api/v4":              DefaultBaseURL,
+	}
+	for raw, expected := range tests {
+		if got, err := instance.Normalize(raw); err != nil || got != expected {
+			t.Errorf("Normalize(%q) = %q, %v; expected %q", raw, got, err, expected)
+		}
+	}
+	if _, err := instance.Normalize("ftp://gitlab.example.com"); err == nil {
+		t.Errorf("expected an error for a non-HTTP URL")
+	}
+}
+
+func TestSetInstance(t *testing.T) {
+	if err := SetInstance(types.GitLabSettings{Token: "glpat-secret"}); err == nil {
+		t.Errorf("expected a literal token to be rejected")
+	}
+
+	setInstance(t, types.GitLabSettings{BaseURL: "https://gitlab.example.com/api/v4", Token: "${TEST_GITLAB_TOKEN}"})
+	t.Setenv("TEST_GITLAB_TOKEN", "instance-token")
+
+	if got := BaseURL(types.Package{}); got != "https://gitlab.example.com" {
+		t.Errorf("expected the settings instance, got %s", got)
+	}
+	if got := BaseURL(types.Package{BaseURL: "https://git.internal/"}); got != "https://git.internal" {
+		t.Errorf("expected the package base_url, got %s", got)
+	}
+
+	if token, source := tokenFor("https://gitlab.example.com", "env-token", "GITLAB_TOKEN"); token != "instance-token" || source != "TEST_GITLAB_TOKEN" {
+		t.Errorf("expected settings.gitlab.token for the settings instance, got %q from %q", token, source)
+	}
+	if token, _ := tokenFor(DefaultBaseURL, "env-token", "GITLAB_TOKEN"); token != "env-token" {
+		t.Errorf("expected GITLAB_TOKEN for gitlab.com, got %q", token)
+	}
+	if token, _ := tokenFor("https://git.internal", "env-token", "GITLAB_TOKEN"); token != "" {
+		t.Errorf("expected no token for other instances, got %q", token)
+	}
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	settings := types.Settings{GitLab: types.GitLabSettings{BaseURL: "https://gitlab.example.com", Token: "${TEST_GITLAB_TOKEN}"}}
+	creds := InstanceCredentials(settings, map[string]types.Credential{"nexus.example.com": {Token: "${NEXUS}"}})
+	if creds["gitlab.example.com"].Token != "${TEST_GITLAB_TOKEN}" {
+		t.Errorf("expected a credential for the instance, got %+v", creds)
+	}
+	if creds["nexus.example.com"].Token != "${NEXUS}" {
+		t.Errorf("expected existing credentials to be kept, got %+v", creds)
+	}
+
+	configured := map[string]types.Credential{"gitlab.example.com": {Username: "ci", Password: "${PASSWORD}"}}
+	if creds := InstanceCredentials(settings, configured); creds["gitlab.example.com"].Username != "ci" {
+		t.Errorf("expected the configured credential to win, got %+v", creds)
+	}
+
+	if creds := InstanceCredentials(types.Settings{GitLab: types.GitLabSettings{BaseURL: "gitlab.com", Token: "${X}"}}, nil); len(creds) != 0 {
+		t.Errorf("expected no credentials for gitlab.com, got %+v", creds)
+	}
+}
+
+func TestFetchReleasesUsesInstance(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"project": {"releases": {"nodes": [{"tagName": "v2.0.0"}]}}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	setInstance(t, types.GitLabSettings{BaseURL: server.URL, Token: "${TEST_GITLAB_TOKEN}"})
+	t.Setenv("TEST_GITLAB_TOKEN", "instance-token")
+
+	m := &GitLabReleaseManager{client: server.Client(), token: "gitlab-com-token"}
+	releases, err := m.fetchReleases(context.Background(), types.Package{Name: "tool", Repo: "group/tool"})
+	if err != nil {
+		t.Fatalf("fetchReleases: %v", err)
+	}
+	if len(releases) != 1 || releases[0].TagName != "v2.0.0" {
+		t.Errorf("unexpected releases %+v", releases)
+	}
+	if authorization != "Bearer instance-token" {
+		t.Errorf("expected the instance token, got %q", authorization)
+	}
+}
+
+func TestWhoAmI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/user" || r.Header.Get("Authorization") != "Bearer env-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("RateLimit-Remaining", "1999")
+		w.Header().Set("RateLimit-Limit", "2000")
+		_, _ = w.Write([]byte(`{"username": "jdoe", "name": "J. Doe"}`))
+	}))
+	t.Cleanup(server.Close)
+	setInstance(t, types.GitLabSettings{BaseURL: server.URL})
+
+	status := whoAmI(WithBaseURL(context.Background(), server.URL), server.Client(), "env-token", "GITLAB_TOKEN")
+	if !status.Authenticated || status.User == nil || status.User.Username != "jdoe" {
+		t.Fatalf("expected to be authenticated as jdoe, got %+v", status)
+	}
+	if status.TokenSource != "GITLAB_TOKEN" || status.Service != "GitLab ("+server.URL+")" {
+		t.Errorf("unexpected token source %q or service %q", status.TokenSource, status.Service)
+	}
+	if status.RateLimit == nil || status.RateLimit.Remaining != 1999 || status.RateLimit.Total != 2000 {
+		t.Errorf("unexpected rate limit %+v", status.RateLimit)
+	}
+
+	if status := whoAmI(WithBaseURL(context.Background(), server.URL), server.Client(), "", ""); status.Authenticated || status.Error == "" {
+		t.Errorf("expected an error without a token, got %+v", status)
+	}
+}
@@ -0,0 +1,322 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flanksource/deps/pkg/extract"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/platform"
+	depstemplate "github.com/flanksource/deps/pkg/template"
+	"github.com/flanksource/deps/pkg/types"
+	"github.com/flanksource/deps/pkg/version"
+)
+
+// maxPackagePages caps the pages of 100 package versions listed per package
+const maxPackagePages = 10
+
+// GenericPackageManager implements the PackageManager interface for files published to the
+// Generic Packages registry of a GitLab project. Repo is the project path and
+// extra.package_name the name of the package, which defaults to the name of the dependency.
+type GenericPackageManager struct {
+	client      *http.Client
+	token       string
+	tokenSource string
+}
+
+// GenericPackage is a version of a package in the Generic Packages registry
+type GenericPackage struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	PackageType string    `json:"package_type"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GenericPackageFile is a file of a package version
+type GenericPackageFile struct {
+	ID         int64  `json:"id"`
+	FileName   string `json:"file_name"`
+	Size       int64  `json:"size"`
+	FileSHA256 string `json:"file_sha256"`
+}
+
+// NewGenericPackageManager creates a new GitLab generic package manager
+func NewGenericPackageManager(token, tokenSource string) *GenericPackageManager {
+	return &GenericPackageManager{
+		client:      depshttp.GetHttpClient(depshttp.WithResponseCache()),
+		token:       token,
+		tokenSource: tokenSource,
+	}
+}
+
+// Name returns the manager identifier
+func (m *GenericPackageManager) Name() string {
+	return "gitlab_package"
+}
+
+// DiscoverVersions returns the most recent versions of the package in the registry
+func (m *GenericPackageManager) DiscoverVersions(ctx context.Context, pkg types.Package, plat platform.Platform, limit int) ([]types.Version, error) {
+	packages, err := m.listPackages(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]types.Version, 0, len(packages))
+	for _, p := range packages {
+		v := types.ParseVersion(version.Normalize(p.Version), p.Version)
+		v.Published = p.CreatedAt
+		versions = append(versions, v)
+	}
+	return version.FinalizeDiscoveredVersions(versions, pkg, limit)
+}
+
+// Resolve finds the file of a package version for the platform. The SHA-256 the registry keeps
+// for each file is used as the checksum, so locking needs no download.
+func (m *GenericPackageManager) Resolve(ctx context.Context, pkg types.Package, versionStr string, plat platform.Platform) (*types.Resolution, error) {
+	packages, err := m.listPackages(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	target := findPackageVersion(packages, versionStr)
+	if target == nil {
+		return nil, &manager.ErrVersionNotFound{Package: pkg.Name, Version: versionStr}
+	}
+
+	files, err := m.listPackageFiles(ctx, pkg, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := selectPackageFile(pkg, target.Version, files, plat)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL := fmt.Sprintf("%s/projects/%s/packages/generic/%s/%s/%s", apiURL(BaseURL(pkg)), projectID(pkg.Repo),
+		url.PathEscape(packageName(pkg)), url.PathEscape(target.Version), url.PathEscape(file.FileName))
+	resolution := &types.Resolution{
+		Package:     pkg,
+		Version:     target.Version,
+		Platform:    plat,
+		DownloadURL: downloadURL,
+		Size:        file.Size,
+		IsArchive:   extract.IsArchive(file.FileName),
+		BinaryPath:  pkg.BinaryPath,
+	}
+	if pkg.Extract != nil {
+		resolution.IsArchive = *pkg.Extract
+	}
+	if file.FileSHA256 != "" {
+		resolution.Checksum = "sha256:" + file.FileSHA256
+	}
+	return resolution, nil
+}
+
+// Install downloads and installs a binary for the given resolution
+func (m *GenericPackageManager) Install(ctx context.Context, resolution *types.Resolution, opts types.InstallOptions) error {
+	return fmt.Errorf("install not implemented for GitLab generic package manager")
+}
+
+// GetChecksums returns the SHA-256 of every file of a package version, keyed by file name
+func (m *GenericPackageManager) GetChecksums(ctx context.Context, pkg types.Package, versionStr string) (map[string]string, error) {
+	packages, err := m.listPackages(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	target := findPackageVersion(packages, versionStr)
+	if target == nil {
+		return nil, &manager.ErrVersionNotFound{Package: pkg.Name, Version: versionStr}
+	}
+	files, err := m.listPackageFiles(ctx, pkg, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(files))
+	for _, file := range files {
+		if file.FileSHA256 != "" {
+			checksums[file.FileName] = "sha256:" + file.FileSHA256
+		}
+	}
+	return checksums, nil
+}
+
+// Verify checks if an installed binary matches the expected version/checksum
+func (m *GenericPackageManager) Verify(ctx context.Context, binaryPath string, pkg types.Package) (*types.InstalledInfo, error) {
+	return nil, fmt.Errorf("verify not implemented for GitLab generic package manager")
+}
+
+// WhoAmI returns the authentication status for the GitLab instance of ctx, from WithBaseURL
+func (m *GenericPackageManager) WhoAmI(ctx context.Context) *types.AuthStatus {
+	return whoAmI(ctx, m.client, m.token, m.tokenSource)
+}
+
+// listPackages returns the versions of the package, newest first, without duplicates and
+// versions that are still being uploaded
+func (m *GenericPackageManager) listPackages(ctx context.Context, pkg types.Package) ([]GenericPackage, error) {
+	if pkg.Repo == "" {
+		return nil, fmt.Errorf("package %s has no GitLab project specified in repo", pkg.Name)
+	}
+	name := packageName(pkg)
+	query := url.Values{
+		"package_type": {"generic"},
+		"package_name": {name},
+		"order_by":     {"created_at"},
+		"sort":         {"desc"},
+		"per_page":     {"100"},
+	}
+
+	var packages []GenericPackage
+	seen := make(map[string]bool)
+	for page := 1; page <= maxPackagePages; page++ {
+		query.Set("page", fmt.Sprint(page))
+		var batch []GenericPackage
+		next, err := m.get(ctx, pkg, fmt.Sprintf("/projects/%s/packages?%s", projectID(pkg.Repo), query.Encode()), &batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list GitLab packages of %s: %w", pkg.Repo, err)
+		}
+		for _, p := range batch {
+			// package_name matches partially, e.g. "cli" also lists "cli-plugins"
+			if p.Name != name || seen[p.Version] || (p.Status != "" && p.Status != "default") {
+				continue
+			}
+			seen[p.Version] = true
+			packages = append(packages, p)
+		}
+		if next == "" {
+			break
+		}
+	}
+	return packages, nil
+}
+
+// listPackageFiles returns the files of a package version. A file uploaded again replaces the
+// earlier upload of the same name.
+func (m *GenericPackageManager) listPackageFiles(ctx context.Context, pkg types.Package, packageID int64) ([]GenericPackageFile, error) {
+	var files []GenericPackageFile
+	if _, err := m.get(ctx, pkg, fmt.Sprintf("/projects/%s/packages/%d/package_files?per_page=100", projectID(pkg.Repo), packageID), &files); err != nil {
+		return nil, fmt.Errorf("failed to list files of GitLab package %s: %w", packageName(pkg), err)
+	}
+
+	latest := make(map[string]int, len(files))
+	var unique []GenericPackageFile
+	for _, file := range files {
+		if i, ok := latest[file.FileName]; ok {
+			unique[i] = file
+			continue
+		}
+		latest[file.FileName] = len(unique)
+		unique = append(unique, file)
+	}
+	return unique, nil
+}
+
+// get decodes the response of a REST API request to the GitLab instance of pkg into result and
+// returns the next page, if any
+func (m *GenericPackageManager) get(ctx context.Context, pkg types.Package, endpoint string, result interface{}) (string, error) {
+	baseURL := BaseURL(pkg)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL(baseURL)+endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	token, _ := tokenFor(baseURL, m.token, m.tokenSource)
+	authenticate(req, token)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusNotFound:
+		// GitLab answers 404 for private projects the token cannot see
+		return "", fmt.Errorf("%s returned %s, check that the project exists and the token for %s can read it", endpoint, resp.Status, baseURL)
+	default:
+		return "", fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", endpoint, err)
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+// packageName returns the name of the generic package: extra.package_name, else the dependency name
+func packageName(pkg types.Package) string {
+	if name, ok := pkg.Extra["package_name"].(string); ok && name != "" {
+		return name
+	}
+	return pkg.Name
+}
+
+// findPackageVersion returns the package version matching versionStr exactly or once normalized
+func findPackageVersion(packages []GenericPackage, versionStr string) *GenericPackage {
+	for i := range packages {
+		if packages[i].Version == versionStr {
+			return &packages[i]
+		}
+	}
+	for i := range packages {
+		if version.Normalize(packages[i].Version) == version.Normalize(versionStr) {
+			return &packages[i]
+		}
+	}
+	return nil
+}
+
+// selectPackageFile picks the file for plat: the one named by asset_patterns, or else the only
+// file left after filtering by OS and architecture
+func selectPackageFile(pkg types.Package, versionStr string, files []GenericPackageFile, plat platform.Platform) (*GenericPackageFile, error) {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.FileName)
+	}
+	if len(files) == 0 {
+		return nil, &manager.ErrAssetNotFound{Package: pkg.Name, Platform: plat.String()}
+	}
+
+	if len(pkg.AssetPatterns) > 0 {
+		pattern, err := manager.ResolveAssetPattern(pkg.AssetPatterns, plat, pkg.Name)
+		if err != nil {
+			return nil, err
+		}
+		fileName, err := depstemplate.TemplateURL(pattern, versionStr, plat.OS, plat.Arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to template asset pattern: %w", err)
+		}
+		for i := range files {
+			if files[i].FileName == fileName {
+				return &files[i], nil
+			}
+		}
+		assetErr := &manager.ErrAssetNotFound{Package: pkg.Name, AssetPattern: fileName, Platform: plat.String(), AvailableAssets: names}
+		return nil, manager.EnhanceAssetNotFoundError(pkg.Name, fileName, plat.String(), names, assetErr)
+	}
+
+	if len(files) == 1 {
+		return &files[0], nil
+	}
+	assets := make([]manager.AssetInfo, len(files))
+	for i, file := range files {
+		assets[i] = manager.AssetInfo{Name: file.FileName, SHA256: file.FileSHA256}
+	}
+	filtered, err := manager.FilterAssetsByPlatform(assets, plat.OS, plat.Arch)
+	if err == nil && len(filtered) == 1 {
+		for i := range files {
+			if files[i].FileName == filtered[0].Name {
+				return &files[i], nil
+			}
+		}
+	}
+	pattern := fmt.Sprintf("(no asset_patterns, %s)", strings.Join(names, ", "))
+	assetErr := &manager.ErrAssetNotFound{Package: pkg.Name, AssetPattern: pattern, Platform: plat.String(), AvailableAssets: names}
+	return nil, manager.EnhanceAssetNotFoundError(pkg.Name, pattern, plat.String(), names, assetErr)
+}
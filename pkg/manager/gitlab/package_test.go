@@ -0,0 +1,111 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/manager"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func newGenericPackageServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected no token for an instance without settings, got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Ftools/packages":
+			if r.URL.Query().Get("package_type") != "generic" || r.URL.Query().Get("package_name") != "cli" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				_, _ = w.Write([]byte(`[
+					{"id": 3, "name": "cli", "version": "1.2.0", "status": "processing", "created_at": "2024-03-01T00:00:00Z"},
+					{"id": 2, "name": "cli", "version": "1.1.0", "status": "default", "created_at": "2024-02-01T00:00:00Z"},
+					{"id": 9, "name": "cli-plugins", "version": "5.0.0", "status": "default", "created_at": "2024-02-01T00:00:00Z"}
+				]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "cli", "version": "1.0.0", "status": "default", "created_at": "2024-01-01T00:00:00Z"}]`))
+		case "/api/v4/projects/group%2Ftools/packages/2/package_files":
+			_, _ = w.Write([]byte(`[
+				{"id": 10, "file_name": "cli-linux-amd64.tar.gz", "size": 10, "file_sha256": "old"},
+				{"id": 11, "file_name": "cli-darwin-arm64.tar.gz", "size": 20, "file_sha256": "bbb"},
+				{"id": 12, "file_name": "cli-linux-amd64.tar.gz", "size": 30, "file_sha256": "aaa"}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGenericPackageManager(t *testing.T) {
+	server := newGenericPackageServer(t)
+	m := &GenericPackageManager{client: server.Client(), token: "gitlab-com-token"}
+	pkg := types.Package{Name: "cli", Manager: "gitlab_package", Repo: "group/tools", BaseURL: server.URL}
+	ctx := context.Background()
+	linux := platform.Platform{OS: "linux", Arch: "amd64"}
+
+	versions, err := m.DiscoverVersions(ctx, pkg, linux, 0)
+	if err != nil {
+		t.Fatalf("DiscoverVersions: %v", err)
+	}
+	var found []string
+	for _, v := range versions {
+		found = append(found, v.Tag)
+	}
+	if len(found) != 2 || found[0] != "1.1.0" || found[1] != "1.0.0" {
+		t.Errorf("expected the published versions of cli, got %v", found)
+	}
+
+	resolution, err := m.Resolve(ctx, pkg, "v1.1.0", linux)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	expectedURL := server.URL + "/api/v4/projects/group%2Ftools/packages/generic/cli/1.1.0/cli-linux-amd64.tar.gz"
+	if resolution.DownloadURL != expectedURL {
+		t.Errorf("expected %s, got %s", expectedURL, resolution.DownloadURL)
+	}
+	if resolution.Checksum != "sha256:aaa" || resolution.Size != 30 || !resolution.IsArchive {
+		t.Errorf("expected the latest upload of the file, got %+v", resolution)
+	}
+
+	pkg.AssetPatterns = map[string]string{"darwin-arm64": "cli-{{.os}}-{{.arch}}.tar.gz"}
+	resolution, err = m.Resolve(ctx, pkg, "1.1.0", platform.Platform{OS: "darwin", Arch: "arm64"})
+	if err != nil {
+		t.Fatalf("Resolve with asset_patterns: %v", err)
+	}
+	if resolution.Checksum != "sha256:bbb" {
+		t.Errorf("expected the file named by asset_patterns, got %+v", resolution)
+	}
+
+	checksums, err := m.GetChecksums(ctx, pkg, "1.1.0")
+	if err != nil {
+		t.Fatalf("GetChecksums: %v", err)
+	}
+	if len(checksums) != 2 || checksums["cli-linux-amd64.tar.gz"] != "sha256:aaa" {
+		t.Errorf("unexpected checksums %v", checksums)
+	}
+
+	var notFound *manager.ErrVersionNotFound
+	if _, err := m.Resolve(ctx, pkg, "1.2.0", linux); !errors.As(err, &notFound) {
+		t.Errorf("expected versions still being uploaded to be skipped, got %v", err)
+	}
+}
+
+func TestGenericPackageName(t *testing.T) {
+	if name := packageName(types.Package{Name: "tool"}); name != "tool" {
+		t.Errorf("expected the dependency name, got %s", name)
+	}
+	if name := packageName(types.Package{Name: "tool", Extra: map[string]interface{}{"package_name": "tool-bin"}}); name != "tool-bin" {
+		t.Errorf("expected extra.package_name, got %s", name)
+	}
+}
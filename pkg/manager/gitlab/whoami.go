@@ -0,0 +1,86 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// gitlabUser is the authenticated user returned by GET /user
+type gitlabUser struct {
+	Username     string     `json:"username"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	Organization string     `json:"organization"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+// whoAmI checks the token for the GitLab instance of ctx against GET /user
+func whoAmI(ctx context.Context, client *http.Client, envToken, envSource string) *types.AuthStatus {
+	baseURL := instance.BaseURLFromContext(ctx)
+	token, source := tokenFor(baseURL, envToken, envSource)
+	status := &types.AuthStatus{
+		Service:     instance.ServiceName(baseURL),
+		TokenSource: source,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL(baseURL)+"/user", nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	authenticate(req, token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		status.Error = fmt.Sprintf("Failed to get user info: %v", err)
+		return status
+	}
+	defer func() { _ = resp.Body.Close() }()
+	status.RateLimit = extractRateLimit(resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		status.Error = fmt.Sprintf("Failed to get user info: %s", resp.Status)
+		return status
+	}
+	var user gitlabUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		status.Error = fmt.Sprintf("Failed to decode user info: %v", err)
+		return status
+	}
+
+	status.Authenticated = true
+	status.HasPermissions = true
+	if status.TokenSource == "" {
+		// Authenticated by an entry in settings.credentials for the host
+		status.TokenSource = "settings.credentials"
+	}
+	status.User = &types.UserInfo{
+		Username:  user.Username,
+		Name:      user.Name,
+		Email:     user.Email,
+		Company:   user.Organization,
+		CreatedAt: user.CreatedAt,
+	}
+	return status
+}
+
+// extractRateLimit reads the RateLimit-* headers GitLab sends when rate limiting is enabled
+func extractRateLimit(header http.Header) *types.RateLimit {
+	remaining, err := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if err != nil {
+		return nil
+	}
+	limit, _ := strconv.Atoi(header.Get("RateLimit-Limit"))
+	rateLimit := &types.RateLimit{Remaining: remaining, Total: limit}
+	if reset, err := strconv.ParseInt(header.Get("RateLimit-Reset"), 10, 64); err == nil {
+		resetTime := time.Unix(reset, 0)
+		rateLimit.ResetTime = &resetTime
+	}
+	return rateLimit
+}
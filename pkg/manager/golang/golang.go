@@ -77,7 +77,7 @@ func (m *GoManager) Install(ctx context.Context, resolution *types.Resolution, o
 
 	// Run go install
 	cmd := exec.CommandContext(ctx, "go", "install", installTarget)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("GOBIN=%s", gobin))
+	cmd.Env = installEnv(gobin, opts)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -88,6 +88,16 @@ func (m *GoManager) Install(ctx context.Context, resolution *types.Resolution, o
 	return nil
 }
 
+// installEnv returns the environment of go install into gobin
+func installEnv(gobin string, opts types.InstallOptions) []string {
+	env := append(os.Environ(), fmt.Sprintf("GOBIN=%s", gobin))
+	if opts.Offline {
+		// Build only from the local module cache
+		env = append(env, "GOPROXY=off")
+	}
+	return env
+}
+
 // GetChecksums is not applicable for Go packages (installed from source)
 func (m *GoManager) GetChecksums(ctx context.Context, pkg types.Package, version string) (map[string]string, error) {
 	// Go packages are built from source, so checksums are not applicable
@@ -84,6 +84,14 @@ var _ = Describe("GoManager", func() {
 		})
 	})
 
+	Describe("installEnv", func() {
+		It("turns off the module proxy for offline installs", func() {
+			GinkgoT().Setenv("GOPROXY", "https://proxy.golang.org")
+			Expect(installEnv("/bin", types.InstallOptions{Offline: true})).To(ContainElements("GOBIN=/bin", "GOPROXY=off"))
+			Expect(installEnv("/bin", types.InstallOptions{})).NotTo(ContainElement("GOPROXY=off"))
+		})
+	})
+
 	Describe("Resolve", func() {
 		It("should resolve a go package version", func() {
 			pkg := types.Package{
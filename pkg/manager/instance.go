@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+// Instance is a forge packages are fetched from, such as GitHub, GitLab or Gitea: a public
+// instance, which settings may replace with a self-hosted one, and which a package's base_url
+// overrides.
+type Instance struct {
+	// Name names the forge, e.g. GitHub
+	Name string
+	// SelfHostedName names a self-hosted instance in whoami, e.g. GitHub Enterprise; defaults to Name
+	SelfHostedName string
+	// Setting is the key of the forge under settings, e.g. github for settings.github.base_url
+	Setting string
+	// TokenExample is the environment variable suggested when a token is configured literally
+	TokenExample string
+	// DefaultBaseURL is the web URL of the public instance, e.g. https://github.com
+	DefaultBaseURL string
+	// PublicHosts are other hosts that mean the public instance, e.g. api.github.com
+	PublicHosts []string
+	// APIPaths are the API roots accepted in place of the web URL, e.g. /api/v3
+	APIPaths []string
+
+	mu      sync.RWMutex
+	baseURL string
+	token   string
+}
+
+type instanceKey struct{ instance *Instance }
+
+// Set sets the instance used by packages without a base_url and the token used for it. The
+// token must reference an environment variable, like settings.credentials.
+func (in *Instance) Set(baseURL, token string) error {
+	base := in.DefaultBaseURL
+	if baseURL != "" {
+		var err error
+		if base, err = in.Normalize(baseURL); err != nil {
+			return fmt.Errorf("settings.%s.base_url: %w", in.Setting, err)
+		}
+	}
+	if token != "" && !strings.Contains(token, "$") {
+		return fmt.Errorf("settings.%s.token must reference an environment variable such as ${%s}, not contain the token", in.Setting, in.TokenExample)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.baseURL = base
+	in.token = token
+	return nil
+}
+
+// Configured returns the instance and token set by Set
+func (in *Instance) Configured() (baseURL, token string) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.baseURL == "" {
+		return in.DefaultBaseURL, ""
+	}
+	return in.baseURL, in.token
+}
+
+// BaseURL returns the web URL of the instance hosting pkg: its base_url, else the one from
+// settings, else the public instance
+func (in *Instance) BaseURL(pkg types.Package) string {
+	if pkg.BaseURL != "" {
+		if base, err := in.Normalize(pkg.BaseURL); err == nil {
+			return base
+		}
+		return strings.TrimSuffix(pkg.BaseURL, "/")
+	}
+	base, _ := in.Configured()
+	return base
+}
+
+// IsSelfHosted reports whether baseURL is a self-hosted instance rather than the public one
+func (in *Instance) IsSelfHosted(baseURL string) bool {
+	return baseURL != "" && baseURL != in.DefaultBaseURL
+}
+
+// WithBaseURL returns a context whose requests go to the instance at baseURL
+func (in *Instance) WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, instanceKey{in}, baseURL)
+}
+
+// BaseURLFromContext returns the base URL set by WithBaseURL, or the one from settings
+func (in *Instance) BaseURLFromContext(ctx context.Context) string {
+	if base, ok := ctx.Value(instanceKey{in}).(string); ok && base != "" {
+		return base
+	}
+	return in.BaseURL(types.Package{})
+}
+
+// ServiceName names the instance at baseURL for whoami
+func (in *Instance) ServiceName(baseURL string) string {
+	if !in.IsSelfHosted(baseURL) {
+		return in.Name
+	}
+	name := in.SelfHostedName
+	if name == "" {
+		name = in.Name
+	}
+	return fmt.Sprintf("%s (%s)", name, baseURL)
+}
+
+// Normalize returns the web URL of an instance without a trailing slash, accepting a bare host
+// or one of its API URLs
+func (in *Instance) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("invalid %s base URL %q, use e.g. https://%s.example.com", in.Name, raw, strings.ToLower(in.Name))
+	}
+	if public, _ := url.Parse(in.DefaultBaseURL); public != nil && strings.EqualFold(u.Host, public.Host) {
+		return in.DefaultBaseURL, nil
+	}
+	for _, host := range in.PublicHosts {
+		if strings.EqualFold(u.Host, host) {
+			return in.DefaultBaseURL, nil
+		}
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	for _, api := range in.APIPaths {
+		path = strings.TrimSuffix(path, api)
+	}
+	return u.Scheme + "://" + u.Host + path, nil
+}
+
+// Credentials returns creds with a credential added for the host of each self-hosted instance
+// in baseURLs, so downloads from it are authenticated like its API calls. credential returns the
+// credential for a normalized base URL, if there is one. An entry already configured for a host
+// is kept.
+func (in *Instance) Credentials(creds map[string]types.Credential, credential func(baseURL string) (types.Credential, bool), baseURLs ...string) map[string]types.Credential {
+	merged, copied := creds, false
+	for _, baseURL := range baseURLs {
+		if baseURL == "" {
+			continue
+		}
+		base, err := in.Normalize(baseURL)
+		if err != nil || !in.IsSelfHosted(base) {
+			continue
+		}
+		host := strings.TrimPrefix(strings.TrimPrefix(base, "https://"), "http://")
+		if _, ok := merged[host]; ok {
+			continue
+		}
+		cred, ok := credential(base)
+		if !ok {
+			continue
+		}
+
+		if !copied {
+			// Copy before the first addition, leaving creds untouched
+			merged, copied = make(map[string]types.Credential, len(creds)+len(baseURLs)), true
+			for pattern, existing := range creds {
+				merged[pattern] = existing
+			}
+		}
+		merged[host] = cred
+	}
+	return merged
+}
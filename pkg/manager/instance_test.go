@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/flanksource/deps/pkg/types"
+)
+
+var _ = Describe("Instance", func() {
+	var forge *Instance
+
+	BeforeEach(func() {
+		forge = &Instance{
+			Name:           "Forge",
+			SelfHostedName: "Forge Server",
+			Setting:        "forge",
+			TokenExample:   "FORGE_TOKEN",
+			DefaultBaseURL: "https://forge.com",
+			PublicHosts:    []string{"api.forge.com"},
+			APIPaths:       []string{"/api/v1"},
+		}
+	})
+
+	It("normalizes base URLs", func() {
+		for raw, expected := range map[string]string{
+			"forge.example.com":                 "https://forge.example.com",
+			"https://forge.example.com/api/v1/": "https://forge.example.com",
+			"http://example.com/forge/":         "http://example.com/forge",
+			"https://api.forge.com":             "https://forge.com",
+			"FORGE.COM":                         "https://forge.com",
+		} {
+			Expect(forge.Normalize(raw)).To(Equal(expected), raw)
+		}
+		_, err := forge.Normalize("ftp://forge.example.com")
+		Expect(err).To(MatchError(ContainSubstring("invalid Forge base URL")))
+	})
+
+	It("takes the base URL from the package, then settings, then the public instance", func() {
+		Expect(forge.BaseURL(types.Package{})).To(Equal("https://forge.com"))
+		Expect(forge.Set("forge.example.com/api/v1", "${FORGE_TOKEN}")).To(Succeed())
+		Expect(forge.BaseURL(types.Package{})).To(Equal("https://forge.example.com"))
+		Expect(forge.BaseURL(types.Package{BaseURL: "https://git.internal/"})).To(Equal("https://git.internal"))
+		base, token := forge.Configured()
+		Expect(base).To(Equal("https://forge.example.com"))
+		Expect(token).To(Equal("${FORGE_TOKEN}"))
+
+		ctx := forge.WithBaseURL(context.Background(), "https://git.internal")
+		Expect(forge.BaseURLFromContext(ctx)).To(Equal("https://git.internal"))
+		Expect(forge.BaseURLFromContext(context.Background())).To(Equal("https://forge.example.com"))
+		Expect(forge.ServiceName("https://git.internal")).To(Equal("Forge Server (https://git.internal)"))
+		Expect(forge.ServiceName("https://forge.com")).To(Equal("Forge"))
+	})
+
+	It("rejects settings that would reveal a token", func() {
+		err := forge.Set("forge.example.com", "secret")
+		Expect(err).To(MatchError(ContainSubstring("settings.forge.token must reference an environment variable such as ${FORGE_TOKEN}")))
+		Expect(err.Error()).NotTo(ContainSubstring("secret"))
+		Expect(forge.Set("ftp://forge.example.com", "")).To(MatchError(ContainSubstring("settings.forge.base_url")))
+	})
+
+	It("adds credentials for self-hosted instances only", func() {
+		credential := func(baseURL string) (types.Credential, bool) {
+			return types.Credential{Token: "${FORGE_TOKEN}"}, true
+		}
+		creds := forge.Credentials(map[string]types.Credential{"nexus.example.com": {Token: "${NEXUS}"}}, credential, "forge.example.com")
+		Expect(creds).To(HaveKeyWithValue("forge.example.com", types.Credential{Token: "${FORGE_TOKEN}"}))
+		Expect(creds).To(HaveKey("nexus.example.com"))
+
+		configured := map[string]types.Credential{"forge.example.com": {Username: "ci"}}
+		Expect(forge.Credentials(configured, credential, "forge.example.com")).To(Equal(configured))
+		Expect(forge.Credentials(nil, credential, "api.forge.com", "")).To(BeEmpty())
+	})
+
+	It("adds a credential for every distinct self-hosted instance", func() {
+		var asked []string
+		credential := func(baseURL string) (types.Credential, bool) {
+			asked = append(asked, baseURL)
+			return types.Credential{Token: "${FORGE_TOKEN}"}, baseURL != "https://git.internal"
+		}
+		creds := forge.Credentials(nil, credential, "forge.example.com", "https://forge.example.com/api/v1", "https://other.example.com/", "git.internal")
+		Expect(creds).To(HaveLen(2))
+		Expect(creds).To(HaveKey("forge.example.com"))
+		Expect(creds).To(HaveKey("other.example.com"))
+		Expect(asked).To(Equal([]string{"https://forge.example.com", "https://other.example.com", "https://git.internal"}))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/deps/pkg/platform"
 	"github.com/flanksource/deps/pkg/types"
 )
@@ -72,6 +73,12 @@ type PackageManager interface {
 	Verify(ctx context.Context, binaryPath string, pkg types.Package) (*types.InstalledInfo, error)
 }
 
+// VersionPrefetcher is implemented by managers that can fetch the versions of many packages at
+// once, so that the DiscoverVersions and Resolve calls that follow need fewer requests
+type VersionPrefetcher interface {
+	PrefetchVersions(ctx context.Context, pkgs []types.Package) error
+}
+
 // Registry holds all registered package managers
 type Registry struct {
 	managers map[string]PackageManager
@@ -113,6 +120,24 @@ func (r *Registry) GetForPackage(pkg types.Package) (PackageManager, error) {
 	return manager, nil
 }
 
+// PrefetchVersions lets each manager implementing VersionPrefetcher fetch the versions of its
+// packages in pkgs up front. Failures are only logged: the packages are then resolved one by one.
+func (r *Registry) PrefetchVersions(ctx context.Context, pkgs []types.Package) {
+	byManager := make(map[string][]types.Package)
+	for _, pkg := range pkgs {
+		byManager[pkg.Manager] = append(byManager[pkg.Manager], pkg)
+	}
+	for name, managerPkgs := range byManager {
+		prefetcher, ok := r.managers[name].(VersionPrefetcher)
+		if !ok {
+			continue
+		}
+		if err := prefetcher.PrefetchVersions(ctx, managerPkgs); err != nil {
+			logger.V(2).Infof("Prefetching versions of %d %s packages failed, resolving them one by one: %v", len(managerPkgs), name, err)
+		}
+	}
+}
+
 // Errors
 
 // ErrManagerNotFound is returned when a package manager is not found
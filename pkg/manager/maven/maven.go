@@ -48,7 +48,7 @@ type MavenCoordinates struct {
 // NewMavenManager creates a new Maven manager
 func NewMavenManager() *MavenManager {
 	return &MavenManager{
-		client: depshttp.GetHttpClient(),
+		client: depshttp.GetHttpClient(depshttp.WithResponseCache()),
 	}
 }
 
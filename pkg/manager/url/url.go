@@ -36,7 +36,7 @@ type URLManager struct {
 // NewURLManager creates a new URL manager
 func NewURLManager() *URLManager {
 	return &URLManager{
-		client:          depshttp.GetHttpClient(),
+		client:          depshttp.GetHttpClient(depshttp.WithResponseCache()),
 		versionMetadata: make(map[string]map[string]*versionMetadata),
 	}
 }
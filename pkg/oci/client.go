@@ -0,0 +1,401 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	depshttp "github.com/flanksource/deps/pkg/http"
+	"github.com/flanksource/deps/pkg/platform"
+)
+
+const (
+	// maxManifestSize is the largest manifest read, like the 4 MiB limit of registries
+	maxManifestSize = 4 << 20
+	// maxTagPages caps the pages of tags listed for a repository
+	maxTagPages = 20
+	// blobReadTimeout is how long a blob download may go without receiving any data. Blobs have
+	// no overall timeout, as large layers take longer than any fixed limit on slow connections.
+	blobReadTimeout = time.Minute
+)
+
+// ErrNotFound is returned for tags, manifests and blobs the registry does not have
+var ErrNotFound = errors.New("not found")
+
+// errStalled cancels a blob download that stopped receiving data
+var errStalled = errors.New("download stalled")
+
+// linkNext matches the next page in a Link header, e.g. </v2/org/tool/tags/list?last=v1&n=1000>; rel="next"
+var linkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// Client talks to container registries over the distribution HTTP API, without a Docker daemon.
+// Requests go through the client from GetHttpClient, so proxies, mirrors and settings.credentials
+// apply, and registries asking for a bearer token get one from their token service.
+type Client struct {
+	client *http.Client
+	blobs  *http.Client // Without a timeout, blob reads have a deadline of their own instead
+
+	stallTimeout time.Duration // Overrides blobReadTimeout
+
+	mu     sync.Mutex
+	tokens map[string]string // Bearer tokens by registry and repository
+}
+
+// NewClient creates a registry client
+func NewClient() *Client {
+	return &Client{
+		client: depshttp.GetHttpClient(depshttp.WithResponseCache()),
+		blobs:  depshttp.GetHttpClient(depshttp.WithTimeout(0)),
+		tokens: make(map[string]string),
+	}
+}
+
+// Tags lists the tags of the repository of ref
+func (c *Client) Tags(ctx context.Context, ref Reference) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("/v2/%s/tags/list?n=1000", ref.Repository)
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := c.get(ctx, c.client, ref, next, "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", ref.WithTag(""), err)
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tags of %s: %w", ref.WithTag(""), err)
+		}
+		tags = append(tags, list.Tags...)
+
+		next = ""
+		if match := linkNext.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			next = match[1]
+		}
+	}
+	return tags, nil
+}
+
+// Manifest fetches the manifest or index ref points to, by its tag or digest, and returns it
+// with its digest. Manifests fetched by digest are verified against it.
+func (c *Client) Manifest(ctx context.Context, ref Reference) (*Manifest, string, error) {
+	reference := ref.Digest
+	if reference == "" {
+		reference = ref.Tag
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+
+	resp, err := c.get(ctx, c.client, ref, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, reference), strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get manifest of %s: %w", ref, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest of %s: %w", ref, err)
+	}
+	if len(body) > maxManifestSize {
+		return nil, "", fmt.Errorf("manifest of %s is larger than %d bytes", ref, maxManifestSize)
+	}
+
+	sum := sha256.Sum256(body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if ref.Digest != "" && strings.HasPrefix(ref.Digest, "sha256:") && ref.Digest != digest {
+		return nil, "", fmt.Errorf("manifest of %s has digest %s", ref, digest)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to decode manifest of %s: %w", ref, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	return &manifest, digest, nil
+}
+
+// PlatformManifest returns the image manifest for plat and its digest. An image index is
+// resolved to the manifest of the platform; a single manifest is returned as is.
+func (c *Client) PlatformManifest(ctx context.Context, ref Reference, plat platform.Platform) (*Manifest, string, error) {
+	manifest, digest, err := c.Manifest(ctx, ref)
+	if err != nil || !manifest.IsIndex() {
+		return manifest, digest, err
+	}
+
+	desc, err := selectPlatform(ref, manifest, plat)
+	if err != nil {
+		return nil, "", err
+	}
+	platformManifest, platformDigest, err := c.Manifest(ctx, ref.WithDigest(desc.Digest))
+	if err != nil {
+		return nil, "", err
+	}
+	if platformManifest.IsIndex() {
+		return nil, "", fmt.Errorf("%s lists a nested index for %s", ref, plat)
+	}
+	return platformManifest, platformDigest, nil
+}
+
+// ImagePlatform returns the platform an image manifest was built for, from its config blob
+func (c *Client) ImagePlatform(ctx context.Context, ref Reference, manifest *Manifest) (Platform, error) {
+	var config bytes.Buffer
+	if err := c.FetchBlob(ctx, ref, manifest.Config, &config); err != nil {
+		return Platform{}, err
+	}
+	var plat Platform
+	if err := json.Unmarshal(config.Bytes(), &plat); err != nil {
+		return Platform{}, fmt.Errorf("failed to decode the config of %s: %w", ref, err)
+	}
+	if plat.OS == "" || plat.Architecture == "" {
+		return Platform{}, fmt.Errorf("the config of %s does not name its platform", ref)
+	}
+	return plat, nil
+}
+
+// selectPlatform returns the manifest of an index for plat, preferring entries without a variant
+// or with the v8 variant for arm64
+func selectPlatform(ref Reference, index *Manifest, plat platform.Platform) (*Descriptor, error) {
+	var match *Descriptor
+	var available []string
+	for i, desc := range index.Manifests {
+		p := desc.Platform
+		if p == nil || p.OS == "unknown" {
+			// Attestations and other artifacts attached to the index
+			continue
+		}
+		available = append(available, p.String())
+		if p.OS != plat.OS || p.Architecture != plat.Arch {
+			continue
+		}
+		if match == nil || p.Variant == "" || (plat.Arch == "arm64" && p.Variant == "v8") {
+			match = &index.Manifests[i]
+		}
+	}
+	if match == nil {
+		return nil, &ErrPlatformNotFound{Reference: ref, Platform: plat.String(), Available: available}
+	}
+	return match, nil
+}
+
+// FetchBlob copies the blob desc refers to into w, failing when its content does not match the
+// digest or size of desc. The download fails when no data arrives for blobReadTimeout.
+func (c *Client) FetchBlob(ctx context.Context, ref Reference, desc Descriptor, w io.Writer) error {
+	algorithm, expected, ok := strings.Cut(desc.Digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %q of blob in %s", desc.Digest, ref)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	timeout := c.readTimeout()
+	deadline := time.AfterFunc(timeout, func() {
+		cancel(fmt.Errorf("%w, no data received for %s", errStalled, timeout))
+	})
+	defer deadline.Stop()
+
+	resp, err := c.get(ctx, c.blobs, ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, desc.Digest), "")
+	if err != nil {
+		return fmt.Errorf("failed to download blob %s of %s: %w", desc.Digest, ref.WithTag(""), stallCause(ctx, err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	hasher := sha256.New()
+	body := &deadlineReader{r: resp.Body, deadline: deadline, timeout: timeout}
+	n, err := io.Copy(io.MultiWriter(w, hasher), body)
+	if err != nil {
+		return fmt.Errorf("failed to download blob %s of %s: %w", desc.Digest, ref.WithTag(""), stallCause(ctx, err))
+	}
+	if desc.Size > 0 && n != desc.Size {
+		return fmt.Errorf("blob %s of %s has %d bytes, expected %d", desc.Digest, ref.WithTag(""), n, desc.Size)
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		return fmt.Errorf("blob %s of %s has digest sha256:%s", desc.Digest, ref.WithTag(""), actual)
+	}
+	return nil
+}
+
+func (c *Client) readTimeout() time.Duration {
+	if c.stallTimeout > 0 {
+		return c.stallTimeout
+	}
+	return blobReadTimeout
+}
+
+// deadlineReader pushes the deadline of a download back each time data arrives
+type deadlineReader struct {
+	r        io.Reader
+	deadline *time.Timer
+	timeout  time.Duration
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if n > 0 {
+		d.deadline.Reset(d.timeout)
+	}
+	return n, err
+}
+
+// stallCause reports a download cancelled by its read deadline as stalled rather than canceled
+func stallCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errStalled) {
+		return cause
+	}
+	return err
+}
+
+// get sends a GET request for path to the registry of ref with client, authenticating with a
+// bearer token when the registry asks for one, and returns the response when it is a 200
+func (c *Client) get(ctx context.Context, client *http.Client, ref Reference, path, accept string) (*http.Response, error) {
+	tokenKey := ref.Registry + "/" + ref.Repository
+	c.mu.Lock()
+	token := c.tokens[tokenKey]
+	c.mu.Unlock()
+
+	resp, err := c.send(ctx, client, ref.baseURL()+path, accept, token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("%s requires authentication, add credentials for %s to settings.credentials", ref.Registry, ref.Registry)
+		}
+		if token, err = c.fetchToken(ctx, ref, challenge); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.tokens[tokenKey] = token
+		c.mu.Unlock()
+		if resp, err = c.send(ctx, client, ref.baseURL()+path, accept, token); err != nil {
+			return nil, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s denied access to %s (%s), check settings.credentials for %s", ref.Registry, ref.Repository, resp.Status, ref.Registry)
+	default:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s returned %s for %s", ref.Registry, resp.Status, path)
+	}
+}
+
+func (c *Client) send(ctx context.Context, client *http.Client, url, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.Do(req)
+}
+
+// fetchToken gets a pull token from the token service named in a Bearer challenge. The token
+// service is sent the credentials configured for its host in settings.credentials, if any, and
+// hands out anonymous tokens for public repositories otherwise.
+func (c *Client) fetchToken(ctx context.Context, ref Reference, challenge string) (string, error) {
+	params := parseChallenge(challenge[len("bearer "):])
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("%s sent a bearer challenge without a realm", ref.Registry)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("%s sent an invalid token realm %q: %w", ref.Registry, realm, err)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	// Tokens expire within minutes, so they must not come from the response cache
+	req.Header.Set("Cache-Control", "no-store")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token for %s: %w", ref.WithTag(""), err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get a token for %s from %s: %s", ref.WithTag(""), tokenURL.Host, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token for %s: %w", ref.WithTag(""), err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", fmt.Errorf("token service %s returned no token for %s", tokenURL.Host, ref.WithTag(""))
+	}
+	logger.V(4).Infof("Got a token for %s from %s", ref.WithTag(""), tokenURL.Host)
+	return body.Token, nil
+}
+
+// parseChallenge parses the comma separated key="value" parameters of a WWW-Authenticate challenge
+func parseChallenge(params string) map[string]string {
+	result := make(map[string]string)
+	for params != "" {
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(strings.TrimLeft(key, ", ")))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		result[key] = strings.TrimSpace(value)
+		params = strings.TrimLeft(rest, ", ")
+	}
+	return result
+}
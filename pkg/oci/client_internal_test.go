@@ -0,0 +1,36 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchBlobStalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Send part of the blob, then nothing until the client gives up
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.stallTimeout = 100 * time.Millisecond
+	ref := Reference{Registry: strings.TrimPrefix(server.URL, "http://"), Repository: "org/tool"}
+	desc := Descriptor{Digest: "sha256:" + strings.Repeat("0", 64), Size: 1 << 20}
+
+	start := time.Now()
+	err := client.FetchBlob(context.Background(), ref, desc, io.Discard)
+	if !errors.Is(err, errStalled) {
+		t.Fatalf("FetchBlob of a stalled download returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("FetchBlob took %s to notice the stall", elapsed)
+	}
+}
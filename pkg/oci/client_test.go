@@ -0,0 +1,151 @@
+package oci_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/oci"
+	"github.com/flanksource/deps/pkg/oci/ocitest"
+	"github.com/flanksource/deps/pkg/platform"
+)
+
+func parse(t *testing.T, s string) oci.Reference {
+	t.Helper()
+	ref, err := oci.ParseReference(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestTagsWithToken(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	registry.RequireToken("secret")
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "latest"} {
+		registry.PushImage(t, "org/tool", tag, ocitest.Layer(t, ocitest.File{Name: "tool", Content: tag}))
+	}
+
+	client := oci.NewClient()
+	tags, err := client.Tags(context.Background(), parse(t, registry.Host+"/org/tool"))
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "latest,v1.0.0,v1.1.0" {
+		t.Errorf("Tags = %v", tags)
+	}
+
+	// The token is fetched once and reused for later requests
+	if _, _, err := client.Manifest(context.Background(), parse(t, registry.Ref("org/tool", "v1.0.0"))); err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	var tokenRequests int
+	for _, req := range registry.Requests() {
+		if req == "GET /token" {
+			tokenRequests++
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token fetched %d times, want 1: %v", tokenRequests, registry.Requests())
+	}
+
+	if _, _, err := client.Manifest(context.Background(), parse(t, registry.Ref("org/tool", "v9.9.9"))); !errors.Is(err, oci.ErrNotFound) {
+		t.Errorf("Manifest of a missing tag returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestPlatformManifest(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	amd64 := registry.PushImage(t, "org/tool", "", ocitest.Layer(t, ocitest.File{Name: "tool", Content: "amd64"}))
+	arm64 := registry.PushImage(t, "org/tool", "", ocitest.Layer(t, ocitest.File{Name: "tool", Content: "arm64"}))
+	attestation := registry.PushImage(t, "org/tool", "")
+	amd64.Platform = &oci.Platform{OS: "linux", Architecture: "amd64"}
+	arm64.Platform = &oci.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	attestation.Platform = &oci.Platform{OS: "unknown", Architecture: "unknown"}
+	registry.PushManifest(t, "org/tool", "v1.0.0", oci.Manifest{
+		MediaType: oci.MediaTypeImageIndex,
+		Manifests: []oci.Descriptor{amd64, arm64, attestation},
+	})
+
+	client := oci.NewClient()
+	ref := parse(t, registry.Ref("org/tool", "v1.0.0"))
+	manifest, digest, err := client.PlatformManifest(context.Background(), ref, platform.Platform{OS: "linux", Arch: "arm64"})
+	if err != nil {
+		t.Fatalf("PlatformManifest failed: %v", err)
+	}
+	if digest != arm64.Digest || manifest.IsIndex() || len(manifest.Layers) != 1 {
+		t.Errorf("PlatformManifest = %s %+v, want %s", digest, manifest, arm64.Digest)
+	}
+
+	_, _, err = client.PlatformManifest(context.Background(), ref, platform.Platform{OS: "windows", Arch: "amd64"})
+	var platformErr *oci.ErrPlatformNotFound
+	if !errors.As(err, &platformErr) {
+		t.Fatalf("PlatformManifest for a missing platform returned %v", err)
+	}
+	if strings.Join(platformErr.Available, ",") != "linux-amd64,linux-arm64/v8" {
+		t.Errorf("Available = %v", platformErr.Available)
+	}
+}
+
+func TestExtractFile(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	registry.PushImage(t, "org/tool", "v1.0.0",
+		ocitest.Layer(t,
+			ocitest.File{Name: "usr/local/bin/tool-1.0.0", Content: "binary"},
+			ocitest.File{Name: "usr/bin/old", Content: "old"},
+			ocitest.File{Name: "opt/app/config", Content: "lower"},
+		),
+		ocitest.Layer(t,
+			ocitest.File{Name: "usr/local/bin/tool", Symlink: "tool-1.0.0"},
+			ocitest.File{Name: "bin/tool", Symlink: "/usr/local/bin/tool"},
+			ocitest.File{Name: "usr/bin/.wh.old"},
+			ocitest.File{Name: "opt/app/.wh..wh..opq"},
+		),
+	)
+
+	client := oci.NewClient()
+	ref := parse(t, registry.Ref("org/tool", "v1.0.0"))
+	manifest, _, err := client.Manifest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filePath := range []string{"/bin/tool", "usr/local/bin/tool", "tool-1.0.0"} {
+		var buf bytes.Buffer
+		extracted, err := client.ExtractFile(context.Background(), ref, manifest, filePath, &buf)
+		if err != nil {
+			t.Errorf("ExtractFile(%q) failed: %v", filePath, err)
+			continue
+		}
+		if buf.String() != "binary" || extracted != "usr/local/bin/tool-1.0.0" {
+			t.Errorf("ExtractFile(%q) = %s %q", filePath, extracted, buf.String())
+		}
+	}
+
+	for _, filePath := range []string{"usr/bin/old", "opt/app/config", "missing"} {
+		if _, err := client.ExtractFile(context.Background(), ref, manifest, filePath, &bytes.Buffer{}); !errors.Is(err, oci.ErrFileNotFound) {
+			t.Errorf("ExtractFile(%q) returned %v, want ErrFileNotFound", filePath, err)
+		}
+	}
+}
+
+func TestFetchBlobVerifiesDigest(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	layer := ocitest.Layer(t, ocitest.File{Name: "tool", Content: "binary"})
+	registry.PushImage(t, "org/tool", "v1.0.0", layer)
+
+	client := oci.NewClient()
+	ref := parse(t, registry.Ref("org/tool", "v1.0.0"))
+	manifest, _, err := client.Manifest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.ReplaceBlob(manifest.Layers[0].Digest, ocitest.Layer(t, ocitest.File{Name: "tool", Content: "tampered"}))
+
+	_, err = client.ExtractFile(context.Background(), ref, manifest, "tool", &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "has digest") && !strings.Contains(err.Error(), "bytes, expected") {
+		t.Errorf("ExtractFile of a tampered layer returned %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+)
+
+const (
+	// maxLinkHops caps the symlinks followed when extracting a file
+	maxLinkHops = 10
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ErrFileNotFound is returned when no layer of an image contains the file being extracted
+var ErrFileNotFound = errors.New("file not found in image")
+
+// ExtractFile copies the file at filePath in the image filesystem of manifest to w, following
+// symlinks and honouring the whiteouts of upper layers. A filePath without a slash matches the
+// first file with that base name, searching from the top layer down. Layers are downloaded
+// once each and verified against their digests. It returns the path of the file extracted.
+func (c *Client) ExtractFile(ctx context.Context, ref Reference, manifest *Manifest, filePath string, w io.Writer) (string, error) {
+	layers := &layerFiles{client: c, ref: ref, files: make(map[string]string)}
+	defer layers.cleanup()
+
+	target := cleanPath(filePath)
+	byName := !strings.Contains(target, "/")
+	for hop := 0; hop <= maxLinkHops; hop++ {
+		hdr, tr, closer, err := layers.find(ctx, manifest, target, byName)
+		if err != nil {
+			return "", err
+		}
+		if hdr == nil {
+			return "", fmt.Errorf("%s in %s: %w", filePath, ref, ErrFileNotFound)
+		}
+		name := cleanPath(hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			_, err := io.Copy(w, tr)
+			_ = closer.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to extract %s from %s: %w", name, ref, err)
+			}
+			return name, nil
+		case tar.TypeSymlink:
+			_ = closer.Close()
+			if path.IsAbs(hdr.Linkname) {
+				target = cleanPath(hdr.Linkname)
+			} else {
+				target = cleanPath(path.Join(path.Dir(name), hdr.Linkname))
+			}
+		case tar.TypeLink:
+			_ = closer.Close()
+			// Hard links name the path of the file within the image
+			target = cleanPath(hdr.Linkname)
+		default:
+			_ = closer.Close()
+			return "", fmt.Errorf("%s in %s is not a regular file", name, ref)
+		}
+		logger.V(4).Infof("Following link %s -> %s in %s", name, target, ref)
+		byName = false
+	}
+	return "", fmt.Errorf("too many links following %s in %s", filePath, ref)
+}
+
+// layerFiles downloads the layers of an image to temporary files as they are needed
+type layerFiles struct {
+	client *Client
+	ref    Reference
+	files  map[string]string // Temporary file by layer digest
+}
+
+// find searches the layers from the top down for target and returns its header, positioned
+// for reading its content. It returns a nil header when the file does not exist, including when
+// an upper layer deleted it.
+func (l *layerFiles) find(ctx context.Context, manifest *Manifest, target string, byName bool) (*tar.Header, io.Reader, io.Closer, error) {
+	for i := len(manifest.Layers) - 1; i >= 0; i-- {
+		layer := manifest.Layers[i]
+		file, err := l.open(ctx, layer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tr, closer, err := layerReader(file, layer.MediaType)
+		if err != nil {
+			_ = file.Close()
+			return nil, nil, nil, fmt.Errorf("layer %s of %s: %w", layer.Digest, l.ref, err)
+		}
+		closers := multiCloser{closer, file}
+
+		deleted, opaque := false, false
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = closers.Close()
+				return nil, nil, nil, fmt.Errorf("failed to read layer %s of %s: %w", layer.Digest, l.ref, err)
+			}
+			name := cleanPath(hdr.Name)
+			dir, base := path.Split(name)
+			dir = strings.TrimSuffix(dir, "/")
+
+			if byName {
+				if base == target && !strings.HasPrefix(base, whiteoutPrefix) && hdr.Typeflag != tar.TypeDir {
+					return hdr, tr, closers, nil
+				}
+				continue
+			}
+
+			switch {
+			case name == target:
+				return hdr, tr, closers, nil
+			case base == whiteoutOpaque && isParent(dir, target):
+				// The directory was replaced, so lower layers no longer count
+				opaque = true
+			case strings.HasPrefix(base, whiteoutPrefix):
+				if removed := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)); removed == target || isParent(removed, target) {
+					deleted = true
+				}
+			}
+		}
+		_ = closers.Close()
+		if deleted || opaque {
+			return nil, nil, nil, nil
+		}
+	}
+	return nil, nil, nil, nil
+}
+
+// open returns the downloaded content of layer, downloading it first if needed
+func (l *layerFiles) open(ctx context.Context, layer Descriptor) (*os.File, error) {
+	if name, ok := l.files[layer.Digest]; ok {
+		return os.Open(name)
+	}
+
+	file, err := os.CreateTemp("", "deps-layer-*")
+	if err != nil {
+		return nil, err
+	}
+	l.files[layer.Digest] = file.Name()
+	logger.V(3).Infof("Downloading layer %s (%d bytes) of %s", layer.Digest, layer.Size, l.ref)
+	if err := l.client.FetchBlob(ctx, l.ref, layer, file); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l *layerFiles) cleanup() {
+	for _, name := range l.files {
+		_ = os.Remove(name)
+	}
+}
+
+// layerReader returns a tar reader for a layer with the given media type
+func layerReader(r io.Reader, mediaType string) (*tar.Reader, io.Closer, error) {
+	switch {
+	case strings.HasSuffix(mediaType, "zstd"):
+		return nil, nil, fmt.Errorf("zstd compressed layers are not supported")
+	case strings.HasSuffix(mediaType, "gzip"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), gz, nil
+	default:
+		return tar.NewReader(r), io.NopCloser(nil), nil
+	}
+}
+
+// cleanPath returns p relative to the root of the image filesystem
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// isParent reports whether dir is an ancestor directory of p
+func isParent(dir, p string) bool {
+	return dir == "" || strings.HasPrefix(p, dir+"/")
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
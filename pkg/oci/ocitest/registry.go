@@ -0,0 +1,267 @@
+// Package ocitest provides an in-memory container registry for tests of the OCI managers.
+package ocitest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/deps/pkg/oci"
+)
+
+// Registry is a registry serving the pull endpoints of the distribution API from memory
+type Registry struct {
+	*httptest.Server
+	// Host is the host:port of the registry, for use in image references
+	Host string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]map[string]string // Manifest digests by repository and tag
+	token     string
+	requests  []string
+}
+
+// TB is the part of testing.TB the registry uses, which GinkgoT() also provides
+type TB interface {
+	Helper()
+	Cleanup(func())
+	Fatal(args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// File is an entry of a layer built by Layer
+type File struct {
+	Name    string
+	Content string
+	// Symlink makes the entry a symbolic link to the given path
+	Symlink string
+	// Hardlink makes the entry a hard link to the given path
+	Hardlink string
+}
+
+// NewRegistry starts a registry that is closed when the test ends
+func NewRegistry(t TB) *Registry {
+	r := &Registry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]map[string]string),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	r.Host = strings.TrimPrefix(r.URL, "http://")
+	t.Cleanup(r.Close)
+	return r
+}
+
+// RequireToken makes the registry answer requests without the bearer token with a challenge
+// pointing to its /token endpoint, which hands out token
+func (r *Registry) RequireToken(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token = token
+}
+
+// Requests returns the method and path of the requests served so far
+func (r *Registry) Requests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+// Ref returns the reference to tag in repo
+func (r *Registry) Ref(repo, tag string) string {
+	return fmt.Sprintf("%s/%s:%s", r.Host, repo, tag)
+}
+
+// PushBlob stores content and returns its descriptor
+func (r *Registry) PushBlob(mediaType string, content []byte) oci.Descriptor {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.mu.Lock()
+	r.blobs[digest] = content
+	r.mu.Unlock()
+	return oci.Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}
+}
+
+// ReplaceBlob serves content for digest, to test that corrupted downloads are rejected
+func (r *Registry) ReplaceBlob(digest string, content []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blobs[digest] = content
+}
+
+// PushManifest stores manifest in repo, tagged with tag unless it is empty, and returns its
+// descriptor
+func (r *Registry) PushManifest(t TB, repo, tag string, manifest oci.Manifest) oci.Descriptor {
+	t.Helper()
+	if manifest.SchemaVersion == 0 {
+		manifest.SchemaVersion = 2
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = oci.MediaTypeImageManifest
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("failed to encode manifest: %v", err)
+	}
+	desc := r.PushBlob(manifest.MediaType, body)
+	desc.ArtifactType = manifest.ArtifactType
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.manifests[repo] == nil {
+		r.manifests[repo] = make(map[string]string)
+	}
+	if tag != "" {
+		r.manifests[repo][tag] = desc.Digest
+	}
+	return desc
+}
+
+// PushImage stores an image with the given layers, each built with Layer, and returns the
+// descriptor of its manifest
+func (r *Registry) PushImage(t TB, repo, tag string, layers ...[]byte) oci.Descriptor {
+	t.Helper()
+	manifest := oci.Manifest{
+		Config: r.PushBlob("application/vnd.oci.image.config.v1+json", []byte(`{"architecture":"amd64","os":"linux"}`)),
+	}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, r.PushBlob(oci.MediaTypeImageLayerGzip, layer))
+	}
+	return r.PushManifest(t, repo, tag, manifest)
+}
+
+// Layer returns a gzipped tar of files
+func Layer(t TB, files ...File) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		hdr := &tar.Header{Name: file.Name, Mode: 0755, Typeflag: tar.TypeReg, Size: int64(len(file.Content))}
+		switch {
+		case file.Symlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, file.Symlink, 0
+		case file.Hardlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, file.Hardlink, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write %s: %v", file.Name, err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(file.Content)); err != nil {
+				t.Fatalf("failed to write %s: %v", file.Name, err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	token := r.token
+	r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+		return
+	}
+	if !strings.HasPrefix(req.URL.Path, "/v2/") {
+		http.NotFound(w, req)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	var repo, kind, reference string
+	for _, sep := range []string{"/manifests/", "/blobs/", "/tags/list"} {
+		if i := strings.LastIndex(path, sep); i > 0 {
+			repo, kind, reference = path[:i], strings.Trim(sep, "/"), path[i+len(sep):]
+			break
+		}
+	}
+	if repo == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if token != "" && req.Header.Get("Authorization") != "Bearer "+token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="ocitest",scope="repository:%s:pull"`, r.URL, repo))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch kind {
+	case "tags/list":
+		r.serveTags(w, req, repo)
+	case "manifests":
+		digest := reference
+		if !strings.HasPrefix(reference, "sha256:") {
+			digest = r.manifests[repo][reference]
+		}
+		body, ok := r.blobs[digest]
+		if !ok || r.manifests[repo] == nil {
+			http.NotFound(w, req)
+			return
+		}
+		var manifest oci.Manifest
+		_ = json.Unmarshal(body, &manifest)
+		w.Header().Set("Content-Type", manifest.MediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		_, _ = w.Write(body)
+	case "blobs":
+		body, ok := r.blobs[reference]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(body)
+	}
+}
+
+// serveTags lists the tags of repo in pages of n, linking to the next page like registries do
+func (r *Registry) serveTags(w http.ResponseWriter, req *http.Request, repo string) {
+	tagged, ok := r.manifests[repo]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	var tags []string
+	for tag := range tagged {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	if last := req.URL.Query().Get("last"); last != "" {
+		i := sort.SearchStrings(tags, last)
+		if i < len(tags) && tags[i] == last {
+			i++
+		}
+		tags = tags[i:]
+	}
+	if n, err := strconv.Atoi(req.URL.Query().Get("n")); err == nil && n > 0 && len(tags) > n {
+		tags = tags[:n]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=%d>; rel="next"`, repo, tags[n-1], n))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+}
@@ -0,0 +1,137 @@
+package oci
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// DockerHub is the registry of images named without a registry host, e.g. "alpine"
+	DockerHub = "registry-1.docker.io"
+	// URLScheme prefixes references to blobs pinned by digest, such as the layers of artifacts,
+	// recorded as download URLs. They are downloaded and cached like any other download.
+	URLScheme = "oci://"
+	// ImageURLScheme prefixes references to images pinned by digest, whose binaries are
+	// extracted from their layers by the docker manager
+	ImageURLScheme = "docker://"
+)
+
+// Reference names a repository in a registry and optionally a tag or digest in it
+type Reference struct {
+	// Registry is the host and optional port of the registry, e.g. ghcr.io
+	Registry string
+	// Repository is the path of the repository, e.g. flanksource/deps
+	Repository string
+	// Tag is the tag, if any
+	Tag string
+	// Digest is the manifest digest, e.g. sha256:..., if any
+	Digest string
+}
+
+// ParseReference parses an image reference such as alpine:3.20, ghcr.io/org/tool:v1.0.0,
+// localhost:5000/tool@sha256:... or oci://ghcr.io/org/tool@sha256:...
+func ParseReference(s string) (Reference, error) {
+	raw := s
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), URLScheme), ImageURLScheme)
+
+	var ref Reference
+	if i := strings.Index(s, "@"); i >= 0 {
+		ref.Digest = s[i+1:]
+		s = s[:i]
+		if !strings.Contains(ref.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid digest in image reference %q", raw)
+		}
+	}
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		ref.Tag = s[i+1:]
+		s = s[:i]
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 && isRegistryHost(parts[0]) {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = DockerHub, s
+	}
+	switch ref.Registry {
+	case "docker.io", "index.docker.io":
+		ref.Registry = DockerHub
+	}
+	for _, component := range strings.Split(ref.Repository, "/") {
+		if component == "" || component != strings.ToLower(component) {
+			return Reference{}, fmt.Errorf("invalid image reference %q, repositories are lowercase paths like org/name", raw)
+		}
+	}
+	if ref.Registry == DockerHub && !strings.Contains(ref.Repository, "/") {
+		// Official images live under library/
+		ref.Repository = "library/" + ref.Repository
+	}
+	return ref, nil
+}
+
+// isRegistryHost reports whether the first component of a reference is a registry rather than
+// part of a Docker Hub repository, following the rules of the docker CLI
+func isRegistryHost(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// String returns the reference as registry/repository[:tag][@digest]
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithTag returns the reference to tag in the same repository
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag, r.Digest = tag, ""
+	return r
+}
+
+// WithDigest returns the reference to digest in the same repository
+func (r Reference) WithDigest(digest string) Reference {
+	r.Tag, r.Digest = "", digest
+	return r
+}
+
+// URL returns the reference as a URL with the oci:// scheme, as recorded in deps-lock.yaml for
+// the blob it is pinned to
+func (r Reference) URL() string {
+	return URLScheme + r.String()
+}
+
+// ImageURL returns the reference as a URL with the docker:// scheme, as recorded in
+// deps-lock.yaml for the image it is pinned to
+func (r Reference) ImageURL() string {
+	return ImageURLScheme + r.String()
+}
+
+// IsURL reports whether url is an oci:// blob reference
+func IsURL(url string) bool {
+	return strings.HasPrefix(url, URLScheme)
+}
+
+// IsImageURL reports whether url is a docker:// image reference, which cannot be downloaded
+// as a single file
+func IsImageURL(url string) bool {
+	return strings.HasPrefix(url, ImageURLScheme)
+}
+
+// baseURL returns the root of the registry API. Registries on the loopback interface are
+// accessed over plain HTTP, like the docker CLI does.
+func (r Reference) baseURL() string {
+	host := r.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http://" + r.Registry
+	}
+	return "https://" + r.Registry
+}
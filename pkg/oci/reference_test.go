@@ -0,0 +1,63 @@
+package oci
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Reference
+	}{
+		{"alpine", Reference{Registry: DockerHub, Repository: "library/alpine"}},
+		{"alpine:3.20", Reference{Registry: DockerHub, Repository: "library/alpine", Tag: "3.20"}},
+		{"docker.io/bitnami/kubectl:1.30", Reference{Registry: DockerHub, Repository: "bitnami/kubectl", Tag: "1.30"}},
+		{"ghcr.io/org/tool:v1.0.0", Reference{Registry: "ghcr.io", Repository: "org/tool", Tag: "v1.0.0"}},
+		{"localhost:5000/tool", Reference{Registry: "localhost:5000", Repository: "tool"}},
+		{"oci://ghcr.io/org/sub/tool@sha256:abc", Reference{Registry: "ghcr.io", Repository: "org/sub/tool", Digest: "sha256:abc"}},
+		{"docker://quay.io/org/tool:1.0@sha256:abc", Reference{Registry: "quay.io", Repository: "org/tool", Tag: "1.0", Digest: "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.input)
+		if err != nil {
+			t.Errorf("ParseReference(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "ghcr.io/Org/Tool", "ghcr.io/org/tool@abc"} {
+		if _, err := ParseReference(input); err == nil {
+			t.Errorf("ParseReference(%q) should fail", input)
+		}
+	}
+}
+
+func TestReferenceURL(t *testing.T) {
+	ref, err := ParseReference("ghcr.io/org/tool:v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := ref.WithDigest("sha256:abc").URL()
+	if url != "oci://ghcr.io/org/tool@sha256:abc" {
+		t.Errorf("URL() = %q", url)
+	}
+	if !IsURL(url) || IsURL("https://ghcr.io/v2/") || IsImageURL(url) {
+		t.Error("IsURL should only match oci:// references")
+	}
+	image := ref.WithDigest("sha256:abc").ImageURL()
+	if image != "docker://ghcr.io/org/tool@sha256:abc" || !IsImageURL(image) || IsURL(image) {
+		t.Errorf("ImageURL() = %q", image)
+	}
+	parsed, err := ParseReference(url)
+	if err != nil || parsed != ref.WithDigest("sha256:abc") {
+		t.Errorf("ParseReference(%q) = %+v, %v", url, parsed, err)
+	}
+
+	if base := (Reference{Registry: "127.0.0.1:5000"}).baseURL(); base != "http://127.0.0.1:5000" {
+		t.Errorf("baseURL() = %q, want plain http for loopback registries", base)
+	}
+	if base := ref.baseURL(); base != "https://ghcr.io" {
+		t.Errorf("baseURL() = %q", base)
+	}
+}
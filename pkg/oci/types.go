@@ -0,0 +1,91 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Media types of manifests and layers
+const (
+	MediaTypeImageIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+
+	MediaTypeImageLayer      = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeImageLayerGzip  = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeImageLayerZstd  = "application/vnd.oci.image.layer.v1.tar+zstd"
+	MediaTypeDockerLayerGzip = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// AnnotationTitle is the annotation holding the file name of a layer, set by ORAS and similar tools
+const AnnotationTitle = "org.opencontainers.image.title"
+
+// manifestMediaTypes are accepted when fetching manifests
+var manifestMediaTypes = []string{MediaTypeImageIndex, MediaTypeImageManifest, MediaTypeDockerManifestList, MediaTypeDockerManifest}
+
+// Platform is the platform of a manifest in an image index
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s-%s/%s", p.OS, p.Architecture, p.Variant)
+	}
+	return fmt.Sprintf("%s-%s", p.OS, p.Architecture)
+}
+
+// Descriptor references a manifest or blob by digest
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+}
+
+// Title returns the org.opencontainers.image.title annotation of the descriptor
+func (d Descriptor) Title() string {
+	return d.Annotations[AnnotationTitle]
+}
+
+// Manifest is an image manifest or an image index (manifest list)
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsIndex reports whether the manifest is an image index listing a manifest per platform
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeImageIndex || m.MediaType == MediaTypeDockerManifestList ||
+		(m.MediaType == "" && len(m.Manifests) > 0)
+}
+
+// Size returns the total size of the layers
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// ErrPlatformNotFound is returned when an image index has no manifest for a platform
+type ErrPlatformNotFound struct {
+	Reference Reference
+	Platform  string
+	Available []string
+}
+
+func (e *ErrPlatformNotFound) Error() string {
+	return fmt.Sprintf("%s has no image for %s, available: %s", e.Reference, e.Platform, strings.Join(e.Available, ", "))
+}
@@ -0,0 +1,382 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/deps/pkg/platform"
+)
+
+// Receipt is the persisted record of an installed package, written to
+// <receiptsDir>/<bin-dir key>/<name>.json. It lists every file, directory and symlink the
+// install created so that uninstall removes exactly those and nothing else. Receipts are kept
+// per bin-dir, so installing a tool in one project never replaces the receipt of another.
+type Receipt struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Manager     string            `json:"manager,omitempty"`
+	SourceURL   string            `json:"source_url,omitempty"`
+	Checksum    string            `json:"checksum,omitempty"`
+	Platform    platform.Platform `json:"platform"`
+	BinDir      string            `json:"bin_dir"`
+	AppDir      string            `json:"app_dir,omitempty"`
+	StoreDir    string            `json:"store_dir,omitempty"` // side-by-side version store
+	Files       []string          `json:"files,omitempty"`     // files and directories created by the install
+	Symlinks    []string          `json:"symlinks,omitempty"`  // symlinks created in bin-dir
+	InstalledAt time.Time         `json:"installed_at"`
+
+	PreviousVersion string            `json:"previous_version,omitempty"`
+	Backups         map[string]string `json:"backups,omitempty"` // replaced path -> copy of the previous version, for rollback
+}
+
+// DefaultDir returns ~/.deps/receipts, or "" when the home directory is unknown.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".deps", "receipts")
+}
+
+// BinDirKey returns a short stable key for binDir, naming the directories that hold its receipts
+// and stored versions
+func BinDirKey(binDir string) string {
+	if abs, err := filepath.Abs(binDir); err == nil {
+		binDir = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(binDir)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func receiptFile(dir, binDir, name string) string {
+	return filepath.Join(dir, BinDirKey(binDir), name+".json")
+}
+
+// legacyFile is where receipts were written before they were kept per bin-dir
+func legacyFile(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+func read(path, name string) (*Receipt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt for %s: %w", name, err)
+	}
+	return &r, nil
+}
+
+// loadLegacy returns the receipt written before receipts were kept per bin-dir, if it
+// belongs to binDir
+func loadLegacy(dir, binDir, name string) (*Receipt, bool) {
+	r, err := read(legacyFile(dir, name), name)
+	if err != nil || !sameDir(r.BinDir, binDir) {
+		return nil, false
+	}
+	return r, true
+}
+
+func sameDir(a, b string) bool {
+	return BinDirKey(a) == BinDirKey(b)
+}
+
+// Load reads the receipt for a package installed in binDir; os.IsNotExist(err) when it was
+// never installed there by deps.
+func Load(dir, binDir, name string) (*Receipt, error) {
+	r, err := read(receiptFile(dir, binDir, name), name)
+	if os.IsNotExist(err) {
+		if legacy, ok := loadLegacy(dir, binDir, name); ok {
+			return legacy, nil
+		}
+	}
+	return r, err
+}
+
+// Save atomically writes the receipt file for the bin-dir of the receipt.
+func (r *Receipt) Save(dir string) error {
+	path := receiptFile(dir, r.BinDir, r.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create receipts dir %s: %w", dir, err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipt for %s: %w", r.Name, err)
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+r.Name+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if _, ok := loadLegacy(dir, r.BinDir, r.Name); ok {
+		_ = os.Remove(legacyFile(dir, r.Name))
+	}
+	return nil
+}
+
+// Delete removes the receipt file of a package installed in binDir.
+func Delete(dir, binDir, name string) error {
+	if _, ok := loadLegacy(dir, binDir, name); ok {
+		if err := os.Remove(legacyFile(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err := os.Remove(receiptFile(dir, binDir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns every stored receipt across bin-dirs, sorted by name and bin-dir.
+func List(dir string) ([]*Receipt, error) {
+	var receipts []*Receipt
+	err := filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			return nil
+		}
+		r, err := read(path, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return err
+		}
+		receipts = append(receipts, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		if receipts[i].Name != receipts[j].Name {
+			return receipts[i].Name < receipts[j].Name
+		}
+		return receipts[i].BinDir < receipts[j].BinDir
+	})
+	return receipts, nil
+}
+
+// AddFile records a file or directory created by the install.
+// Paths outside bin-dir, app-dir and the version store (e.g. from system installers) are not recorded.
+func (r *Receipt) AddFile(path string) {
+	r.Files = r.add(r.Files, path)
+}
+
+// AddSymlink records a symlink created by the install.
+func (r *Receipt) AddSymlink(path string) {
+	r.Symlinks = r.add(r.Symlinks, path)
+}
+
+// MarkSymlink records that a path previously added as a file has been replaced by a symlink,
+// e.g. when a binary is moved into the version store.
+func (r *Receipt) MarkSymlink(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	files := r.Files[:0]
+	for _, existing := range r.Files {
+		if existing != abs {
+			files = append(files, existing)
+		}
+	}
+	r.Files = files
+	r.AddSymlink(abs)
+}
+
+// AddBackup records that the previous version of path was kept at backup.
+func (r *Receipt) AddBackup(path, backup string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	absBackup, err := filepath.Abs(backup)
+	if err != nil {
+		return
+	}
+	if r.Backups == nil {
+		r.Backups = make(map[string]string)
+	}
+	r.Backups[abs] = absBackup
+}
+
+func (r *Receipt) add(list []string, path string) []string {
+	if path == "" {
+		return list
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || !r.owns(abs) {
+		return list
+	}
+	for _, existing := range list {
+		if existing == abs {
+			return list
+		}
+	}
+	return append(list, abs)
+}
+
+// owns reports whether path lies inside the receipt's bin-dir, app-dir or version store.
+func (r *Receipt) owns(path string) bool {
+	for _, root := range []string{r.BinDir, r.AppDir, r.StoreDir} {
+		if root == "" {
+			continue
+		}
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge carries over paths from a previous receipt for the same bin-dir that still
+// exist, e.g. the folder of an older version left behind by versioned_folder.
+func (r *Receipt) Merge(previous *Receipt) {
+	if previous == nil || previous.BinDir != r.BinDir {
+		return
+	}
+	for _, path := range previous.Files {
+		if _, err := os.Lstat(path); err == nil {
+			r.AddFile(path)
+		}
+	}
+	for _, path := range previous.Symlinks {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			r.AddSymlink(path)
+		}
+	}
+}
+
+// UninstallResult reports what Uninstall removed and what it refused to touch.
+type UninstallResult struct {
+	Removed []string
+	Skipped map[string]string // path -> reason
+}
+
+// Uninstall removes the symlinks, files, directories and rollback backups recorded in the receipt.
+// Paths outside the receipt's bin-dir/app-dir, or whose type no longer matches what
+// was recorded (e.g. a symlink replaced by a regular file), are left untouched. So are paths
+// also recorded by one of others, such as an app-dir folder shared with another bin-dir.
+func (r *Receipt) Uninstall(others ...*Receipt) (*UninstallResult, error) {
+	result := &UninstallResult{Skipped: make(map[string]string)}
+
+	for _, path := range r.Symlinks {
+		info, err := os.Lstat(path)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			result.Skipped[path] = err.Error()
+			continue
+		case !r.owns(path):
+			result.Skipped[path] = "outside bin-dir, app-dir and version store"
+			continue
+		case usedBy(path, others) != nil:
+			result.Skipped[path] = fmt.Sprintf("still used by %s", usedBy(path, others))
+			continue
+		case info.Mode()&os.ModeSymlink == 0:
+			result.Skipped[path] = "no longer a symlink"
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return result, fmt.Errorf("failed to remove symlink %s: %w", path, err)
+		}
+		result.Removed = append(result.Removed, path)
+	}
+
+	for _, backup := range r.Backups {
+		if _, err := os.Lstat(backup); err != nil {
+			continue
+		}
+		if !r.owns(backup) {
+			result.Skipped[backup] = "outside bin-dir, app-dir and version store"
+			continue
+		}
+		if other := usedBy(backup, others); other != nil {
+			result.Skipped[backup] = fmt.Sprintf("still used by %s", other)
+			continue
+		}
+		if err := os.RemoveAll(backup); err != nil {
+			return result, fmt.Errorf("failed to remove backup %s: %w", backup, err)
+		}
+		result.Removed = append(result.Removed, backup)
+	}
+
+	for _, path := range r.Files {
+		info, err := os.Lstat(path)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			result.Skipped[path] = err.Error()
+			continue
+		case !r.owns(path):
+			result.Skipped[path] = "outside bin-dir, app-dir and version store"
+			continue
+		case usedBy(path, others) != nil:
+			result.Skipped[path] = fmt.Sprintf("still used by %s", usedBy(path, others))
+			continue
+		case info.Mode()&os.ModeSymlink != 0:
+			result.Skipped[path] = "replaced by a symlink"
+			continue
+		}
+		if info.IsDir() {
+			err = os.RemoveAll(path)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		result.Removed = append(result.Removed, path)
+	}
+
+	return result, nil
+}
+
+// String names the receipt's tool and bin-dir, e.g. "jq in /project/bin"
+func (r *Receipt) String() string {
+	return fmt.Sprintf("%s in %s", r.Name, r.BinDir)
+}
+
+// usedBy returns the first of others that records path, or a path inside or containing it
+func usedBy(path string, others []*Receipt) *Receipt {
+	for _, other := range others {
+		recorded := append(append([]string{}, other.Files...), other.Symlinks...)
+		for _, backup := range other.Backups {
+			recorded = append(recorded, backup)
+		}
+		for _, p := range recorded {
+			if p == path || isWithin(p, path) || isWithin(path, p) {
+				return other
+			}
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether path lies inside dir
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,249 @@
+package receipt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadList(t *testing.T) {
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "bin")
+
+	r := &Receipt{Name: "jq", Version: "1.7.1", BinDir: binDir}
+	r.AddFile(filepath.Join(binDir, "jq"))
+	if err := r.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(dir, binDir, "jq")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Version != "1.7.1" || len(loaded.Files) != 1 {
+		t.Errorf("Load() = %+v, want version 1.7.1 with 1 file", loaded)
+	}
+
+	if _, err := Load(dir, binDir, "missing"); !os.IsNotExist(err) {
+		t.Errorf("Load(missing) error = %v, want not-exist", err)
+	}
+
+	receipts, err := List(dir)
+	if err != nil || len(receipts) != 1 || receipts[0].Name != "jq" {
+		t.Errorf("List() = %v, %v, want [jq]", receipts, err)
+	}
+}
+
+func TestReceiptsArePerBinDir(t *testing.T) {
+	dir := t.TempDir()
+	projectA := filepath.Join(dir, "a", "bin")
+	projectB := filepath.Join(dir, "b", "bin")
+
+	for _, binDir := range []string{projectA, projectB} {
+		r := &Receipt{Name: "jq", Version: "1.7.1", BinDir: binDir}
+		r.AddFile(filepath.Join(binDir, "jq"))
+		if err := r.Save(dir); err != nil {
+			t.Fatalf("Save(%s) error = %v", binDir, err)
+		}
+	}
+
+	loaded, err := Load(dir, projectA, "jq")
+	if err != nil || loaded.BinDir != projectA {
+		t.Fatalf("Load(a) = %+v, %v, want the receipt of project a", loaded, err)
+	}
+	if err := Delete(dir, projectA, "jq"); err != nil {
+		t.Fatalf("Delete(a) error = %v", err)
+	}
+	if _, err := Load(dir, projectA, "jq"); !os.IsNotExist(err) {
+		t.Errorf("Load(a) after Delete error = %v, want not-exist", err)
+	}
+	if loaded, err := Load(dir, projectB, "jq"); err != nil || loaded.BinDir != projectB {
+		t.Errorf("Load(b) = %+v, %v, want the receipt of project b to be kept", loaded, err)
+	}
+}
+
+func TestLoadLegacyReceipt(t *testing.T) {
+	dir := t.TempDir()
+	binDir := filepath.Join(dir, "bin")
+	if err := os.WriteFile(filepath.Join(dir, "jq.json"), []byte(`{"name":"jq","version":"1.6","bin_dir":"`+binDir+`"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(dir, filepath.Join(dir, "other"), "jq"); !os.IsNotExist(err) {
+		t.Errorf("Load(other) error = %v, want the receipt of another bin-dir to be ignored", err)
+	}
+	loaded, err := Load(dir, binDir, "jq")
+	if err != nil || loaded.Version != "1.6" {
+		t.Fatalf("Load() = %+v, %v, want the legacy receipt", loaded, err)
+	}
+
+	// Saving moves it next to the other receipts of the bin-dir
+	if err := loaded.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "jq.json")); !os.IsNotExist(err) {
+		t.Errorf("legacy receipt still present: %v", err)
+	}
+	if receipts, err := List(dir); err != nil || len(receipts) != 1 {
+		t.Errorf("List() = %v, %v, want one receipt", receipts, err)
+	}
+}
+
+func TestAddFileIgnoresPathsOutsideBinAndAppDir(t *testing.T) {
+	r := &Receipt{BinDir: "/opt/deps/bin", AppDir: "/opt/deps/apps"}
+	r.AddFile("/opt/deps/bin/kubectl")
+	r.AddFile("/opt/deps/apps/jdk")
+	r.AddFile("/usr/bin/kubectl")
+	r.AddFile("/opt/deps/bin")
+	r.AddFile("/opt/deps/bin/kubectl")
+
+	if len(r.Files) != 2 {
+		t.Errorf("Files = %v, want only the two paths inside bin-dir and app-dir", r.Files)
+	}
+}
+
+func TestUninstallRemovesOnlyRecordedPaths(t *testing.T) {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "bin")
+	appDir := filepath.Join(root, "opt")
+	mustMkdir(t, binDir)
+	mustMkdir(t, filepath.Join(appDir, "jdk", "bin"))
+
+	binary := filepath.Join(binDir, "tool")
+	unrelated := filepath.Join(binDir, "other")
+	link := filepath.Join(binDir, "java")
+	replaced := filepath.Join(binDir, "javac")
+	mustWrite(t, binary)
+	mustWrite(t, unrelated)
+	mustWrite(t, filepath.Join(appDir, "jdk", "bin", "java"))
+	if err := os.Symlink("../opt/jdk/bin/java", link); err != nil {
+		t.Fatal(err)
+	}
+	// javac was recorded as a symlink but has since been replaced by a user-managed file
+	mustWrite(t, replaced)
+
+	r := &Receipt{Name: "tool", BinDir: binDir, AppDir: appDir}
+	r.AddFile(binary)
+	r.AddFile(filepath.Join(appDir, "jdk"))
+	r.AddSymlink(link)
+	r.AddSymlink(replaced)
+
+	result, err := r.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	for _, path := range []string{binary, link, filepath.Join(appDir, "jdk")} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", path)
+		}
+	}
+	for _, path := range []string{unrelated, replaced} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s should have been left untouched: %v", path, err)
+		}
+	}
+	if _, ok := result.Skipped[replaced]; !ok {
+		t.Errorf("Skipped = %v, want %s reported", result.Skipped, replaced)
+	}
+}
+
+func TestMergeKeepsExistingPathsFromPreviousInstall(t *testing.T) {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "bin")
+	old := filepath.Join(root, "opt", "go1.24")
+	mustMkdir(t, old)
+
+	previous := &Receipt{BinDir: binDir, AppDir: filepath.Join(root, "opt")}
+	previous.AddFile(old)
+	previous.AddFile(filepath.Join(root, "opt", "go1.23"))
+
+	current := &Receipt{BinDir: binDir, AppDir: filepath.Join(root, "opt")}
+	current.AddFile(filepath.Join(root, "opt", "go1.25"))
+	current.Merge(previous)
+
+	if len(current.Files) != 2 {
+		t.Errorf("Files = %v, want go1.25 plus the still-present go1.24", current.Files)
+	}
+}
+
+func mustMkdir(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustWrite(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMarkSymlinkMovesPathFromFilesToSymlinks(t *testing.T) {
+	r := &Receipt{BinDir: "/opt/deps/bin", StoreDir: "/home/u/.deps/versions"}
+	r.AddFile("/opt/deps/bin/jq")
+	r.AddFile("/home/u/.deps/versions/jq")
+	r.MarkSymlink("/opt/deps/bin/jq")
+
+	if len(r.Files) != 1 || r.Files[0] != "/home/u/.deps/versions/jq" {
+		t.Errorf("Files = %v, want only the version store entry", r.Files)
+	}
+	if len(r.Symlinks) != 1 || r.Symlinks[0] != "/opt/deps/bin/jq" {
+		t.Errorf("Symlinks = %v, want [/opt/deps/bin/jq]", r.Symlinks)
+	}
+}
+
+func TestUninstallRemovesRollbackBackups(t *testing.T) {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "bin")
+	mustMkdir(t, filepath.Join(binDir, ".deps-previous"))
+
+	binary := filepath.Join(binDir, "tool")
+	backup := filepath.Join(binDir, ".deps-previous", "tool")
+	outside := filepath.Join(root, "elsewhere")
+	mustWrite(t, binary)
+	mustWrite(t, backup)
+	mustWrite(t, outside)
+
+	r := &Receipt{Name: "tool", BinDir: binDir}
+	r.AddFile(binary)
+	r.AddBackup(binary, backup)
+	r.AddBackup(filepath.Join(binDir, "other"), outside)
+
+	result, err := r.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if _, err := os.Lstat(backup); !os.IsNotExist(err) {
+		t.Errorf("%s should have been removed", backup)
+	}
+	if _, ok := result.Skipped[outside]; !ok {
+		t.Errorf("Skipped = %v, want %s reported", result.Skipped, outside)
+	}
+}
+
+func TestUninstallKeepsPathsOtherReceiptsRecord(t *testing.T) {
+	root := t.TempDir()
+	appDir := filepath.Join(root, "opt")
+	shared := filepath.Join(appDir, "jdk")
+	mustMkdir(t, filepath.Join(shared, "bin"))
+
+	r := &Receipt{Name: "jdk", BinDir: filepath.Join(root, "a"), AppDir: appDir}
+	r.AddFile(shared)
+	other := &Receipt{Name: "jdk", BinDir: filepath.Join(root, "b"), AppDir: appDir}
+	other.AddFile(filepath.Join(shared, "bin"))
+
+	result, err := r.Uninstall(other)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if _, err := os.Stat(shared); err != nil || len(result.Removed) != 0 {
+		t.Errorf("expected %s to be kept, removed %v", shared, result.Removed)
+	}
+	if reason := result.Skipped[shared]; !strings.Contains(reason, "still used by jdk in "+other.BinDir) {
+		t.Errorf("Skipped = %v", result.Skipped)
+	}
+}
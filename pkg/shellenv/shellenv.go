@@ -0,0 +1,191 @@
+package shellenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/gomplate/v3"
+)
+
+// Shells lists the shells Render supports
+var Shells = []string{"bash", "zsh", "fish", "powershell", "direnv"}
+
+// Var is a single exported environment variable
+type Var struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Env is the environment that activates a project: directories to prepend to PATH
+// and the variables declared by the env of its dependencies.
+type Env struct {
+	Project string   `json:"project"`
+	Path    []string `json:"path"`
+	Vars    []Var    `json:"vars,omitempty"`
+}
+
+// ForProject builds the environment for a project: its bin-dir (which holds the binaries and
+// the symlinks into app-dir) on PATH, and the templated env of every dependency.
+func ForProject(project *config.Project, appDir string, plat platform.Platform) (*Env, error) {
+	settings := project.Config.Settings
+	if settings.AppDir != "" {
+		appDir = settings.AppDir
+	}
+
+	env := &Env{Project: project.Dir, Path: []string{settings.BinDir}}
+
+	names := make([]string, 0, len(project.Config.Dependencies))
+	for name := range project.Config.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := map[string]string{}
+	for _, name := range names {
+		pkg, ok := project.Config.Registry[name]
+		if !ok || len(pkg.Env) == 0 {
+			continue
+		}
+		if pkg.Name == "" {
+			pkg.Name = name
+		}
+		version, _ := project.PinnedVersion(name)
+		data := map[string]any{
+			"appDir":     appDir,
+			"binDir":     settings.BinDir,
+			"name":       pkg.Name,
+			"folderName": pkg.FolderName(version),
+			"version":    version,
+			"os":         plat.OS,
+			"arch":       plat.Arch,
+		}
+		for key, tmpl := range pkg.Env {
+			value, err := gomplate.RunTemplate(data, gomplate.Template{Template: tmpl})
+			if err != nil {
+				return nil, fmt.Errorf("failed to template env %s for %s: %w", key, name, err)
+			}
+			vars[key] = value
+		}
+	}
+
+	for key, value := range vars {
+		env.Vars = append(env.Vars, Var{Name: key, Value: value})
+	}
+	sort.Slice(env.Vars, func(a, b int) bool { return env.Vars[a].Name < env.Vars[b].Name })
+	return env, nil
+}
+
+// DetectShell returns the current shell from $SHELL, defaulting to powershell on Windows and bash elsewhere
+func DetectShell() string {
+	if shell := filepath.Base(os.Getenv("SHELL")); shell != "" && shell != "." {
+		switch shell {
+		case "zsh", "fish", "bash":
+			return shell
+		case "pwsh", "pwsh.exe", "powershell.exe":
+			return "powershell"
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "bash"
+}
+
+// Environ returns base with the environment applied, e.g. for spawning a subshell
+func (e *Env) Environ(base []string) []string {
+	result := make([]string, 0, len(base)+len(e.Vars)+1)
+	pathSet := false
+	for _, kv := range base {
+		key, value, _ := strings.Cut(kv, "=")
+		if e.has(key) {
+			continue
+		}
+		if strings.EqualFold(key, "PATH") && !pathSet {
+			result = append(result, key+"="+e.prependPath(value))
+			pathSet = true
+			continue
+		}
+		result = append(result, kv)
+	}
+	if !pathSet {
+		result = append(result, "PATH="+e.prependPath(""))
+	}
+	for _, v := range e.Vars {
+		result = append(result, v.Name+"="+v.Value)
+	}
+	return result
+}
+
+func (e *Env) has(name string) bool {
+	for _, v := range e.Vars {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Env) prependPath(path string) string {
+	entries := append([]string{}, e.Path...)
+	if path != "" {
+		entries = append(entries, path)
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
+}
+
+// Render prints the environment as commands for shell to eval
+func (e *Env) Render(shell string) (string, error) {
+	var b strings.Builder
+	switch shell {
+	case "bash", "zsh":
+		fmt.Fprintf(&b, "export PATH=%s\"${PATH:+:$PATH}\"\n", posixQuote(strings.Join(e.Path, ":")))
+		for _, v := range e.Vars {
+			fmt.Fprintf(&b, "export %s=%s\n", v.Name, posixQuote(v.Value))
+		}
+	case "fish":
+		for idx := len(e.Path) - 1; idx >= 0; idx-- {
+			fmt.Fprintf(&b, "set -gx PATH %s $PATH\n", fishQuote(e.Path[idx]))
+		}
+		for _, v := range e.Vars {
+			fmt.Fprintf(&b, "set -gx %s %s\n", v.Name, fishQuote(v.Value))
+		}
+	case "powershell", "pwsh":
+		fmt.Fprintf(&b, "$env:PATH = %s + [IO.Path]::PathSeparator + $env:PATH\n",
+			powershellQuote(strings.Join(e.Path, string(os.PathListSeparator))))
+		for _, v := range e.Vars {
+			fmt.Fprintf(&b, "$env:%s = %s\n", v.Name, powershellQuote(v.Value))
+		}
+	case "direnv":
+		b.WriteString(DirenvHook)
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+	return b.String(), nil
+}
+
+// DirenvHook is a direnv stdlib extension enabling 'use deps' in .envrc files
+const DirenvHook = `# Add to ~/.config/direnv/direnvrc, then put 'use deps' in a project's .envrc
+use_deps() {
+  watch_file deps.yaml deps-lock.yaml
+  eval "$(deps env --shell bash)"
+}
+`
+
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
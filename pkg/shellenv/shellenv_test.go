@@ -0,0 +1,78 @@
+package shellenv
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/deps/pkg/config"
+	"github.com/flanksource/deps/pkg/platform"
+	"github.com/flanksource/deps/pkg/types"
+)
+
+func TestForProjectTemplatesPackageEnv(t *testing.T) {
+	project := &config.Project{
+		Dir:        "/work/app",
+		ConfigPath: "/work/app/deps.yaml",
+		Config: &types.DepsConfig{
+			Dependencies: map[string]string{"openjdk": "21.0.5", "jq": "v1.7.1"},
+			Registry: map[string]types.Package{
+				"openjdk": {Name: "openjdk", VersionedFolder: true, Env: map[string]string{"JAVA_HOME": "{{.appDir}}/{{.folderName}}"}},
+				"jq":      {Name: "jq"},
+			},
+			Settings: types.Settings{BinDir: "/work/app/bin"},
+		},
+	}
+
+	env, err := ForProject(project, "/opt", platform.Platform{OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatalf("ForProject() error = %v", err)
+	}
+	if len(env.Path) != 1 || env.Path[0] != "/work/app/bin" {
+		t.Errorf("Path = %v, want [/work/app/bin]", env.Path)
+	}
+	if len(env.Vars) != 1 || env.Vars[0] != (Var{Name: "JAVA_HOME", Value: "/opt/openjdk21.0.5"}) {
+		t.Errorf("Vars = %v, want JAVA_HOME=/opt/openjdk21.0.5", env.Vars)
+	}
+}
+
+func TestRender(t *testing.T) {
+	env := &Env{Path: []string{"/work/app/bin"}, Vars: []Var{{Name: "GREETING", Value: "it's"}}}
+
+	tests := map[string][]string{
+		"bash":       {`export PATH='/work/app/bin'"${PATH:+:$PATH}"`, `export GREETING='it'\''s'`},
+		"fish":       {`set -gx PATH '/work/app/bin' $PATH`, `set -gx GREETING 'it\'s'`},
+		"powershell": {`$env:GREETING = 'it''s'`},
+		"direnv":     {"use_deps()"},
+	}
+	for shell, want := range tests {
+		out, err := env.Render(shell)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", shell, err)
+		}
+		for _, line := range want {
+			if !strings.Contains(out, line) {
+				t.Errorf("Render(%s) = %q, want it to contain %q", shell, out, line)
+			}
+		}
+	}
+
+	if _, err := env.Render("tcsh"); err == nil {
+		t.Error("Render(tcsh) error = nil, want unsupported shell")
+	}
+}
+
+func TestEnvironPrependsPath(t *testing.T) {
+	env := &Env{Path: []string{"/work/app/bin"}, Vars: []Var{{Name: "JAVA_HOME", Value: "/opt/jdk"}}}
+	got := env.Environ([]string{"PATH=/usr/bin", "JAVA_HOME=/old", "HOME=/home/u"})
+
+	want := map[string]bool{"PATH=/work/app/bin" + string(os.PathListSeparator) + "/usr/bin": true, "JAVA_HOME=/opt/jdk": true, "HOME=/home/u": true}
+	if len(got) != len(want) {
+		t.Fatalf("Environ() = %v, want %d entries", got, len(want))
+	}
+	for _, kv := range got {
+		if !want[kv] {
+			t.Errorf("Environ() has unexpected %q", kv)
+		}
+	}
+}
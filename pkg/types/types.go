@@ -39,6 +39,9 @@ type Package struct {
 	Manager string `json:"manager" yaml:"manager"`
 	// Repo is the repository identifier for GitHub packages (format: "owner/repo")
 	Repo string `json:"repo,omitempty" yaml:"repo,omitempty"`
+	// BaseURL is the web URL of the GitHub Enterprise Server or self-managed GitLab instance hosting Repo,
+	// e.g. https://github.example.com (defaults to settings.github.base_url or settings.gitlab.base_url)
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
 	// URLTemplate is a template string for direct download URLs with placeholders for {{.os}}, {{.arch}}, {{.version}}, etc.
 	URLTemplate string `json:"url_template,omitempty" yaml:"url_template,omitempty"`
 	// VersionsURL is the URL to fetch available versions (used by url manager)
@@ -81,6 +84,10 @@ type Package struct {
 	Symlinks []string `json:"symlinks,omitempty" yaml:"symlinks,omitempty"`
 	// WrapperScript is a template for creating a wrapper script in bin-dir (supports {{.appDir}}, {{.binDir}}, {{.name}}, {{.version}}, {{.os}}, {{.arch}})
 	WrapperScript string `json:"wrapper_script,omitempty" yaml:"wrapper_script,omitempty"`
+	// Requires lists packages installed before this one, as "name" or "name@constraint" (e.g. "go@>=1.22")
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+	// Env contains environment variables exported by 'deps env' and 'deps shell' (supports the same placeholders as WrapperScript plus {{.folderName}})
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
 	// Extra contains manager-specific configuration options
 	Extra map[string]interface{} `json:"extra,omitempty" yaml:"extra,omitempty"`
 	// FallbackVersion is used when GitHub API rate limits are reached (defaults to "latest")
@@ -225,6 +232,8 @@ type LockEntry struct {
 type GitHubLockInfo struct {
 	// Repo is the GitHub repository in "owner/repo" format
 	Repo string `json:"repo" yaml:"repo"`
+	// BaseURL is the GitHub Enterprise Server hosting Repo, empty for github.com
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
 	// Tag is the Git tag/release name that was locked
 	Tag string `json:"tag" yaml:"tag"`
 	// ChecksumFile is the name of the checksum file in the GitHub release
@@ -265,8 +274,117 @@ type Settings struct {
 	Platform platform.Platform `json:"platform" yaml:"platform"`
 	// Parallel enables parallel downloads and installations
 	Parallel bool `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	// Jobs is the maximum number of packages installed at once when Parallel is enabled (0 = default)
+	Jobs int `json:"jobs,omitempty" yaml:"jobs,omitempty"`
 	// SkipVerify disables checksum verification (not recommended for production)
 	SkipVerify bool `json:"skip_verify,omitempty" yaml:"skip_verify,omitempty"`
+	// Mirrors rewrite downloads and API calls to internal mirrors, first match wins
+	Mirrors []Mirror `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Retry configures how failed downloads and API calls are retried
+	Retry RetryPolicy `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Proxy is the proxy URL for all requests, overriding HTTPS_PROXY and HTTP_PROXY
+	Proxy string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	// NoProxy lists the hosts, domains and CIDRs reached without the proxy, overriding NO_PROXY
+	NoProxy string `json:"no_proxy,omitempty" yaml:"no_proxy,omitempty"`
+	// CAFile is a PEM bundle of root CAs trusted in addition to the system ones, e.g. of a TLS-intercepting proxy
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// ClientCert is a PEM client certificate presented to servers that require mutual TLS
+	ClientCert string `json:"client_cert,omitempty" yaml:"client_cert,omitempty"`
+	// ClientKey is the PEM private key of ClientCert, if it is not in the same file
+	ClientKey string `json:"client_key,omitempty" yaml:"client_key,omitempty"`
+	// Credentials authenticate requests to private hosts, keyed by host pattern such as
+	// "nexus.example.com", "*.example.com" or "nexus.example.com/repository/private"
+	Credentials map[string]Credential `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	// GitHub configures the GitHub instance used by packages without a base_url
+	GitHub GitHubSettings `json:"github,omitempty" yaml:"github,omitempty"`
+	// GitLab configures the GitLab instance used by packages without a base_url
+	GitLab GitLabSettings `json:"gitlab,omitempty" yaml:"gitlab,omitempty"`
+	// HTTPCache configures the on-disk cache of API responses, stored under CacheDir
+	HTTPCache HTTPCacheSettings `json:"http_cache,omitempty" yaml:"http_cache,omitempty"`
+	// Cache configures the download cache of artifacts, stored under CacheDir
+	Cache CacheSettings `json:"cache,omitempty" yaml:"cache,omitempty"`
+}
+
+// CacheSettings configures the download cache of artifacts
+type CacheSettings struct {
+	// MaxSize caps the total size of cached artifacts, e.g. "10GB", evicting the least recently
+	// used ones once it is exceeded (DEPS_CACHE_MAX_SIZE overrides it)
+	MaxSize string `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+}
+
+// GitLabSettings points GitLab packages at a self-managed GitLab instance
+type GitLabSettings struct {
+	// BaseURL is the web URL of the instance, e.g. https://gitlab.example.com (defaults to https://gitlab.com)
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// Token references the environment variable holding the token for BaseURL, e.g. ${GITLAB_EXAMPLE_TOKEN}
+	// (defaults to GITLAB_TOKEN, GL_TOKEN or GITLAB_ACCESS_TOKEN)
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// HTTPCacheSettings configures how long API responses such as release lists, GraphQL results,
+// maven-metadata.xml and directory listings are reused before they are revalidated
+type HTTPCacheSettings struct {
+	// TTL is how long a cached response is used without asking the server (default 10m)
+	TTL time.Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	// Disabled sends every request to the server
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
+
+// GitHubSettings points GitHub packages at a GitHub Enterprise Server instance
+type GitHubSettings struct {
+	// BaseURL is the web URL of the instance, e.g. https://github.example.com (defaults to https://github.com)
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// Token references the environment variable holding the token for BaseURL, e.g. ${GHE_TOKEN}
+	// (defaults to GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN)
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// Credential authenticates the requests to a host. Secrets are not written in deps.yaml itself:
+// Token and Password reference environment variables such as ${NEXUS_TOKEN}, or are read from
+// ~/.netrc or the output of a credential helper command.
+type Credential struct {
+	// Type is bearer, basic or header. It defaults to header when Header is set, to basic when
+	// Username or Netrc is set, and to bearer otherwise.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Username for basic auth
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	// Password for basic auth, e.g. ${NEXUS_PASSWORD}
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Token for bearer and header auth, e.g. ${ARTIFACTORY_TOKEN}
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// Header is the header the token is sent in for header auth, e.g. X-JFrog-Art-Api
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	// Netrc reads the login and password of the host from $NETRC or ~/.netrc
+	Netrc bool `json:"netrc,omitempty" yaml:"netrc,omitempty"`
+	// Command is a credential helper whose output is the token or password, e.g. "vault kv get -field=token secret/nexus"
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+}
+
+// RetryPolicy configures how failed network requests are retried. Zero values use the defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per request including the first (default 3, 1 disables retries)
+	MaxAttempts int `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	// Backoff is the wait before the first retry, doubled for each further retry (default 500ms)
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// MaxBackoff caps the backoff between attempts (default 30s)
+	MaxBackoff time.Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+	// MaxRetryAfter is the longest wait asked for by a Retry-After header that is honored; a request
+	// asked to wait longer is not retried (default 5m)
+	MaxRetryAfter time.Duration `json:"max_retry_after,omitempty" yaml:"max_retry_after,omitempty"`
+	// Statuses are the HTTP statuses to retry, as codes or classes such as "5xx" (default 408, 429 and 5xx)
+	Statuses []string `json:"statuses,omitempty" yaml:"statuses,omitempty"`
+}
+
+// Mirror redirects requests for URLs starting with Prefix to URL. Lock files and receipts
+// keep the original URL, so they stay valid with or without the mirror.
+type Mirror struct {
+	// Prefix is matched against the URL without its scheme, e.g. "get.helm.sh" or
+	// "github.com/*/releases/download", where '*' matches any run of characters
+	Prefix string `json:"prefix" yaml:"prefix"`
+	// URL replaces the part of Prefix before its first '*' (or all of it), e.g. https://artifactory.example.com/github
+	URL string `json:"url" yaml:"url"`
+	// Fallback retries the original URL when the mirror fails or does not have the file
+	Fallback bool `json:"fallback,omitempty" yaml:"fallback,omitempty"`
 }
 
 // InstallOptions configures installation behavior
@@ -287,6 +405,8 @@ type InstallOptions struct {
 	Parallel bool
 	// OutputDir specifies an alternate output directory for cross-platform installations
 	OutputDir string
+	// Offline makes no network calls: managers that build from source use only their local caches
+	Offline bool
 }
 
 // LockOptions configures lock file generation
@@ -431,6 +551,8 @@ type InstallResult struct {
 	InstalledSize int64 `json:"installed_size,omitempty"`
 	// Checksum is the SHA256 checksum of the downloaded file
 	Checksum string `json:"checksum,omitempty"`
+	// Attempts is 1 plus the number of network requests retried during the install
+	Attempts int `json:"attempts,omitempty"`
 }
 
 func relativeDir(base string) string {
@@ -485,6 +607,9 @@ func (r InstallResult) Pretty() api.Text {
 	if r.DownloadSize > 0 {
 		text = text.Append(" downloaded: ", "muted").Append(formatBytes(r.DownloadSize))
 	}
+	if r.Attempts > 1 {
+		text = text.Append(" attempts: ", "muted").Printf("%d", r.Attempts)
+	}
 
 	return text
 }
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -82,11 +83,50 @@ func FormatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// ShortenURL shortens a URL for logging by removing protocol and showing only domain + path
+// sensitiveQueryParams carry secrets in URLs, such as tokens and the signatures of pre-signed URLs
+var sensitiveQueryParams = map[string]bool{
+	"token": true, "access_token": true, "private_token": true, "password": true,
+	"sig": true, "signature": true,
+	"x-amz-signature": true, "x-amz-credential": true, "x-amz-security-token": true,
+	"x-goog-signature": true, "x-goog-credential": true,
+}
+
+// RedactURL hides the password and secret query parameters of a URL, so it can be logged
+func RedactURL(raw string) string {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	redacted := false
+	if _, ok := u.User.Password(); ok {
+		u.User = neturl.UserPassword(u.User.Username(), "xxxxx")
+		redacted = true
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if sensitiveQueryParams[strings.ToLower(key)] {
+				query.Set(key, "xxxxx")
+				redacted = true
+			}
+		}
+		if redacted {
+			u.RawQuery = query.Encode()
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	return u.String()
+}
+
+// ShortenURL shortens a URL for logging by removing protocol and showing only domain + path.
+// Passwords and secret query parameters are redacted.
 func ShortenURL(url string) string {
 	if url == "" {
 		return ""
 	}
+	url = RedactURL(url)
 
 	// Remove protocol
 	if strings.HasPrefix(url, "https://") {